@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"zoteroflow2-server/core"
+)
+
+// runAnnotations 导出文献在Zotero中的批注：annotations <条目Key|文献名> [--by color|page] [--json] [-o 文件]
+func (h *CommandHandler) runAnnotations(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("annotations", flag.ContinueOnError)
+	by := flags.String("by", core.AnnotationsByColor, "分组方式：color（按颜色）或 page（按页码）")
+	asJSON := flags.Bool("json", false, "以JSON输出批注")
+	output := flags.String("o", "", "写入文件而不是打印")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// 允许选项写在文献之后
+	var ref string
+	if flags.NArg() > 0 {
+		ref = flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("用法: annotations <条目Key|文献名> [--by color|page] [--json] [-o 文件]")
+	}
+
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		return fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+	defer zoteroDB.Close()
+
+	item, err := h.resolveItem(zoteroDB, ref)
+	if err != nil {
+		return err
+	}
+	annotations, err := zoteroDB.GetItemAnnotations(item.ItemID)
+	if err != nil {
+		return err
+	}
+
+	var content []byte
+	if *asJSON {
+		if content, err = json.MarshalIndent(annotations, "", "  "); err != nil {
+			return err
+		}
+	} else {
+		markdown, err := core.RenderAnnotationsMarkdown(item, annotations, *by)
+		if err != nil {
+			return err
+		}
+		content = []byte(markdown)
+	}
+
+	if *output == "" {
+		fmt.Println(string(content))
+		return nil
+	}
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		return fmt.Errorf("写入批注失败: %w", err)
+	}
+	fmt.Printf("🖍️ 已导出《%s》的 %d 条批注到 %s\n", item.Title, len(annotations), *output)
+	return nil
+}
+
+// resolveItem 按条目Key、解析结果目录名或文献标题查找Zotero条目
+func (h *CommandHandler) resolveItem(zoteroDB *core.ZoteroDB, ref string) (*core.ZoteroItem, error) {
+	item, err := zoteroDB.ResolveItem(ref, h.config.ResultsDir)
+	if err != nil {
+		if result := h.findResultByTitle(ref); result != nil {
+			return zoteroDB.ResolveItem(result.Name, h.config.ResultsDir)
+		}
+	}
+	return item, err
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runAsk 自然语言提问，按意图分类结果转到对应命令：ask [--explain] <问题>
+func (h *CommandHandler) runAsk(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("ask", flag.ContinueOnError)
+	explain := flags.Bool("explain", false, "只显示意图分类结果，不执行")
+	rulesOnly := flags.Bool("rules", false, "只使用规则分类（不调用AI）")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	if query == "" {
+		return fmt.Errorf("用法: ask [--explain] [--rules] <问题>")
+	}
+
+	var client core.AIClient
+	if h.config.AIAPIKey != "" && !*rulesOnly {
+		client = mcp.NewAIClient(h.config)
+	}
+	intent := core.NewIntentClassifier(client).Classify(context.Background(), query)
+	slots := intent.Slots
+	fmt.Printf("🧭 意图: %s（%s，置信度 %.2f）\n", intent.Intent, intent.Source, intent.Confidence)
+	for _, slot := range [][2]string{{"文献", slots.Document}, {"DOI", slots.DOI}, {"分类", slots.Collection}, {"内容", slots.Question}} {
+		if slot[1] != "" {
+			fmt.Printf("   %s: %s\n", slot[0], slot[1])
+		}
+	}
+	if *explain {
+		return nil
+	}
+	fmt.Println()
+
+	switch intent.Intent {
+	case core.IntentViewPDF, core.IntentAnalyze:
+		result := h.findResultByTitle(slots.Document)
+		if result == nil {
+			break // 找不到已解析的文献时交给AI对话
+		}
+		if intent.Intent == core.IntentViewPDF {
+			return h.openResult(result.Name)
+		}
+		return h.runSummarize([]string{result.Name})
+	case core.IntentRelated:
+		if result := h.findResultByTitle(slots.Document); result != nil {
+			return h.runSimilar([]string{result.Name})
+		}
+		return h.runSimilar([]string{"--search", slots.Question})
+	case core.IntentFullText:
+		return h.runFullText([]string{slots.Question})
+	case core.IntentSearch:
+		return h.searchLibrary(slots)
+	}
+	return h.runChat([]string{query})
+}
+
+// findResultByTitle 按目录名或标题（不区分大小写，包含即可）查找解析结果
+func (h *CommandHandler) findResultByTitle(ref string) *core.ParsedResult {
+	if ref == "" {
+		return nil
+	}
+	if result, err := core.GetParsedResult(h.config.ResultsDir, ref); err == nil {
+		return result
+	}
+	results, err := core.ListParsedResults(h.config.ResultsDir)
+	if err != nil {
+		return nil
+	}
+	ref = strings.ToLower(ref)
+	for i := range results {
+		if strings.Contains(strings.ToLower(results[i].Title()), ref) {
+			return &results[i]
+		}
+	}
+	return nil
+}
+
+// searchLibrary 在Zotero文献库中按分类、DOI或标题关键词查找条目
+func (h *CommandHandler) searchLibrary(slots core.IntentSlots) error {
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		return fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+	defer zoteroDB.Close()
+
+	var items []core.ZoteroItem
+	switch {
+	case slots.Collection != "":
+		items, err = zoteroDB.CollectionItems(slots.Collection, true)
+	case slots.DOI != "":
+		var all []core.ZoteroItem
+		all, err = zoteroDB.ListItems()
+		for _, item := range all {
+			if strings.EqualFold(item.DOI, slots.DOI) {
+				items = append(items, item)
+			}
+		}
+	default:
+		var results []core.SearchResult
+		results, err = zoteroDB.SearchByTitle(slots.Question, 20)
+		for _, result := range results {
+			items = append(items, result.ZoteroItem)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		fmt.Println("📋 未找到匹配的文献")
+		return nil
+	}
+
+	fmt.Printf("📚 找到 %d 篇文献:\n", len(items))
+	for i, item := range items {
+		fmt.Printf("%d. %s", i+1, item.Title)
+		if item.Year != 0 {
+			fmt.Printf(" (%d)", item.Year)
+		}
+		fmt.Printf(" [%s]\n", item.ItemKey)
+		if len(item.Authors) > 0 {
+			fmt.Printf("   %s\n", strings.Join(item.Authors, "; "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+
+	"zoteroflow2-server/mcp"
+)
+
+// runCache 管理MCP工具结果缓存：cache stats | cache clear [--server 名称] [--tool 名称]
+func (h *CommandHandler) runCache(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("用法: cache stats | cache clear [--server 服务器] [--tool 工具]")
+	}
+
+	manager, err := mcp.NewMCPManager(mcp.DefaultConfigFile)
+	if err != nil {
+		return err
+	}
+	cache := manager.Cache()
+	if cache == nil {
+		return fmt.Errorf("MCP工具结果缓存未启用（globalSettings.cache.enabled）")
+	}
+
+	switch args[0] {
+	case "stats":
+		return printCacheStats(cache)
+	case "clear":
+		flags := flag.NewFlagSet("cache clear", flag.ContinueOnError)
+		server := flags.String("server", "", "只清除指定服务器的缓存")
+		tool := flags.String("tool", "", "只清除指定工具的缓存")
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		removed, err := cache.Clear(*server, *tool)
+		if err != nil {
+			return err
+		}
+		if *server == "" && *tool == "" {
+			if err := cache.ResetMetrics(); err != nil {
+				return fmt.Errorf("重置缓存统计失败: %w", err)
+			}
+		}
+		fmt.Printf("🧹 已清除 %d 条缓存\n", removed)
+		return nil
+	default:
+		return fmt.Errorf("未知的cache子命令: %s", args[0])
+	}
+}
+
+// printCacheStats 输出缓存占用和命中统计
+func printCacheStats(cache *mcp.ToolCallCache) error {
+	stats, err := cache.Stats()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("📦 缓存目录: %s\n", stats.Dir)
+	fmt.Printf("   条目: %d / %d\n", stats.Entries, stats.MaxEntries)
+	fmt.Printf("   大小: %.1f KB / %.1f MB\n", float64(stats.Bytes)/1024, float64(stats.MaxBytes)/(1<<20))
+	if len(stats.Tools) == 0 {
+		fmt.Println("   暂无缓存记录")
+		return nil
+	}
+
+	fmt.Println()
+	fmt.Printf("%-45s %6s %10s %8s %8s %8s %8s\n", "工具", "条目", "大小(KB)", "命中", "未命中", "淘汰", "命中率")
+	for _, t := range stats.Tools {
+		fmt.Printf("%-45s %6d %10.1f %8d %8d %8d %7.1f%%\n",
+			t.Tool, t.Entries, float64(t.Bytes)/1024, t.Hits, t.Misses, t.Evictions, t.HitRate*100)
+	}
+	return nil
+}
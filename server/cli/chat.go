@@ -0,0 +1,324 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// chatUsage chat命令用法
+const chatUsage = `用法:
+  chat [--doc 文献名] [问题]           开始新对话（不带问题时进入交互模式）
+  chat --resume <对话ID> [问题]        继续历史对话
+  chat --list                          列出历史对话
+  chat --rename <对话ID> <标题>        重命名对话
+  chat --delete <对话ID>               删除对话
+  chat --fork <对话ID> [--at 消息数]   从历史对话创建分支
+  chat --export <对话ID> [--format md|json] [-o 文件]  导出对话
+
+对话中可使用 /summarize、/critique、/methods 等命令（/commands 查看全部），
+命令模板可在 PROMPTS_DIR/commands/ 中自定义`
+
+// runChat AI对话及对话历史管理
+func (h *CommandHandler) runChat(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	doc := flags.String("doc", "", "基于指定文献（解析结果目录名）对话")
+	resume := flags.String("resume", "", "继续指定ID的对话")
+	list := flags.Bool("list", false, "列出历史对话")
+	rename := flags.String("rename", "", "重命名指定ID的对话")
+	remove := flags.String("delete", "", "删除指定ID的对话")
+	fork := flags.String("fork", "", "从指定ID的对话创建分支")
+	at := flags.Int("at", 0, "分支保留的消息数（默认全部）")
+	export := flags.String("export", "", "导出指定ID的对话")
+	format := flags.String("format", "md", "导出格式: md 或 json")
+	output := flags.String("o", "", "导出文件路径（默认输出到终端）")
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w\n%s", err, chatUsage)
+	}
+	question := strings.Join(flags.Args(), " ")
+
+	manager, closeManager := h.newConversationManager()
+	defer closeManager()
+
+	switch {
+	case *list:
+		return printConversations(manager)
+	case *rename != "":
+		conv, err := manager.RenameConversation(*rename, question)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("✅ 对话 %s 已重命名为: %s\n", conv.ID, conv.Title)
+		return nil
+	case *remove != "":
+		if err := manager.DeleteConversation(*remove); err != nil {
+			return err
+		}
+		fmt.Printf("🗑️ 已删除对话 %s\n", *remove)
+		return nil
+	case *fork != "":
+		conv, err := manager.ForkConversation(*fork, *at)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("🌿 已创建分支 %s（%d 条消息）\n", conv.ID, len(conv.Messages))
+		fmt.Printf("💡 使用 'chat --resume %s' 继续\n", conv.ID)
+		return nil
+	case *export != "":
+		return exportConversation(manager, *export, *format, *output)
+	}
+
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	var conv *core.Conversation
+	if *resume != "" {
+		var err error
+		conv, err = manager.GetConversation(*resume)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("📜 继续对话: %s（%d 条消息）\n", conv.Summary().Title, len(conv.Messages))
+		printRecentMessages(conv, 4)
+		if question != "" {
+			_, err := h.chatTurn(manager, conv, "", question)
+			return err
+		}
+	} else if *doc != "" {
+		if _, err := core.GetParsedResult(h.config.ResultsDir, *doc); err != nil {
+			return err
+		}
+	}
+
+	if question != "" {
+		_, err := h.chatTurn(manager, nil, *doc, question)
+		return err
+	}
+	return h.chatLoop(manager, conv, *doc)
+}
+
+// newConversationManager 创建使用持久化存储的对话管理器，返回的函数用于释放数据库连接
+func (h *CommandHandler) newConversationManager() (*core.AIConversationManager, func()) {
+	var client core.AIClient
+	if h.config.AIAPIKey != "" {
+		client = mcp.NewAIClient(h.config)
+	}
+
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		log.Printf("⚠️ 连接Zotero数据库失败，仅使用解析结果作为上下文: %v", err)
+		zoteroDB = nil
+	}
+
+	manager := core.NewAIConversationManager(client, zoteroDB)
+	manager.SetRAGPipeline(core.NewRAGPipeline(h.config.ResultsDir))
+	manager.SetConversationStore(core.NewConversationStore(h.config.ConversationsDir))
+	manager.SetContextBudget(core.ResolveContextBudget(h.config.AIModel, h.config.AIContextWindow))
+	manager.SetPromptLibrary(core.LoadPromptLibrary(h.config.PromptsDir, h.config.PromptLanguage))
+
+	closeFn := func() {
+		if zoteroDB != nil {
+			zoteroDB.Close()
+		}
+	}
+	return manager, closeFn
+}
+
+// chatTurn 发送一轮提问并打印回复；conv为nil时开始新对话
+func (h *CommandHandler) chatTurn(manager *core.AIConversationManager, conv *core.Conversation, doc, question string) (*core.Conversation, error) {
+	if strings.TrimSpace(question) == "/commands" {
+		printCommands(manager.Prompts())
+		return conv, nil
+	}
+	question, err := expandChatCommand(manager, conv, doc, question, h.config.ResultsDir)
+	if err != nil {
+		return conv, err
+	}
+
+	timeout := time.Duration(h.config.AITimeout) * time.Second
+	if timeout < 60*time.Second {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	switch {
+	case conv != nil:
+		conv, err = manager.ContinueConversation(ctx, conv.ID, question)
+	case doc != "":
+		conv, err = manager.StartConversationWithDocument(ctx, question, &core.DocumentContext{DocumentNames: []string{doc}})
+	default:
+		conv, err = manager.StartConversation(ctx, question, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	printAssistantMessage(conv.Messages[len(conv.Messages)-1])
+	fmt.Printf("💾 对话ID: %s · 累计 %d tokens\n", conv.ID, conv.Usage.TotalTokens)
+	return conv, nil
+}
+
+// expandChatCommand 将/命令展开为提问，当前对话只涉及一篇文献时使用其元数据填充模板
+func expandChatCommand(manager *core.AIConversationManager, conv *core.Conversation, doc, question, resultsDir string) (string, error) {
+	if _, _, ok := core.ParseCommand(question); !ok {
+		return question, nil
+	}
+	if doc == "" && conv != nil && conv.Context != nil && len(conv.Context.DocumentNames) == 1 {
+		doc = conv.Context.DocumentNames[0]
+	}
+	var data core.PromptData
+	if doc != "" {
+		if result, err := core.GetParsedResult(resultsDir, doc); err == nil {
+			data = core.DocumentPromptData(result)
+		}
+	}
+	expanded, _, err := manager.Prompts().Expand(question, data)
+	return expanded, err
+}
+
+// printCommands 列出对话中可用的命令
+func printCommands(prompts *core.PromptLibrary) {
+	fmt.Println("\n📋 可用命令:")
+	for _, command := range prompts.Commands() {
+		source := ""
+		if command.Custom {
+			source = "（自定义）"
+		}
+		fmt.Printf("  /%-12s %s%s\n", command.Name, command.Description, source)
+	}
+}
+
+// chatLoop 交互式对话，输入 exit 或 quit 退出
+func (h *CommandHandler) chatLoop(manager *core.AIConversationManager, conv *core.Conversation, doc string) error {
+	fmt.Println("🤖 进入AI对话模式，输入 /commands 查看命令，exit 退出")
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		fmt.Print("\n🧑 > ")
+		if !scanner.Scan() {
+			break
+		}
+		question := strings.TrimSpace(scanner.Text())
+		if question == "" {
+			continue
+		}
+		if question == "exit" || question == "quit" {
+			break
+		}
+
+		next, err := h.chatTurn(manager, conv, doc, question)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			continue
+		}
+		conv = next
+	}
+	if conv != nil {
+		fmt.Printf("\n👋 对话已保存，使用 'chat --resume %s' 继续\n", conv.ID)
+	}
+	return scanner.Err()
+}
+
+// printAssistantMessage 打印AI回复及其引用
+func printAssistantMessage(msg core.ChatMessage) {
+	fmt.Printf("\n🤖 %s\n", strings.TrimSpace(msg.Content))
+	if msg.Metadata == nil {
+		return
+	}
+	cited := make(map[int]bool)
+	for _, n := range core.CitedNumbers(msg.Content) {
+		cited[n] = true
+	}
+	if len(cited) > 0 {
+		fmt.Println("\n📎 引用:")
+		for _, citation := range msg.Metadata.Citations {
+			if !cited[citation.Number] {
+				continue
+			}
+			fmt.Printf("  [%d] %s", citation.Number, citation.Title)
+			if citation.Page > 0 {
+				fmt.Printf(" 第%d页", citation.Page)
+			}
+			fmt.Println()
+		}
+	}
+	for _, unsupported := range msg.Metadata.Unsupported {
+		fmt.Printf("⚠️ %s：%s\n", unsupported.Reason, unsupported.Sentence)
+	}
+}
+
+// printRecentMessages 打印对话最后n条问答，便于继续对话时回顾
+func printRecentMessages(conv *core.Conversation, n int) {
+	var visible []core.ChatMessage
+	for _, msg := range conv.Messages {
+		if msg.Role != "system" {
+			visible = append(visible, msg)
+		}
+	}
+	if len(visible) > n {
+		visible = visible[len(visible)-n:]
+	}
+	for _, msg := range visible {
+		prefix := "🧑"
+		if msg.Role == "assistant" {
+			prefix = "🤖"
+		}
+		fmt.Printf("%s %s\n", prefix, snippet(msg.Content, 120))
+	}
+}
+
+// printConversations 列出历史对话
+func printConversations(manager *core.AIConversationManager) error {
+	summaries, err := manager.ListConversations()
+	if err != nil {
+		return err
+	}
+	if len(summaries) == 0 {
+		fmt.Println("📋 暂无历史对话")
+		return nil
+	}
+
+	fmt.Printf("💬 历史对话 (共 %d 个):\n", len(summaries))
+	fmt.Println(strings.Repeat("─", 80))
+	for _, summary := range summaries {
+		fmt.Printf("%s  %s  %s（%d 条消息，%d tokens）\n", summary.ID, summary.UpdatedAt.Format("2006-01-02 15:04"), summary.Title, summary.MessageCount, summary.Usage.TotalTokens)
+	}
+	fmt.Println(strings.Repeat("─", 80))
+	fmt.Println("💡 使用 'chat --resume <对话ID>' 继续对话")
+	return nil
+}
+
+// exportConversation 导出对话到文件或终端
+func exportConversation(manager *core.AIConversationManager, id, format, output string) error {
+	conv, err := manager.GetConversation(id)
+	if err != nil {
+		return err
+	}
+	data, err := core.ExportConversation(conv, format)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	fmt.Printf("✅ 已导出到 %s\n", output)
+	return nil
+}
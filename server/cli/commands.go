@@ -1,14 +1,20 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"zoteroflow2-server/config"
+	"zoteroflow2-server/mcp"
 )
 
+// Version 程序版本，由main设置
+var Version = "dev"
+
 // CommandHandler 处理CLI命令
 type CommandHandler struct {
 	config *config.Config
@@ -45,10 +51,40 @@ func (h *CommandHandler) HandleCommand(args []string) error {
 			return fmt.Errorf("用法: doi <DOI号>")
 		}
 		return fmt.Errorf("doi命令暂未实现，请使用Web界面")
+	case "ask":
+		return h.runAsk(args[1:])
 	case "chat":
-		return fmt.Errorf("chat命令暂未实现，请使用Web界面")
+		return h.runChat(args[1:])
 	case "related":
 		return fmt.Errorf("related命令暂未实现，请使用Web界面")
+	case "similar":
+		return h.runSimilar(args[1:])
+	case "fulltext":
+		return h.runFullText(args[1:])
+	case "annotations":
+		return h.runAnnotations(args[1:])
+	case "notes":
+		return h.runNotes(args[1:])
+	case "writeback":
+		return h.runWriteback(args[1:])
+	case "summarize":
+		return h.runSummarize(args[1:])
+	case "extract":
+		return h.runExtract(args[1:])
+	case "review":
+		return h.runReview(args[1:])
+	case "compare":
+		return h.runCompare(args[1:])
+	case "translate":
+		return h.runTranslate(args[1:])
+	case "mcp":
+		return h.runMCPServer()
+	case "cache":
+		return h.runCache(args[1:])
+	case "usage":
+		return h.runUsage(args[1:])
+	case "eval":
+		return h.runEval(args[1:])
 	case "help":
 		return h.ShowHelp()
 	default:
@@ -69,16 +105,45 @@ func (h *CommandHandler) ShowHelp() error {
 	fmt.Println("  open <名称>             - 打开指定文献文件夹")
 	fmt.Println("  search <关键词>         -��标题搜索并解析文献")
 	fmt.Println("  doi <DOI号>             - 按DOI搜索并解析文献")
+	fmt.Println("  fulltext <查询>         - 全文搜索解析结果、Zotero全文缓存和笔记（支持\"短语\"）")
+	fmt.Println("  annotations <条目Key/文献名> [--by color|page] [-o 文件] - 导出Zotero中的高亮和批注为Markdown")
+	fmt.Println("  notes <条目Key/文献名> [--json] [-o 文件] - 导出条目在Zotero中的子笔记为Markdown")
+	fmt.Println("  writeback [--dry-run] [--all | 文献名...] - 将摘要、要点和抽取结果写回Zotero子笔记并添加标签")
 	fmt.Println()
 	fmt.Println("🤖 AI助手对话:")
 	fmt.Println("  chat                    - 进入交互式AI对话模式")
 	fmt.Println("  chat <问题>             - 单次AI问答")
 	fmt.Println("  chat --doc=文献名 <问题> - 基于指定文献的AI对话")
+	fmt.Println("  chat --list             - 列出历史对话")
+	fmt.Println("  chat --resume <对话ID>  - 继续历史对话")
+	fmt.Println("  chat --rename/--delete/--fork <对话ID> - 重命名、删除或分支对话")
+	fmt.Println("  chat --export <对话ID> [--format md|json] [-o 文件] - 导出对话")
+	fmt.Println("  对话中输入 /summarize、/critique、/methods 等命令（/commands 查看全部，模板见 PROMPTS_DIR）")
 	fmt.Println()
 	fmt.Println("🔍 智能文献分析:")
+	fmt.Println("  ask [--explain] <问题>   - 自动识别意图（查看、搜索、全文检索、相关文献、总结或对话）并执行")
 	fmt.Println("  related <文献名/DOI> <问题> - 查找相关文献并AI分析")
+	fmt.Println("  similar <条目Key/文献名> [-k 数量] - 基于本地嵌入索引查找相似文献")
+	fmt.Println("  similar --search <查询>  - 在标题、摘要和全文中语义搜索")
+	fmt.Println("  similar --reindex        - 增量更新嵌入索引")
+	fmt.Println("  summarize <文献名> [--force] - 生成TL;DR、结构化摘要和关键要点 (summary.md)")
+	fmt.Println("  summarize --collection <分类> | --all - 批量摘要分类或全部已解析文献")
+	fmt.Println("  extract --schema <模式.json> | --fields <字段> [--collection <分类> | --all | <文献名>...] - 抽取信息矩阵")
+	fmt.Println("          [--format csv|excel|md|json] [-o 文件] [--force]")
+	fmt.Println("  review --collection <分类> | --tag <标签> | --query <查询> [--title 标题] [--clusters 数量] [-o 文件]")
+	fmt.Println("          - 按主题聚类生成带引用和参考文献的综述")
+	fmt.Println("  compare <条目Key|文献名> <条目Key|文献名> [...] [-o 文件] - 按方法、数据集、结果等维度对照比较")
+	fmt.Println("  translate [--lang zh] [--glossary 术语表] [--force] <文献名> - 逐章节翻译全文，生成 full.<语言>.md 和双语对照")
+	fmt.Println()
+	fmt.Println("🔌 MCP服务器:")
+	fmt.Println("  mcp                     - 以stdio方式运行MCP服务器，供Claude Desktop等MCP客户端调用")
+	fmt.Println("  cache stats             - 查看MCP工具结果缓存的占用和命中率")
+	fmt.Println("  cache clear [--tool 工具] [--server 服务器] - 清除工具结果缓存")
 	fmt.Println()
 	fmt.Println("🔧 其他命令:")
+	fmt.Println("  eval [--fake] [--baseline 报告.json] <用例.json|yaml> - 评测文献问答的事实召回率和引用正确率")
+	fmt.Println("  eval --serve-fake <地址>  - 启动确定性的OpenAI兼容假服务，配合 eval --base-url <地址> 使用，无需AI_API_KEY")
+	fmt.Println("  usage [--days 30] [--by feature|model|provider|day|month] [--json] - AI用量统计和预算状态")
 	fmt.Println("  help                    - 显示此帮助信息")
 	fmt.Println("  version                 - 显示版本信息")
 	fmt.Println()
@@ -186,6 +251,25 @@ func (h *CommandHandler) openResult(name string) error {
 	return nil
 }
 
+// runMCPServer 以stdio方式运行ZoteroFlow MCP服务器
+func (h *CommandHandler) runMCPServer() error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	// stdout专用于JSON-RPC消息，日志统一输出到stderr
+	log.SetOutput(os.Stderr)
+
+	server, err := mcp.NewZoteroFlowServer(h.config, Version)
+	if err != nil {
+		return err
+	}
+	defer server.Close()
+
+	log.Printf("🔌 ZoteroFlow MCP服务器已启动 (stdio)")
+	return server.Serve(context.Background(), os.Stdin, os.Stdout)
+}
+
 // 辅助函数
 func runCommand(cmd string) error {
 	// 简化实现，实际可以使用os/exec包
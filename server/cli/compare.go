@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"zoteroflow2-server/core"
+)
+
+// runCompare 对照比较多篇文献：compare <条目Key|文献名> <条目Key|文献名> [...] [-o 文件]
+func (h *CommandHandler) runCompare(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	output := flags.String("o", "", "将比较结果写入Markdown文件")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() < 2 {
+		return fmt.Errorf("用法: compare <条目Key|文献名> <条目Key|文献名> [...] [-o 文件]")
+	}
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	manager, closeFn := h.newConversationManager()
+	defer closeFn()
+
+	timeout := max(time.Duration(h.config.AITimeout)*time.Second, 120*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	fmt.Printf("⚖️ 正在比较 %d 篇文献...\n", flags.NArg())
+	comparison, err := manager.Compare(ctx, flags.Args(), nil)
+	if err != nil {
+		return err
+	}
+
+	markdown := comparison.Markdown()
+	if *output != "" {
+		if err := os.WriteFile(*output, []byte(markdown), 0644); err != nil {
+			return fmt.Errorf("写入比较结果失败: %w", err)
+		}
+		fmt.Printf("📁 比较结果已写入 %s\n", *output)
+	} else {
+		printAssistantMessage(core.ChatMessage{
+			Role:    "assistant",
+			Content: markdown,
+			Metadata: &core.MessageMetadata{
+				Citations:   comparison.Citations,
+				Unsupported: comparison.Unsupported,
+			},
+		})
+	}
+	fmt.Printf("💾 对话ID: %s · %d tokens，使用 'chat --resume %s' 继续追问\n",
+		comparison.ConversationID, comparison.Usage.TotalTokens, comparison.ConversationID)
+	return nil
+}
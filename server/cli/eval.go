@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runEval 运行文献问答评测并与基线比较：
+// eval [--fake | --base-url URL [--api-key 密钥] --model 模型] [--label 标签] [--baseline 报告.json] [-o 目录] <用例.json|yaml>
+// eval --serve-fake 127.0.0.1:8765 启动确定性的OpenAI兼容假服务
+func (h *CommandHandler) runEval(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	fake := flags.Bool("fake", false, "使用进程内的抽取式假客户端（确定性，不调用模型）")
+	serveFake := flags.String("serve-fake", "", "在指定地址启动抽取式假服务，供 --base-url 或 AI_BASE_URL 使用")
+	baseURL := flags.String("base-url", h.config.AIBaseURL, "AI服务地址")
+	apiKey := flags.String("api-key", h.config.AIAPIKey, "AI服务密钥，默认使用 AI_API_KEY；显式指定 --base-url 时可为空")
+	model := flags.String("model", h.config.AIModel, "模型名称")
+	label := flags.String("label", "", "本次运行的标签，如提示或检索配置的名称")
+	resultsDir := flags.String("results", h.config.ResultsDir, "解析结果目录")
+	promptsDir := flags.String("prompts", h.config.PromptsDir, "提示模板目录")
+	baseline := flags.String("baseline", "", "用于比较的历史评测报告（JSON）")
+	failOnRegression := flags.Bool("fail-on-regression", false, "召回率或引用正确率低于基线时返回错误")
+	output := flags.String("o", "data/eval/reports", "评测报告输出目录")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *serveFake != "" {
+		fmt.Printf("🧪 抽取式假AI服务: http://%s/chat/completions（Ctrl+C 停止）\n", *serveFake)
+		return http.ListenAndServe(*serveFake, core.NewFakeAIServer(core.NewExtractiveAIClient()))
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("用法: eval [--fake | --base-url URL [--api-key 密钥] --model 模型] [--label 标签] [--baseline 报告.json] [-o 目录] <用例.json|yaml>")
+	}
+
+	suite, err := core.LoadEvalSuite(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var base *core.EvalReport
+	if *baseline != "" {
+		if base, err = core.LoadEvalReport(*baseline); err != nil {
+			return err
+		}
+	}
+
+	var client core.AIClient
+	modelName := *model
+	if *fake {
+		client = core.NewExtractiveAIClient()
+		modelName = "extractive"
+	} else {
+		// 显式指定的服务地址（如 eval --serve-fake 启动的假服务）不要求密钥
+		explicitBaseURL := false
+		flags.Visit(func(f *flag.Flag) { explicitBaseURL = explicitBaseURL || f.Name == "base-url" })
+		if *apiKey == "" && !explicitBaseURL {
+			return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 或 --api-key，或使用 --fake 运行确定性评测")
+		}
+		evalConfig := *h.config
+		evalConfig.AIBaseURL, evalConfig.AIModel, evalConfig.AIAPIKey = *baseURL, *model, *apiKey
+		client = mcp.NewAIClient(&evalConfig)
+	}
+
+	// 评测对话不写入对话历史
+	manager := core.NewAIConversationManager(client, nil)
+	manager.SetRAGPipeline(core.NewRAGPipeline(*resultsDir))
+	manager.SetContextBudget(core.ResolveContextBudget(*model, h.config.AIContextWindow))
+	manager.SetPromptLibrary(core.LoadPromptLibrary(*promptsDir, h.config.PromptLanguage))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("🧪 评测 %s：%d 条用例，模型 %s\n", suite.Name, len(suite.Cases), modelName)
+	report, runErr := core.RunEval(ctx, manager, suite, core.EvalOptions{
+		Label: *label,
+		Model: modelName,
+		Progress: func(done, total int, result core.EvalCaseResult) {
+			if result.Error != "" {
+				fmt.Printf("   [%d/%d] %s ❌ %s\n", done, total, result.ID, result.Error)
+				return
+			}
+			fmt.Printf("   [%d/%d] %s 召回 %.0f%% 引用 %.0f%%\n", done, total, result.ID, result.FactRecall*100, result.CitationAccuracy*100)
+		},
+	})
+	if runErr != nil {
+		fmt.Printf("⚠️ 评测中断（%v），保存已完成的 %d 条\n", runErr, len(report.Results))
+	}
+
+	var comparison *core.EvalComparison
+	if base != nil {
+		comparison = core.CompareEvalReports(base, report)
+	}
+	path, err := core.WriteEvalReport(*output, report, comparison)
+	if err != nil {
+		return err
+	}
+
+	summary := report.Summary
+	fmt.Printf("\n📊 事实召回率 %.1f%%，引用正确率 %.1f%%，章节命中率 %.1f%%，未通过校验 %d 句，失败 %d 条\n",
+		summary.FactRecall*100, summary.CitationAccuracy*100, summary.SectionHitRate*100, summary.Unsupported, summary.Errors)
+	if comparison != nil {
+		fmt.Printf("↔️  对比基线：召回率 %+.1f%%，引用正确率 %+.1f%%，token %+d\n",
+			comparison.FactRecall*100, comparison.CitationAccuracy*100, comparison.Tokens)
+		for _, delta := range comparison.Changed {
+			fmt.Printf("   %s 召回 %.0f%% → %.0f%%\n", delta.ID, delta.Before*100, delta.After*100)
+		}
+	}
+	fmt.Printf("📁 报告: %s\n", path)
+
+	if runErr != nil {
+		return runErr
+	}
+	if *failOnRegression && comparison != nil && comparison.Regressed() {
+		return fmt.Errorf("评测得分低于基线")
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runExtract 按用户定义的字段从多篇文献中抽取信息并导出矩阵：
+// extract --schema 模式.json | --fields "dataset, sample_size:integer:样本量" [--collection 分类 | --all | 文献名...] [--format csv|excel|md|json] [-o 文件] [--force]
+func (h *CommandHandler) runExtract(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	schemaPath := flags.String("schema", "", "抽取模式JSON文件")
+	fieldSpec := flags.String("fields", "", "字段列表：名称[:类型[:说明]]，逗号分隔")
+	name := flags.String("name", "matrix", "使用--fields时的模式名（决定结果文件名）")
+	collection := flags.String("collection", "", "抽取指定分类（名称或Key，含子分类）中已解析的文献")
+	all := flags.Bool("all", false, "抽取全部解析结果")
+	format := flags.String("format", "md", "导出格式：csv、excel、md、json")
+	output := flags.String("o", "", "导出到文件（默认输出到终端）")
+	force := flags.Bool("force", false, "忽略已保存的抽取结果")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if (*schemaPath == "") == (*fieldSpec == "") {
+		return fmt.Errorf("用法: extract --schema <模式.json> | --fields <字段列表> [--collection <分类> | --all | <文献名>...] [--format csv|excel|md|json] [-o 文件]")
+	}
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	var schema *core.ExtractionSchema
+	var err error
+	if *schemaPath != "" {
+		schema, err = core.LoadExtractionSchema(*schemaPath)
+	} else {
+		schema, err = core.ParseExtractionFields(*name, *fieldSpec)
+	}
+	if err != nil {
+		return err
+	}
+
+	var results []core.ParsedResult
+	switch {
+	case *collection != "":
+		results, err = h.collectionResults(*collection)
+	case *all:
+		results, err = core.ListParsedResults(h.config.ResultsDir)
+	default:
+		if flags.NArg() == 0 {
+			return fmt.Errorf("请指定文献名、--collection 或 --all")
+		}
+		for _, docName := range flags.Args() {
+			result, findErr := core.GetParsedResult(h.config.ResultsDir, docName)
+			if findErr != nil {
+				return findErr
+			}
+			results = append(results, *result)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("📋 没有可抽取的解析结果")
+		return nil
+	}
+
+	extractor := core.NewExtractor(
+		mcp.NewAIClient(h.config),
+		core.NewRAGPipeline(h.config.ResultsDir),
+		h.config.AIResponseFormat,
+	)
+	matrix := extractor.ExtractAll(context.Background(), schema, results, *force, func(done, total int, row *core.ExtractionResult) {
+		switch {
+		case row.Error != "":
+			fmt.Fprintf(os.Stderr, "[%d/%d] ❌ %s: %s\n", done, total, row.Title, row.Error)
+		case row.Cached:
+			fmt.Fprintf(os.Stderr, "[%d/%d] 💾 %s（已保存）\n", done, total, row.Title)
+		default:
+			fmt.Fprintf(os.Stderr, "[%d/%d] ✅ %s\n", done, total, row.Title)
+		}
+	})
+
+	data, _, _, err := matrix.Export(*format)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		fmt.Println(string(data))
+		return nil
+	}
+	if err := os.WriteFile(*output, data, 0644); err != nil {
+		return fmt.Errorf("写入导出文件失败: %w", err)
+	}
+	fmt.Printf("📁 抽取矩阵已导出到 %s（%d 篇文献，%d 个字段）\n", *output, len(matrix.Rows), len(schema.Fields))
+	return nil
+}
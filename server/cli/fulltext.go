@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"zoteroflow2-server/core"
+)
+
+// runFullText 全文搜索解析结果、Zotero全文缓存和笔记：fulltext [-n 数量] <查询> | fulltext --reindex
+func (h *CommandHandler) runFullText(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("fulltext", flag.ContinueOnError)
+	limit := flags.Int("n", 20, "返回结果数量")
+	reindex := flags.Bool("reindex", false, "只更新全文索引")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	query := strings.Join(flags.Args(), " ")
+	if query == "" && !*reindex {
+		return fmt.Errorf("用法: fulltext [-n 数量] <查询>（用引号包裹短语，如 '\"self attention\" 翻译'）")
+	}
+
+	index, err := core.OpenFullTextIndex(h.config.IndexDir)
+	if err != nil {
+		return err
+	}
+
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		log.Printf("⚠️ 连接Zotero数据库失败，仅搜索解析结果: %v", err)
+		zoteroDB = nil
+	} else {
+		defer zoteroDB.Close()
+	}
+
+	sources, err := core.CollectFullTextSources(zoteroDB, h.config.ResultsDir)
+	if err != nil {
+		return err
+	}
+	stats, err := index.Update(sources)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔎 全文索引: %d 篇（新增 %d，更新 %d，删除 %d）\n", index.Len(), stats.Added, stats.Updated, stats.Removed)
+	if query == "" {
+		return nil
+	}
+
+	var hits []core.FullTextHit
+	if zoteroDB != nil {
+		hits, err = zoteroDB.SearchFullText(index, query, *limit)
+	} else {
+		hits, err = index.Search(query, *limit)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("\n🔍 全文搜索: %s\n", query)
+	if len(hits) == 0 {
+		fmt.Println("   没有匹配结果")
+		return nil
+	}
+	for i, hit := range hits {
+		source := "Zotero全文"
+		switch hit.Kind {
+		case core.FullTextKindResult:
+			source = "解析结果 " + hit.Document
+		case core.FullTextKindNote:
+			source = "Zotero笔记"
+		}
+		fmt.Printf("%2d. [%.2f] %s (%s)\n", i+1, hit.Score, hit.Title, source)
+		if hit.Snippet != "" {
+			fmt.Printf("    %s\n", hit.Snippet)
+		}
+	}
+	return nil
+}
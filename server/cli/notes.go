@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"zoteroflow2-server/core"
+)
+
+// runNotes 导出文献在Zotero中的子笔记：notes <条目Key|文献名> [--json] [-o 文件]
+func (h *CommandHandler) runNotes(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("notes", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "以JSON输出笔记")
+	output := flags.String("o", "", "写入文件而不是打印")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// 允许选项写在文献之后
+	var ref string
+	if flags.NArg() > 0 {
+		ref = flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	if ref == "" {
+		return fmt.Errorf("用法: notes <条目Key|文献名> [--json] [-o 文件]")
+	}
+
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		return fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+	defer zoteroDB.Close()
+
+	item, err := h.resolveItem(zoteroDB, ref)
+	if err != nil {
+		return err
+	}
+	notes, err := zoteroDB.GetItemNotes(item.ItemID)
+	if err != nil {
+		return err
+	}
+
+	content := []byte(core.RenderNotesMarkdown(item, notes))
+	if *asJSON {
+		if content, err = json.MarshalIndent(notes, "", "  "); err != nil {
+			return err
+		}
+	}
+
+	if *output == "" {
+		fmt.Println(string(content))
+		return nil
+	}
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		return fmt.Errorf("写入笔记失败: %w", err)
+	}
+	fmt.Printf("📝 已导出《%s》的 %d 篇笔记到 %s\n", item.Title, len(notes), *output)
+	return nil
+}
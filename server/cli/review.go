@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runReview 生成多文献综述：
+// review --collection <分类> | --tag <标签> | --query <查询> [--title 标题] [--clusters 数量] [--max 数量] [--force] [-o 文件]
+func (h *CommandHandler) runReview(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	var scope core.ReviewScope
+	flags.StringVar(&scope.Collection, "collection", "", "综述指定分类（名称或Key，含子分类）中已解析的文献")
+	flags.StringVar(&scope.Tag, "tag", "", "综述带有指定标签的已解析文献")
+	flags.StringVar(&scope.Query, "query", "", "综述全文检索命中的已解析文献")
+	var opts core.ReviewOptions
+	flags.StringVar(&opts.Title, "title", "", "综述标题（默认由AI拟定）")
+	flags.IntVar(&opts.Clusters, "clusters", 0, "主题数（默认按文献数自动确定）")
+	flags.BoolVar(&opts.Force, "force", false, "忽略缓存重新生成单篇摘要")
+	maxPapers := flags.Int("max", core.DefaultReviewMaxPapers, "最多纳入的文献数")
+	output := flags.String("o", "", "写入Markdown文件（默认输出到终端）")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := scope.Validate(); err != nil {
+		return fmt.Errorf("用法: review --collection <分类> | --tag <标签> | --query <查询> [--title 标题] [--clusters 数量] [-o 文件]")
+	}
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		log.Printf("⚠️ 连接Zotero数据库失败，参考文献仅包含标题: %v", err)
+		zoteroDB = nil
+	} else {
+		defer zoteroDB.Close()
+	}
+
+	results, skipped, err := core.ResolveReviewScope(scope, zoteroDB, h.config.ResultsDir, h.config.IndexDir, *maxPapers)
+	if err != nil {
+		return err
+	}
+	for _, title := range skipped {
+		fmt.Fprintf(os.Stderr, "⏭️ 未解析，跳过: %s\n", title)
+	}
+	if len(results) == 0 {
+		fmt.Println("📋 范围内没有已解析的文献")
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "📚 %s：纳入 %d 篇已解析文献\n", scope, len(results))
+
+	client := mcp.NewAIClient(h.config)
+	embedder, err := core.NewEmbeddingProvider(h.config.EmbeddingProvider, h.config.EmbeddingBaseURL,
+		h.config.EmbeddingAPIKey, h.config.EmbeddingModel)
+	if err != nil {
+		log.Printf("⚠️ 嵌入服务不可用，使用本地嵌入聚类: %v", err)
+		embedder = nil
+	}
+	generator := core.NewReviewGenerator(client, core.NewSummarizer(client, h.config.SummaryCacheDir), embedder)
+
+	review, err := generator.Generate(context.Background(), scope.String(), core.NewReviewPapers(results, zoteroDB), opts, printReviewProgress)
+	if err != nil {
+		return err
+	}
+
+	markdown := review.Markdown()
+	if *output == "" {
+		fmt.Println(markdown)
+		return nil
+	}
+	if err := os.WriteFile(*output, []byte(markdown), 0644); err != nil {
+		return fmt.Errorf("写入综述失败: %w", err)
+	}
+	fmt.Printf("📁 综述已写入 %s（%d 个主题，%d 篇参考文献）\n", *output, len(review.Clusters), len(review.Papers))
+	return nil
+}
+
+// printReviewProgress 在标准错误输出综述进度，避免混入Markdown输出
+func printReviewProgress(p core.ReviewProgress) {
+	switch p.Stage {
+	case core.ReviewStageSummarize:
+		fmt.Fprintf(os.Stderr, "[摘要 %d/%d] %s\n", p.Done, p.Total, p.Message)
+	case core.ReviewStageCluster:
+		fmt.Fprintf(os.Stderr, "🧭 %s\n", p.Message)
+	case core.ReviewStageTheme:
+		fmt.Fprintf(os.Stderr, "[主题 %d/%d] %s\n", p.Done, p.Total, p.Message)
+	case core.ReviewStageSynthesize:
+		fmt.Fprintf(os.Stderr, "🧩 %s...\n", p.Message)
+	case core.ReviewStageDone:
+		fmt.Fprintf(os.Stderr, "✅ 综述完成: %s\n", p.Message)
+	}
+}
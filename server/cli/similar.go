@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"zoteroflow2-server/core"
+)
+
+// runSimilar 基于嵌入索引查找相似文献或语义搜索：
+// similar <条目Key|文献名> [-k 数量] | similar --search <查询> | similar --reindex
+func (h *CommandHandler) runSimilar(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("similar", flag.ContinueOnError)
+	k := flags.Int("k", 10, "返回结果数量")
+	search := flags.String("search", "", "语义搜索的查询文本")
+	reindex := flags.Bool("reindex", false, "只更新嵌入索引")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	// 允许选项写在文献Key之后
+	var target string
+	if flags.NArg() > 0 {
+		target = flags.Arg(0)
+		if err := flags.Parse(flags.Args()[1:]); err != nil {
+			return err
+		}
+	}
+	if target == "" && *search == "" && !*reindex {
+		return fmt.Errorf("用法: similar <条目Key|文献名> [-k 数量] | similar --search <查询> | similar --reindex")
+	}
+
+	ctx := context.Background()
+	index, err := h.updateEmbeddingIndex(ctx)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *search != "":
+		matches, err := index.Search(ctx, *search, *k)
+		if err != nil {
+			return err
+		}
+		printSemanticMatches(*search, matches)
+	case target != "":
+		items, err := index.SimilarItems(target, *k)
+		if err != nil {
+			return err
+		}
+		printSimilarItems(target, items)
+	}
+	return nil
+}
+
+// updateEmbeddingIndex 打开嵌入索引并按当前文献库增量更新
+func (h *CommandHandler) updateEmbeddingIndex(ctx context.Context) (*core.EmbeddingIndex, error) {
+	provider, err := core.NewEmbeddingProvider(h.config.EmbeddingProvider, h.config.EmbeddingBaseURL,
+		h.config.EmbeddingAPIKey, h.config.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	index, err := core.OpenEmbeddingIndex(h.config.IndexDir, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zotero数据库不可用时仍可索引已解析的全文
+	var items []core.ZoteroItem
+	if zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir); err != nil {
+		log.Printf("⚠️ 连接Zotero数据库失败，仅索引解析结果: %v", err)
+	} else {
+		items, err = zoteroDB.ListItems()
+		zoteroDB.Close()
+		if err != nil {
+			log.Printf("⚠️ 读取文献列表失败: %v", err)
+		}
+	}
+
+	docs, err := core.CollectEmbeddingDocuments(items, h.config.ResultsDir)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := index.Update(ctx, docs)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("🧭 嵌入索引: %d 个文本单元（新增 %d，更新 %d，删除 %d）\n",
+		index.Len(), stats.Added, stats.Updated, stats.Removed)
+	return index, nil
+}
+
+// printSimilarItems 输出相似文献列表
+func printSimilarItems(target string, items []core.SimilarItem) {
+	fmt.Printf("\n📚 与 %s 相似的文献:\n", target)
+	if len(items) == 0 {
+		fmt.Println("   未找到相似文献")
+		return
+	}
+	for i, item := range items {
+		key := item.ItemKey
+		if key == "" {
+			key = item.Document
+		}
+		fmt.Printf("%2d. [%.3f] %s (%s)\n", i+1, item.Score, item.Title, key)
+	}
+}
+
+// printSemanticMatches 输出语义搜索结果
+func printSemanticMatches(query string, matches []core.SemanticMatch) {
+	fmt.Printf("\n🔍 语义搜索: %s\n", query)
+	if len(matches) == 0 {
+		fmt.Println("   没有匹配结果")
+		return
+	}
+	for i, m := range matches {
+		location := m.Kind
+		if m.Section != "" {
+			location += " §" + m.Section
+		}
+		if m.Page > 0 {
+			location += fmt.Sprintf(" p.%d", m.Page)
+		}
+		fmt.Printf("%2d. [%.3f] %s — %s\n", i+1, m.Score, m.Title, location)
+		if m.Kind != core.EmbeddingKindTitle {
+			fmt.Printf("    %s\n", snippet(m.Text, 120))
+		}
+	}
+}
+
+// snippet 截取单行摘要
+func snippet(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > maxRunes {
+		return string(runes[:maxRunes]) + "…"
+	}
+	return text
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runSummarize 生成文献结构化摘要：
+// summarize <文献名> [--force] | summarize --collection <分类> [--force] | summarize --all [--force]
+func (h *CommandHandler) runSummarize(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("summarize", flag.ContinueOnError)
+	collection := flags.String("collection", "", "摘要指定分类（名称或Key，含子分类）中已解析的文献")
+	all := flags.Bool("all", false, "摘要全部解析结果")
+	force := flags.Bool("force", false, "忽略缓存重新生成")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	name := strings.Join(flags.Args(), " ")
+	if name == "" && *collection == "" && !*all {
+		return fmt.Errorf("用法: summarize <文献名> | summarize --collection <分类> | summarize --all [--force]")
+	}
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	summarizer := core.NewSummarizer(mcp.NewAIClient(h.config), h.config.SummaryCacheDir)
+	ctx := context.Background()
+
+	if name != "" {
+		result, err := core.GetParsedResult(h.config.ResultsDir, name)
+		if err != nil {
+			return err
+		}
+		summary, err := summarizer.Summarize(ctx, result, *force)
+		if err != nil {
+			return err
+		}
+		fmt.Println(summary.Markdown())
+		fmt.Printf("📁 已写入 %s/summary.md\n", result.Dir)
+		return nil
+	}
+
+	var results []core.ParsedResult
+	var err error
+	if *collection != "" {
+		results, err = h.collectionResults(*collection)
+	} else {
+		results, err = core.ListParsedResults(h.config.ResultsDir)
+	}
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("📋 没有可摘要的解析结果")
+		return nil
+	}
+
+	stats := summarizer.SummarizeAll(ctx, results, *force, func(done, total int, result *core.ParsedResult, summary *core.PaperSummary, err error) {
+		switch {
+		case err != nil:
+			fmt.Printf("[%d/%d] ❌ %s: %v\n", done, total, result.Title(), err)
+		case summary.Cached:
+			fmt.Printf("[%d/%d] 💾 %s（缓存）\n", done, total, result.Title())
+		default:
+			fmt.Printf("[%d/%d] ✅ %s\n      %s\n", done, total, result.Title(), summary.TLDR)
+		}
+	})
+	fmt.Printf("\n📝 摘要完成：新生成 %d 篇，使用缓存 %d 篇，失败 %d 篇\n", stats.Summarized, stats.Cached, stats.Failed)
+	return nil
+}
+
+// collectionResults 查找分类（含子分类）中已解析的文献，未解析的条目给出提示
+func (h *CommandHandler) collectionResults(collection string) ([]core.ParsedResult, error) {
+	zoteroDB, err := core.NewZoteroDB(h.config.ZoteroDBPath, h.config.ZoteroDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+	defer zoteroDB.Close()
+
+	items, err := zoteroDB.CollectionItems(collection, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []core.ParsedResult
+	for i := range items {
+		result, err := core.FindParsedResult(h.config.ResultsDir, &items[i])
+		if err != nil || result == nil {
+			fmt.Printf("⏭️ 未解析，跳过: %s\n", items[i].Title)
+			continue
+		}
+		results = append(results, *result)
+	}
+	fmt.Printf("📚 分类 %s：%d 篇文献，其中 %d 篇已解析\n", collection, len(items), len(results))
+	return results, nil
+}
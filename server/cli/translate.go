@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runTranslate 逐章节翻译解析结果的全文，保留公式、表格和引用：
+// translate [--lang zh] [--glossary 术语表] [--force] <文献名>
+func (h *CommandHandler) runTranslate(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("translate", flag.ContinueOnError)
+	lang := flags.String("lang", "zh", "目标语言代码，如 zh、en、ja")
+	glossaryPath := flags.String("glossary", h.config.TranslationGlossary, "术语表文件（每行\"术语<TAB>译名\"或JSON对象）")
+	force := flags.Bool("force", false, "丢弃已有进度重新翻译")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	name := strings.Join(flags.Args(), " ")
+	if name == "" {
+		return fmt.Errorf("用法: translate [--lang zh] [--glossary 术语表] [--force] <文献名>")
+	}
+	if h.config.AIAPIKey == "" {
+		return fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+	}
+
+	result, err := core.GetParsedResult(h.config.ResultsDir, name)
+	if err != nil {
+		return err
+	}
+
+	var glossary core.Glossary
+	if *glossaryPath != "" {
+		if _, statErr := os.Stat(*glossaryPath); statErr == nil {
+			if glossary, err = core.LoadGlossary(*glossaryPath); err != nil {
+				return err
+			}
+			fmt.Printf("📖 使用术语表 %s（%d 条）\n", *glossaryPath, len(glossary))
+		} else if *glossaryPath != h.config.TranslationGlossary {
+			return fmt.Errorf("术语表不存在: %s", *glossaryPath)
+		}
+	}
+
+	// Ctrl+C 时停止并保留已完成的进度，再次运行从断点继续
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("🌐 正在翻译《%s》→ %s...\n", result.Title(), *lang)
+	translator := core.NewTranslator(mcp.NewAIClient(h.config), glossary)
+	stats, err := translator.Translate(ctx, result, *lang, *force, func(done, total int) {
+		fmt.Printf("\r⏳ %d/%d 段", done, total)
+	})
+	fmt.Println()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ 翻译完成：新翻译 %d 段，复用 %d 段，失败 %d 段\n", stats.Translated, stats.Reused, stats.Failed)
+	if stats.Failed > 0 {
+		fmt.Println("⚠️ 失败的段落保留原文，再次运行 translate 将重试")
+	}
+	fmt.Printf("📁 译文: %s\n📁 对照: %s\n", stats.Path, stats.BilingualPath)
+	return nil
+}
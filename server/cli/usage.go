@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// runUsage 显示AI用量报告和预算状态：usage [--days 30] [--by feature|model|provider|day|month] [--json]
+func (h *CommandHandler) runUsage(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	days := flags.Int("days", 30, "统计最近的天数（含今天）")
+	by := flags.String("by", "feature", "分组方式：feature、model、provider、day或month")
+	asJSON := flags.Bool("json", false, "以JSON输出")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("--days 必须大于0")
+	}
+
+	ledger := core.SharedUsageLedger(h.config.UsageDir)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	report, err := ledger.Report(today.AddDate(0, 0, 1-*days), today.AddDate(0, 0, 1), *by)
+	if err != nil {
+		return err
+	}
+	status := ledger.Status(mcp.UsageBudget(h.config))
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{"report": report, "status": status})
+	}
+
+	fmt.Printf("📊 AI用量（%s ~ %s，按%s分组）\n", report.From.Format("2006-01-02"), today.Format("2006-01-02"), report.By)
+	if report.Total.Requests == 0 {
+		fmt.Println("   暂无记录")
+	} else {
+		fmt.Printf("%-24s %8s %6s %12s %12s %12s %10s\n", "分组", "请求", "失败", "输入token", "输出token", "合计token", "平均延迟")
+		for _, group := range report.Groups {
+			printUsageRow(group.Key, group.UsageTotals)
+		}
+		printUsageRow("合计", report.Total)
+	}
+
+	fmt.Println()
+	fmt.Println("💰 预算")
+	budget := status.Budget
+	printBudgetLine("今日token", status.Today.TotalTokens, budget.DailyTokens)
+	printBudgetLine("本月token", status.Month.TotalTokens, budget.MonthlyTokens)
+	printBudgetLine("今日请求", status.Today.Requests, budget.DailyRequests)
+	printBudgetLine("本月请求", status.Month.Requests, budget.MonthlyRequests)
+	if status.Exceeded != "" {
+		if budget.Action == core.BudgetActionDowngrade && budget.FallbackModel != "" {
+			fmt.Printf("⚠️ 已超出预算（%s），AI请求将改用 %s\n", status.Exceeded, budget.FallbackModel)
+		} else {
+			fmt.Printf("⛔ 已超出预算（%s），AI请求将被拒绝\n", status.Exceeded)
+		}
+	}
+	return nil
+}
+
+// printUsageRow 输出一行用量统计
+func printUsageRow(key string, totals core.UsageTotals) {
+	if key == "" {
+		key = "(未知)"
+	}
+	fmt.Printf("%-24s %8d %6d %12d %12d %12d %8dms\n", key, totals.Requests, totals.Errors,
+		totals.PromptTokens, totals.CompletionTokens, totals.TotalTokens, totals.AvgLatencyMS())
+}
+
+// printBudgetLine 输出一项预算的使用情况，未设置预算时只显示用量
+func printBudgetLine(label string, used, limit int) {
+	if limit <= 0 {
+		fmt.Printf("   %s: %d（不限）\n", label, used)
+		return
+	}
+	fmt.Printf("   %s: %d / %d（%.0f%%）\n", label, used, limit, float64(used)*100/float64(limit))
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"zoteroflow2-server/core"
+)
+
+// runWriteback 将摘要、关键要点和抽取结果作为子笔记写回Zotero并添加标签：
+// writeback [--dry-run] [--tags zf:parsed] [--schemas 模式,...] [--collection 分类 | --all | 文献名...]
+func (h *CommandHandler) runWriteback(args []string) error {
+	if h.config == nil {
+		return fmt.Errorf("配置未加载")
+	}
+
+	flags := flag.NewFlagSet("writeback", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "只列出将要创建或更新的笔记和标签，不调用Zotero API")
+	tags := flags.String("tags", h.config.ZoteroWritebackTags, "为条目添加的标签，逗号分隔")
+	schemas := flags.String("schemas", "", "写回的抽取模式，逗号分隔（默认全部已保存的抽取结果）")
+	collection := flags.String("collection", "", "写回指定分类（名称或Key，含子分类）中已解析的文献")
+	all := flags.Bool("all", false, "写回全部已关联Zotero条目的解析结果")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var results []core.ParsedResult
+	var err error
+	switch {
+	case *collection != "":
+		results, err = h.collectionResults(*collection)
+	case *all:
+		results, err = core.ListParsedResults(h.config.ResultsDir)
+	default:
+		if flags.NArg() == 0 {
+			return fmt.Errorf("用法: writeback [--dry-run] [--tags 标签,...] [--schemas 模式,...] [--collection 分类 | --all | 文献名...]")
+		}
+		for _, ref := range flags.Args() {
+			result := h.findResultByTitle(ref)
+			if result == nil {
+				return fmt.Errorf("未找到解析结果: %s", ref)
+			}
+			results = append(results, *result)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	var api *core.ZoteroWebAPI
+	if !*dryRun {
+		if h.config.ZoteroLibrary == "" {
+			return fmt.Errorf("写回未配置，请设置 ZOTERO_LIBRARY（users/<用户ID> 或 groups/<群组ID>）和 ZOTERO_API_KEY，或使用 --dry-run 预览")
+		}
+		api = core.NewZoteroWebAPI(h.config.ZoteroAPIURL, h.config.ZoteroLibrary, h.config.ZoteroAPIKey)
+	}
+	writer := core.NewZoteroWriter(api)
+	opts := core.WritebackOptions{DryRun: *dryRun, Tags: core.SplitCommaList(*tags), Schemas: core.SplitCommaList(*schemas)}
+
+	if *dryRun {
+		fmt.Println("🔍 预览模式：不会修改Zotero")
+	}
+	written, failed := 0, 0
+	for i := range results {
+		result := &results[i]
+		if result.Info == nil || result.Info.ItemKey == "" {
+			if !*all && *collection == "" {
+				fmt.Printf("⏭️  %s 未关联Zotero条目，跳过\n", result.Title())
+			}
+			continue
+		}
+		report, err := writer.WriteBack(context.Background(), result, opts)
+		if err != nil {
+			fmt.Printf("❌ %s: %v\n", result.Title(), err)
+			failed++
+			continue
+		}
+		fmt.Printf("📄 %s（%s）\n", result.Title(), report.ItemKey)
+		if len(report.Actions) == 0 {
+			fmt.Println("   没有可写回的摘要或抽取结果")
+		}
+		for _, action := range report.Actions {
+			fmt.Println("   " + formatWritebackAction(action))
+			if action.Error != "" {
+				failed++
+			}
+		}
+		if report.Changed() {
+			written++
+		}
+	}
+
+	verb := "已写回"
+	if *dryRun {
+		verb = "将写回"
+	}
+	fmt.Printf("\n✅ %s %d 篇文献", verb, written)
+	if failed > 0 {
+		fmt.Printf("，%d 项失败", failed)
+	}
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("部分写回失败")
+	}
+	return nil
+}
+
+// formatWritebackAction 一项写回操作的显示文本
+func formatWritebackAction(action core.WritebackAction) string {
+	var line string
+	switch action.Op {
+	case core.WritebackCreate:
+		line = "➕ 创建笔记 " + action.Title
+	case core.WritebackUpdate:
+		line = "✏️  更新笔记 " + action.Title
+	case core.WritebackSkip:
+		line = "⏸️  未变化 " + action.Title
+	case core.WritebackTag:
+		line = fmt.Sprintf("🏷️  添加标签 %v", action.Tags)
+	}
+	if action.NoteKey != "" {
+		line += " [" + action.NoteKey + "]"
+	}
+	if action.Error != "" {
+		line += " ❌ " + action.Error
+	}
+	return line
+}
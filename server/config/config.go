@@ -12,6 +12,12 @@ type Config struct {
 	// Zotero配置
 	ZoteroDBPath  string `json:"zotero_db_path"`
 	ZoteroDataDir string `json:"zotero_data_dir"`
+	// Zotero写回：通过Web API（或实现同一协议的本地服务）创建笔记和标签，从不直接写数据库
+	ZoteroAPIURL  string `json:"zotero_api_url"`
+	ZoteroAPIKey  string `json:"zotero_api_key"`
+	ZoteroLibrary string `json:"zotero_library"` // users/<用户ID> 或 groups/<群组ID>
+	// ZoteroWritebackTags 写回时为条目添加的标签，逗号分隔
+	ZoteroWritebackTags string `json:"zotero_writeback_tags"`
 
 	// MinerU配置
 	MineruAPIURL string `json:"mineru_api_url"`
@@ -21,6 +27,32 @@ type Config struct {
 	AIAPIKey  string `json:"ai_api_key"`
 	AIBaseURL string `json:"ai_base_url"`
 	AIModel   string `json:"ai_model"`
+	// AIContextWindow 模型上下文窗口（token），为0时按模型名称推断
+	AIContextWindow int `json:"ai_context_window"`
+	// AIResponseFormat 结构化输出方式：json_schema、json_object或none（仅在提示中约束）
+	AIResponseFormat string `json:"ai_response_format"`
+	// TranslationGlossary 全文翻译使用的术语表文件，文件不存在时不使用
+	TranslationGlossary string `json:"translation_glossary"`
+	// PromptsDir 自定义提示模板目录：<模板名>.tmpl覆盖系统提示，commands/<命令名>.tmpl定义对话命令
+	PromptsDir string `json:"prompts_dir"`
+	// PromptLanguage 提示模板中的回答语言
+	PromptLanguage string `json:"prompt_language"`
+	// UsageDir AI用量账本目录（按月一个JSONL文件）
+	UsageDir string `json:"usage_dir"`
+	// AI用量预算，为0时不限制
+	AIDailyTokenBudget     int `json:"ai_daily_token_budget"`
+	AIMonthlyTokenBudget   int `json:"ai_monthly_token_budget"`
+	AIDailyRequestBudget   int `json:"ai_daily_request_budget"`
+	AIMonthlyRequestBudget int `json:"ai_monthly_request_budget"`
+	// AIBudgetAction 超出预算时的处理：block拒绝请求，downgrade改用AIFallbackModel
+	AIBudgetAction  string `json:"ai_budget_action"`
+	AIFallbackModel string `json:"ai_fallback_model"`
+
+	// 嵌入配置（语义搜索和相似文献）
+	EmbeddingProvider string `json:"embedding_provider"` // openai 或 local
+	EmbeddingBaseURL  string `json:"embedding_base_url"`
+	EmbeddingAPIKey   string `json:"embedding_api_key"`
+	EmbeddingModel    string `json:"embedding_model"`
 
 	// 缓存配置
 	CacheDir string `json:"cache_dir"`
@@ -28,6 +60,12 @@ type Config struct {
 	// 数据目录配置
 	ResultsDir string `json:"results_dir"`
 	RecordsDir string `json:"records_dir"`
+	IndexDir   string `json:"index_dir"`
+
+	// 对话历史存储目录
+	ConversationsDir string `json:"conversations_dir"`
+	// SummaryCacheDir 按全文哈希缓存论文摘要的目录
+	SummaryCacheDir string `json:"summary_cache_dir"`
 
 	// 超时配置 (秒)
 	AITimeout     int `json:"ai_timeout"`
@@ -72,6 +110,7 @@ func Load() (*Config, error) {
 		AIBaseURL:      getEnv("AI_BASE_URL", "https://open.bigmodel.cn/api/coding/paas/v4"),
 		AIModel:        getEnv("AI_MODEL", "glm-4.6"),
 		CacheDir:       getEnv("CACHE_DIR", expandPath("~/.zoteroflow/cache")),
+		IndexDir:       getEnv("INDEX_DIR", "data/index"),
 		ResultsDir:     getEnv("RESULTS_DIR", "data/results"),
 		RecordsDir:     getEnv("RECORDS_DIR", "data/records"),
 		AITimeout:      getIntEnv("AI_TIMEOUT", 20),
@@ -79,6 +118,31 @@ func Load() (*Config, error) {
 		AbstractLength: getIntEnv("ABSTRACT_LENGTH", 200),
 	}
 
+	// 嵌入服务默认复用AI服务的地址和密钥
+	config.EmbeddingProvider = getEnv("EMBEDDING_PROVIDER", "openai")
+	config.EmbeddingBaseURL = getEnv("EMBEDDING_BASE_URL", config.AIBaseURL)
+	config.EmbeddingAPIKey = getEnv("EMBEDDING_API_KEY", config.AIAPIKey)
+	config.EmbeddingModel = getEnv("EMBEDDING_MODEL", "embedding-3")
+
+	config.ConversationsDir = getEnv("CONVERSATIONS_DIR", "data/conversations")
+	config.SummaryCacheDir = getEnv("SUMMARY_CACHE_DIR", "data/cache/summaries")
+	config.AIContextWindow = getIntEnv("AI_CONTEXT_WINDOW", 0)
+	config.AIResponseFormat = getEnv("AI_RESPONSE_FORMAT", "json_object")
+	config.TranslationGlossary = getEnv("TRANSLATION_GLOSSARY", "data/glossary.txt")
+	config.PromptsDir = getEnv("PROMPTS_DIR", "data/prompts")
+	config.PromptLanguage = getEnv("PROMPT_LANGUAGE", "中文")
+	config.UsageDir = getEnv("USAGE_DIR", "data/usage")
+	config.AIDailyTokenBudget = getIntEnv("AI_DAILY_TOKEN_BUDGET", 0)
+	config.AIMonthlyTokenBudget = getIntEnv("AI_MONTHLY_TOKEN_BUDGET", 0)
+	config.AIDailyRequestBudget = getIntEnv("AI_DAILY_REQUEST_BUDGET", 0)
+	config.AIMonthlyRequestBudget = getIntEnv("AI_MONTHLY_REQUEST_BUDGET", 0)
+	config.AIBudgetAction = getEnv("AI_BUDGET_ACTION", "block")
+	config.AIFallbackModel = getEnv("AI_FALLBACK_MODEL", "")
+	config.ZoteroAPIURL = getEnv("ZOTERO_API_URL", "https://api.zotero.org")
+	config.ZoteroAPIKey = getEnv("ZOTERO_API_KEY", "")
+	config.ZoteroLibrary = getEnv("ZOTERO_LIBRARY", "")
+	config.ZoteroWritebackTags = getEnv("ZOTERO_WRITEBACK_TAGS", "zf:parsed")
+
 	// 2. 验证必要配置
 	if !fileExists(config.ZoteroDBPath) {
 		return nil, fmt.Errorf("Zotero数据库文件不存在: %s", config.ZoteroDBPath)
@@ -71,6 +71,28 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadConfigSummaryCacheDir(t *testing.T) {
+	tempDB := t.TempDir() + "/test_zotero.sqlite"
+	if err := os.WriteFile(tempDB, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZOTERO_DB_PATH", tempDB)
+
+	for _, tt := range []struct{ env, want string }{
+		{"", "data/cache/summaries"},
+		{"/tmp/summaries", "/tmp/summaries"},
+	} {
+		t.Setenv("SUMMARY_CACHE_DIR", tt.env)
+		cfg, err := Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.SummaryCacheDir != tt.want {
+			t.Errorf("SUMMARY_CACHE_DIR=%q: SummaryCacheDir = %v, want %v", tt.env, cfg.SummaryCacheDir, tt.want)
+		}
+	}
+}
+
 func TestLoadConfigError(t *testing.T) {
 	// 保存原始环境变量
 	originalDB := os.Getenv("ZOTERO_DB_PATH")
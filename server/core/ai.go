@@ -9,18 +9,26 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Conversation 对话结构
 type Conversation struct {
-	ID        string           `json:"id"`
-	Messages  []ChatMessage    `json:"messages"`
-	Context   *DocumentContext `json:"context,omitempty"`
-	CreatedAt time.Time        `json:"created_at"`
-	UpdatedAt time.Time        `json:"updated_at"`
+	ID         string           `json:"id"`
+	Title      string           `json:"title,omitempty"`
+	ForkedFrom string           `json:"forked_from,omitempty"` // 分支来源的对话ID
+	Messages   []ChatMessage    `json:"messages"`
+	Context    *DocumentContext `json:"context,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	UpdatedAt  time.Time        `json:"updated_at"`
+
+	// HistorySummary 超出上下文预算后较早轮次的压缩摘要，覆盖Messages[1:SummarizedUntil]
+	HistorySummary  string    `json:"history_summary,omitempty"`
+	SummarizedUntil int       `json:"summarized_until,omitempty"`
+	Usage           UsageInfo `json:"usage"` // 整个对话累计的token用量
 }
 
 // ChatMessage 聊天消息
@@ -33,8 +41,12 @@ type ChatMessage struct {
 
 // MessageMetadata 消息元数据
 type MessageMetadata struct {
-	DocumentIDs []int  `json:"document_ids,omitempty"` // 关联的文献ID
-	QueryType   string `json:"query_type,omitempty"`   // search, analysis, summary
+	DocumentIDs []int            `json:"document_ids,omitempty"` // 关联的文献ID
+	QueryType   string           `json:"query_type,omitempty"`   // search, analysis, summary
+	Chunks      []RetrievedChunk `json:"chunks,omitempty"`       // 生成回答时使用的文献片段
+	Citations   []Citation       `json:"citations,omitempty"`    // 与回答中[编号]对应的引用
+	Unsupported []CitationFlag   `json:"unsupported,omitempty"`  // 引用校验未通过的句子
+	Usage       *UsageInfo       `json:"usage,omitempty"`        // 生成该回复的token用量
 }
 
 // DocumentContext 文档上下文
@@ -42,6 +54,14 @@ type DocumentContext struct {
 	Documents []DocumentSummary `json:"documents"`
 	Query     string            `json:"query"`
 	Relevance float64           `json:"relevance"`
+	// DocumentNames 限定检索范围的结果目录名，为空时检索全部解析结果
+	DocumentNames []string `json:"document_names,omitempty"`
+	// Chunks 针对当前问题检索到的文献片段
+	Chunks []RetrievedChunk `json:"chunks,omitempty"`
+	// Annotations 用户在Zotero中对这些文献的批注，作为优先参考的上下文
+	Annotations []ZoteroAnnotation `json:"annotations,omitempty"`
+	// Notes 这些文献在Zotero中的子笔记（团队的阅读笔记）
+	Notes []ZoteroNote `json:"notes,omitempty"`
 }
 
 // DocumentSummary 文档摘要
@@ -61,6 +81,21 @@ type AIRequest struct {
 	Temperature float64       `json:"temperature,omitempty"`
 	MaxTokens   int           `json:"max_tokens,omitempty"`
 	TopP        float64       `json:"top_p,omitempty"`
+	// ResponseFormat 约束输出格式（JSON对象或JSON Schema），为nil时输出自由文本
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+}
+
+// ResponseFormat OpenAI兼容的response_format参数
+type ResponseFormat struct {
+	Type       string          `json:"type"` // json_object 或 json_schema
+	JSONSchema *JSONSchemaSpec `json:"json_schema,omitempty"`
+}
+
+// JSONSchemaSpec response_format为json_schema时的模式定义
+type JSONSchemaSpec struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+	Strict bool                   `json:"strict,omitempty"`
 }
 
 // AIResponse AI 响应结构
@@ -153,6 +188,9 @@ func (c *GLMClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, erro
 	if req.TopP > 0 {
 		glmReq["top_p"] = req.TopP
 	}
+	if req.ResponseFormat != nil {
+		glmReq["response_format"] = req.ResponseFormat
+	}
 
 	reqBody, err := json.Marshal(glmReq)
 	if err != nil {
@@ -280,9 +318,16 @@ func (c *GLMClient) ChatStream(ctx context.Context, req *AIRequest) (<-chan *Cho
 
 // AIConversationManager AI 对话管理器
 type AIConversationManager struct {
-	client        AIClient
-	zoteroDB      *ZoteroDB
+	client   AIClient
+	zoteroDB *ZoteroDB
+	rag      *RAGPipeline
+	store    *ConversationStore // 为nil时对话只保存在内存中
+	budget   ContextBudget
+	prompts  *PromptLibrary
+
+	mu            sync.Mutex
 	conversations map[string]*Conversation
+	convLocks     map[string]*sync.Mutex // 同一对话的多轮请求串行执行
 }
 
 // NewAIConversationManager 创建对话管理器
@@ -290,13 +335,42 @@ func NewAIConversationManager(client AIClient, zoteroDB *ZoteroDB) *AIConversati
 	return &AIConversationManager{
 		client:        client,
 		zoteroDB:      zoteroDB,
+		rag:           NewRAGPipeline("data/results"),
+		budget:        BudgetForModel(""),
+		prompts:       DefaultPromptLibrary(),
 		conversations: make(map[string]*Conversation),
+		convLocks:     make(map[string]*sync.Mutex),
 	}
 }
 
+// SetRAGPipeline 替换检索流水线（如使用其他结果目录或检索器）
+func (m *AIConversationManager) SetRAGPipeline(rag *RAGPipeline) {
+	m.rag = rag
+}
+
+// SetPromptLibrary 设置系统提示和命令模板库
+func (m *AIConversationManager) SetPromptLibrary(prompts *PromptLibrary) {
+	m.prompts = prompts
+}
+
+// Prompts 对话使用的模板库
+func (m *AIConversationManager) Prompts() *PromptLibrary {
+	return m.prompts
+}
+
+// SetContextBudget 设置单次请求的token预算，通常由BudgetForModel按模型生成
+func (m *AIConversationManager) SetContextBudget(budget ContextBudget) {
+	m.budget = budget
+}
+
+// SetConversationStore 设置对话持久化存储，设置后对话在每轮回复后写入磁盘
+func (m *AIConversationManager) SetConversationStore(store *ConversationStore) {
+	m.store = store
+}
+
 // StartConversation 开始新对话
 func (m *AIConversationManager) StartConversation(ctx context.Context, message string, documentIDs []int) (*Conversation, error) {
-	convID := fmt.Sprintf("conv_%d", time.Now().Unix())
+	convID := newConversationID()
 
 	conv := &Conversation{
 		ID:        convID,
@@ -345,91 +419,118 @@ func (m *AIConversationManager) StartConversation(ctx context.Context, message s
 	conv.Messages = append(conv.Messages, userMsg)
 
 	// 获取 AI 响应
-	aiResp, err := m.client.Chat(ctx, &AIRequest{
-		Model:    "", // 使用默认模型
-		Messages: conv.Messages,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("AI 响应失败: %w", err)
+	if err := m.reply(ctx, conv); err != nil {
+		return nil, err
 	}
-
-	if len(aiResp.Choices) > 0 {
-		assistantMsg := aiResp.Choices[0].Message
-		assistantMsg.Timestamp = time.Now()
-		conv.Messages = append(conv.Messages, assistantMsg)
+	conv.Title = defaultConversationTitle(conv.Messages)
+	if err := m.save(conv); err != nil {
+		return nil, err
 	}
-
-	conv.UpdatedAt = time.Now()
-	m.conversations[convID] = conv
-
 	return conv, nil
 }
 
-// buildDocumentContext 构建文档上下文
+// buildDocumentContext 构建文档上下文：从解析结果中检索与问题相关的文献片段
 func (m *AIConversationManager) buildDocumentContext(ctx context.Context, query string, documentIDs []int) (*DocumentContext, error) {
-	// 首先尝试从解析结果中获取文档
-	documents, err := m.getDocumentsFromResults(query)
+	names := m.resultNamesForItems(documentIDs)
+	if len(documentIDs) > 0 && len(names) == 0 {
+		// 指定的文献尚未解析，降级到数据库元数据
+		return m.buildDocumentContextFromDB(ctx, query, documentIDs)
+	}
+
+	chunks, err := m.rag.Retrieve(ctx, query, RAGOptions{Documents: names})
 	if err != nil {
-		log.Printf("从解析结果获取文档失败: %v", err)
+		log.Printf("从解析结果检索文献片段失败: %v", err)
 		// 降级到数据库查询
 		return m.buildDocumentContextFromDB(ctx, query, documentIDs)
 	}
 
-	return &DocumentContext{
-		Documents: documents,
-		Query:     query,
-		Relevance: 0.9, // 解析结果的相关性更高
-	}, nil
+	docCtx := &DocumentContext{
+		Documents:     m.rag.Summaries(chunks),
+		Query:         query,
+		Relevance:     0.9, // 解析结果的相关性更高
+		DocumentNames: names,
+		Chunks:        chunks,
+	}
+	m.attachAnnotations(docCtx, query)
+	m.attachNotes(docCtx, query)
+	return docCtx, nil
+}
+
+// resultNamesForItems 将Zotero条目ID映射为对应的解析结果目录名
+func (m *AIConversationManager) resultNamesForItems(documentIDs []int) []string {
+	if m.zoteroDB == nil {
+		return nil
+	}
+	var names []string
+	for _, id := range documentIDs {
+		item, err := m.zoteroDB.GetItemByID(id)
+		if err != nil {
+			log.Printf("获取文献 %d 失败: %v", id, err)
+			continue
+		}
+		if result, err := FindParsedResult(m.rag.ResultsDir(), item); err == nil && result != nil {
+			names = append(names, result.Name)
+		}
+	}
+	return names
 }
 
-// getDocumentsFromResults 从解析结果获取文档
-func (m *AIConversationManager) getDocumentsFromResults(query string) ([]DocumentSummary, error) {
-	resultsDir := "data/results"
+// refreshContext 针对新的问题重新检索片段并更新系统提示
+func (m *AIConversationManager) refreshContext(ctx context.Context, conv *Conversation, question string) {
+	if conv.Context == nil || len(conv.Context.Chunks) == 0 && len(conv.Context.DocumentNames) == 0 {
+		return
+	}
 
-	entries, err := os.ReadDir(resultsDir)
+	chunks, err := m.rag.Retrieve(ctx, question, RAGOptions{Documents: conv.Context.DocumentNames})
 	if err != nil {
-		return nil, fmt.Errorf("读取结果目录失败: %w", err)
+		log.Printf("检索文献片段失败: %v", err)
+		return
 	}
+	conv.Context.Query = question
+	conv.Context.Chunks = chunks
+	conv.Context.Documents = m.rag.Summaries(chunks)
 
-	var documents []DocumentSummary
-	queryLower := strings.ToLower(query)
+	if len(conv.Messages) > 0 && conv.Messages[0].Role == "system" {
+		conv.Messages[0].Content = m.buildSystemPrompt(conv.Context)
+	}
+}
 
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == "latest" {
-			continue
-		}
+// reply 请求AI回复并追加到对话，回复元数据中记录使用的文献片段和token用量
+//
+// 请求前将文献上下文和对话历史裁剪到上下文预算内
+func (m *AIConversationManager) reply(ctx context.Context, conv *Conversation) error {
+	ctx = WithUsageFeature(ctx, FeatureChat)
+	systemBudget := m.budget.DocumentBudget() - EstimateTokens(m.buildSystemPrompt(nil))
+	if fitDocumentContext(conv.Context, systemBudget) && len(conv.Messages) > 0 && conv.Messages[0].Role == "system" {
+		conv.Messages[0].Content = m.buildSystemPrompt(conv.Context)
+	}
+	messages := m.requestMessages(ctx, conv)
 
-		// 读取元数据
-		metaFile := filepath.Join(resultsDir, entry.Name(), "meta.json")
-		if info := readMeta(metaFile); info != nil {
-			// 检查是否与查询相关
-			titleMatch := strings.Contains(strings.ToLower(info.Title), queryLower)
-			contentMatch := false
-
-			// 检查markdown内容是否相关
-			mdFile := filepath.Join(resultsDir, entry.Name(), "full.md")
-			if content, err := os.ReadFile(mdFile); err == nil {
-				contentStr := string(content)
-				if strings.Contains(strings.ToLower(contentStr), queryLower) {
-					contentMatch = true
-				}
+	aiResp, err := m.client.Chat(ctx, &AIRequest{
+		Model:    "", // 使用默认模型
+		Messages: messages,
+	})
+	if err != nil {
+		return fmt.Errorf("AI 响应失败: %w", err)
+	}
 
-				// 如果相关，创建文档摘要
-				if titleMatch || contentMatch {
-					doc := DocumentSummary{
-						ID:       len(documents), // 简化ID
-						Title:    info.Title,
-						Authors:  extractAuthorsFromContent(string(content)),
-						Abstract: extractAbstractFromContent(string(content)),
-						Keywords: extractKeywordsFromContent(string(content)),
-					}
-					documents = append(documents, doc)
-				}
-			}
+	if len(aiResp.Choices) > 0 {
+		assistantMsg := aiResp.Choices[0].Message
+		assistantMsg.Timestamp = time.Now()
+		usage := resolveUsage(aiResp.Usage, messages, assistantMsg.Content)
+		conv.Usage.add(usage)
+		assistantMsg.Metadata = &MessageMetadata{Usage: &usage}
+		if conv.Context != nil && len(conv.Context.Chunks) > 0 {
+			chunks := conv.Context.Chunks
+			assistantMsg.Metadata.Chunks = chunks
+			assistantMsg.Metadata.Citations = CitationsFromChunks(chunks)
+			assistantMsg.Metadata.Unsupported = VerifyCitations(assistantMsg.Content, chunks)
 		}
+		conv.Messages = append(conv.Messages, assistantMsg)
 	}
 
-	return documents, nil
+	conv.UpdatedAt = time.Now()
+	return nil
 }
 
 // buildDocumentContextFromDB 从数据库构建文档上下文（降级方案）
@@ -556,32 +657,31 @@ func extractKeywordsFromContent(content string) []string {
 
 // buildSystemPrompt 构建系统提示
 func (m *AIConversationManager) buildSystemPrompt(context *DocumentContext) string {
-	basePrompt := `你是一个专业的学术文献助手，专门帮助用户分析和理解学术论文。请用中文回答，并提供准确、有用的信息。
+	var data PromptData
+	if context != nil && len(context.Documents) == 1 {
+		data.Title = context.Documents[0].Title
+		data.Authors = context.Documents[0].Authors
+		data.Abstract = context.Documents[0].Abstract
+	}
+	basePrompt := m.prompts.System(PromptChatSystem, data)
 
-你的主要功能包括：
-1. 分析论文内容和方法
-2. 解释专业术语和概念
-3. 比较不同研究方法的优缺点
-4. 提供研究建议和未来方向
-5. 协助文献综述和总结
+	if context != nil && len(context.Annotations) > 0 {
+		basePrompt += "\n\n=== 我在Zotero中的批注和高亮（用户标注的重点，优先参考） ===\n" + formatAnnotationContext(context.Annotations)
+	}
+	if context != nil && len(context.Notes) > 0 {
+		basePrompt += "\n\n=== 我在Zotero中的阅读笔记 ===\n" + formatNoteContext(context.Notes)
+	}
 
-请基于提供的文献内容进行回答，保持专业、客观、有帮助的态度。`
+	if context != nil && len(context.Chunks) > 0 {
+		basePrompt += "\n\n=== 相关文献片段 ===\n" + FormatChunkContext(context.Chunks)
+		basePrompt += "\n\n💡 请依据上述片段回答，引用时在句末用[编号]标注来源；片段中没有的信息请明确说明。"
+		return basePrompt
+	}
 
 	if context != nil && len(context.Documents) > 0 {
 		contextInfo := "\n\n=== 相关文献信息 ===\n"
 		for i, doc := range context.Documents {
-			contextInfo += fmt.Sprintf("\n%d. %s\n", i+1, doc.Title)
-			contextInfo += fmt.Sprintf("   作者: %s\n", doc.Authors)
-
-			if doc.Abstract != "" {
-				contextInfo += fmt.Sprintf("   摘要: %s\n", doc.Abstract)
-			}
-
-			if len(doc.Keywords) > 0 {
-				contextInfo += fmt.Sprintf("   关键词: %s\n", strings.Join(doc.Keywords, ", "))
-			}
-
-			contextInfo += "   ---\n"
+			contextInfo += formatDocumentSummary(i, doc)
 		}
 
 		contextInfo += fmt.Sprintf("\n💡 请基于上述文献内容回答用户的问题: %s", context.Query)
@@ -591,9 +691,37 @@ func (m *AIConversationManager) buildSystemPrompt(context *DocumentContext) stri
 	return basePrompt
 }
 
+// formatDocumentSummary 系统提示中的一篇文献摘要
+func formatDocumentSummary(i int, doc DocumentSummary) string {
+	info := fmt.Sprintf("\n%d. %s\n", i+1, doc.Title)
+	info += fmt.Sprintf("   作者: %s\n", doc.Authors)
+
+	if doc.Abstract != "" {
+		info += fmt.Sprintf("   摘要: %s\n", doc.Abstract)
+	}
+
+	if len(doc.Keywords) > 0 {
+		info += fmt.Sprintf("   关键词: %s\n", strings.Join(doc.Keywords, ", "))
+	}
+
+	return info + "   ---\n"
+}
+
 // StartConversationWithDocument 基于指定文献开始对话
 func (m *AIConversationManager) StartConversationWithDocument(ctx context.Context, message string, docContext *DocumentContext) (*Conversation, error) {
-	convID := fmt.Sprintf("conv_%d", time.Now().Unix())
+	convID := newConversationID()
+
+	// 指定了文献但未提供片段时，从这些文献中检索片段以便回答可以引用
+	if docContext != nil && len(docContext.Chunks) == 0 && len(docContext.DocumentNames) > 0 {
+		chunks, err := m.rag.Retrieve(ctx, message, RAGOptions{Documents: docContext.DocumentNames})
+		if err != nil {
+			log.Printf("检索文献片段失败: %v", err)
+		} else {
+			docContext.Chunks = chunks
+		}
+	}
+	m.attachAnnotations(docContext, message)
+	m.attachNotes(docContext, message)
 
 	conv := &Conversation{
 		ID:        convID,
@@ -624,33 +752,29 @@ func (m *AIConversationManager) StartConversationWithDocument(ctx context.Contex
 	conv.Messages = append(conv.Messages, userMsg)
 
 	// 获取 AI 响应
-	aiResp, err := m.client.Chat(ctx, &AIRequest{
-		Model:    "", // 使用默认模型
-		Messages: conv.Messages,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("AI 响应失败: %w", err)
+	if err := m.reply(ctx, conv); err != nil {
+		return nil, err
 	}
-
-	if len(aiResp.Choices) > 0 {
-		assistantMsg := aiResp.Choices[0].Message
-		assistantMsg.Timestamp = time.Now()
-		conv.Messages = append(conv.Messages, assistantMsg)
+	conv.Title = defaultConversationTitle(conv.Messages)
+	if err := m.save(conv); err != nil {
+		return nil, err
 	}
-
-	conv.UpdatedAt = time.Now()
-	m.conversations[convID] = conv
-
 	return conv, nil
 }
 
-// ContinueConversation 继续对话
+// ContinueConversation 继续对话，对话不在内存中时从存储加载
 func (m *AIConversationManager) ContinueConversation(ctx context.Context, convID, message string) (*Conversation, error) {
-	conv, exists := m.conversations[convID]
-	if !exists {
-		return nil, fmt.Errorf("对话不存在: %s", convID)
+	unlock := m.lockConversation(convID)
+	defer unlock()
+
+	conv, err := m.conversation(convID)
+	if err != nil {
+		return nil, err
 	}
 
+	// 针对本轮问题重新检索文献片段
+	m.refreshContext(ctx, conv, message)
+
 	// 添加用户消息
 	userMsg := ChatMessage{
 		Role:      "user",
@@ -659,48 +783,153 @@ func (m *AIConversationManager) ContinueConversation(ctx context.Context, convID
 	}
 	conv.Messages = append(conv.Messages, userMsg)
 
-	// 获取 AI 响应
-	aiResp, err := m.client.Chat(ctx, &AIRequest{
-		Model:    "", // 使用默认模型
-		Messages: conv.Messages,
-	})
-	if err != nil {
-		return nil, fmt.Errorf("AI 响应失败: %w", err)
+	// 获取 AI 响应，失败时撤回本轮提问
+	if err := m.reply(ctx, conv); err != nil {
+		conv.Messages = conv.Messages[:len(conv.Messages)-1]
+		return nil, err
 	}
-
-	if len(aiResp.Choices) > 0 {
-		assistantMsg := aiResp.Choices[0].Message
-		assistantMsg.Timestamp = time.Now()
-		conv.Messages = append(conv.Messages, assistantMsg)
+	if err := m.save(conv); err != nil {
+		return nil, err
 	}
+	return cloneConversation(conv)
+}
 
-	conv.UpdatedAt = time.Now()
-	return conv, nil
+// lockConversation 获取单个对话的锁，返回解锁函数
+func (m *AIConversationManager) lockConversation(convID string) func() {
+	m.mu.Lock()
+	lock, ok := m.convLocks[convID]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.convLocks[convID] = lock
+	}
+	m.mu.Unlock()
+	lock.Lock()
+	return lock.Unlock
 }
 
-// GetConversation 获取对话历史
+// save 缓存对话并写入存储
+func (m *AIConversationManager) save(conv *Conversation) error {
+	m.mu.Lock()
+	m.conversations[conv.ID] = conv
+	m.mu.Unlock()
+	if m.store == nil {
+		return nil
+	}
+	if err := m.store.Save(conv); err != nil {
+		return fmt.Errorf("保存对话失败: %w", err)
+	}
+	return nil
+}
+
+// GetConversation 获取对话历史的副本，可以在其他请求继续该对话时安全读取
 func (m *AIConversationManager) GetConversation(convID string) (*Conversation, error) {
+	unlock := m.lockConversation(convID)
+	defer unlock()
+
+	conv, err := m.conversation(convID)
+	if err != nil {
+		return nil, err
+	}
+	return cloneConversation(conv)
+}
+
+// conversation 返回缓存中的对话，不在内存中时从存储加载；调用方需持有该对话的锁
+func (m *AIConversationManager) conversation(convID string) (*Conversation, error) {
+	m.mu.Lock()
 	conv, exists := m.conversations[convID]
-	if !exists {
-		return nil, fmt.Errorf("对话不存在: %s", convID)
+	m.mu.Unlock()
+	if exists {
+		return conv, nil
 	}
+	if m.store == nil {
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, convID)
+	}
+
+	conv, err := m.store.Load(convID)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if cached, ok := m.conversations[convID]; ok {
+		conv = cached
+	} else {
+		m.conversations[convID] = conv
+	}
+	m.mu.Unlock()
 	return conv, nil
 }
 
-// ListConversations 列出所有对话
-func (m *AIConversationManager) ListConversations() []string {
-	var convIDs []string
-	for id := range m.conversations {
-		convIDs = append(convIDs, id)
+// ListConversations 列出所有对话，最近更新的在前
+func (m *AIConversationManager) ListConversations() ([]ConversationSummary, error) {
+	if m.store != nil {
+		return m.store.List()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	summaries := make([]ConversationSummary, 0, len(m.conversations))
+	for _, conv := range m.conversations {
+		summaries = append(summaries, conv.Summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+	return summaries, nil
+}
+
+// RenameConversation 修改对话标题
+func (m *AIConversationManager) RenameConversation(convID, title string) (*Conversation, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("标题不能为空")
+	}
+	unlock := m.lockConversation(convID)
+	defer unlock()
+
+	conv, err := m.conversation(convID)
+	if err != nil {
+		return nil, err
 	}
-	return convIDs
+	conv.Title = title
+	conv.UpdatedAt = time.Now()
+	if err := m.save(conv); err != nil {
+		return nil, err
+	}
+	return cloneConversation(conv)
+}
+
+// ForkConversation 从对话的前n条消息（n<=0时为全部）创建新对话，原对话不变
+func (m *AIConversationManager) ForkConversation(convID string, n int) (*Conversation, error) {
+	unlock := m.lockConversation(convID)
+	conv, err := m.conversation(convID)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	fork, err := forkConversation(conv, n)
+	unlock()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.save(fork); err != nil {
+		return nil, err
+	}
+	return fork, nil
 }
 
 // DeleteConversation 删除对话
 func (m *AIConversationManager) DeleteConversation(convID string) error {
-	if _, exists := m.conversations[convID]; !exists {
-		return fmt.Errorf("对话不存在: %s", convID)
-	}
+	unlock := m.lockConversation(convID)
+	defer unlock()
+
+	m.mu.Lock()
+	_, cached := m.conversations[convID]
 	delete(m.conversations, convID)
+	m.mu.Unlock()
+
+	if m.store != nil {
+		return m.store.Delete(convID)
+	}
+	if !cached {
+		return fmt.Errorf("%w: %s", ErrConversationNotFound, convID)
+	}
 	return nil
 }
@@ -0,0 +1,352 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ZoteroAnnotation Zotero 6/7 PDF阅读器中的批注（高亮、下划线、便笺、图片区域等）
+type ZoteroAnnotation struct {
+	ItemID        int    `json:"item_id"`
+	Key           string `json:"key"`
+	AttachmentID  int    `json:"attachment_id"`
+	AttachmentKey string `json:"attachment_key"`
+	Type          string `json:"type"` // highlight、underline、note、image、ink、text
+	Text          string `json:"text,omitempty"`
+	Comment       string `json:"comment,omitempty"`
+	Color         string `json:"color,omitempty"` // 如 #ffd400
+	PageLabel     string `json:"page_label,omitempty"`
+	PageIndex     int    `json:"page_index"` // 从0开始的PDF页序号，取自position
+	// Position 阅读器记录的位置（pageIndex和rects），原样保留
+	Position  json.RawMessage `json:"position,omitempty"`
+	SortIndex string          `json:"sort_index,omitempty"`
+	DateAdded string          `json:"date_added,omitempty"`
+	Tags      []string        `json:"tags,omitempty"`
+}
+
+// annotationTypes itemAnnotations.type 取值对应的类型名
+var annotationTypes = map[int]string{
+	1: "highlight",
+	2: "note",
+	3: "image",
+	4: "ink",
+	5: "underline",
+	6: "text",
+}
+
+// annotationColors Zotero阅读器默认调色板的颜色名
+var annotationColors = map[string]string{
+	"#ffd400": "黄色",
+	"#ff6666": "红色",
+	"#5fb236": "绿色",
+	"#2ea8e5": "蓝色",
+	"#a28ae5": "紫色",
+	"#e56eee": "品红",
+	"#f19837": "橙色",
+	"#aaaaaa": "灰色",
+}
+
+// AnnotationColorName 颜色的中文名称，非默认调色板的颜色返回色值本身
+func AnnotationColorName(color string) string {
+	if name, ok := annotationColors[strings.ToLower(color)]; ok {
+		return name
+	}
+	if color == "" {
+		return "无颜色"
+	}
+	return color
+}
+
+// Page 批注所在页的显示页码：优先使用页标签，否则为页序号+1
+func (a ZoteroAnnotation) Page() string {
+	if a.PageLabel != "" {
+		return a.PageLabel
+	}
+	return fmt.Sprint(a.PageIndex + 1)
+}
+
+// hasTable 数据库中是否存在指定的表（旧版本Zotero没有批注等表）
+func (z *ZoteroDB) hasTable(name string) bool {
+	var count int
+	err := z.db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&count)
+	return err == nil && count > 0
+}
+
+// GetAnnotations 读取附件上的批注，按阅读顺序（页码、位置）排列，跳过回收站中的批注；
+// Zotero 6之前的数据库返回空列表
+func (z *ZoteroDB) GetAnnotations(attachmentID int) ([]ZoteroAnnotation, error) {
+	if !z.hasTable("itemAnnotations") {
+		return nil, nil
+	}
+	query := `
+		SELECT a.itemID, i.key, a.parentItemID, p.key, a.type,
+			COALESCE(a.text, ''), COALESCE(a.comment, ''), COALESCE(a.color, ''),
+			COALESCE(a.pageLabel, ''), COALESCE(a.position, ''), COALESCE(a.sortIndex, ''),
+			COALESCE(i.dateAdded, '')
+		FROM itemAnnotations a
+		JOIN items i ON i.itemID = a.itemID
+		JOIN items p ON p.itemID = a.parentItemID
+		WHERE a.parentItemID = ?`
+	if z.hasTable("deletedItems") {
+		query += " AND a.itemID NOT IN (SELECT itemID FROM deletedItems)"
+	}
+	rows, err := z.db.Query(query+" ORDER BY a.sortIndex, a.itemID", attachmentID)
+	if err != nil {
+		return nil, fmt.Errorf("查询批注失败: %w", err)
+	}
+
+	var annotations []ZoteroAnnotation
+	for rows.Next() {
+		var a ZoteroAnnotation
+		var typeID int
+		var position string
+		if err := rows.Scan(&a.ItemID, &a.Key, &a.AttachmentID, &a.AttachmentKey, &typeID,
+			&a.Text, &a.Comment, &a.Color, &a.PageLabel, &position, &a.SortIndex, &a.DateAdded); err != nil {
+			log.Printf("扫描批注数据失败: %v", err)
+			continue
+		}
+		a.Type = annotationTypes[typeID]
+		if a.Type == "" {
+			a.Type = fmt.Sprintf("type%d", typeID)
+		}
+		if position != "" && json.Valid([]byte(position)) {
+			a.Position = json.RawMessage(position)
+			var pos struct {
+				PageIndex int `json:"pageIndex"`
+			}
+			json.Unmarshal(a.Position, &pos)
+			a.PageIndex = pos.PageIndex
+		}
+		annotations = append(annotations, a)
+	}
+	rows.Close()
+
+	for i := range annotations {
+		if tags, err := z.getItemTags(annotations[i].ItemID); err == nil {
+			annotations[i].Tags = tags
+		}
+	}
+	return annotations, nil
+}
+
+// GetItemAnnotations 读取文献条目所有PDF附件上的批注
+func (z *ZoteroDB) GetItemAnnotations(itemID int) ([]ZoteroAnnotation, error) {
+	rows, err := z.db.Query(`
+		SELECT itemID FROM itemAttachments
+		WHERE parentItemID = ? AND contentType = 'application/pdf'
+		ORDER BY itemID`, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("查询附件失败: %w", err)
+	}
+	var attachmentIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			attachmentIDs = append(attachmentIDs, id)
+		}
+	}
+	rows.Close()
+
+	var annotations []ZoteroAnnotation
+	for _, id := range attachmentIDs {
+		found, err := z.GetAnnotations(id)
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, found...)
+	}
+	return annotations, nil
+}
+
+// 批注导出的分组方式
+const (
+	AnnotationsByColor = "color"
+	AnnotationsByPage  = "page"
+)
+
+// RenderAnnotationsMarkdown 将批注导出为Markdown笔记，按颜色或页码分组
+func RenderAnnotationsMarkdown(item *ZoteroItem, annotations []ZoteroAnnotation, groupBy string) (string, error) {
+	if groupBy == "" {
+		groupBy = AnnotationsByColor
+	}
+	if groupBy != AnnotationsByColor && groupBy != AnnotationsByPage {
+		return "", fmt.Errorf("不支持的分组方式: %s（可选 color、page）", groupBy)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("# 批注：%s\n\n", item.Title))
+	meta := strings.Join(item.Authors, ", ")
+	if item.Year != 0 {
+		meta = strings.TrimSpace(fmt.Sprintf("%s (%d)", meta, item.Year))
+	}
+	if meta != "" {
+		builder.WriteString(meta + "\n\n")
+	}
+	if len(annotations) == 0 {
+		builder.WriteString("（没有批注）\n")
+		return builder.String(), nil
+	}
+
+	// 按首次出现的顺序分组：颜色组内按阅读顺序，页码组按页序号
+	var keys []string
+	groups := make(map[string][]ZoteroAnnotation)
+	for _, a := range annotations {
+		key := strings.ToLower(a.Color)
+		if groupBy == AnnotationsByPage {
+			key = fmt.Sprintf("%06d", a.PageIndex)
+		}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], a)
+	}
+	if groupBy == AnnotationsByPage {
+		sort.Strings(keys)
+	}
+
+	for _, key := range keys {
+		group := groups[key]
+		if groupBy == AnnotationsByPage {
+			builder.WriteString(fmt.Sprintf("## 第 %s 页\n\n", group[0].Page()))
+		} else {
+			builder.WriteString(fmt.Sprintf("## %s（%d）\n\n", AnnotationColorName(group[0].Color), len(group)))
+		}
+		for _, a := range group {
+			builder.WriteString(formatAnnotationMarkdown(a, groupBy))
+		}
+	}
+	return builder.String(), nil
+}
+
+// formatAnnotationMarkdown 一条批注的Markdown：原文引用、出处和评论
+func formatAnnotationMarkdown(a ZoteroAnnotation, groupBy string) string {
+	var builder strings.Builder
+	if text := strings.TrimSpace(a.Text); text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			builder.WriteString("> " + line + "\n")
+		}
+	} else if a.Type == "image" || a.Type == "ink" {
+		builder.WriteString(fmt.Sprintf("> （%s批注）\n", a.Type))
+	}
+
+	source := fmt.Sprintf("p. %s", a.Page())
+	if groupBy == AnnotationsByPage {
+		source = AnnotationColorName(a.Color)
+	}
+	if a.Type != "highlight" {
+		source += " · " + a.Type
+	}
+	if builder.Len() > 0 {
+		builder.WriteString("> — " + source + "\n")
+	} else {
+		builder.WriteString("- " + source + "\n")
+	}
+	if comment := strings.TrimSpace(a.Comment); comment != "" {
+		builder.WriteString("\n" + comment + "\n")
+	}
+	if len(a.Tags) > 0 {
+		builder.WriteString("\n" + "#" + strings.Join(a.Tags, " #") + "\n")
+	}
+	builder.WriteString("\n")
+	return builder.String()
+}
+
+// annotationContextShare 用户批注和笔记合计最多占用文献上下文预算的比例，其余留给检索片段
+const annotationContextShare = 0.5
+
+// formatAnnotationContext 系统提示中的批注列表
+func formatAnnotationContext(annotations []ZoteroAnnotation) string {
+	var builder strings.Builder
+	for _, a := range annotations {
+		builder.WriteString(formatAnnotationLine(a) + "\n")
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// formatAnnotationLine 一条批注在提示中的写法：- [高亮·黄色·p.3] "原文" 评论: ...
+func formatAnnotationLine(a ZoteroAnnotation) string {
+	kind := map[string]string{"highlight": "高亮", "underline": "下划线", "note": "便笺", "image": "图片", "ink": "手绘", "text": "文本"}[a.Type]
+	if kind == "" {
+		kind = a.Type
+	}
+	line := fmt.Sprintf("- [%s·%s·p.%s]", kind, AnnotationColorName(a.Color), a.Page())
+	if text := strings.Join(strings.Fields(a.Text), " "); text != "" {
+		line += fmt.Sprintf(" \"%s\"", text)
+	}
+	if comment := strings.Join(strings.Fields(a.Comment), " "); comment != "" {
+		line += " 评论: " + comment
+	}
+	return line
+}
+
+// rankAnnotations 按与问题的词项重叠排序（稳定排序，无重叠的保持阅读顺序），使预算不足时优先保留相关批注
+func rankAnnotations(annotations []ZoteroAnnotation, query string) []ZoteroAnnotation {
+	queryTerms := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		queryTerms[term] = true
+	}
+	scores := make(map[int]int, len(annotations))
+	for _, a := range annotations {
+		for _, term := range uniqueTerms(tokenize(a.Text + " " + a.Comment)) {
+			if queryTerms[term] {
+				scores[a.ItemID]++
+			}
+		}
+	}
+	ranked := append([]ZoteroAnnotation(nil), annotations...)
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i].ItemID] > scores[ranked[j].ItemID] })
+	return ranked
+}
+
+// fitAnnotations 按顺序装入批注，返回装入的批注和使用的token数
+func fitAnnotations(annotations []ZoteroAnnotation, budget int) ([]ZoteroAnnotation, int) {
+	var fitted []ZoteroAnnotation
+	used := 0
+	for _, a := range annotations {
+		tokens := EstimateTokens(formatAnnotationLine(a))
+		if used+tokens > budget {
+			continue
+		}
+		fitted = append(fitted, a)
+		used += tokens
+	}
+	return fitted, used
+}
+
+// attachAnnotations 为指定的解析结果读取对应Zotero条目的批注，按与问题的相关度排序后放入上下文
+func (m *AIConversationManager) attachAnnotations(docCtx *DocumentContext, query string) {
+	if m.zoteroDB == nil || docCtx == nil || len(docCtx.Annotations) > 0 {
+		return
+	}
+	var annotations []ZoteroAnnotation
+	for _, item := range m.documentItems(docCtx) {
+		found, err := m.zoteroDB.GetItemAnnotations(item.ItemID)
+		if err != nil {
+			log.Printf("读取批注失败: %v", err)
+			continue
+		}
+		annotations = append(annotations, found...)
+	}
+	if len(annotations) > 0 {
+		log.Printf("🖍️ 对话上下文包含 %d 条批注", len(annotations))
+		docCtx.Annotations = rankAnnotations(annotations, query)
+	}
+}
+
+// documentItems 上下文中的解析结果对应的Zotero条目，没有关联条目的结果被跳过
+func (m *AIConversationManager) documentItems(docCtx *DocumentContext) []*ZoteroItem {
+	var items []*ZoteroItem
+	for _, name := range docCtx.DocumentNames {
+		result, err := GetParsedResult(m.rag.ResultsDir(), name)
+		if err != nil || result.Info == nil || result.Info.ItemKey == "" {
+			continue
+		}
+		if item, err := m.zoteroDB.GetItemByKey(result.Info.ItemKey); err == nil {
+			items = append(items, item)
+		}
+	}
+	return items
+}
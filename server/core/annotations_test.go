@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// fixtureAnnotations Zotero 7的批注表和附件2上的四条批注，插入顺序与阅读顺序不同
+const fixtureAnnotations = `
+CREATE TABLE itemAnnotations (itemID INTEGER PRIMARY KEY, parentItemID INT NOT NULL, type INTEGER NOT NULL,
+	authorName TEXT, text TEXT, comment TEXT, color TEXT, pageLabel TEXT, sortIndex TEXT NOT NULL, position TEXT NOT NULL, isExternal INT NOT NULL);
+INSERT INTO itemTypes VALUES (3, 'annotation');
+INSERT INTO items VALUES (10, 3, 'ANNO0001', '2024-02-01'), (11, 3, 'ANNO0002', '2024-02-01'), (12, 3, 'ANNO0003', '2024-02-02'), (13, 3, 'ANNO0004', '2024-02-03');
+INSERT INTO itemAnnotations VALUES
+	(10, 2, 1, NULL, 'multi-head attention allows the model to jointly attend', '多头注意力是关键', '#ffd400', '4', '00003|000100|00200', '{"pageIndex":3,"rects":[[1,2,3,4]]}', 0),
+	(11, 2, 1, NULL, 'The Transformer dispenses with recurrence entirely', NULL, '#ff6666', '1', '00000|000050|00100', '{"pageIndex":0,"rects":[[1,2,3,4]]}', 0),
+	(12, 2, 2, NULL, NULL, '与BERT对比', '#ffd400', '', '00003|000010|00050', '{"pageIndex":3}', 0),
+	(13, 2, 3, NULL, NULL, NULL, '#5fb236', 'iv', '00001|000000|00010', '{"pageIndex":1}', 0);
+INSERT INTO tags VALUES (2, 'important');
+INSERT INTO itemTags VALUES (10, 2);
+`
+
+// addFixtureAnnotations 向测试数据库写入批注
+func addFixtureAnnotations(t *testing.T, db *ZoteroDB) {
+	t.Helper()
+	raw, err := sql.Open("sqlite3", db.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(fixtureAnnotations); err != nil {
+		t.Fatalf("写入批注失败: %v", err)
+	}
+}
+
+func TestGetItemAnnotations(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	if annotations, err := db.GetItemAnnotations(1); err != nil || len(annotations) != 0 {
+		t.Fatalf("没有批注表的旧数据库应返回空列表: %v, %v", annotations, err)
+	}
+
+	addFixtureAnnotations(t, db)
+	annotations, err := db.GetItemAnnotations(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, a := range annotations {
+		keys = append(keys, a.Key)
+	}
+	if strings.Join(keys, ",") != "ANNO0002,ANNO0004,ANNO0003,ANNO0001" {
+		t.Fatalf("批注应按阅读顺序排列: %v", keys)
+	}
+
+	first := annotations[3]
+	if first.Type != "highlight" || first.Comment != "多头注意力是关键" || first.Color != "#ffd400" || first.PageLabel != "4" ||
+		first.PageIndex != 3 || first.AttachmentKey != "PDF00001" || len(first.Tags) != 1 || first.Tags[0] != "important" {
+		t.Errorf("批注 = %+v", first)
+	}
+	if note := annotations[2]; note.Type != "note" || note.Page() != "4" || note.Text != "" {
+		t.Errorf("便笺 = %+v", note)
+	}
+	if image := annotations[1]; image.Type != "image" || image.Page() != "iv" || !strings.Contains(string(image.Position), "pageIndex") {
+		t.Errorf("图片批注 = %+v", image)
+	}
+}
+
+func TestGetAnnotationsSkipsDeleted(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	addFixtureAnnotations(t, db)
+	raw, err := sql.Open("sqlite3", db.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(`CREATE TABLE deletedItems (itemID INTEGER PRIMARY KEY, dateDeleted TEXT);
+		INSERT INTO deletedItems VALUES (12, '2024-03-05');`); err != nil {
+		t.Fatal(err)
+	}
+
+	annotations, err := db.GetAnnotations(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, a := range annotations {
+		keys = append(keys, a.Key)
+	}
+	if strings.Join(keys, ",") != "ANNO0002,ANNO0004,ANNO0001" {
+		t.Errorf("回收站中的批注不应返回: %v", keys)
+	}
+}
+
+func TestRenderAnnotationsMarkdown(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	addFixtureAnnotations(t, db)
+	item, _ := db.GetItemByKey("ABCD1234")
+	annotations, _ := db.GetItemAnnotations(item.ItemID)
+
+	byColor, err := RenderAnnotationsMarkdown(item, annotations, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# 批注：Attention Is All You Need",
+		"Ashish Vaswani, Noam Shazeer (2017)",
+		"## 红色（1）",
+		"## 黄色（2）",
+		"> multi-head attention allows the model to jointly attend\n> — p. 4\n\n多头注意力是关键\n\n#important",
+		"- p. 4 · note\n\n与BERT对比",
+		"> （image批注）\n> — p. iv · image",
+	} {
+		if !strings.Contains(byColor, want) {
+			t.Errorf("按颜色导出缺少 %q:\n%s", want, byColor)
+		}
+	}
+	if strings.Index(byColor, "## 红色") > strings.Index(byColor, "## 黄色") {
+		t.Error("颜色分组应按首次出现的顺序排列")
+	}
+
+	byPage, _ := RenderAnnotationsMarkdown(item, annotations, AnnotationsByPage)
+	first, second, fourth := strings.Index(byPage, "## 第 1 页"), strings.Index(byPage, "## 第 iv 页"), strings.Index(byPage, "## 第 4 页")
+	if first < 0 || second < first || fourth < second || !strings.Contains(byPage, "> — 黄色") {
+		t.Errorf("按页导出:\n%s", byPage)
+	}
+	if _, err := RenderAnnotationsMarkdown(item, annotations, "author"); err == nil {
+		t.Error("未知分组方式应返回错误")
+	}
+}
+
+func TestConversationIncludesAnnotations(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	addFixtureAnnotations(t, db)
+
+	client := &fakeAIClient{reply: "你高亮了多头注意力。"}
+	manager := NewAIConversationManager(client, db)
+	manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+	_, err := manager.StartConversationWithDocument(context.Background(), "我对 multi-head attention 高亮了什么？", &DocumentContext{DocumentNames: []string{"attention_20240101"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	system := client.requests[0].Messages[0].Content
+	annotationsAt := strings.Index(system, "=== 我在Zotero中的批注和高亮")
+	if annotationsAt < 0 || annotationsAt > strings.Index(system, "=== 相关文献片段") {
+		t.Fatalf("批注应出现在检索片段之前:\n%s", system)
+	}
+	// 与问题相关的批注排在最前
+	if !strings.Contains(system, "批注和高亮（用户标注的重点，优先参考） ===\n- [高亮·黄色·p.4] \"multi-head attention allows the model to jointly attend\" 评论: 多头注意力是关键") {
+		t.Errorf("批注格式或顺序不正确:\n%s", system)
+	}
+
+	// 预算紧张时批注只占一部分，其余留给片段
+	docCtx := &DocumentContext{Chunks: []RetrievedChunk{{Chunk: Chunk{Text: "chunk"}}}}
+	for i := 0; i < 50; i++ {
+		docCtx.Annotations = append(docCtx.Annotations, ZoteroAnnotation{ItemID: i, Type: "highlight", Text: strings.Repeat("attention ", 20)})
+	}
+	if !fitDocumentContext(docCtx, 400) || len(docCtx.Annotations) == 0 || len(docCtx.Annotations) == 50 || len(docCtx.Chunks) != 1 {
+		t.Errorf("裁剪后: %d 条批注, %d 个片段", len(docCtx.Annotations), len(docCtx.Chunks))
+	}
+}
@@ -0,0 +1,308 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 分块默认参数（按EstimateTokens估算）
+const (
+	defaultChunkTokens    = 350
+	defaultMaxChunkTokens = 500
+)
+
+// Chunk full.md中的一个文本块
+type Chunk struct {
+	ID       string `json:"id"`       // <结果目录名>#<序号>
+	Document string `json:"document"` // 结果目录名
+	Title    string `json:"title"`
+	ItemKey  string `json:"item_key,omitempty"`
+	Index    int    `json:"index"`
+	Section  string `json:"section,omitempty"`
+	Page     int    `json:"page,omitempty"`     // 起始页码（从1开始，0表示未知）
+	PageEnd  int    `json:"page_end,omitempty"` // 结束页码
+	Text     string `json:"text"`
+	Tokens   int    `json:"tokens"`
+}
+
+// ChunkOptions 分块参数
+type ChunkOptions struct {
+	// TargetTokens 同一章节内合并段落直到约此大小
+	TargetTokens int
+	// MaxTokens 超过此大小的段落按句子拆分
+	MaxTokens int
+}
+
+// paragraph 带章节和页码的段落
+type paragraph struct {
+	section string
+	page    int
+	text    string
+}
+
+var (
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.+?)\s*#*$`)
+	imagePattern   = regexp.MustCompile(`^!\[[^\]]*\]\([^)]*\)$`)
+	sentenceEnd    = regexp.MustCompile(`[。！？!?；;]|\.\s`)
+)
+
+// ChunkParsedResult 将解析结果的full.md按章节和段落切分为文本块
+//
+// 结果目录中存在MinerU的*_content_list.json时，用其中的page_idx标注页码
+func ChunkParsedResult(result *ParsedResult, opts ChunkOptions) ([]Chunk, error) {
+	content, err := result.ReadFullText()
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := chunkMarkdown(content, loadPageIndex(result.Dir), opts)
+	for i := range chunks {
+		chunks[i].ID = fmt.Sprintf("%s#%d", result.Name, chunks[i].Index)
+		chunks[i].Document = result.Name
+		chunks[i].Title = result.Title()
+		if result.Info != nil {
+			chunks[i].ItemKey = result.Info.ItemKey
+		}
+	}
+	return chunks, nil
+}
+
+// chunkMarkdown 按标题划分章节，章节内合并相邻段落，过长段落按句子拆分
+func chunkMarkdown(content string, pages *pageIndex, opts ChunkOptions) []Chunk {
+	if opts.TargetTokens <= 0 {
+		opts.TargetTokens = defaultChunkTokens
+	}
+	if opts.MaxTokens < opts.TargetTokens {
+		opts.MaxTokens = max(defaultMaxChunkTokens, opts.TargetTokens)
+	}
+
+	var chunks []Chunk
+	var current []paragraph
+	currentTokens := 0
+
+	flush := func() {
+		if len(current) == 0 {
+			return
+		}
+		texts := make([]string, len(current))
+		for i, p := range current {
+			texts[i] = p.text
+		}
+		text := strings.Join(texts, "\n\n")
+		chunks = append(chunks, Chunk{
+			Index:   len(chunks),
+			Section: current[0].section,
+			Page:    current[0].page,
+			PageEnd: current[len(current)-1].page,
+			Text:    text,
+			Tokens:  EstimateTokens(text),
+		})
+		current = nil
+		currentTokens = 0
+	}
+
+	for _, p := range splitParagraphs(content, pages) {
+		if len(current) > 0 && current[0].section != p.section {
+			flush()
+		}
+		for _, piece := range splitLongText(p.text, opts.MaxTokens) {
+			tokens := EstimateTokens(piece)
+			if currentTokens > 0 && currentTokens+tokens > opts.TargetTokens {
+				flush()
+			}
+			current = append(current, paragraph{section: p.section, page: p.page, text: piece})
+			currentTokens += tokens
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitParagraphs 按空行切分段落，记录所属章节（最近的标题）和页码
+func splitParagraphs(content string, pages *pageIndex) []paragraph {
+	var paragraphs []paragraph
+	var lines []string
+	section := ""
+
+	emit := func() {
+		text := strings.TrimSpace(strings.Join(lines, "\n"))
+		lines = nil
+		if text == "" {
+			return
+		}
+		paragraphs = append(paragraphs, paragraph{section: section, page: pages.lookup(text), text: text})
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			emit()
+		case headingPattern.MatchString(trimmed):
+			emit()
+			section = headingPattern.FindStringSubmatch(trimmed)[1]
+			pages.lookup(section) // 标题同样推进页码游标
+		case imagePattern.MatchString(trimmed):
+			// 图片引用不参与检索
+		default:
+			lines = append(lines, line)
+		}
+	}
+	emit()
+	return paragraphs
+}
+
+// splitLongText 将超过maxTokens的文本按句子边界拆分
+func splitLongText(text string, maxTokens int) []string {
+	if EstimateTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	var pieces []string
+	var builder strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(builder.String()); s != "" {
+			pieces = append(pieces, s)
+		}
+		builder.Reset()
+	}
+
+	for _, sentence := range splitSentences(text) {
+		if builder.Len() > 0 && EstimateTokens(builder.String()+sentence) > maxTokens {
+			flush()
+		}
+		// 单句仍然过长时按字符硬切
+		for EstimateTokens(sentence) > maxTokens {
+			cut := cutByTokens(sentence, maxTokens)
+			builder.WriteString(sentence[:cut])
+			flush()
+			sentence = sentence[cut:]
+		}
+		builder.WriteString(sentence)
+	}
+	flush()
+	return pieces
+}
+
+// splitSentences 在句末标点后切分，保留标点
+func splitSentences(text string) []string {
+	var sentences []string
+	last := 0
+	for _, loc := range sentenceEnd.FindAllStringIndex(text, -1) {
+		sentences = append(sentences, text[last:loc[1]])
+		last = loc[1]
+	}
+	if last < len(text) {
+		sentences = append(sentences, text[last:])
+	}
+	return sentences
+}
+
+// cutByTokens 返回不超过maxTokens的最长前缀的字节位置（至少一个字符）
+func cutByTokens(text string, maxTokens int) int {
+	cjk, other := 0, 0
+	for i, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+		if i > 0 && cjk+(other+3)/4 > maxTokens {
+			return i
+		}
+	}
+	return len(text)
+}
+
+// pageIndex MinerU content_list中按顺序排列的文本块及其页码
+type pageIndex struct {
+	entries []pageEntry
+	cursor  int
+	page    int
+}
+
+type pageEntry struct {
+	key  string
+	page int
+}
+
+// loadPageIndex 读取结果目录中的*_content_list.json，不存在时返回nil（页码未知）
+func loadPageIndex(dir string) *pageIndex {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*content_list.json"))
+	if len(matches) == 0 {
+		return nil
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		return nil
+	}
+
+	var blocks []struct {
+		Type    string `json:"type"`
+		Text    string `json:"text"`
+		PageIdx int    `json:"page_idx"`
+	}
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return nil
+	}
+
+	index := &pageIndex{}
+	for _, block := range blocks {
+		if key := pageKey(block.Text); key != "" {
+			index.entries = append(index.entries, pageEntry{key: key, page: block.PageIdx + 1})
+		}
+	}
+	return index
+}
+
+// 段落与content_list块匹配参数：向后查找的最大块数、前缀匹配的最短长度（字节）
+const (
+	pageLookahead = 50
+	minPageKeyLen = 8
+)
+
+// lookup 顺序匹配段落对应的content_list块，匹配不到时沿用上一段的页码
+func (p *pageIndex) lookup(text string) int {
+	if p == nil {
+		return 0
+	}
+	key := pageKey(text)
+	if key == "" {
+		return p.page
+	}
+	for i := p.cursor; i < len(p.entries) && i < p.cursor+pageLookahead; i++ {
+		entry := p.entries[i]
+		if entry.key == key || (len(key) >= minPageKeyLen && len(entry.key) >= minPageKeyLen &&
+			(strings.HasPrefix(entry.key, key) || strings.HasPrefix(key, entry.key))) {
+			p.cursor = i + 1
+			p.page = entry.page
+			break
+		}
+	}
+	if p.page == 0 && len(p.entries) > 0 {
+		p.page = p.entries[0].page
+	}
+	return p.page
+}
+
+// pageKey 去掉空白和Markdown符号后的前20个字符，用于段落与content_list块的比对
+func pageKey(text string) string {
+	var builder strings.Builder
+	n := 0
+	for _, r := range text {
+		if unicode.IsSpace(r) || strings.ContainsRune("#*_`$>|-", r) {
+			continue
+		}
+		builder.WriteRune(unicode.ToLower(r))
+		n++
+		if n >= 20 {
+			break
+		}
+	}
+	return builder.String()
+}
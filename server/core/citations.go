@@ -0,0 +1,191 @@
+package core
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Citation 答案中[编号]引用对应的文献片段位置
+type Citation struct {
+	Number   int    `json:"number"`
+	ChunkID  string `json:"chunk_id"`
+	ItemKey  string `json:"item_key,omitempty"`
+	Document string `json:"document"`
+	Title    string `json:"title"`
+	Section  string `json:"section,omitempty"`
+	Page     int    `json:"page,omitempty"`
+	PageEnd  int    `json:"page_end,omitempty"`
+	Quote    string `json:"quote"`         // 片段开头的摘录
+	URL      string `json:"url,omitempty"` // 由Web层填写的PDF页面链接
+}
+
+// CitationFlag 校验未通过的句子
+type CitationFlag struct {
+	Sentence string `json:"sentence"`
+	Reason   string `json:"reason"`
+}
+
+// 引用校验参数
+const (
+	// minSupportRatio 句子词项在所引片段中出现的最低比例
+	minSupportRatio = 0.3
+	// minCheckedSentenceRunes 短于此长度的句子（如过渡语）不校验
+	minCheckedSentenceRunes = 12
+	// citationQuoteRunes 引用摘录长度
+	citationQuoteRunes = 80
+)
+
+var citationMarker = regexp.MustCompile(`\[(\d+(?:\s*[,，]\s*\d+)*)\]`)
+
+// citationWithSpace 连同前导空白的引用标记，用于从句子中去除引用
+var citationWithSpace = regexp.MustCompile(`\s*\[\d+(?:\s*[,，]\s*\d+)*\]`)
+
+// leadingCitations 句首的引用标记
+var leadingCitations = regexp.MustCompile(`^\s*(?:\[\d+(?:\s*[,，]\s*\d+)*\]\s*)+`)
+
+// CitationsFromChunks 按FormatChunkContext的编号生成引用列表
+func CitationsFromChunks(chunks []RetrievedChunk) []Citation {
+	citations := make([]Citation, len(chunks))
+	for i, chunk := range chunks {
+		citations[i] = Citation{
+			Number:   i + 1,
+			ChunkID:  chunk.ID,
+			ItemKey:  chunk.ItemKey,
+			Document: chunk.Document,
+			Title:    chunk.Title,
+			Section:  chunk.Section,
+			Page:     chunk.Page,
+			PageEnd:  chunk.PageEnd,
+			Quote:    truncateRunes(strings.Join(strings.Fields(chunk.Text), " "), citationQuoteRunes),
+		}
+	}
+	return citations
+}
+
+// CitedNumbers 提取文本中的引用编号，支持[1]、[1][2]和[1, 2]写法
+func CitedNumbers(text string) []int {
+	var numbers []int
+	for _, match := range citationMarker.FindAllStringSubmatch(text, -1) {
+		for _, part := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == '，' || r == ' ' }) {
+			if n, err := strconv.Atoi(part); err == nil {
+				numbers = append(numbers, n)
+			}
+		}
+	}
+	return numbers
+}
+
+// VerifyCitations 逐句校验答案：没有引用、引用编号无效或所引片段不包含句中内容的句子会被标记
+//
+// 按词项重叠判断，只能发现明显无据的句子，不能替代人工核对
+func VerifyCitations(answer string, chunks []RetrievedChunk) []CitationFlag {
+	if len(chunks) == 0 {
+		return nil
+	}
+	chunkTerms := make([]map[string]bool, len(chunks))
+	for i, chunk := range chunks {
+		chunkTerms[i] = make(map[string]bool)
+		for _, term := range tokenize(chunk.Section + "\n" + chunk.Text) {
+			chunkTerms[i][term] = true
+		}
+	}
+
+	var flags []CitationFlag
+	for _, sentence := range answerSentences(answer) {
+		numbers := CitedNumbers(sentence)
+		text := strings.TrimSpace(citationWithSpace.ReplaceAllString(sentence, ""))
+		if utf8.RuneCountInString(text) < minCheckedSentenceRunes {
+			continue
+		}
+
+		if len(numbers) == 0 {
+			flags = append(flags, CitationFlag{Sentence: text, Reason: "没有引用来源"})
+			continue
+		}
+
+		supported := make(map[string]bool)
+		invalid := false
+		for _, n := range numbers {
+			if n < 1 || n > len(chunks) {
+				invalid = true
+				continue
+			}
+			for term := range chunkTerms[n-1] {
+				supported[term] = true
+			}
+		}
+		if invalid {
+			flags = append(flags, CitationFlag{Sentence: text, Reason: "引用编号不存在"})
+			continue
+		}
+
+		terms := uniqueTerms(tokenize(text))
+		if len(terms) == 0 {
+			continue
+		}
+		matched := 0
+		for _, term := range terms {
+			if supported[term] {
+				matched++
+			}
+		}
+		if float64(matched)/float64(len(terms)) < minSupportRatio {
+			flags = append(flags, CitationFlag{Sentence: text, Reason: "所引片段不支持该内容"})
+		}
+	}
+	return flags
+}
+
+// answerSentences 将答案按行和句末标点切分，跳过标题和引用列表
+func answerSentences(answer string) []string {
+	var sentences []string
+	for _, line := range strings.Split(answer, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "```") {
+			continue
+		}
+		line = strings.TrimLeft(line, "-*> ")
+		for _, sentence := range splitSentences(line) {
+			// 句末引用可能写在标点之后，如"……。[1]"，并入前一句
+			if lead := leadingCitations.FindString(sentence); lead != "" && len(sentences) > 0 {
+				sentences[len(sentences)-1] += strings.TrimSpace(lead)
+				sentence = sentence[len(lead):]
+			}
+			if strings.TrimSpace(sentence) != "" {
+				sentences = append(sentences, sentence)
+			}
+		}
+	}
+	return sentences
+}
+
+// truncateRunes 按字符数截断
+func truncateRunes(text string, n int) string {
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n]) + "…"
+	}
+	return text
+}
+
+// QueryCoverage 问题中的词项在检索片段中出现的比例，用于判断本地文献能否回答该问题
+func QueryCoverage(query string, chunks []RetrievedChunk) float64 {
+	terms := uniqueTerms(tokenize(query))
+	if len(terms) == 0 {
+		return 0
+	}
+	found := make(map[string]bool)
+	for _, chunk := range chunks {
+		for _, term := range tokenize(chunk.Section + "\n" + chunk.Text) {
+			found[term] = true
+		}
+	}
+	matched := 0
+	for _, term := range terms {
+		if found[term] {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(terms))
+}
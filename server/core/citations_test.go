@@ -0,0 +1,72 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestCitedNumbers(t *testing.T) {
+	got := CitedNumbers("Transformer 使用自注意力[1][3]，并行训练 [2, 4]。参见 [x]")
+	if want := []int{1, 3, 2, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CitedNumbers() = %v, want %v", got, want)
+	}
+}
+
+func TestVerifyCitations(t *testing.T) {
+	chunks := []RetrievedChunk{
+		{Chunk: Chunk{ID: "a#0", Title: "Attention", Section: "Model", Page: 2, Text: "The Transformer uses stacked self-attention and point-wise feed-forward layers."}},
+		{Chunk: Chunk{ID: "a#1", Title: "Attention", Section: "训练", Page: 3, Text: "我们在机器翻译任务上训练模型，使用注意力机制替代循环结构。"}},
+	}
+	answer := `## 结论
+The Transformer uses stacked self-attention layers [1].
+模型在机器翻译任务上训练。[2]
+The model was pretrained on billions of web images [1].
+It achieves state-of-the-art accuracy on every benchmark.
+See the appendix [5] for detailed hyperparameters.
+好的。`
+
+	flags := VerifyCitations(answer, chunks)
+	want := []CitationFlag{
+		{Sentence: "The model was pretrained on billions of web images.", Reason: "所引片段不支持该内容"},
+		{Sentence: "It achieves state-of-the-art accuracy on every benchmark.", Reason: "没有引用来源"},
+		{Sentence: "See the appendix for detailed hyperparameters.", Reason: "引用编号不存在"},
+	}
+	if !reflect.DeepEqual(flags, want) {
+		t.Errorf("VerifyCitations() =\n%+v\nwant\n%+v", flags, want)
+	}
+
+	citations := CitationsFromChunks(chunks)
+	if len(citations) != 2 || citations[1].Number != 2 || citations[1].Page != 3 || citations[1].Section != "训练" {
+		t.Errorf("CitationsFromChunks() = %+v", citations)
+	}
+}
+
+func TestRAGAnswerCitations(t *testing.T) {
+	pipeline := NewRAGPipeline(writeRAGFixture(t))
+	client := &fakeAIClient{reply: "The Transformer uses stacked self-attention and feed-forward layers [1]. It was trained on ImageNet."}
+
+	answer, err := pipeline.Answer(context.Background(), client, "What layers does the Transformer use?", RAGOptions{TopK: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer.Citations) != 1 || answer.Citations[0].ItemKey != "ABCD1234" || answer.Citations[0].Page != 2 {
+		t.Errorf("Citations = %+v", answer.Citations)
+	}
+	if len(answer.Unsupported) != 1 || answer.Unsupported[0].Reason != "没有引用来源" {
+		t.Errorf("Unsupported = %+v", answer.Unsupported)
+	}
+
+	// 指定文献的对话同样附带引用
+	manager := NewAIConversationManager(client, nil)
+	manager.SetRAGPipeline(pipeline)
+	conv, err := manager.StartConversationWithDocument(context.Background(), "Transformer layers",
+		&DocumentContext{DocumentNames: []string{"attention_20240101"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := conv.Messages[len(conv.Messages)-1].Metadata
+	if meta == nil || len(meta.Citations) == 0 || meta.Citations[0].Document != "attention_20240101" {
+		t.Errorf("回复元数据缺少引用: %+v", meta)
+	}
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// compareChunksPerAspect 每篇文献每个比较维度最多选取的片段数
+const compareChunksPerAspect = 2
+
+// CompareAspect 比较维度：优先选取章节名匹配的片段，没有时按查询检索
+type CompareAspect struct {
+	Name     string   `json:"name"`
+	Query    string   `json:"query"`
+	Sections []string `json:"sections"` // 章节名关键词（小写）
+}
+
+// DefaultCompareAspects 默认比较维度
+var DefaultCompareAspects = []CompareAspect{
+	{Name: "研究问题", Query: "problem motivation objective contribution 研究问题 目标 贡献", Sections: []string{"abstract", "introduction", "motivation", "摘要", "引言", "背景"}},
+	{Name: "方法", Query: "method approach model architecture algorithm 方法 模型 算法", Sections: []string{"method", "approach", "model", "architecture", "方法", "模型"}},
+	{Name: "数据集", Query: "dataset data corpus benchmark training data 数据集 数据", Sections: []string{"dataset", "data", "corpus", "benchmark", "数据"}},
+	{Name: "实验结果", Query: "results performance accuracy evaluation score 结果 性能 准确率", Sections: []string{"result", "experiment", "evaluation", "实验", "结果"}},
+	{Name: "局限性", Query: "limitation weakness future work 局限 不足 未来工作", Sections: []string{"limitation", "discussion", "conclusion", "future", "局限", "讨论", "结论"}},
+}
+
+// ComparisonPaper 参与比较的文献
+type ComparisonPaper struct {
+	Document string `json:"document"`
+	ItemKey  string `json:"item_key,omitempty"`
+	Title    string `json:"title"`
+}
+
+// ComparisonRow 比较表的一行，Cells与Papers一一对应，以[编号]引用片段
+type ComparisonRow struct {
+	Aspect string   `json:"aspect"`
+	Cells  []string `json:"cells"`
+}
+
+// Comparison 多篇文献的对照比较
+type Comparison struct {
+	Papers      []ComparisonPaper `json:"papers"`
+	Rows        []ComparisonRow   `json:"rows"`
+	Differences []string          `json:"differences"`
+	Summary     string            `json:"summary"`
+	Citations   []Citation        `json:"citations"`
+	Unsupported []CitationFlag    `json:"unsupported,omitempty"`
+	Usage       UsageInfo         `json:"usage"`
+	// ConversationID 保存比较结果的对话，可继续追问
+	ConversationID string `json:"conversation_id,omitempty"`
+}
+
+// ResolveDocument 按解析结果目录名或Zotero条目Key查找解析结果
+func (m *AIConversationManager) ResolveDocument(ref string) (*ParsedResult, error) {
+	if result, err := GetParsedResult(m.rag.ResultsDir(), ref); err == nil {
+		return result, nil
+	}
+	if m.zoteroDB != nil {
+		if item, err := m.zoteroDB.GetItemByKey(ref); err == nil {
+			if result, err := FindParsedResult(m.rag.ResultsDir(), item); err == nil && result != nil {
+				return result, nil
+			}
+			return nil, fmt.Errorf("文献尚未解析: %s", item.Title)
+		}
+	}
+	return nil, fmt.Errorf("未找到解析结果或条目: %s", ref)
+}
+
+// buildComparisonContext 为每篇文献按比较维度选取片段，各篇平分文献上下文预算
+func (m *AIConversationManager) buildComparisonContext(ctx context.Context, results []ParsedResult, aspects []CompareAspect) (*DocumentContext, error) {
+	perPaper := (m.budget.DocumentBudget() - EstimateTokens(m.buildSystemPrompt(nil))) / len(results)
+	docCtx := &DocumentContext{Query: "比较文献", Relevance: 0.9}
+
+	for _, result := range results {
+		chunks, err := m.rag.Chunks(result.Name)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunks) == 0 {
+			return nil, fmt.Errorf("文献 %s 没有可比较的内容", result.Name)
+		}
+
+		seen := make(map[string]bool)
+		var selected []RetrievedChunk
+		add := func(chunk RetrievedChunk) {
+			if !seen[chunk.ID] {
+				seen[chunk.ID] = true
+				selected = append(selected, chunk)
+			}
+		}
+		for _, aspect := range aspects {
+			matched := 0
+			for _, chunk := range chunks {
+				if matched < compareChunksPerAspect && sectionMatches(chunk.Section, aspect.Sections) {
+					add(RetrievedChunk{Chunk: chunk})
+					matched++
+				}
+			}
+			if matched > 0 {
+				continue
+			}
+			retrieved, err := m.rag.Retrieve(ctx, aspect.Query, RAGOptions{TopK: compareChunksPerAspect, Documents: []string{result.Name}})
+			if err != nil {
+				return nil, err
+			}
+			for _, chunk := range retrieved {
+				add(chunk)
+			}
+		}
+		if len(selected) == 0 {
+			// 各维度都没有命中时使用开头的片段
+			for _, chunk := range chunks {
+				add(RetrievedChunk{Chunk: chunk})
+			}
+		}
+
+		docCtx.DocumentNames = append(docCtx.DocumentNames, result.Name)
+		docCtx.Chunks = append(docCtx.Chunks, PackChunks(selected, perPaper)...)
+	}
+	docCtx.Documents = m.rag.Summaries(docCtx.Chunks)
+	return docCtx, nil
+}
+
+// sectionMatches 章节名是否包含任一关键词
+func sectionMatches(section string, keywords []string) bool {
+	section = strings.ToLower(section)
+	if section == "" {
+		return false
+	}
+	for _, keyword := range keywords {
+		if strings.Contains(section, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare 对照比较多篇文献：按维度对齐的表格和带引用的差异说明，结果保存为可继续追问的对话
+//
+// refs为解析结果目录名或Zotero条目Key；aspects为空时使用DefaultCompareAspects
+func (m *AIConversationManager) Compare(ctx context.Context, refs []string, aspects []CompareAspect) (*Comparison, error) {
+	ctx = WithUsageFeature(ctx, FeatureCompare)
+	if len(refs) < 2 {
+		return nil, fmt.Errorf("至少需要两篇文献才能比较")
+	}
+	if m.client == nil {
+		return nil, fmt.Errorf("AI功能未配置")
+	}
+	if len(aspects) == 0 {
+		aspects = DefaultCompareAspects
+	}
+
+	comparison := &Comparison{}
+	var results []ParsedResult
+	for _, ref := range refs {
+		result, err := m.ResolveDocument(ref)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+		paper := ComparisonPaper{Document: result.Name, Title: result.Title()}
+		if result.Info != nil {
+			paper.ItemKey = result.Info.ItemKey
+		}
+		comparison.Papers = append(comparison.Papers, paper)
+	}
+
+	docCtx, err := m.buildComparisonContext(ctx, results, aspects)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt := m.buildSystemPrompt(docCtx)
+	prompt := comparisonPrompt(comparison.Papers, aspects)
+	messages := []ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: prompt},
+	}
+
+	resp, err := m.client.Chat(ctx, &AIRequest{Messages: messages, Temperature: 0.2})
+	if err != nil {
+		return nil, fmt.Errorf("AI比较失败: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("AI响应为空")
+	}
+	reply := resp.Choices[0].Message.Content
+	comparison.Usage = resolveUsage(resp.Usage, messages, reply)
+
+	var parsed struct {
+		Rows []struct {
+			Aspect string   `json:"aspect"`
+			Cells  []string `json:"cells"`
+		} `json:"rows"`
+		Differences []string `json:"differences"`
+		Summary     string   `json:"summary"`
+	}
+	if err := decodeJSONReply(reply, &parsed); err != nil {
+		return nil, fmt.Errorf("解析比较结果失败: %w", err)
+	}
+	if len(parsed.Rows) == 0 {
+		return nil, fmt.Errorf("比较结果中没有表格内容")
+	}
+
+	// 对齐表格：每行的单元格数与文献数一致
+	for _, row := range parsed.Rows {
+		cells := make([]string, len(comparison.Papers))
+		for i := range cells {
+			cells[i] = "—"
+			if i < len(row.Cells) && strings.TrimSpace(row.Cells[i]) != "" {
+				cells[i] = strings.TrimSpace(row.Cells[i])
+			}
+		}
+		comparison.Rows = append(comparison.Rows, ComparisonRow{Aspect: row.Aspect, Cells: cells})
+	}
+	for _, difference := range parsed.Differences {
+		if difference = strings.TrimSpace(difference); difference != "" {
+			comparison.Differences = append(comparison.Differences, difference)
+		}
+	}
+	comparison.Summary = strings.TrimSpace(parsed.Summary)
+	comparison.Citations = CitationsFromChunks(docCtx.Chunks)
+	narrative := append(append([]string{}, comparison.Differences...), comparison.Summary)
+	comparison.Unsupported = VerifyCitations(strings.Join(narrative, "\n"), docCtx.Chunks)
+
+	conv, err := m.saveComparison(docCtx, systemPrompt, comparison)
+	if err != nil {
+		return nil, err
+	}
+	comparison.ConversationID = conv.ID
+	return comparison, nil
+}
+
+// comparisonPrompt 要求模型输出按维度对齐的JSON
+func comparisonPrompt(papers []ComparisonPaper, aspects []CompareAspect) string {
+	var sb strings.Builder
+	sb.WriteString("请对照比较以下文献：\n")
+	for i, paper := range papers {
+		sb.WriteString(fmt.Sprintf("文献%d：《%s》\n", i+1, paper.Title))
+	}
+	names := make([]string, len(aspects))
+	for i, aspect := range aspects {
+		names[i] = aspect.Name
+	}
+	sb.WriteString(fmt.Sprintf(`
+比较维度：%s
+要求：
+- rows中每个维度一行，cells按上面的文献顺序各给一段简洁描述（50字以内），片段中没有相关信息时写“未提及”
+- differences列出3-6条关键差异，说明各篇的不同做法或结论
+- summary用一段话总结各篇的适用场景和取舍
+- 所有描述都要在句末用[编号]引用上面的文献片段
+只输出JSON：{"rows": [{"aspect": "维度", "cells": ["文献1", "文献2"]}], "differences": ["..."], "summary": "..."}`, strings.Join(names, "、")))
+	return sb.String()
+}
+
+// saveComparison 将比较结果保存为对话，后续追问沿用比较时选取的文献
+func (m *AIConversationManager) saveComparison(docCtx *DocumentContext, systemPrompt string, comparison *Comparison) (*Conversation, error) {
+	titles := make([]string, len(comparison.Papers))
+	for i, paper := range comparison.Papers {
+		titles[i] = paper.Title
+	}
+	now := time.Now()
+	usage := comparison.Usage
+	conv := &Conversation{
+		ID:      newConversationID(),
+		Title:   truncateRunes("比较: "+strings.Join(titles, " vs "), conversationTitleRunes),
+		Context: docCtx,
+		Messages: []ChatMessage{
+			{Role: "system", Content: systemPrompt, Timestamp: now},
+			{Role: "user", Content: "比较以下文献：" + strings.Join(titles, "；"), Timestamp: now,
+				Metadata: &MessageMetadata{QueryType: "compare"}},
+			{Role: "assistant", Content: comparison.Markdown(), Timestamp: now,
+				Metadata: &MessageMetadata{
+					Chunks:      docCtx.Chunks,
+					Citations:   comparison.Citations,
+					Unsupported: comparison.Unsupported,
+					Usage:       &usage,
+				}},
+		},
+		Usage:     usage,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := m.save(conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// Markdown 渲染比较表、关键差异和总结
+func (c *Comparison) Markdown() string {
+	escape := func(text string) string {
+		return strings.Join(strings.Fields(strings.ReplaceAll(text, "|", "\\|")), " ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| 维度 |")
+	for i, paper := range c.Papers {
+		sb.WriteString(fmt.Sprintf(" 文献%d：%s |", i+1, escape(paper.Title)))
+	}
+	sb.WriteString("\n|---|" + strings.Repeat("---|", len(c.Papers)) + "\n")
+	for _, row := range c.Rows {
+		sb.WriteString("| " + escape(row.Aspect) + " |")
+		for _, cell := range row.Cells {
+			sb.WriteString(" " + escape(cell) + " |")
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(c.Differences) > 0 {
+		sb.WriteString("\n## 关键差异\n\n")
+		for _, difference := range c.Differences {
+			sb.WriteString("- " + difference + "\n")
+		}
+	}
+	if c.Summary != "" {
+		sb.WriteString("\n## 总结\n\n" + c.Summary + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// writeCompareFixture 在RAG测试结果之外再写入一篇带方法、数据和结果章节的文献
+func writeCompareFixture(t *testing.T) string {
+	t.Helper()
+	resultsDir := writeRAGFixture(t)
+	writeParsedResult(t, resultsDir, "bert_20240102", "BERT", `# BERT
+
+## Introduction
+
+Language model pretraining improves many tasks.
+
+## Method
+
+BERT pretrains a bidirectional Transformer encoder with masked language modeling.
+
+## Datasets
+
+We pretrain on BooksCorpus and English Wikipedia.
+
+## Results
+
+BERT obtains new state-of-the-art results on GLUE.
+`)
+	return resultsDir
+}
+
+func TestCompareDocuments(t *testing.T) {
+	resultsDir := writeCompareFixture(t)
+	client := &fakeAIClient{reply: "```json\n" + `{"rows": [
+		{"aspect": "方法", "cells": ["堆叠自注意力 [2]", "双向编码器预训练 [5]"]},
+		{"aspect": "数据集", "cells": ["机器翻译 [3]"]}
+	], "differences": ["Transformer面向翻译，BERT面向预训练 [2][5]"], "summary": "两者都基于Transformer [2][5]。"}` + "\n```"}
+
+	manager := NewAIConversationManager(client, newFixtureZoteroDB(t))
+	manager.SetRAGPipeline(NewRAGPipeline(resultsDir))
+	manager.SetConversationStore(NewConversationStore(t.TempDir()))
+
+	comparison, err := manager.Compare(context.Background(), []string{"ABCD1234", "bert_20240102"}, nil)
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	if len(comparison.Papers) != 2 || comparison.Papers[0].Document != "attention_20240101" || comparison.Papers[0].ItemKey != "ABCD1234" {
+		t.Errorf("应按条目Key找到解析结果: %+v", comparison.Papers)
+	}
+	if got := comparison.Rows[1].Cells; len(got) != 2 || got[1] != "—" {
+		t.Errorf("缺少的单元格应补齐: %q", got)
+	}
+	if comparison.Usage.TotalTokens == 0 {
+		t.Error("应记录token用量")
+	}
+
+	// 按章节名为各维度选取片段
+	system := client.requests[0].Messages[0].Content
+	for _, want := range []string{"§Model Architecture", "§Method", "§Datasets", "§Results", "文献片段"} {
+		if !strings.Contains(system, want) {
+			t.Errorf("系统提示缺少 %q", want)
+		}
+	}
+	if !strings.Contains(client.requests[0].Messages[1].Content, "文献2：《BERT》") {
+		t.Errorf("比较提示 = %s", client.requests[0].Messages[1].Content)
+	}
+
+	md := comparison.Markdown()
+	if !strings.Contains(md, "| 维度 | 文献1：Attention Is All You Need | 文献2：BERT |") || !strings.Contains(md, "## 关键差异") {
+		t.Errorf("Markdown() = %s", md)
+	}
+
+	conv, err := manager.GetConversation(comparison.ConversationID)
+	if err != nil {
+		t.Fatalf("比较结果应保存为对话: %v", err)
+	}
+	if len(conv.Messages) != 3 || !strings.HasPrefix(conv.Title, "比较: ") || len(conv.Context.DocumentNames) != 2 {
+		t.Errorf("对话 = %+v", conv.Summary())
+	}
+
+	if _, err := manager.Compare(context.Background(), []string{"bert_20240102"}, nil); err == nil {
+		t.Error("少于两篇文献时应返回错误")
+	}
+	if _, err := manager.Compare(context.Background(), []string{"bert_20240102", "missing"}, nil); err == nil {
+		t.Error("找不到文献时应返回错误")
+	}
+}
@@ -0,0 +1,319 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// 上下文预算默认参数
+const (
+	// DefaultContextWindow 未知模型使用的上下文窗口
+	DefaultContextWindow = 32000
+	// defaultReservedOutput 为模型回复预留的token数
+	defaultReservedOutput = 4096
+	// defaultDocumentShare 文献上下文最多占用可用预算的比例
+	defaultDocumentShare = 0.5
+	// defaultRecentMessages 压缩历史时原样保留的最近消息数
+	defaultRecentMessages = 6
+	// historySummaryRunes 历史摘要的目标长度
+	historySummaryRunes = 800
+	// messageOverheadTokens 每条消息的角色等格式开销
+	messageOverheadTokens = 4
+)
+
+// modelContextWindows 常见模型的上下文窗口，按前缀匹配，较长的前缀在前
+var modelContextWindows = []struct {
+	prefix string
+	window int
+}{
+	{"glm-4.6", 200000},
+	{"glm-4.5", 128000},
+	{"glm-4-long", 1000000},
+	{"glm-4", 128000},
+	{"glm-3", 128000},
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 1000000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"deepseek", 64000},
+	{"qwen", 32000},
+	{"moonshot-v1-8k", 8000},
+	{"moonshot-v1-32k", 32000},
+	{"moonshot-v1-128k", 128000},
+}
+
+// ContextBudget 单次请求的token预算
+type ContextBudget struct {
+	ContextWindow  int     `json:"context_window"`  // 模型上下文窗口
+	ReservedOutput int     `json:"reserved_output"` // 为回复预留的token
+	DocumentShare  float64 `json:"document_share"`  // 文献上下文最多占可用预算的比例
+	RecentMessages int     `json:"recent_messages"` // 压缩历史时原样保留的最近消息数
+}
+
+// BudgetForModel 按模型名称选择上下文窗口，未知模型使用DefaultContextWindow
+func BudgetForModel(model string) ContextBudget {
+	window := DefaultContextWindow
+	name := strings.ToLower(model)
+	for _, known := range modelContextWindows {
+		if strings.HasPrefix(name, known.prefix) {
+			window = known.window
+			break
+		}
+	}
+	return NewContextBudget(window)
+}
+
+// ResolveContextBudget 配置了上下文窗口时使用配置值，否则按模型名称推断
+func ResolveContextBudget(model string, window int) ContextBudget {
+	if window > 0 {
+		return NewContextBudget(window)
+	}
+	return BudgetForModel(model)
+}
+
+// NewContextBudget 按上下文窗口创建预算，回复预留不超过窗口的四分之一
+func NewContextBudget(window int) ContextBudget {
+	if window <= 0 {
+		window = DefaultContextWindow
+	}
+	return ContextBudget{
+		ContextWindow:  window,
+		ReservedOutput: min(defaultReservedOutput, window/4),
+		DocumentShare:  defaultDocumentShare,
+		RecentMessages: defaultRecentMessages,
+	}
+}
+
+// Available 可用于输入消息的token数
+func (b ContextBudget) Available() int {
+	return max(b.ContextWindow-b.ReservedOutput, 0)
+}
+
+// DocumentBudget 文献上下文可用的token数
+func (b ContextBudget) DocumentBudget() int {
+	return int(float64(b.Available()) * b.DocumentShare)
+}
+
+// EstimateMessagesTokens 估算一组消息的token数
+func EstimateMessagesTokens(messages []ChatMessage) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateTokens(msg.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// fitChunks 按检索得分顺序装入片段，超出预算的片段被丢弃
+func fitChunks(chunks []RetrievedChunk, budget int) []RetrievedChunk {
+	var fitted []RetrievedChunk
+	used := 0
+	for _, chunk := range chunks {
+		// 片段在提示中带有编号和出处
+		tokens := EstimateTokens(chunk.Citation()+"\n"+chunk.Text) + messageOverheadTokens
+		if used+tokens > budget {
+			continue
+		}
+		fitted = append(fitted, chunk)
+		used += tokens
+	}
+	return fitted
+}
+
+// fitDocumentSummaries 按顺序装入文献摘要：先缩短摘要、去掉关键词，仍超出时丢弃靠后的文献
+func fitDocumentSummaries(docs []DocumentSummary, budget int) ([]DocumentSummary, bool) {
+	total := 0
+	for i, doc := range docs {
+		total += EstimateTokens(formatDocumentSummary(i, doc))
+	}
+	if total <= budget {
+		return docs, false
+	}
+
+	var fitted []DocumentSummary
+	used := 0
+	for _, doc := range docs {
+		doc.Abstract = truncateRunes(doc.Abstract, 200)
+		doc.Keywords = nil
+		tokens := EstimateTokens(formatDocumentSummary(len(fitted), doc))
+		if used+tokens > budget {
+			break
+		}
+		fitted = append(fitted, doc)
+		used += tokens
+	}
+	return fitted, true
+}
+
+// fitDocumentContext 将文献上下文裁剪到预算内，返回是否发生了裁剪
+//
+// 用户批注和笔记最先装入（合计最多占预算的annotationContextShare，批注优先）；片段优先于文献摘要：
+// 有片段时按得分保留，否则按相关度保留文献摘要
+func fitDocumentContext(docCtx *DocumentContext, budget int) bool {
+	if docCtx == nil {
+		return false
+	}
+	trimmed := false
+	userBudget := int(float64(budget) * annotationContextShare)
+	if len(docCtx.Annotations) > 0 {
+		fitted, used := fitAnnotations(docCtx.Annotations, userBudget)
+		if len(fitted) < len(docCtx.Annotations) {
+			log.Printf("✂️ 批注超出预算，保留 %d/%d 条", len(fitted), len(docCtx.Annotations))
+			docCtx.Annotations = fitted
+			trimmed = true
+		}
+		userBudget -= used
+		budget -= used
+	}
+	if len(docCtx.Notes) > 0 {
+		fitted, used, notesTrimmed := fitNotes(docCtx.Notes, userBudget)
+		if notesTrimmed {
+			log.Printf("✂️ 笔记超出预算，保留 %d/%d 篇", len(fitted), len(docCtx.Notes))
+			docCtx.Notes = fitted
+			trimmed = true
+		}
+		budget -= used
+	}
+	if len(docCtx.Chunks) > 0 {
+		fitted := fitChunks(docCtx.Chunks, budget)
+		if len(fitted) == len(docCtx.Chunks) {
+			return trimmed
+		}
+		log.Printf("✂️ 文献片段超出预算，保留 %d/%d 个", len(fitted), len(docCtx.Chunks))
+		docCtx.Chunks = fitted
+		return true
+	}
+	if len(docCtx.Documents) > 0 {
+		fitted, summariesTrimmed := fitDocumentSummaries(docCtx.Documents, budget)
+		if !summariesTrimmed {
+			return trimmed
+		}
+		log.Printf("✂️ 文献摘要超出预算，保留 %d/%d 篇", len(fitted), len(docCtx.Documents))
+		docCtx.Documents = fitted
+		return true
+	}
+	return trimmed
+}
+
+// requestMessages 生成发送给模型的消息：系统提示 + 历史摘要 + 最近的消息
+//
+// 超出预算时先把较早的轮次压缩为摘要，仍超出时丢弃最早的消息（至少保留最后一条）
+func (m *AIConversationManager) requestMessages(ctx context.Context, conv *Conversation) []ChatMessage {
+	var system *ChatMessage
+	start := 0
+	if len(conv.Messages) > 0 && conv.Messages[0].Role == "system" {
+		system = &conv.Messages[0]
+		start = 1
+	}
+	start = max(start, conv.SummarizedUntil)
+
+	available := m.budget.Available()
+	build := func(history []ChatMessage) []ChatMessage {
+		var messages []ChatMessage
+		if system != nil || conv.HistorySummary != "" {
+			prompt := ""
+			if system != nil {
+				prompt = system.Content
+			}
+			if conv.HistorySummary != "" {
+				prompt += "\n\n=== 此前对话摘要 ===\n" + conv.HistorySummary
+			}
+			messages = append(messages, ChatMessage{Role: "system", Content: strings.TrimSpace(prompt)})
+		}
+		return append(messages, history...)
+	}
+
+	messages := build(conv.Messages[start:])
+	if EstimateMessagesTokens(messages) <= available {
+		return messages
+	}
+
+	// 压缩较早的轮次，保留最近的消息
+	keep := max(m.budget.RecentMessages, 1)
+	if split := len(conv.Messages) - keep; split > start {
+		m.summarizeHistory(ctx, conv, start, split)
+		start = split
+		messages = build(conv.Messages[start:])
+	}
+
+	// 最近的消息本身仍超出预算时，丢弃最早的消息
+	for EstimateMessagesTokens(messages) > available && len(conv.Messages)-start > 1 {
+		start++
+		messages = build(conv.Messages[start:])
+	}
+	return messages
+}
+
+// summarizeHistory 将messages[from:to]并入对话的历史摘要，AI不可用时退化为提问列表
+func (m *AIConversationManager) summarizeHistory(ctx context.Context, conv *Conversation, from, to int) {
+	var transcript strings.Builder
+	for _, msg := range conv.Messages[from:to] {
+		role := "用户"
+		if msg.Role == "assistant" {
+			role = "助手"
+		}
+		transcript.WriteString(fmt.Sprintf("%s: %s\n\n", role, msg.Content))
+	}
+
+	prompt := fmt.Sprintf(`请将下面的对话压缩为不超过%d字的摘要，保留用户关心的问题、已得出的结论、涉及的文献和尚未解决的问题，不要添加对话中没有的信息。`, historySummaryRunes)
+	if conv.HistorySummary != "" {
+		prompt += "\n\n已有摘要：\n" + conv.HistorySummary
+	}
+	prompt += "\n\n对话：\n" + transcript.String()
+
+	summary := ""
+	if m.client != nil {
+		resp, err := m.client.Chat(ctx, &AIRequest{
+			Messages:    []ChatMessage{{Role: "user", Content: prompt}},
+			MaxTokens:   historySummaryRunes * 2,
+			Temperature: 0.2,
+		})
+		if err != nil {
+			log.Printf("⚠️ 压缩对话历史失败，改用提问列表: %v", err)
+		} else if len(resp.Choices) > 0 {
+			summary = strings.TrimSpace(resp.Choices[0].Message.Content)
+			conv.Usage.add(resolveUsage(resp.Usage, []ChatMessage{{Content: prompt}}, summary))
+		}
+	}
+	if summary == "" {
+		summary = fallbackHistorySummary(conv.HistorySummary, conv.Messages[from:to])
+	}
+
+	log.Printf("🗜️ 对话 %s 已压缩 %d 条较早的消息", conv.ID, to-from)
+	conv.HistorySummary = summary
+	conv.SummarizedUntil = to
+}
+
+// fallbackHistorySummary 不调用AI的历史摘要：保留已有摘要并列出用户的提问
+func fallbackHistorySummary(previous string, messages []ChatMessage) string {
+	var lines []string
+	if previous != "" {
+		lines = append(lines, previous)
+	}
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			lines = append(lines, "- 用户曾问: "+truncateRunes(strings.Join(strings.Fields(msg.Content), " "), 100))
+		}
+	}
+	return truncateRunes(strings.Join(lines, "\n"), historySummaryRunes*2)
+}
+
+// add 累加用量
+func (u *UsageInfo) add(other UsageInfo) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// resolveUsage 服务端未返回用量时按估算值补齐
+func resolveUsage(usage UsageInfo, prompt []ChatMessage, completion string) UsageInfo {
+	if usage.TotalTokens > 0 {
+		return usage
+	}
+	usage.PromptTokens = EstimateMessagesTokens(prompt)
+	usage.CompletionTokens = EstimateTokens(completion)
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
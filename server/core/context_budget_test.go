@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBudgetForModel(t *testing.T) {
+	tests := []struct {
+		model  string
+		window int
+	}{
+		{"glm-4.6", 200000},
+		{"GLM-4-Flash", 128000},
+		{"gpt-4", 8192},
+		{"gpt-4o-mini", 128000},
+		{"unknown-model", DefaultContextWindow},
+	}
+	for _, tt := range tests {
+		if got := BudgetForModel(tt.model).ContextWindow; got != tt.window {
+			t.Errorf("BudgetForModel(%q) = %d, want %d", tt.model, got, tt.window)
+		}
+	}
+
+	budget := ResolveContextBudget("glm-4.6", 2000)
+	if budget.ContextWindow != 2000 || budget.ReservedOutput != 500 || budget.Available() != 1500 {
+		t.Errorf("ResolveContextBudget() = %+v", budget)
+	}
+}
+
+func TestFitDocumentContext(t *testing.T) {
+	long := strings.Repeat("注意力机制", 100)
+	docCtx := &DocumentContext{Chunks: []RetrievedChunk{
+		{Chunk: Chunk{ID: "a#0", Title: "A", Text: "short high score chunk"}, Score: 3},
+		{Chunk: Chunk{ID: "a#1", Title: "A", Text: long}, Score: 2},
+		{Chunk: Chunk{ID: "a#2", Title: "A", Text: "another short chunk"}, Score: 1},
+	}}
+	if !fitDocumentContext(docCtx, 100) {
+		t.Fatal("超出预算时应裁剪片段")
+	}
+	if len(docCtx.Chunks) != 2 || docCtx.Chunks[0].ID != "a#0" || docCtx.Chunks[1].ID != "a#2" {
+		t.Errorf("应保留能装入预算的高分片段: %+v", docCtx.Chunks)
+	}
+
+	docs := &DocumentContext{Documents: []DocumentSummary{
+		{Title: "First", Abstract: long, Keywords: []string{"attention"}},
+		{Title: "Second", Abstract: long},
+		{Title: "Third", Abstract: long},
+	}}
+	if !fitDocumentContext(docs, 450) {
+		t.Fatal("超出预算时应裁剪文献摘要")
+	}
+	if len(docs.Documents) != 2 || docs.Documents[0].Title != "First" || docs.Documents[0].Keywords != nil {
+		t.Errorf("应缩短摘要并丢弃靠后的文献: %+v", docs.Documents)
+	}
+	if fitDocumentContext(docs, 100000) {
+		t.Error("预算充足时不应裁剪")
+	}
+}
+
+func TestRollingHistorySummary(t *testing.T) {
+	client := &fakeAIClient{reply: strings.Repeat("这是一段较长的回答。", 20)}
+	manager := NewAIConversationManager(client, nil)
+	manager.SetRAGPipeline(NewRAGPipeline(t.TempDir()))
+	budget := NewContextBudget(1200)
+	budget.RecentMessages = 2
+	manager.SetContextBudget(budget)
+
+	ctx := context.Background()
+	conv, err := manager.StartConversation(ctx, "第一个问题", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, question := range []string{"第二个问题", "第三个问题", "第四个问题", "第五个问题"} {
+		if conv, err = manager.ContinueConversation(ctx, conv.ID, question); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if conv.SummarizedUntil == 0 || conv.HistorySummary == "" {
+		t.Fatalf("超出预算后应压缩历史: until=%d summary=%q", conv.SummarizedUntil, conv.HistorySummary)
+	}
+	if len(conv.Messages) != 11 {
+		t.Errorf("完整历史应保留在对话中, got %d 条", len(conv.Messages))
+	}
+
+	last := client.requests[len(client.requests)-1]
+	if tokens := EstimateMessagesTokens(last.Messages); tokens > budget.Available() {
+		t.Errorf("请求 %d tokens 超出预算 %d", tokens, budget.Available())
+	}
+	if !strings.Contains(last.Messages[0].Content, "此前对话摘要") {
+		t.Error("系统提示应包含历史摘要")
+	}
+	if got := last.Messages[len(last.Messages)-1].Content; got != "第五个问题" {
+		t.Errorf("最后一条消息 = %q", got)
+	}
+
+	// 假客户端不返回用量时按估算累计
+	reply := conv.Messages[len(conv.Messages)-1]
+	if reply.Metadata == nil || reply.Metadata.Usage == nil || reply.Metadata.Usage.TotalTokens == 0 {
+		t.Errorf("回复应记录用量: %+v", reply.Metadata)
+	}
+	if conv.Usage.TotalTokens <= reply.Metadata.Usage.TotalTokens {
+		t.Errorf("对话累计用量 = %+v", conv.Usage)
+	}
+}
@@ -0,0 +1,313 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConversationsDir 默认的对话存储目录
+const DefaultConversationsDir = "data/conversations"
+
+// conversationTitleRunes 自动生成标题时截取的字符数
+const conversationTitleRunes = 40
+
+// validConversationID 对话ID只允许字母、数字、下划线和连字符，避免路径穿越
+var validConversationID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+var (
+	// ErrConversationNotFound 对话不存在
+	ErrConversationNotFound = errors.New("对话不存在")
+	// ErrInvalidConversationID 对话ID包含不允许的字符
+	ErrInvalidConversationID = errors.New("无效的对话ID")
+)
+
+// ConversationSummary 对话列表项
+type ConversationSummary struct {
+	ID            string    `json:"id"`
+	Title         string    `json:"title"`
+	MessageCount  int       `json:"message_count"`
+	DocumentNames []string  `json:"document_names,omitempty"`
+	ForkedFrom    string    `json:"forked_from,omitempty"`
+	Usage         UsageInfo `json:"usage"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ConversationStore 以JSON文件持久化对话，每个对话一个文件：<dir>/<id>.json
+type ConversationStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewConversationStore 创建对话存储
+func NewConversationStore(dir string) *ConversationStore {
+	if dir == "" {
+		dir = DefaultConversationsDir
+	}
+	return &ConversationStore{dir: dir}
+}
+
+// Dir 返回存储目录
+func (s *ConversationStore) Dir() string {
+	return s.dir
+}
+
+// newConversationID 生成对话ID：conv_<时间戳>_<随机后缀>，同一秒内创建也不会冲突
+func newConversationID() string {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("conv_%d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("conv_%d_%s", time.Now().Unix(), hex.EncodeToString(suffix))
+}
+
+// path 返回对话文件路径
+func (s *ConversationStore) path(id string) (string, error) {
+	if !validConversationID.MatchString(id) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidConversationID, id)
+	}
+	return filepath.Join(s.dir, id+".json"), nil
+}
+
+// Save 写入对话（先写临时文件再重命名）
+func (s *ConversationStore) Save(conv *Conversation) error {
+	path, err := s.path(conv.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(conv, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化对话失败: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("创建对话目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.dir, ".conversation-*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入对话失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入对话失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入对话失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Load 读取对话
+func (s *ConversationStore) Load(id string) (*Conversation, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取对话失败: %w", err)
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("解析对话文件失败: %w", err)
+	}
+	return &conv, nil
+}
+
+// List 列出所有对话，最近更新的在前
+func (s *ConversationStore) List() ([]ConversationSummary, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取对话目录失败: %w", err)
+	}
+
+	var summaries []ConversationSummary
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		conv, err := s.Load(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			continue
+		}
+		summaries = append(summaries, conv.Summary())
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].UpdatedAt.After(summaries[j].UpdatedAt) })
+	return summaries, nil
+}
+
+// Delete 删除对话文件
+func (s *ConversationStore) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%w: %s", ErrConversationNotFound, id)
+		}
+		return fmt.Errorf("删除对话失败: %w", err)
+	}
+	return nil
+}
+
+// Summary 生成对话列表项
+func (c *Conversation) Summary() ConversationSummary {
+	summary := ConversationSummary{
+		ID:           c.ID,
+		Title:        c.Title,
+		MessageCount: len(c.Messages),
+		ForkedFrom:   c.ForkedFrom,
+		Usage:        c.Usage,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+	}
+	if c.Context != nil {
+		summary.DocumentNames = c.Context.DocumentNames
+	}
+	if summary.Title == "" {
+		summary.Title = defaultConversationTitle(c.Messages)
+	}
+	return summary
+}
+
+// defaultConversationTitle 以第一条用户消息作为对话标题
+func defaultConversationTitle(messages []ChatMessage) string {
+	for _, msg := range messages {
+		if msg.Role == "user" {
+			return truncateRunes(strings.Join(strings.Fields(msg.Content), " "), conversationTitleRunes)
+		}
+	}
+	return "新对话"
+}
+
+// cloneConversation 深拷贝对话，与持久化时的JSON结构一致
+func cloneConversation(src *Conversation) (*Conversation, error) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return nil, fmt.Errorf("复制对话失败: %w", err)
+	}
+	var conv Conversation
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return nil, fmt.Errorf("复制对话失败: %w", err)
+	}
+	return &conv, nil
+}
+
+// forkConversation 复制对话的前n条消息（n<=0时复制全部）作为新对话
+func forkConversation(src *Conversation, n int) (*Conversation, error) {
+	if n <= 0 || n > len(src.Messages) {
+		n = len(src.Messages)
+	}
+	fork, err := cloneConversation(src)
+	if err != nil {
+		return nil, err
+	}
+
+	title := src.Title
+	if title == "" {
+		title = defaultConversationTitle(src.Messages)
+	}
+	fork.ID = newConversationID()
+	fork.Title = title + "（分支）"
+	fork.ForkedFrom = src.ID
+	fork.Messages = fork.Messages[:n]
+	fork.Usage = UsageInfo{}
+	// 历史摘要覆盖了分支点之后的消息时不能沿用
+	if fork.SummarizedUntil > n {
+		fork.HistorySummary = ""
+		fork.SummarizedUntil = 0
+	}
+	fork.CreatedAt = time.Now()
+	fork.UpdatedAt = fork.CreatedAt
+	return fork, nil
+}
+
+// ExportConversation 导出对话，format为markdown(md)或json
+func ExportConversation(conv *Conversation, format string) ([]byte, error) {
+	switch strings.ToLower(format) {
+	case "", "md", "markdown":
+		return []byte(ConversationMarkdown(conv)), nil
+	case "json":
+		data, err := json.MarshalIndent(conv, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("序列化对话失败: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("不支持的导出格式: %s（可选 md、json）", format)
+	}
+}
+
+// ConversationMarkdown 将对话渲染为Markdown，系统提示不导出，回复附带引用列表
+func ConversationMarkdown(conv *Conversation) string {
+	var sb strings.Builder
+	summary := conv.Summary()
+	sb.WriteString(fmt.Sprintf("# %s\n\n", summary.Title))
+	sb.WriteString(fmt.Sprintf("- 对话ID: %s\n", conv.ID))
+	sb.WriteString(fmt.Sprintf("- 创建时间: %s\n", conv.CreatedAt.Format("2006-01-02 15:04")))
+	if conv.ForkedFrom != "" {
+		sb.WriteString(fmt.Sprintf("- 分支自: %s\n", conv.ForkedFrom))
+	}
+	if len(summary.DocumentNames) > 0 {
+		sb.WriteString(fmt.Sprintf("- 关联文献: %s\n", strings.Join(summary.DocumentNames, ", ")))
+	}
+
+	for _, msg := range conv.Messages {
+		switch msg.Role {
+		case "user":
+			sb.WriteString("\n## 🧑 提问\n\n")
+		case "assistant":
+			sb.WriteString("\n## 🤖 回答\n\n")
+		default:
+			continue
+		}
+		sb.WriteString(strings.TrimSpace(msg.Content))
+		sb.WriteString("\n")
+
+		if msg.Metadata == nil || len(msg.Metadata.Citations) == 0 {
+			continue
+		}
+		cited := make(map[int]bool)
+		for _, n := range CitedNumbers(msg.Content) {
+			cited[n] = true
+		}
+		sb.WriteString("\n**引用**\n\n")
+		for _, citation := range msg.Metadata.Citations {
+			if len(cited) > 0 && !cited[citation.Number] {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%d. %s", citation.Number, citation.Title))
+			if citation.Section != "" {
+				sb.WriteString(" · " + citation.Section)
+			}
+			if citation.Page > 0 {
+				sb.WriteString(fmt.Sprintf(" · 第%d页", citation.Page))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
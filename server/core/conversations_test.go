@@ -0,0 +1,183 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConversationPersistence(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	store := NewConversationStore(t.TempDir())
+	client := &fakeAIClient{reply: "The Transformer uses stacked self-attention layers [1]."}
+
+	manager := NewAIConversationManager(client, nil)
+	manager.SetRAGPipeline(NewRAGPipeline(resultsDir))
+	manager.SetConversationStore(store)
+
+	ctx := context.Background()
+	first, err := manager.StartConversation(ctx, "What layers does the Transformer use?", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := manager.StartConversation(ctx, "另一个问题", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("同一秒内创建的对话ID冲突: %s", first.ID)
+	}
+
+	// 新的管理器从磁盘恢复对话并继续
+	restored := NewAIConversationManager(client, nil)
+	restored.SetRAGPipeline(NewRAGPipeline(resultsDir))
+	restored.SetConversationStore(store)
+	conv, err := restored.ContinueConversation(ctx, first.ID, "How is it trained?")
+	if err != nil {
+		t.Fatalf("ContinueConversation() error = %v", err)
+	}
+	if len(conv.Messages) != 5 {
+		t.Fatalf("消息数 = %d, want 5", len(conv.Messages))
+	}
+	if conv.Title != "What layers does the Transformer use?" {
+		t.Errorf("Title = %q", conv.Title)
+	}
+	reply := conv.Messages[2]
+	if reply.Metadata == nil || len(reply.Metadata.Citations) == 0 || reply.Metadata.Citations[0].ItemKey != "ABCD1234" {
+		t.Errorf("回复元数据未持久化: %+v", reply.Metadata)
+	}
+
+	summaries, err := restored.ListConversations()
+	if err != nil || len(summaries) != 2 || summaries[0].ID != first.ID {
+		t.Fatalf("ListConversations() = %+v, %v", summaries, err)
+	}
+
+	if _, err := restored.RenameConversation(first.ID, "Transformer 结构"); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := store.Load(first.ID)
+	if err != nil || reloaded.Title != "Transformer 结构" {
+		t.Errorf("重命名未写入磁盘: %+v, %v", reloaded, err)
+	}
+
+	fork, err := restored.ForkConversation(first.ID, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.ForkedFrom != first.ID || len(fork.Messages) != 3 || fork.ID == first.ID {
+		t.Errorf("ForkConversation() = %+v", fork.Summary())
+	}
+	fork.Messages[1].Content = "changed"
+	if original, _ := restored.GetConversation(first.ID); original.Messages[1].Content == "changed" {
+		t.Error("分支不应与原对话共享消息")
+	}
+
+	if err := restored.DeleteConversation(second.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(second.ID); err == nil {
+		t.Error("删除后对话文件仍存在")
+	}
+	if err := restored.DeleteConversation(second.ID); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("重复删除应返回 ErrConversationNotFound: %v", err)
+	}
+	if _, err := restored.GetConversation(second.ID); !errors.Is(err, ErrConversationNotFound) {
+		t.Errorf("GetConversation() 已删除的对话: %v", err)
+	}
+	if _, err := store.Load("../etc/passwd"); !errors.Is(err, ErrInvalidConversationID) {
+		t.Errorf("应拒绝无效的对话ID: %v", err)
+	}
+}
+
+// 读取对话与继续对话并发执行时不能读到正在修改的对话（配合 go test -race）
+func TestConversationConcurrentReadAndContinue(t *testing.T) {
+	manager := NewAIConversationManager(&fakeAIClient{reply: "Self-attention [1]."}, nil)
+	manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+	manager.SetConversationStore(NewConversationStore(t.TempDir()))
+
+	ctx := context.Background()
+	conv, err := manager.StartConversation(ctx, "What layers does the Transformer use?", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const rounds = 5
+	var wg sync.WaitGroup
+	for i := 0; i < rounds; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := manager.ContinueConversation(ctx, conv.ID, "How is it trained?"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			snapshot, err := manager.GetConversation(conv.ID)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if _, err := json.Marshal(snapshot); err != nil {
+				t.Error(err)
+			}
+			if _, err := ExportConversation(snapshot, "markdown"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	final, err := manager.GetConversation(conv.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 3 + 2*rounds; len(final.Messages) != want {
+		t.Errorf("消息数 = %d, want %d", len(final.Messages), want)
+	}
+}
+
+func TestExportConversation(t *testing.T) {
+	conv := &Conversation{
+		ID:    "conv_1_abcd",
+		Title: "注意力机制",
+		Messages: []ChatMessage{
+			{Role: "system", Content: "系统提示"},
+			{Role: "user", Content: "Transformer 用了什么层？"},
+			{Role: "assistant", Content: "自注意力层[2]。", Metadata: &MessageMetadata{Citations: []Citation{
+				{Number: 1, Title: "Unused"},
+				{Number: 2, Title: "Attention Is All You Need", Section: "Model", Page: 2},
+			}}},
+		},
+	}
+
+	data, err := ExportConversation(conv, "md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := string(data)
+	for _, want := range []string{"# 注意力机制", "## 🧑 提问", "自注意力层[2]。", "2. Attention Is All You Need · Model · 第2页"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown缺少 %q:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "系统提示") || strings.Contains(md, "Unused") {
+		t.Errorf("Markdown不应包含系统提示和未引用的来源:\n%s", md)
+	}
+
+	data, err = ExportConversation(conv, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Conversation
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.Messages) != 3 {
+		t.Errorf("JSON导出无法还原: %v", err)
+	}
+
+	if _, err := ExportConversation(conv, "pdf"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
@@ -0,0 +1,509 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// embeddingIndexFileName 索引目录下的嵌入索引文件名
+const embeddingIndexFileName = "embeddings.json"
+
+// 嵌入单元类型
+const (
+	EmbeddingKindTitle    = "title"
+	EmbeddingKindAbstract = "abstract"
+	EmbeddingKindChunk    = "chunk"
+)
+
+// EmbeddingDocument 待嵌入的文本单元：文献标题、摘要或全文块
+type EmbeddingDocument struct {
+	ID       string `json:"id"`
+	Kind     string `json:"kind"`
+	ItemKey  string `json:"item_key,omitempty"`
+	Document string `json:"document,omitempty"` // 解析结果目录名
+	Title    string `json:"title"`
+	Section  string `json:"section,omitempty"`
+	Page     int    `json:"page,omitempty"`
+	Text     string `json:"text"`
+}
+
+// groupKey 文献分组键：有Zotero条目Key时用Key，否则用解析结果目录名
+func (d EmbeddingDocument) groupKey() string {
+	if d.ItemKey != "" {
+		return d.ItemKey
+	}
+	return "doc:" + d.Document
+}
+
+// embeddingVector 以base64编码的小端float32序列持久化，比JSON数组紧凑得多
+type embeddingVector []float32
+
+func (v embeddingVector) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return json.Marshal(base64.StdEncoding.EncodeToString(buf))
+}
+
+func (v *embeddingVector) UnmarshalJSON(data []byte) error {
+	var encoded string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(buf)%4 != 0 {
+		return fmt.Errorf("向量编码无效")
+	}
+	*v = make(embeddingVector, len(buf)/4)
+	for i := range *v {
+		(*v)[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return nil
+}
+
+// embeddingEntry 索引中的一条记录
+type embeddingEntry struct {
+	EmbeddingDocument
+	Hash   string          `json:"hash"` // 文本内容哈希，未变化时不重新嵌入
+	Vector embeddingVector `json:"vector"`
+}
+
+// embeddingIndexFile 索引文件结构
+type embeddingIndexFile struct {
+	Model     string            `json:"model"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Entries   []*embeddingEntry `json:"entries"`
+}
+
+// EmbeddingUpdateStats 增量更新统计
+type EmbeddingUpdateStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// SemanticMatch 语义搜索命中的文本单元
+type SemanticMatch struct {
+	EmbeddingDocument
+	Score float64 `json:"score"`
+}
+
+// SimilarItem 相似文献
+type SimilarItem struct {
+	ItemKey  string  `json:"item_key,omitempty"`
+	Document string  `json:"document,omitempty"`
+	Title    string  `json:"title"`
+	Score    float64 `json:"score"`
+}
+
+// EmbeddingIndex 文献库的本地嵌入索引
+type EmbeddingIndex struct {
+	path     string
+	provider EmbeddingProvider
+
+	mu        sync.RWMutex
+	updatedAt time.Time
+	entries   map[string]*embeddingEntry
+}
+
+// OpenEmbeddingIndex 打开dir下的嵌入索引，不存在时创建空索引
+//
+// 索引使用的模型与provider不一致时丢弃旧向量，下次Update全部重新嵌入
+func OpenEmbeddingIndex(dir string, provider EmbeddingProvider) (*EmbeddingIndex, error) {
+	index := &EmbeddingIndex{
+		path:     filepath.Join(dir, embeddingIndexFileName),
+		provider: provider,
+		entries:  make(map[string]*embeddingEntry),
+	}
+
+	data, err := os.ReadFile(index.path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取嵌入索引失败: %w", err)
+	}
+
+	var file embeddingIndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("解析嵌入索引失败: %w", err)
+	}
+	if file.Model != provider.Model() {
+		log.Printf("⚠️ 嵌入模型已从 %s 变为 %s，索引将重建", file.Model, provider.Model())
+		return index, nil
+	}
+	for _, entry := range file.Entries {
+		index.entries[entry.ID] = entry
+	}
+	index.updatedAt = file.UpdatedAt
+	return index, nil
+}
+
+// Len 索引中的文本单元数
+func (idx *EmbeddingIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.entries)
+}
+
+// UpdatedAt 最近一次更新时间
+func (idx *EmbeddingIndex) UpdatedAt() time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.updatedAt
+}
+
+// Update 以docs为完整集合增量更新索引：只嵌入新增或内容变化的单元，删除不再存在的单元并保存
+func (idx *EmbeddingIndex) Update(ctx context.Context, docs []EmbeddingDocument) (*EmbeddingUpdateStats, error) {
+	stats := &EmbeddingUpdateStats{}
+
+	idx.mu.RLock()
+	var pending []*embeddingEntry
+	wanted := make(map[string]EmbeddingDocument, len(docs))
+	for _, doc := range docs {
+		wanted[doc.ID] = doc
+		hash := textHash(doc.Text)
+		if existing, ok := idx.entries[doc.ID]; ok && existing.Hash == hash {
+			stats.Unchanged++
+			continue
+		}
+		if _, ok := idx.entries[doc.ID]; ok {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+		pending = append(pending, &embeddingEntry{EmbeddingDocument: doc, Hash: hash})
+	}
+	for id := range idx.entries {
+		if _, ok := wanted[id]; !ok {
+			stats.Removed++
+		}
+	}
+	idx.mu.RUnlock()
+
+	if len(pending) > 0 {
+		texts := make([]string, len(pending))
+		for i, entry := range pending {
+			texts[i] = entry.Text
+		}
+		vectors, err := idx.provider.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("计算嵌入失败: %w", err)
+		}
+		if len(vectors) != len(texts) {
+			return nil, fmt.Errorf("嵌入结果数量不匹配: 请求 %d 条，返回 %d 条", len(texts), len(vectors))
+		}
+		for i, entry := range pending {
+			entry.Vector = normalizeVector(vectors[i])
+		}
+	}
+
+	idx.mu.Lock()
+	for id, entry := range idx.entries {
+		if doc, ok := wanted[id]; ok {
+			// 元数据（标题、页码等）变化不影响向量，直接更新
+			entry.EmbeddingDocument = doc
+		} else {
+			delete(idx.entries, id)
+		}
+	}
+	for _, entry := range pending {
+		idx.entries[entry.ID] = entry
+	}
+	idx.updatedAt = time.Now()
+	idx.mu.Unlock()
+
+	if stats.Added+stats.Updated+stats.Removed > 0 {
+		log.Printf("🧭 嵌入索引更新: 新增 %d，更新 %d，删除 %d，未变 %d", stats.Added, stats.Updated, stats.Removed, stats.Unchanged)
+	}
+	return stats, idx.Save()
+}
+
+// Save 原子写入索引文件
+func (idx *EmbeddingIndex) Save() error {
+	idx.mu.RLock()
+	file := embeddingIndexFile{Model: idx.provider.Model(), UpdatedAt: idx.updatedAt}
+	for _, entry := range idx.entries {
+		file.Entries = append(file.Entries, entry)
+	}
+	idx.mu.RUnlock()
+	sort.Slice(file.Entries, func(i, j int) bool { return file.Entries[i].ID < file.Entries[j].ID })
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("序列化嵌入索引失败: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(idx.path), 0755); err != nil {
+		return fmt.Errorf("创建索引目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(idx.path), ".embeddings-*.tmp")
+	if err != nil {
+		return fmt.Errorf("写入嵌入索引失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入嵌入索引失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("写入嵌入索引失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), idx.path)
+}
+
+// embedQuery 计算查询文本的单位向量
+func (idx *EmbeddingIndex) embedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := idx.provider.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("计算查询嵌入失败: %w", err)
+	}
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("嵌入服务未返回向量")
+	}
+	return normalizeVector(vectors[0]), nil
+}
+
+// Search 语义搜索：返回与查询最相近的k个文本单元（标题、摘要或全文块）
+func (idx *EmbeddingIndex) Search(ctx context.Context, query string, k int) ([]SemanticMatch, error) {
+	queryVector, err := idx.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.RLock()
+	matches := make([]SemanticMatch, 0, len(idx.entries))
+	for _, entry := range idx.entries {
+		if score := cosineSimilarity(queryVector, entry.Vector); score > 0 {
+			matches = append(matches, SemanticMatch{EmbeddingDocument: entry.EmbeddingDocument, Score: score})
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	if k > 0 && len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+// itemVector 文献的代表向量：其全部文本单元向量的均值
+type itemVector struct {
+	item   SimilarItem
+	vector []float32
+	count  int
+}
+
+// itemVectors 按文献分组计算代表向量
+func (idx *EmbeddingIndex) itemVectors() map[string]*itemVector {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	items := make(map[string]*itemVector)
+	for _, entry := range idx.entries {
+		if len(entry.Vector) == 0 {
+			continue
+		}
+		key := entry.groupKey()
+		iv, ok := items[key]
+		if !ok {
+			iv = &itemVector{
+				item:   SimilarItem{ItemKey: entry.ItemKey, Document: entry.Document, Title: entry.Title},
+				vector: make([]float32, len(entry.Vector)),
+			}
+			items[key] = iv
+		}
+		if len(entry.Vector) != len(iv.vector) {
+			continue
+		}
+		for i, x := range entry.Vector {
+			iv.vector[i] += x
+		}
+		iv.count++
+		if iv.item.Document == "" {
+			iv.item.Document = entry.Document
+		}
+		// 优先使用标题单元中的标题
+		if entry.Kind == EmbeddingKindTitle {
+			iv.item.Title = entry.Title
+		}
+	}
+	return items
+}
+
+// SimilarItems 返回与指定文献最相似的k篇文献
+//
+// itemKey可以是Zotero条目Key，也可以是未关联条目的解析结果目录名
+func (idx *EmbeddingIndex) SimilarItems(itemKey string, k int) ([]SimilarItem, error) {
+	items := idx.itemVectors()
+	target, ok := items[itemKey]
+	if !ok {
+		target, ok = items["doc:"+itemKey]
+	}
+	if !ok {
+		return nil, fmt.Errorf("嵌入索引中没有文献: %s", itemKey)
+	}
+
+	var similar []SimilarItem
+	for _, iv := range items {
+		if iv == target {
+			continue
+		}
+		if score := cosineSimilarity(target.vector, iv.vector); score > 0 {
+			item := iv.item
+			item.Score = score
+			similar = append(similar, item)
+		}
+	}
+	sort.Slice(similar, func(i, j int) bool {
+		if similar[i].Score != similar[j].Score {
+			return similar[i].Score > similar[j].Score
+		}
+		return similar[i].Title < similar[j].Title
+	})
+	if k > 0 && len(similar) > k {
+		similar = similar[:k]
+	}
+	return similar, nil
+}
+
+// Retrieve 实现Retriever：按语义相似度为候选块排序
+//
+// 候选块已在索引中且内容未变时复用向量，否则临时计算（不写入索引）
+func (idx *EmbeddingIndex) Retrieve(ctx context.Context, query string, chunks []Chunk, k int) ([]RetrievedChunk, error) {
+	queryVector, err := idx.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(chunks))
+	var missing []int
+	idx.mu.RLock()
+	for i, chunk := range chunks {
+		if entry, ok := idx.entries[chunk.ID]; ok && entry.Hash == textHash(chunk.Text) {
+			vectors[i] = entry.Vector
+		} else {
+			missing = append(missing, i)
+		}
+	}
+	idx.mu.RUnlock()
+
+	if len(missing) > 0 {
+		texts := make([]string, len(missing))
+		for j, i := range missing {
+			texts[j] = chunks[i].Text
+		}
+		embedded, err := idx.provider.Embed(ctx, texts)
+		if err != nil {
+			return nil, fmt.Errorf("计算文本块嵌入失败: %w", err)
+		}
+		if len(embedded) != len(texts) {
+			return nil, fmt.Errorf("嵌入结果数量不匹配: 请求 %d 条，返回 %d 条", len(texts), len(embedded))
+		}
+		for j, i := range missing {
+			vectors[i] = embedded[j]
+		}
+	}
+
+	var results []RetrievedChunk
+	for i, chunk := range chunks {
+		if score := cosineSimilarity(queryVector, vectors[i]); score > 0 {
+			results = append(results, RetrievedChunk{Chunk: chunk, Score: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// CollectEmbeddingDocuments 汇总文献库中需要嵌入的文本单元：
+// Zotero条目的标题和摘要，以及解析结果full.md的全部文本块
+func CollectEmbeddingDocuments(items []ZoteroItem, resultsDir string) ([]EmbeddingDocument, error) {
+	var docs []EmbeddingDocument
+	titled := make(map[string]bool)
+
+	addItem := func(group, itemKey, document, title, abstract string) {
+		if titled[group] {
+			return
+		}
+		titled[group] = true
+		if title != "" {
+			docs = append(docs, EmbeddingDocument{ID: group + "#title", Kind: EmbeddingKindTitle, ItemKey: itemKey, Document: document, Title: title, Text: title})
+		}
+		if abstract != "" {
+			docs = append(docs, EmbeddingDocument{ID: group + "#abstract", Kind: EmbeddingKindAbstract, ItemKey: itemKey, Document: document, Title: title, Text: abstract})
+		}
+	}
+
+	results, err := ListParsedResults(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+	documentByKey := make(map[string]string)
+	for _, result := range results {
+		if result.Info != nil && result.Info.ItemKey != "" {
+			documentByKey[result.Info.ItemKey] = result.Name
+		}
+	}
+
+	for _, item := range items {
+		addItem(item.ItemKey, item.ItemKey, documentByKey[item.ItemKey], item.Title, item.Abstract)
+	}
+
+	for _, result := range results {
+		chunks, err := ChunkParsedResult(&result, ChunkOptions{})
+		if err != nil {
+			log.Printf("⚠️ 文献分块失败 %s: %v", result.Name, err)
+			continue
+		}
+
+		doc := EmbeddingDocument{Document: result.Name, Title: result.Title()}
+		if result.Info != nil {
+			doc.ItemKey = result.Info.ItemKey
+		}
+		if !titled[doc.groupKey()] {
+			content, _ := result.ReadFullText()
+			addItem(doc.groupKey(), doc.ItemKey, doc.Document, doc.Title, extractAbstractFromContent(content))
+		}
+
+		for _, chunk := range chunks {
+			docs = append(docs, EmbeddingDocument{
+				ID:       chunk.ID,
+				Kind:     EmbeddingKindChunk,
+				ItemKey:  chunk.ItemKey,
+				Document: chunk.Document,
+				Title:    chunk.Title,
+				Section:  chunk.Section,
+				Page:     chunk.Page,
+				Text:     chunk.Text,
+			})
+		}
+	}
+	return docs, nil
+}
+
+// textHash 文本内容哈希
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 嵌入提供方类型
+const (
+	EmbeddingProviderOpenAI = "openai" // OpenAI兼容的 /embeddings 接口（含本地部署的兼容服务）
+	EmbeddingProviderLocal  = "local"  // 进程内特征哈希，无需网络
+)
+
+// DefaultLocalEmbeddingDim 本地哈希嵌入的向量维度
+const DefaultLocalEmbeddingDim = 512
+
+// embeddingBatchSize 单次请求嵌入的文本数
+const embeddingBatchSize = 32
+
+// EmbeddingProvider 文本嵌入提供方
+type EmbeddingProvider interface {
+	// Model 模型标识，模型变化时索引需要重建
+	Model() string
+	// Embed 计算一批文本的嵌入向量，返回顺序与输入一致
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// NewEmbeddingProvider 按类型创建嵌入提供方
+func NewEmbeddingProvider(kind, baseURL, apiKey, model string) (EmbeddingProvider, error) {
+	switch kind {
+	case EmbeddingProviderOpenAI, "":
+		if baseURL == "" {
+			return nil, fmt.Errorf("未配置嵌入服务地址 EMBEDDING_BASE_URL")
+		}
+		return NewOpenAIEmbeddingProvider(baseURL, apiKey, model), nil
+	case EmbeddingProviderLocal:
+		return NewLocalEmbeddingProvider(DefaultLocalEmbeddingDim), nil
+	default:
+		return nil, fmt.Errorf("不支持的嵌入提供方: %s", kind)
+	}
+}
+
+// OpenAIEmbeddingProvider 调用OpenAI兼容的 /embeddings 接口
+type OpenAIEmbeddingProvider struct {
+	apiKey     string
+	baseURL    string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIEmbeddingProvider 创建OpenAI兼容的嵌入提供方
+func NewOpenAIEmbeddingProvider(baseURL, apiKey, model string) *OpenAIEmbeddingProvider {
+	return &OpenAIEmbeddingProvider{
+		apiKey:  apiKey,
+		baseURL: baseURL,
+		model:   model,
+		httpClient: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+	}
+}
+
+// Model 模型标识
+func (p *OpenAIEmbeddingProvider) Model() string {
+	return "openai:" + p.model
+}
+
+// Embed 分批请求嵌入接口
+func (p *OpenAIEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += embeddingBatchSize {
+		end := min(start+embeddingBatchSize, len(texts))
+		batch, err := p.embedBatch(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// embedBatch 单次请求嵌入接口
+func (p *OpenAIEmbeddingProvider) embedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model": p.model,
+		"input": texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	apiURL := strings.TrimSuffix(p.baseURL, "/")
+	if !strings.HasSuffix(apiURL, "/embeddings") {
+		apiURL += "/embeddings"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("请求嵌入接口失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("嵌入接口错误 %d: %s", resp.StatusCode, string(body))
+	}
+
+	var embedResp struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &embedResp); err != nil {
+		return nil, fmt.Errorf("解析嵌入响应失败: %w", err)
+	}
+	if len(embedResp.Data) != len(texts) {
+		return nil, fmt.Errorf("嵌入结果数量不匹配: 请求 %d 条，返回 %d 条", len(texts), len(embedResp.Data))
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range embedResp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("嵌入结果序号越界: %d", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// LocalEmbeddingProvider 基于词项特征哈希的本地嵌入
+//
+// 不理解语义，只反映词项重叠，用于离线环境或没有嵌入服务时的替代
+type LocalEmbeddingProvider struct {
+	dim int
+}
+
+// NewLocalEmbeddingProvider 创建本地哈希嵌入提供方
+func NewLocalEmbeddingProvider(dim int) *LocalEmbeddingProvider {
+	if dim <= 0 {
+		dim = DefaultLocalEmbeddingDim
+	}
+	return &LocalEmbeddingProvider{dim: dim}
+}
+
+// Model 模型标识
+func (p *LocalEmbeddingProvider) Model() string {
+	return fmt.Sprintf("local:hash-%d", p.dim)
+}
+
+// Embed 将tokenize得到的词项哈希到固定维度并归一化
+func (p *LocalEmbeddingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, p.dim)
+		for _, term := range tokenize(text) {
+			h := fnv.New32a()
+			h.Write([]byte(term))
+			sum := h.Sum32()
+			// 最高位决定符号，减少哈希冲突带来的偏差
+			if sum&(1<<31) != 0 {
+				vector[int(sum%uint32(p.dim))]--
+			} else {
+				vector[int(sum%uint32(p.dim))]++
+			}
+		}
+		vectors[i] = normalizeVector(vector)
+	}
+	return vectors, nil
+}
+
+// normalizeVector 归一化为单位向量（零向量原样返回）
+func normalizeVector(v []float32) []float32 {
+	var norm float64
+	for _, x := range v {
+		norm += float64(x) * float64(x)
+	}
+	if norm == 0 {
+		return v
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
+
+// cosineSimilarity 余弦相似度，维度不一致时返回0
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// countingProvider 记录嵌入文本数的本地嵌入提供方
+type countingProvider struct {
+	*LocalEmbeddingProvider
+	embedded int
+}
+
+func (p *countingProvider) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	p.embedded += len(texts)
+	return p.LocalEmbeddingProvider.Embed(ctx, texts)
+}
+
+func TestOpenAIEmbeddingProvider(t *testing.T) {
+	var gotModel, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		gotModel, gotAuth = req.Model, r.Header.Get("Authorization")
+
+		// 倒序返回，验证按index还原顺序
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []datum
+		for i := len(req.Input) - 1; i >= 0; i-- {
+			data = append(data, datum{Index: i, Embedding: []float32{float32(len(req.Input[i])), 1}})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	provider, err := NewEmbeddingProvider(EmbeddingProviderOpenAI, server.URL+"/v1/", "secret", "embedding-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := provider.Embed(context.Background(), []string{"a", "abc"})
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 1 || vectors[1][0] != 3 {
+		t.Errorf("Embed() = %v", vectors)
+	}
+	if gotModel != "embedding-3" || gotAuth != "Bearer secret" {
+		t.Errorf("请求参数不正确: model=%q auth=%q", gotModel, gotAuth)
+	}
+	if provider.Model() != "openai:embedding-3" {
+		t.Errorf("Model() = %q", provider.Model())
+	}
+
+	if _, err := NewEmbeddingProvider("unknown", "", "", ""); err == nil {
+		t.Error("未知提供方应返回错误")
+	}
+}
+
+func TestEmbeddingIndexIncrementalUpdate(t *testing.T) {
+	dir := t.TempDir()
+	provider := &countingProvider{LocalEmbeddingProvider: NewLocalEmbeddingProvider(256)}
+	docs := []EmbeddingDocument{
+		{ID: "A#title", Kind: EmbeddingKindTitle, ItemKey: "A", Title: "Attention transformer", Text: "Attention transformer"},
+		{ID: "B#title", Kind: EmbeddingKindTitle, ItemKey: "B", Title: "Protein folding", Text: "Protein folding"},
+	}
+
+	index, err := OpenEmbeddingIndex(dir, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.Update(context.Background(), docs); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// 重新打开后只嵌入变化的单元，并删除不再存在的单元
+	reopened, err := OpenEmbeddingIndex(dir, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 2 {
+		t.Fatalf("索引未持久化: Len() = %d", reopened.Len())
+	}
+	provider.embedded = 0
+	docs[1].Text = "Protein structure prediction"
+	docs = append(docs[:2], EmbeddingDocument{ID: "C#title", Kind: EmbeddingKindTitle, ItemKey: "C", Text: "Graph networks"})
+	stats, err := reopened.Update(context.Background(), docs[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EmbeddingUpdateStats{Added: 1, Updated: 1, Removed: 1, Unchanged: 0}
+	if *stats != want || provider.embedded != 2 || reopened.Len() != 2 {
+		t.Errorf("stats = %+v, embedded = %d, Len() = %d", *stats, provider.embedded, reopened.Len())
+	}
+
+	// 模型变化时丢弃旧向量
+	rebuilt, err := OpenEmbeddingIndex(dir, NewLocalEmbeddingProvider(128))
+	if err != nil || rebuilt.Len() != 0 {
+		t.Errorf("模型变化后应重建索引: Len() = %d, err = %v", rebuilt.Len(), err)
+	}
+}
+
+func TestEmbeddingIndexSearchAndSimilar(t *testing.T) {
+	index, err := OpenEmbeddingIndex(t.TempDir(), NewLocalEmbeddingProvider(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []EmbeddingDocument{
+		{ID: "A#title", Kind: EmbeddingKindTitle, ItemKey: "A", Title: "Attention Is All You Need", Text: "Attention Is All You Need"},
+		{ID: "A#abstract", Kind: EmbeddingKindAbstract, ItemKey: "A", Title: "Attention Is All You Need", Text: "transformer self-attention sequence transduction"},
+		{ID: "B#title", Kind: EmbeddingKindTitle, ItemKey: "B", Title: "Efficient Transformers", Text: "Efficient Transformers: a survey of self-attention"},
+		{ID: "paper#0", Kind: EmbeddingKindChunk, Document: "paper", Title: "Protein Folding", Section: "Methods", Page: 3, Text: "protein structure prediction with residue contacts"},
+	}
+	if _, err := index.Update(context.Background(), docs); err != nil {
+		t.Fatal(err)
+	}
+
+	matches, err := index.Search(context.Background(), "protein structure", 2)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	if len(matches) == 0 || matches[0].ID != "paper#0" || matches[0].Page != 3 {
+		t.Errorf("Search() = %+v", matches)
+	}
+
+	similar, err := index.SimilarItems("A", 5)
+	if err != nil {
+		t.Fatalf("SimilarItems() error = %v", err)
+	}
+	if len(similar) == 0 || similar[0].ItemKey != "B" || similar[0].Title != "Efficient Transformers" {
+		t.Errorf("SimilarItems(A) = %+v", similar)
+	}
+	// 未关联条目的解析结果按目录名查找
+	if _, err := index.SimilarItems("paper", 5); err != nil {
+		t.Errorf("按文献名查找失败: %v", err)
+	}
+	if _, err := index.SimilarItems("MISSING", 5); err == nil {
+		t.Error("不存在的文献应返回错误")
+	}
+}
+
+func TestCollectEmbeddingDocuments(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	items, err := db.ListItems()
+	if err != nil {
+		t.Fatalf("ListItems() error = %v", err)
+	}
+	if len(items) != 1 || items[0].ItemKey != "ABCD1234" || items[0].Abstract != "We propose the Transformer." {
+		t.Fatalf("ListItems() = %+v", items)
+	}
+
+	docs, err := CollectEmbeddingDocuments(items, writeRAGFixture(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, doc := range docs {
+		kinds[doc.Kind]++
+		if doc.ItemKey != "ABCD1234" {
+			t.Errorf("文本单元应关联到条目: %+v", doc)
+		}
+	}
+	if kinds[EmbeddingKindTitle] != 1 || kinds[EmbeddingKindAbstract] != 1 || kinds[EmbeddingKindChunk] != 3 {
+		t.Errorf("文本单元统计 = %v", kinds)
+	}
+
+	// 索引可直接作为RAG检索器使用
+	index, _ := OpenEmbeddingIndex(t.TempDir(), NewLocalEmbeddingProvider(512))
+	index.Update(context.Background(), docs)
+	pipeline := NewRAGPipeline(writeRAGFixture(t))
+	pipeline.SetRetriever(index)
+	chunks, err := pipeline.Retrieve(context.Background(), "stacked self-attention feed-forward layers", RAGOptions{TopK: 1})
+	if err != nil || len(chunks) != 1 || chunks[0].Section != "Model Architecture" {
+		t.Errorf("Retrieve() = %+v, %v", chunks, err)
+	}
+}
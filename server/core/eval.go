@@ -0,0 +1,599 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EvalCase 一条评测用例：针对一篇已解析文献的问题和期望答案包含的事实
+type EvalCase struct {
+	ID       string `json:"id" yaml:"id"`
+	Document string `json:"document" yaml:"document"` // 解析结果目录名
+	Question string `json:"question" yaml:"question"`
+	// Facts 期望答案包含的事实，同一事实的不同表述用|分隔，匹配时忽略大小写和空白
+	Facts []string `json:"facts" yaml:"facts"`
+	// Sections 期望被引用的章节，任一引用片段的章节包含其一即命中，为空时不检查
+	Sections []string `json:"sections,omitempty" yaml:"sections"`
+}
+
+// EvalSuite 评测用例集
+type EvalSuite struct {
+	Name  string     `json:"name" yaml:"name"`
+	Cases []EvalCase `json:"cases" yaml:"cases"`
+}
+
+// LoadEvalSuite 读取JSON或YAML（.yaml/.yml）格式的用例集
+//
+// 文件可以是{"name": ..., "cases": [...]}，也可以直接是用例数组
+func LoadEvalSuite(path string) (*EvalSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取评测用例失败: %w", err)
+	}
+	suite := &EvalSuite{}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		err = unmarshalEvalSuite(data, suite, yaml.Unmarshal)
+	} else {
+		err = unmarshalEvalSuite(data, suite, json.Unmarshal)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析评测用例失败: %w", err)
+	}
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return suite, suite.validate()
+}
+
+// unmarshalEvalSuite 解码用例集，不是映射时按用例数组解码
+func unmarshalEvalSuite(data []byte, suite *EvalSuite, unmarshal func([]byte, interface{}) error) error {
+	err := unmarshal(data, suite)
+	if err != nil && unmarshal(data, &suite.Cases) == nil {
+		return nil
+	}
+	return err
+}
+
+// validate 检查必填字段，为没有ID的用例按序号编号
+func (s *EvalSuite) validate() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("评测用例集 %s 为空", s.Name)
+	}
+	seen := make(map[string]bool)
+	for i := range s.Cases {
+		c := &s.Cases[i]
+		if c.ID == "" {
+			c.ID = fmt.Sprintf("case-%d", i+1)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("评测用例ID重复: %s", c.ID)
+		}
+		seen[c.ID] = true
+		if c.Document == "" || strings.TrimSpace(c.Question) == "" {
+			return fmt.Errorf("评测用例 %s 缺少 document 或 question", c.ID)
+		}
+	}
+	return nil
+}
+
+// EvalCaseResult 一条用例的回答和得分
+type EvalCaseResult struct {
+	ID           string   `json:"id"`
+	Document     string   `json:"document"`
+	Question     string   `json:"question"`
+	Answer       string   `json:"answer"`
+	FactsFound   []string `json:"facts_found,omitempty"`
+	FactsMissing []string `json:"facts_missing,omitempty"`
+	FactRecall   float64  `json:"fact_recall"`
+	// Citations 回答中的引用标记数，ValidCitations 其中编号有效且指向该文献片段的数量
+	Citations        int     `json:"citations"`
+	ValidCitations   int     `json:"valid_citations"`
+	CitationAccuracy float64 `json:"citation_accuracy"`
+	SectionHit       *bool   `json:"section_hit,omitempty"` // 用例未指定章节时为空
+	Unsupported      int     `json:"unsupported"`           // 引用校验未通过的句子数
+	Tokens           int     `json:"tokens"`
+	LatencyMS        int64   `json:"latency_ms"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// EvalSummary 整个用例集的平均得分，出错的用例按0分计入
+type EvalSummary struct {
+	Cases            int     `json:"cases"`
+	Errors           int     `json:"errors"`
+	FactRecall       float64 `json:"fact_recall"`
+	CitationAccuracy float64 `json:"citation_accuracy"`
+	SectionHitRate   float64 `json:"section_hit_rate"`
+	Unsupported      int     `json:"unsupported"`
+	Tokens           int     `json:"tokens"`
+	AvgLatencyMS     int64   `json:"avg_latency_ms"`
+}
+
+// EvalReport 一次评测运行的报告，保存为JSON后可与之后的运行比较
+type EvalReport struct {
+	Suite     string           `json:"suite"`
+	Label     string           `json:"label,omitempty"` // 如提示或检索配置的名称
+	Model     string           `json:"model"`
+	CreatedAt time.Time        `json:"created_at"`
+	Summary   EvalSummary      `json:"summary"`
+	Results   []EvalCaseResult `json:"results"`
+}
+
+// EvalOptions 评测运行参数
+type EvalOptions struct {
+	Label    string
+	Model    string
+	Progress func(done, total int, result EvalCaseResult)
+}
+
+// RunEval 逐条用例通过对话管理器提问（与chat --doc相同的检索和提示流程）并评分
+//
+// 单条用例失败记录在结果中；context取消时返回已完成部分的报告和错误
+func RunEval(ctx context.Context, manager *AIConversationManager, suite *EvalSuite, opts EvalOptions) (*EvalReport, error) {
+	ctx = WithUsageFeature(ctx, FeatureEval)
+	report := &EvalReport{Suite: suite.Name, Label: opts.Label, Model: opts.Model, CreatedAt: time.Now()}
+	for i, c := range suite.Cases {
+		if err := ctx.Err(); err != nil {
+			report.summarize()
+			return report, err
+		}
+		result := runEvalCase(ctx, manager, c)
+		report.Results = append(report.Results, result)
+		if opts.Progress != nil {
+			opts.Progress(i+1, len(suite.Cases), result)
+		}
+	}
+	report.summarize()
+	return report, nil
+}
+
+// runEvalCase 运行一条用例
+func runEvalCase(ctx context.Context, manager *AIConversationManager, c EvalCase) EvalCaseResult {
+	result := EvalCaseResult{ID: c.ID, Document: c.Document, Question: c.Question, FactsMissing: c.Facts}
+	if _, err := GetParsedResult(manager.rag.ResultsDir(), c.Document); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	conv, err := manager.StartConversationWithDocument(ctx, c.Question, &DocumentContext{DocumentNames: []string{c.Document}})
+	result.LatencyMS = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	last := conv.Messages[len(conv.Messages)-1]
+	if last.Role != "assistant" {
+		result.Error = "模型没有回复"
+		return result
+	}
+	result.Tokens = conv.Usage.TotalTokens
+
+	var citations []Citation
+	if last.Metadata != nil {
+		citations = last.Metadata.Citations
+		result.Unsupported = len(last.Metadata.Unsupported)
+	}
+	scoreEvalAnswer(&result, c, last.Content, citations)
+	return result
+}
+
+// scoreEvalAnswer 计算事实召回率、引用正确率和章节命中
+func scoreEvalAnswer(result *EvalCaseResult, c EvalCase, answer string, citations []Citation) {
+	result.Answer = answer
+	result.FactsFound, result.FactsMissing = nil, nil
+	result.ValidCitations, result.SectionHit = 0, nil
+	normalized := normalizeEvalText(answer)
+	for _, fact := range c.Facts {
+		if matchEvalFact(normalized, fact) {
+			result.FactsFound = append(result.FactsFound, fact)
+		} else {
+			result.FactsMissing = append(result.FactsMissing, fact)
+		}
+	}
+	result.FactRecall = 1
+	if len(c.Facts) > 0 {
+		result.FactRecall = float64(len(result.FactsFound)) / float64(len(c.Facts))
+	}
+
+	sectionHit := false
+	numbers := CitedNumbers(answer)
+	result.Citations = len(numbers)
+	for _, n := range numbers {
+		if n < 1 || n > len(citations) || citations[n-1].Document != c.Document {
+			continue
+		}
+		result.ValidCitations++
+		section := strings.ToLower(citations[n-1].Section)
+		for _, want := range c.Sections {
+			if want != "" && strings.Contains(section, strings.ToLower(want)) {
+				sectionHit = true
+			}
+		}
+	}
+	result.CitationAccuracy = 0
+	if result.Citations > 0 {
+		result.CitationAccuracy = float64(result.ValidCitations) / float64(result.Citations)
+	}
+	if len(c.Sections) > 0 {
+		result.SectionHit = &sectionHit
+	}
+}
+
+// normalizeEvalText 去除引用标记、统一小写并合并空白
+func normalizeEvalText(text string) string {
+	text = citationWithSpace.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
+
+// matchEvalFact 回答（已规范化）是否包含事实的任一表述
+func matchEvalFact(normalizedAnswer, fact string) bool {
+	for _, alternative := range strings.Split(fact, "|") {
+		if alternative = normalizeEvalText(alternative); alternative != "" && strings.Contains(normalizedAnswer, alternative) {
+			return true
+		}
+	}
+	return false
+}
+
+// summarize 计算平均得分
+func (r *EvalReport) summarize() {
+	summary := EvalSummary{Cases: len(r.Results)}
+	sections, hits := 0, 0
+	var latency int64
+	for _, result := range r.Results {
+		if result.Error != "" {
+			summary.Errors++
+		}
+		if result.Error == "" {
+			summary.FactRecall += result.FactRecall
+			summary.CitationAccuracy += result.CitationAccuracy
+		}
+		if result.SectionHit != nil {
+			sections++
+			if *result.SectionHit {
+				hits++
+			}
+		}
+		summary.Unsupported += result.Unsupported
+		summary.Tokens += result.Tokens
+		latency += result.LatencyMS
+	}
+	if summary.Cases > 0 {
+		summary.FactRecall = roundScore(summary.FactRecall / float64(summary.Cases))
+		summary.CitationAccuracy = roundScore(summary.CitationAccuracy / float64(summary.Cases))
+		summary.AvgLatencyMS = latency / int64(summary.Cases)
+	}
+	if sections > 0 {
+		summary.SectionHitRate = roundScore(float64(hits) / float64(sections))
+	}
+	r.Summary = summary
+}
+
+// roundScore 得分保留4位小数，便于报告之间比较
+func roundScore(score float64) float64 {
+	return math.Round(score*10000) / 10000
+}
+
+// LoadEvalReport 读取保存的评测报告
+func LoadEvalReport(path string) (*EvalReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取评测报告失败: %w", err)
+	}
+	var report EvalReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("解析评测报告失败: %w", err)
+	}
+	return &report, nil
+}
+
+// EvalCaseDelta 一条用例相对基线的得分变化
+type EvalCaseDelta struct {
+	ID               string  `json:"id"`
+	FactRecall       float64 `json:"fact_recall"`
+	CitationAccuracy float64 `json:"citation_accuracy"`
+	Before           float64 `json:"before_fact_recall"`
+	After            float64 `json:"after_fact_recall"`
+}
+
+// EvalComparison 两次评测的对比，差值为当前减基线
+type EvalComparison struct {
+	Baseline         string          `json:"baseline"`
+	Current          string          `json:"current"`
+	FactRecall       float64         `json:"fact_recall"`
+	CitationAccuracy float64         `json:"citation_accuracy"`
+	SectionHitRate   float64         `json:"section_hit_rate"`
+	Unsupported      int             `json:"unsupported"`
+	Tokens           int             `json:"tokens"`
+	Changed          []EvalCaseDelta `json:"changed,omitempty"` // 得分有变化的用例，按召回率变化从差到好排序
+	Added            []string        `json:"added,omitempty"`   // 基线中没有的用例
+	Removed          []string        `json:"removed,omitempty"` // 当前运行中没有的用例
+}
+
+// CompareEvalReports 比较两次评测；用例集不同时只比较共有用例的逐条得分
+func CompareEvalReports(baseline, current *EvalReport) *EvalComparison {
+	comparison := &EvalComparison{
+		Baseline:         baseline.describe(),
+		Current:          current.describe(),
+		FactRecall:       roundScore(current.Summary.FactRecall - baseline.Summary.FactRecall),
+		CitationAccuracy: roundScore(current.Summary.CitationAccuracy - baseline.Summary.CitationAccuracy),
+		SectionHitRate:   roundScore(current.Summary.SectionHitRate - baseline.Summary.SectionHitRate),
+		Unsupported:      current.Summary.Unsupported - baseline.Summary.Unsupported,
+		Tokens:           current.Summary.Tokens - baseline.Summary.Tokens,
+	}
+
+	before := make(map[string]EvalCaseResult, len(baseline.Results))
+	for _, result := range baseline.Results {
+		before[result.ID] = result
+	}
+	for _, result := range current.Results {
+		old, ok := before[result.ID]
+		if !ok {
+			comparison.Added = append(comparison.Added, result.ID)
+			continue
+		}
+		delete(before, result.ID)
+		delta := EvalCaseDelta{
+			ID:               result.ID,
+			FactRecall:       roundScore(result.FactRecall - old.FactRecall),
+			CitationAccuracy: roundScore(result.CitationAccuracy - old.CitationAccuracy),
+			Before:           old.FactRecall,
+			After:            result.FactRecall,
+		}
+		if delta.FactRecall != 0 || delta.CitationAccuracy != 0 {
+			comparison.Changed = append(comparison.Changed, delta)
+		}
+	}
+	for _, result := range baseline.Results {
+		if _, ok := before[result.ID]; ok {
+			comparison.Removed = append(comparison.Removed, result.ID)
+		}
+	}
+	sort.SliceStable(comparison.Changed, func(i, j int) bool {
+		return comparison.Changed[i].FactRecall < comparison.Changed[j].FactRecall
+	})
+	return comparison
+}
+
+// describe 报告的简短说明：用例集、标签、模型和时间
+func (r *EvalReport) describe() string {
+	parts := []string{r.Suite}
+	if r.Label != "" {
+		parts = append(parts, r.Label)
+	}
+	if r.Model != "" {
+		parts = append(parts, r.Model)
+	}
+	return strings.Join(parts, " / ") + " @ " + r.CreatedAt.Format("2006-01-02 15:04")
+}
+
+// Regressed 召回率或引用正确率是否下降
+func (c *EvalComparison) Regressed() bool {
+	return c.FactRecall < 0 || c.CitationAccuracy < 0
+}
+
+// RenderEvalMarkdown 将评测报告（及可选的基线对比）渲染为Markdown
+func RenderEvalMarkdown(report *EvalReport, comparison *EvalComparison) string {
+	var builder strings.Builder
+	summary := report.Summary
+	builder.WriteString(fmt.Sprintf("# 评测报告：%s\n\n", report.describe()))
+	builder.WriteString(fmt.Sprintf("- 用例：%d（失败 %d）\n", summary.Cases, summary.Errors))
+	builder.WriteString(fmt.Sprintf("- 事实召回率：%.1f%%\n", summary.FactRecall*100))
+	builder.WriteString(fmt.Sprintf("- 引用正确率：%.1f%%\n", summary.CitationAccuracy*100))
+	builder.WriteString(fmt.Sprintf("- 章节命中率：%.1f%%\n", summary.SectionHitRate*100))
+	builder.WriteString(fmt.Sprintf("- 未通过引用校验的句子：%d\n", summary.Unsupported))
+	builder.WriteString(fmt.Sprintf("- token：%d，平均延迟 %dms\n", summary.Tokens, summary.AvgLatencyMS))
+
+	if comparison != nil {
+		builder.WriteString(fmt.Sprintf("\n## 与基线对比\n\n基线：%s\n\n", comparison.Baseline))
+		builder.WriteString(fmt.Sprintf("- 事实召回率：%+.1f%%\n", comparison.FactRecall*100))
+		builder.WriteString(fmt.Sprintf("- 引用正确率：%+.1f%%\n", comparison.CitationAccuracy*100))
+		builder.WriteString(fmt.Sprintf("- 章节命中率：%+.1f%%\n", comparison.SectionHitRate*100))
+		builder.WriteString(fmt.Sprintf("- 未通过校验的句子：%+d，token：%+d\n", comparison.Unsupported, comparison.Tokens))
+		for _, delta := range comparison.Changed {
+			builder.WriteString(fmt.Sprintf("- `%s` 召回 %.0f%% → %.0f%%，引用正确率 %+.0f%%\n", delta.ID, delta.Before*100, delta.After*100, delta.CitationAccuracy*100))
+		}
+		if len(comparison.Added) > 0 {
+			builder.WriteString(fmt.Sprintf("- 新增用例：%s\n", strings.Join(comparison.Added, ", ")))
+		}
+		if len(comparison.Removed) > 0 {
+			builder.WriteString(fmt.Sprintf("- 移除用例：%s\n", strings.Join(comparison.Removed, ", ")))
+		}
+	}
+
+	builder.WriteString("\n## 用例\n\n| 用例 | 事实召回 | 引用正确率 | 章节命中 | 未支持句 | token | 延迟 | 缺失事实 |\n|---|---|---|---|---|---|---|---|\n")
+	for _, result := range report.Results {
+		hit := "-"
+		if result.SectionHit != nil {
+			hit = map[bool]string{true: "✓", false: "✗"}[*result.SectionHit]
+		}
+		missing := strings.Join(result.FactsMissing, "; ")
+		if result.Error != "" {
+			missing = "错误: " + result.Error
+		}
+		builder.WriteString(fmt.Sprintf("| %s | %.0f%% | %.0f%% | %s | %d | %d | %dms | %s |\n",
+			result.ID, result.FactRecall*100, result.CitationAccuracy*100, hit, result.Unsupported,
+			result.Tokens, result.LatencyMS, strings.ReplaceAll(missing, "|", "/")))
+	}
+	return builder.String()
+}
+
+// WriteEvalReport 将报告写入 <dir>/<用例集>-<时间>.json 和同名 .md，返回JSON路径
+func WriteEvalReport(dir string, report *EvalReport, comparison *EvalComparison) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建评测报告目录失败: %w", err)
+	}
+	base := filepath.Join(dir, sanitizeFilename(report.Suite)+"-"+report.CreatedAt.Format("20060102-150405"))
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化评测报告失败: %w", err)
+	}
+	if err := os.WriteFile(base+".json", data, 0644); err != nil {
+		return "", fmt.Errorf("写入评测报告失败: %w", err)
+	}
+	if err := os.WriteFile(base+".md", []byte(RenderEvalMarkdown(report, comparison)), 0644); err != nil {
+		return "", fmt.Errorf("写入评测报告失败: %w", err)
+	}
+	return base + ".json", nil
+}
+
+// ExtractiveAIClient 确定性的抽取式AI客户端：从系统提示的文献片段中选出与问题词项重叠最多的句子并标注引用
+//
+// 不调用模型，同样的输入总是得到同样的回答，用作评测基线和离线联调时的替身
+type ExtractiveAIClient struct {
+	// MaxSentences 回答最多包含的句子数，为0时使用2
+	MaxSentences int
+}
+
+// NewExtractiveAIClient 创建抽取式AI客户端
+func NewExtractiveAIClient() *ExtractiveAIClient {
+	return &ExtractiveAIClient{}
+}
+
+// chunkBlockPattern FormatChunkContext输出中每个片段的标题行
+var chunkBlockPattern = regexp.MustCompile(`(?m)^\[(\d+)\] [^\n]*\n`)
+
+// extractiveNoAnswer 片段中找不到相关内容时的回答
+const extractiveNoAnswer = "提供的文献片段中没有与问题相关的内容。"
+
+// Chat 根据系统提示中的片段和最后一个用户问题生成回答
+func (c *ExtractiveAIClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	var system, question string
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case "system":
+			system = msg.Content
+		case "user":
+			question = msg.Content
+		}
+	}
+
+	type candidate struct {
+		text   string
+		number int
+		score  int
+	}
+	questionTerms := make(map[string]bool)
+	for _, term := range tokenize(question) {
+		questionTerms[term] = true
+	}
+	var candidates []candidate
+	blocks := chunkBlockPattern.FindAllStringSubmatchIndex(system, -1)
+	for i, loc := range blocks {
+		number, _ := strconv.Atoi(system[loc[2]:loc[3]])
+		end := len(system)
+		if i+1 < len(blocks) {
+			end = blocks[i+1][0]
+		}
+		// 最后一个片段之后是回答要求
+		text := system[loc[1]:end]
+		if cut := strings.Index(text, "\n\n💡"); cut >= 0 {
+			text = text[:cut]
+		}
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "![") || strings.HasPrefix(line, "|") {
+				continue
+			}
+			for _, sentence := range splitSentences(line) {
+				sentence = strings.TrimSpace(sentence)
+				if utf8.RuneCountInString(sentence) < minCheckedSentenceRunes {
+					continue
+				}
+				score := 0
+				for _, term := range uniqueTerms(tokenize(sentence)) {
+					if questionTerms[term] {
+						score++
+					}
+				}
+				if score > 0 {
+					candidates = append(candidates, candidate{text: sentence, number: number, score: score})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	limit := c.MaxSentences
+	if limit <= 0 {
+		limit = 2
+	}
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	answer := extractiveNoAnswer
+	if len(candidates) > 0 {
+		lines := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			lines[i] = fmt.Sprintf("%s [%d]", candidate.text, candidate.number)
+		}
+		answer = strings.Join(lines, "\n")
+	}
+
+	prompt := 0
+	for _, msg := range req.Messages {
+		prompt += EstimateTokens(msg.Content)
+	}
+	completion := EstimateTokens(answer)
+	return &AIResponse{
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   "extractive",
+		Choices: []Choice{{Message: ChatMessage{Role: "assistant", Content: answer}, FinishReason: "stop"}},
+		Usage:   UsageInfo{PromptTokens: prompt, CompletionTokens: completion, TotalTokens: prompt + completion},
+	}, nil
+}
+
+// ChatStream 将完整回答作为单个增量返回
+func (c *ExtractiveAIClient) ChatStream(ctx context.Context, req *AIRequest) (<-chan *Choice, error) {
+	resp, err := c.Chat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *Choice, 1)
+	ch <- &Choice{Delta: &MessageDelta{Role: "assistant", Content: resp.Choices[0].Message.Content}, FinishReason: "stop"}
+	close(ch)
+	return ch, nil
+}
+
+// NewFakeAIServer OpenAI兼容的 /chat/completions 接口，由给定客户端（一般为ExtractiveAIClient）生成回复，
+// 将AI_BASE_URL指向它即可在不访问真实服务的情况下运行评测或联调；不支持流式请求
+func NewFakeAIServer(client AIClient) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		var req AIRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, "请求格式错误: "+err.Error()), http.StatusBadRequest)
+			return
+		}
+		if req.Stream {
+			http.Error(w, `{"error":"不支持流式请求"}`, http.StatusBadRequest)
+			return
+		}
+		resp, err := client.Chat(r.Context(), &req)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		if resp.ID == "" {
+			resp.ID = "fake-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
@@ -0,0 +1,218 @@
+package core
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadEvalSuite(t *testing.T) {
+	fromYAML, err := LoadEvalSuite("testdata/eval_cases.yaml")
+	if err != nil {
+		t.Fatalf("LoadEvalSuite(yaml) error = %v", err)
+	}
+	fromJSON, err := LoadEvalSuite("testdata/eval_cases.json")
+	if err != nil {
+		t.Fatalf("LoadEvalSuite(json) error = %v", err)
+	}
+	if !reflect.DeepEqual(fromYAML, fromJSON) {
+		t.Errorf("YAML和JSON用例应一致:\n%+v\n%+v", fromYAML, fromJSON)
+	}
+	if fromYAML.Name != "attention" || len(fromYAML.Cases) != 4 || fromYAML.Cases[0].Facts[1] != "feed-forward|feed forward" {
+		t.Errorf("用例集 = %+v", fromYAML)
+	}
+
+	// 直接写用例数组时按文件名命名并为用例编号
+	dir := t.TempDir()
+	path := filepath.Join(dir, "smoke.json")
+	os.WriteFile(path, []byte(`[{"document": "a", "question": "q?"}]`), 0644)
+	suite, err := LoadEvalSuite(path)
+	if err != nil || suite.Name != "smoke" || suite.Cases[0].ID != "case-1" {
+		t.Errorf("LoadEvalSuite(数组) = %+v, %v", suite, err)
+	}
+
+	os.WriteFile(path, []byte(`[{"id": "x", "document": "a", "question": "q"}, {"id": "x", "document": "b", "question": "q"}]`), 0644)
+	if _, err := LoadEvalSuite(path); err == nil || !strings.Contains(err.Error(), "重复") {
+		t.Errorf("重复ID应报错: %v", err)
+	}
+	os.WriteFile(path, []byte(`{"cases": [{"question": "q"}]}`), 0644)
+	if _, err := LoadEvalSuite(path); err == nil {
+		t.Error("缺少document应报错")
+	}
+}
+
+func TestLoadEvalSuiteYAML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "smoke.yml")
+	os.WriteFile(path, []byte(`# 顶层直接是用例数组
+- document: attention_20240101
+  question: |
+    What layers does
+    the Transformer use?
+  facts:
+    - "self\u002Dattention"
+    - >
+      feed-forward
+      networks
+`), 0644)
+	suite, err := LoadEvalSuite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := suite.Cases[0]
+	if suite.Name != "smoke" || c.ID != "case-1" || c.Question != "What layers does\nthe Transformer use?\n" ||
+		!reflect.DeepEqual(c.Facts, []string{"self-attention", "feed-forward networks\n"}) {
+		t.Errorf("LoadEvalSuite(yaml数组) = %+v", suite)
+	}
+
+	os.WriteFile(path, []byte("cases:\n  - document: a\n    question: q\n   bad indent: x\n"), 0644)
+	if _, err := LoadEvalSuite(path); err == nil {
+		t.Error("YAML格式错误应返回错误")
+	}
+}
+
+func TestScoreEvalAnswer(t *testing.T) {
+	c := EvalCase{ID: "x", Document: "attention_20240101", Facts: []string{"Self-Attention", "RNN|recurrent networks", "Adam"}, Sections: []string{"architecture"}}
+	citations := []Citation{
+		{Number: 1, Document: "attention_20240101", Section: "Abstract"},
+		{Number: 2, Document: "attention_20240101", Section: "Model Architecture"},
+		{Number: 3, Document: "bert_20240101", Section: "Model Architecture"},
+	}
+	var result EvalCaseResult
+	scoreEvalAnswer(&result, c, "The Transformer uses self-attention [2]. Earlier models are  recurrent\nnetworks [1][3]. See also [7].", citations)
+
+	if result.FactRecall != 2.0/3 || !reflect.DeepEqual(result.FactsMissing, []string{"Adam"}) {
+		t.Errorf("事实召回 = %v, 缺失 %v", result.FactRecall, result.FactsMissing)
+	}
+	// [3]引用了其他文献，[7]编号不存在
+	if result.Citations != 4 || result.ValidCitations != 2 || result.CitationAccuracy != 0.5 {
+		t.Errorf("引用 = %d/%d, %v", result.ValidCitations, result.Citations, result.CitationAccuracy)
+	}
+	if result.SectionHit == nil || !*result.SectionHit {
+		t.Errorf("应命中Model Architecture章节: %v", result.SectionHit)
+	}
+
+	scoreEvalAnswer(&result, EvalCase{Document: "attention_20240101"}, "没有引用的回答", citations)
+	if result.FactRecall != 1 || result.CitationAccuracy != 0 || result.SectionHit != nil || result.FactsMissing != nil {
+		t.Errorf("无事实和章节要求时 = %+v", result)
+	}
+}
+
+func TestRunEvalWithFakeServer(t *testing.T) {
+	server := httptest.NewServer(NewFakeAIServer(NewExtractiveAIClient()))
+	defer server.Close()
+
+	suite, err := LoadEvalSuite("testdata/eval_cases.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Cases = append(suite.Cases, EvalCase{ID: "missing", Document: "bert_20240101", Question: "What is BERT?"})
+
+	run := func(label string) *EvalReport {
+		manager := NewAIConversationManager(NewGLMClient("test-key", server.URL, "fake"), nil)
+		manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+		var progress []string
+		report, err := RunEval(context.Background(), manager, suite, EvalOptions{Label: label, Model: "fake", Progress: func(done, total int, result EvalCaseResult) {
+			progress = append(progress, result.ID)
+		}})
+		if err != nil {
+			t.Fatalf("RunEval() error = %v", err)
+		}
+		if len(progress) != len(suite.Cases) {
+			t.Errorf("进度回调 = %v", progress)
+		}
+		return report
+	}
+	report := run("baseline")
+
+	byID := make(map[string]EvalCaseResult)
+	for _, result := range report.Results {
+		byID[result.ID] = result
+	}
+	for _, id := range []string{"architecture", "abstract", "training"} {
+		result := byID[id]
+		if result.Error != "" || result.FactRecall != 1 || result.CitationAccuracy != 1 || result.Tokens == 0 {
+			t.Errorf("%s = %+v", id, result)
+		}
+		if result.SectionHit != nil && !*result.SectionHit {
+			t.Errorf("%s 应引用期望的章节: %+v", id, result)
+		}
+	}
+	if result := byID["optimizer"]; result.FactRecall != 0 || len(result.FactsMissing) != 1 {
+		t.Errorf("片段中没有的事实不应被召回: %+v", result)
+	}
+	if result := byID["missing"]; result.Error == "" {
+		t.Errorf("文献未解析时应记录错误: %+v", result)
+	}
+	if s := report.Summary; s.Cases != 5 || s.Errors != 1 || s.FactRecall != 0.6 || s.CitationAccuracy != 0.6 || s.SectionHitRate != 1 {
+		t.Errorf("汇总 = %+v", s)
+	}
+
+	// 抽取式客户端是确定性的，重复运行得分不变
+	again := run("again")
+	comparison := CompareEvalReports(report, again)
+	if comparison.FactRecall != 0 || comparison.CitationAccuracy != 0 || len(comparison.Changed) != 0 || comparison.Regressed() {
+		t.Errorf("重复运行的对比 = %+v", comparison)
+	}
+
+	// 保存后读回，与修改过的运行比较
+	dir := t.TempDir()
+	path, err := WriteEvalReport(dir, report, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadEvalReport(path)
+	if err != nil || loaded.Summary != report.Summary || len(loaded.Results) != 5 {
+		t.Fatalf("LoadEvalReport() = %+v, %v", loaded, err)
+	}
+	again.Results[0].FactRecall = 0.5
+	again.Results = again.Results[:4]
+	again.Results = append(again.Results, EvalCaseResult{ID: "new"})
+	again.summarize()
+	comparison = CompareEvalReports(loaded, again)
+	if !comparison.Regressed() || len(comparison.Changed) != 1 || comparison.Changed[0].ID != "architecture" || comparison.Changed[0].FactRecall != -0.5 {
+		t.Errorf("对比 = %+v", comparison)
+	}
+	if !reflect.DeepEqual(comparison.Added, []string{"new"}) || !reflect.DeepEqual(comparison.Removed, []string{"missing"}) {
+		t.Errorf("新增/移除 = %v / %v", comparison.Added, comparison.Removed)
+	}
+	markdown := RenderEvalMarkdown(again, comparison)
+	for _, want := range []string{"# 评测报告：attention / again / fake", "事实召回率：-10.0%", "`architecture` 召回 100% → 50%", "移除用例：missing", "| optimizer | 0% |"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Markdown缺少 %q:\n%s", want, markdown)
+		}
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, ".json") + ".md"); err != nil {
+		t.Errorf("应同时写入Markdown报告: %v", err)
+	}
+}
+
+func TestExtractiveAIClient(t *testing.T) {
+	chunks := []RetrievedChunk{
+		{Chunk: Chunk{Document: "a", Section: "Intro", Text: "Short. Transformers replace recurrence with attention entirely.\n\nUnrelated sentence about hardware budgets."}},
+	}
+	req := &AIRequest{Messages: []ChatMessage{
+		{Role: "system", Content: "prompt\n\n=== 相关文献片段 ===\n" + FormatChunkContext(chunks) + "\n\n💡 请依据上述片段回答"},
+		{Role: "user", Content: "What do transformers replace?"},
+	}}
+	resp, err := NewExtractiveAIClient().Chat(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "Transformers replace recurrence with attention entirely. [1]" {
+		t.Errorf("回答 = %q", got)
+	}
+	if resp.Usage.TotalTokens == 0 {
+		t.Error("应估算用量")
+	}
+
+	req.Messages[1].Content = "量子计算"
+	resp, _ = NewExtractiveAIClient().Chat(context.Background(), req)
+	if got := resp.Choices[0].Message.Content; got != extractiveNoAnswer {
+		t.Errorf("无相关内容时的回答 = %q", got)
+	}
+}
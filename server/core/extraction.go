@@ -0,0 +1,693 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 信息抽取参数
+const (
+	// extractionTokenBudget 单篇文献送入模型的片段token上限，全文不超过时整篇送入
+	extractionTokenBudget = 6000
+	// extractionTopK 全文超出预算时检索的片段数
+	extractionTopK = 12
+	// extractionRetries 输出不符合模式时的重试次数
+	extractionRetries = 1
+)
+
+// validSchemaName 模式名用作结果文件名
+var validSchemaName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ExtractionField 抽取字段
+type ExtractionField struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Type        string   `json:"type,omitempty"` // string（默认）、number、integer、boolean、array
+	Enum        []string `json:"enum,omitempty"` // 取值限定（仅string）
+}
+
+// ExtractionSchema 用户定义的抽取模式
+type ExtractionSchema struct {
+	Name   string            `json:"name"`
+	Fields []ExtractionField `json:"fields"`
+}
+
+// LoadExtractionSchema 从JSON文件读取抽取模式，未指定name时使用文件名
+func LoadExtractionSchema(path string) (*ExtractionSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取抽取模式失败: %w", err)
+	}
+	var schema ExtractionSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("解析抽取模式失败: %w", err)
+	}
+	if schema.Name == "" {
+		schema.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+// ParseExtractionFields 解析命令行字段列表："名称[:类型[:说明]], ..."，如 "dataset, sample_size:integer:样本量"
+func ParseExtractionFields(name, spec string) (*ExtractionSchema, error) {
+	schema := &ExtractionSchema{Name: name}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		pieces := strings.SplitN(part, ":", 3)
+		field := ExtractionField{Name: strings.TrimSpace(pieces[0])}
+		if len(pieces) > 1 {
+			field.Type = strings.TrimSpace(pieces[1])
+		}
+		if len(pieces) > 2 {
+			field.Description = strings.TrimSpace(pieces[2])
+		}
+		schema.Fields = append(schema.Fields, field)
+	}
+	if err := schema.Validate(); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Validate 检查模式名和字段定义，并补全默认类型
+func (s *ExtractionSchema) Validate() error {
+	if !validSchemaName.MatchString(s.Name) {
+		return fmt.Errorf("抽取模式名只能包含字母、数字、下划线和连字符: %q", s.Name)
+	}
+	if len(s.Fields) == 0 {
+		return fmt.Errorf("抽取模式至少需要一个字段")
+	}
+	seen := make(map[string]bool)
+	for i := range s.Fields {
+		field := &s.Fields[i]
+		if field.Name == "" {
+			return fmt.Errorf("第 %d 个字段缺少名称", i+1)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("字段重复: %s", field.Name)
+		}
+		seen[field.Name] = true
+		if field.Type == "" {
+			field.Type = "string"
+		}
+		switch field.Type {
+		case "string", "number", "integer", "boolean", "array":
+		default:
+			return fmt.Errorf("字段 %s 的类型不受支持: %s", field.Name, field.Type)
+		}
+		if len(field.Enum) > 0 && field.Type != "string" {
+			return fmt.Errorf("字段 %s: 只有string类型支持enum", field.Name)
+		}
+	}
+	return nil
+}
+
+// Hash 模式定义的哈希，模式变化后已有抽取结果失效
+func (s *ExtractionSchema) Hash() string {
+	data, _ := json.Marshal(s)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// JSONSchema 模型输出的JSON Schema：每个字段为 {"value": 值或null, "sources": [片段编号]}
+func (s *ExtractionSchema) JSONSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0, len(s.Fields))
+	for _, field := range s.Fields {
+		value := map[string]interface{}{"type": []string{field.Type, "null"}}
+		if field.Type == "array" {
+			value["items"] = map[string]interface{}{"type": "string"}
+		}
+		if len(field.Enum) > 0 {
+			value["enum"] = append(append([]interface{}{}, stringsToInterfaces(field.Enum)...), nil)
+		}
+		if field.Description != "" {
+			value["description"] = field.Description
+		}
+		properties[field.Name] = map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"value":   value,
+				"sources": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			},
+			"required":             []string{"value", "sources"},
+			"additionalProperties": false,
+		}
+		required = append(required, field.Name)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// stringsToInterfaces 转换为[]interface{}
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
+
+// ExtractionValue 单个字段的抽取结果
+type ExtractionValue struct {
+	Field     string      `json:"field"`
+	Value     interface{} `json:"value"` // 原文未提及时为nil
+	Citations []Citation  `json:"citations,omitempty"`
+}
+
+// ExtractionResult 一篇文献按某个模式的抽取结果，保存在结果目录的extractions/<模式名>.json
+type ExtractionResult struct {
+	Schema      string            `json:"schema"`
+	SchemaHash  string            `json:"schema_hash"`
+	ContentHash string            `json:"content_hash"`
+	Document    string            `json:"document"`
+	ItemKey     string            `json:"item_key,omitempty"`
+	Title       string            `json:"title"`
+	Values      []ExtractionValue `json:"values"`
+	Error       string            `json:"error,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+
+	Cached bool `json:"-"`
+}
+
+// Value 按字段名取值
+func (r *ExtractionResult) Value(field string) *ExtractionValue {
+	for i := range r.Values {
+		if r.Values[i].Field == field {
+			return &r.Values[i]
+		}
+	}
+	return nil
+}
+
+// extractionPath 抽取结果文件路径
+func extractionPath(result *ParsedResult, schema string) string {
+	return filepath.Join(result.Dir, "extractions", schema+".json")
+}
+
+// LoadExtraction 读取已保存的抽取结果，不存在时返回nil
+func LoadExtraction(result *ParsedResult, schema string) (*ExtractionResult, error) {
+	data, err := os.ReadFile(extractionPath(result, schema))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取抽取结果失败: %w", err)
+	}
+	var extraction ExtractionResult
+	if err := json.Unmarshal(data, &extraction); err != nil {
+		return nil, fmt.Errorf("解析抽取结果失败: %w", err)
+	}
+	return &extraction, nil
+}
+
+// NewResponseFormat 按配置生成response_format：json_schema、json_object，其他值不约束输出
+func NewResponseFormat(kind, name string, schema map[string]interface{}) *ResponseFormat {
+	switch kind {
+	case "json_schema":
+		return &ResponseFormat{Type: "json_schema", JSONSchema: &JSONSchemaSpec{Name: name, Schema: schema, Strict: true}}
+	case "json_object":
+		return &ResponseFormat{Type: "json_object"}
+	default:
+		return nil
+	}
+}
+
+// Extractor 按抽取模式从解析结果中提取结构化信息
+type Extractor struct {
+	client         AIClient
+	rag            *RAGPipeline
+	responseFormat string
+}
+
+// NewExtractor 创建抽取器，responseFormat为json_schema、json_object或空（仅在提示中约束）
+func NewExtractor(client AIClient, rag *RAGPipeline, responseFormat string) *Extractor {
+	return &Extractor{client: client, rag: rag, responseFormat: responseFormat}
+}
+
+// Extract 抽取一篇文献，全文和模式都未变化时返回已保存的结果
+func (e *Extractor) Extract(ctx context.Context, schema *ExtractionSchema, result *ParsedResult, force bool) (*ExtractionResult, error) {
+	ctx = WithUsageFeature(ctx, FeatureExtract)
+	content, err := result.ReadFullText()
+	if err != nil {
+		return nil, err
+	}
+	contentHash := textHash(content)
+	schemaHash := schema.Hash()
+
+	if !force {
+		if saved, err := LoadExtraction(result, schema.Name); err == nil && saved != nil &&
+			saved.Error == "" && saved.SchemaHash == schemaHash && saved.ContentHash == contentHash {
+			saved.Cached = true
+			return saved, nil
+		}
+	}
+
+	chunks, err := e.contextChunks(ctx, schema, result)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("文献 %s 没有可抽取的内容", result.Name)
+	}
+
+	values, err := e.ask(ctx, schema, result.Title(), chunks)
+	if err != nil {
+		return nil, err
+	}
+
+	extraction := &ExtractionResult{
+		Schema:      schema.Name,
+		SchemaHash:  schemaHash,
+		ContentHash: contentHash,
+		Document:    result.Name,
+		Title:       result.Title(),
+		Values:      values,
+		CreatedAt:   time.Now(),
+	}
+	if result.Info != nil {
+		extraction.ItemKey = result.Info.ItemKey
+	}
+
+	data, err := json.MarshalIndent(extraction, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("序列化抽取结果失败: %w", err)
+	}
+	path := extractionPath(result, schema.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建抽取结果目录失败: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入抽取结果失败: %w", err)
+	}
+	return extraction, nil
+}
+
+// contextChunks 选取送入模型的片段：全文不超过预算时整篇送入，否则按字段描述检索
+func (e *Extractor) contextChunks(ctx context.Context, schema *ExtractionSchema, result *ParsedResult) ([]RetrievedChunk, error) {
+	chunks, err := e.rag.Chunks(result.Name)
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, chunk := range chunks {
+		total += chunk.Tokens
+	}
+	if total <= extractionTokenBudget {
+		retrieved := make([]RetrievedChunk, len(chunks))
+		for i, chunk := range chunks {
+			retrieved[i] = RetrievedChunk{Chunk: chunk}
+		}
+		return retrieved, nil
+	}
+
+	var query []string
+	for _, field := range schema.Fields {
+		query = append(query, strings.ReplaceAll(field.Name, "_", " "), field.Description)
+	}
+	retrieved, err := e.rag.Retrieve(ctx, strings.Join(query, " "), RAGOptions{
+		TopK:        extractionTopK,
+		TokenBudget: extractionTokenBudget,
+		Documents:   []string{result.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(retrieved) > 0 {
+		return retrieved, nil
+	}
+
+	// 没有命中时使用开头的片段（通常包含摘要和方法概述）
+	for _, chunk := range chunks {
+		retrieved = append(retrieved, RetrievedChunk{Chunk: chunk})
+	}
+	return PackChunks(retrieved, extractionTokenBudget), nil
+}
+
+// ask 请求模型抽取，输出不符合模式时带上错误说明重试
+func (e *Extractor) ask(ctx context.Context, schema *ExtractionSchema, title string, chunks []RetrievedChunk) ([]ExtractionValue, error) {
+	var fields strings.Builder
+	for _, field := range schema.Fields {
+		fields.WriteString(fmt.Sprintf("- %s（%s）", field.Name, field.Type))
+		if field.Description != "" {
+			fields.WriteString("：" + field.Description)
+		}
+		if len(field.Enum) > 0 {
+			fields.WriteString("，可选值: " + strings.Join(field.Enum, " / "))
+		}
+		fields.WriteString("\n")
+	}
+	schemaJSON, _ := json.Marshal(schema.JSONSchema())
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "你是严谨的学术信息抽取助手。只依据提供的文献片段抽取信息，不要推测；原文未提及的字段value为null、sources为空数组。只输出JSON。"},
+		{Role: "user", Content: fmt.Sprintf(`论文《%s》的片段：
+
+%s
+
+请抽取以下字段：
+%s
+输出一个JSON对象，键为字段名，值为 {"value": 抽取值或null, "sources": [支持该值的片段编号]}。JSON Schema：
+%s`, title, FormatChunkContext(chunks), fields.String(), schemaJSON)},
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= extractionRetries; attempt++ {
+		resp, err := e.client.Chat(ctx, &AIRequest{
+			Messages:       messages,
+			Temperature:    0.1,
+			ResponseFormat: NewResponseFormat(e.responseFormat, schema.Name, schema.JSONSchema()),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("AI抽取失败: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("AI响应为空")
+		}
+		reply := resp.Choices[0].Message.Content
+
+		values, err := parseExtractionReply(schema, reply, chunks)
+		if err == nil {
+			return values, nil
+		}
+		lastErr = err
+		log.Printf("⚠️ 抽取结果不符合模式（第%d次）: %v", attempt+1, err)
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: reply},
+			ChatMessage{Role: "user", Content: "上面的输出不符合要求：" + err.Error() + "。请修正后只输出符合JSON Schema的JSON。"})
+	}
+	return nil, fmt.Errorf("抽取结果不符合模式: %w", lastErr)
+}
+
+// parseExtractionReply 按模式校验模型输出并把片段编号转换为引用
+func parseExtractionReply(schema *ExtractionSchema, reply string, chunks []RetrievedChunk) ([]ExtractionValue, error) {
+	var raw map[string]json.RawMessage
+	if err := decodeJSONReply(reply, &raw); err != nil {
+		return nil, err
+	}
+	citations := CitationsFromChunks(chunks)
+
+	var problems []string
+	values := make([]ExtractionValue, 0, len(schema.Fields))
+	for _, field := range schema.Fields {
+		entry, ok := raw[field.Name]
+		if !ok {
+			problems = append(problems, "缺少字段 "+field.Name)
+			continue
+		}
+		var cell struct {
+			Value   interface{} `json:"value"`
+			Sources []int       `json:"sources"`
+		}
+		if err := json.Unmarshal(entry, &cell); err != nil {
+			problems = append(problems, fmt.Sprintf("字段 %s 应为 {\"value\", \"sources\"} 对象", field.Name))
+			continue
+		}
+		value, err := coerceExtractionValue(field, cell.Value)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+
+		extracted := ExtractionValue{Field: field.Name, Value: value}
+		if value != nil {
+			for _, n := range cell.Sources {
+				if n >= 1 && n <= len(citations) {
+					extracted.Citations = append(extracted.Citations, citations[n-1])
+				}
+			}
+		}
+		values = append(values, extracted)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(problems, "；"))
+	}
+	return values, nil
+}
+
+// coerceExtractionValue 检查取值类型，数字写成字符串等常见偏差会被纠正
+func coerceExtractionValue(field ExtractionField, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if s, ok := value.(string); ok && field.Type != "string" {
+		trimmed := strings.TrimSpace(strings.ReplaceAll(s, ",", ""))
+		switch field.Type {
+		case "number", "integer":
+			if f, err := strconv.ParseFloat(trimmed, 64); err == nil {
+				value = f
+			}
+		case "boolean":
+			if b, err := strconv.ParseBool(trimmed); err == nil {
+				value = b
+			}
+		case "array":
+			value = []interface{}{s}
+		}
+	}
+
+	switch field.Type {
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("字段 %s 应为字符串", field.Name)
+		}
+		if len(field.Enum) > 0 {
+			for _, option := range field.Enum {
+				if strings.EqualFold(option, s) {
+					return option, nil
+				}
+			}
+			return nil, fmt.Errorf("字段 %s 的取值 %q 不在 %v 中", field.Name, s, field.Enum)
+		}
+		return s, nil
+	case "number":
+		if f, ok := value.(float64); ok {
+			return f, nil
+		}
+		return nil, fmt.Errorf("字段 %s 应为数字", field.Name)
+	case "integer":
+		if f, ok := value.(float64); ok && f == math.Trunc(f) {
+			return f, nil
+		}
+		return nil, fmt.Errorf("字段 %s 应为整数", field.Name)
+	case "boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("字段 %s 应为布尔值", field.Name)
+	case "array":
+		if items, ok := value.([]interface{}); ok {
+			return items, nil
+		}
+		return nil, fmt.Errorf("字段 %s 应为数组", field.Name)
+	}
+	return value, nil
+}
+
+// ExtractionMatrix 多篇文献的抽取结果矩阵
+type ExtractionMatrix struct {
+	Schema *ExtractionSchema  `json:"schema"`
+	Rows   []ExtractionResult `json:"rows"`
+}
+
+// ExtractAll 依次抽取多篇文献，单篇失败时在对应行记录错误；progress可为nil
+func (e *Extractor) ExtractAll(ctx context.Context, schema *ExtractionSchema, results []ParsedResult, force bool, progress func(done, total int, row *ExtractionResult)) *ExtractionMatrix {
+	matrix := &ExtractionMatrix{Schema: schema}
+	for i := range results {
+		if ctx.Err() != nil {
+			break
+		}
+		row, err := e.Extract(ctx, schema, &results[i], force)
+		if err != nil {
+			log.Printf("⚠️ 抽取失败 %s: %v", results[i].Name, err)
+			row = &ExtractionResult{
+				Schema:   schema.Name,
+				Document: results[i].Name,
+				Title:    results[i].Title(),
+				Error:    err.Error(),
+			}
+			if results[i].Info != nil {
+				row.ItemKey = results[i].Info.ItemKey
+			}
+		}
+		matrix.Rows = append(matrix.Rows, *row)
+		if progress != nil {
+			progress(i+1, len(results), row)
+		}
+	}
+	return matrix
+}
+
+// formatExtractionValue 单元格文本
+func formatExtractionValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "是"
+		}
+		return "否"
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = formatExtractionValue(item)
+		}
+		return strings.Join(parts, "; ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// citationLocation 引用位置的简短描述：p.3 §Methods
+func citationLocation(citation Citation) string {
+	var parts []string
+	if citation.Page > 0 {
+		parts = append(parts, fmt.Sprintf("p.%d", citation.Page))
+	}
+	if citation.Section != "" {
+		parts = append(parts, "§"+citation.Section)
+	}
+	if len(parts) == 0 {
+		return citation.ChunkID
+	}
+	return strings.Join(parts, " ")
+}
+
+// sourceText 字段来源列的文本
+func sourceText(value *ExtractionValue) string {
+	if value == nil {
+		return ""
+	}
+	locations := make([]string, len(value.Citations))
+	for i, citation := range value.Citations {
+		locations[i] = citationLocation(citation)
+	}
+	return strings.Join(locations, "; ")
+}
+
+// CSV 导出CSV：每个字段一列值、一列来源；excel为true时添加UTF-8 BOM并使用CRLF换行，便于Excel直接打开
+func (m *ExtractionMatrix) CSV(excel bool) ([]byte, error) {
+	var buf bytes.Buffer
+	if excel {
+		buf.WriteString("\ufeff")
+	}
+	writer := csv.NewWriter(&buf)
+	writer.UseCRLF = excel
+
+	header := []string{"document", "title", "item_key"}
+	for _, field := range m.Schema.Fields {
+		header = append(header, field.Name, field.Name+"_source")
+	}
+	header = append(header, "error")
+	if err := writer.Write(header); err != nil {
+		return nil, fmt.Errorf("写入CSV失败: %w", err)
+	}
+
+	for _, row := range m.Rows {
+		record := []string{row.Document, row.Title, row.ItemKey}
+		for _, field := range m.Schema.Fields {
+			value := row.Value(field.Name)
+			text := ""
+			if value != nil {
+				text = formatExtractionValue(value.Value)
+			}
+			record = append(record, text, sourceText(value))
+		}
+		record = append(record, row.Error)
+		if err := writer.Write(record); err != nil {
+			return nil, fmt.Errorf("写入CSV失败: %w", err)
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("写入CSV失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Markdown 导出Markdown表格，值后附来源页码
+func (m *ExtractionMatrix) Markdown() string {
+	escape := func(text string) string {
+		text = strings.ReplaceAll(text, "|", "\\|")
+		return strings.Join(strings.Fields(text), " ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("| 文献 |")
+	for _, field := range m.Schema.Fields {
+		sb.WriteString(" " + escape(field.Name) + " |")
+	}
+	sb.WriteString("\n|---|")
+	sb.WriteString(strings.Repeat("---|", len(m.Schema.Fields)))
+	sb.WriteString("\n")
+
+	for _, row := range m.Rows {
+		sb.WriteString("| " + escape(row.Title) + " |")
+		for _, field := range m.Schema.Fields {
+			cell := ""
+			switch value := row.Value(field.Name); {
+			case row.Error != "":
+				cell = "⚠️"
+			case value == nil || value.Value == nil:
+				cell = "—"
+			default:
+				cell = escape(formatExtractionValue(value.Value))
+				if source := sourceText(value); source != "" {
+					cell += " <sub>" + escape(source) + "</sub>"
+				}
+			}
+			sb.WriteString(" " + cell + " |")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Export 按格式导出：csv、excel（带BOM的CSV）、md、json，返回内容、Content-Type和扩展名
+func (m *ExtractionMatrix) Export(format string) ([]byte, string, string, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		data, err := m.CSV(false)
+		return data, "text/csv; charset=utf-8", "csv", err
+	case "excel", "xlsx":
+		data, err := m.CSV(true)
+		return data, "text/csv; charset=utf-8", "csv", err
+	case "md", "markdown":
+		return []byte(m.Markdown()), "text/markdown; charset=utf-8", "md", nil
+	case "", "json":
+		data, err := json.MarshalIndent(m, "", "  ")
+		if err != nil {
+			return nil, "", "", fmt.Errorf("序列化抽取矩阵失败: %w", err)
+		}
+		return data, "application/json; charset=utf-8", "json", nil
+	default:
+		return nil, "", "", fmt.Errorf("不支持的导出格式: %s（可选 csv、excel、md、json）", format)
+	}
+}
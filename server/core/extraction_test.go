@@ -0,0 +1,153 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParseExtractionFields(t *testing.T) {
+	schema, err := ParseExtractionFields("matrix", "dataset, sample_size:integer:样本量, ,open_source:boolean")
+	if err != nil {
+		t.Fatalf("ParseExtractionFields() error = %v", err)
+	}
+	if len(schema.Fields) != 3 || schema.Fields[0].Type != "string" || schema.Fields[1].Description != "样本量" {
+		t.Errorf("ParseExtractionFields() = %+v", schema.Fields)
+	}
+
+	invalid := []struct {
+		name, spec string
+	}{
+		{"matrix", ""},
+		{"../x", "dataset"},
+		{"matrix", "dataset, dataset"},
+		{"matrix", "size:float"},
+	}
+	for _, tt := range invalid {
+		if _, err := ParseExtractionFields(tt.name, tt.spec); err == nil {
+			t.Errorf("ParseExtractionFields(%q, %q) 应返回错误", tt.name, tt.spec)
+		}
+	}
+
+	enumSchema := &ExtractionSchema{Name: "m", Fields: []ExtractionField{{Name: "size", Type: "integer", Enum: []string{"a"}}}}
+	if err := enumSchema.Validate(); err == nil {
+		t.Error("非string字段不应支持enum")
+	}
+}
+
+func TestExtractorRetriesAndCaches(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	result, _ := GetParsedResult(resultsDir, "attention_20240101")
+	schema, _ := ParseExtractionFields("matrix", "architecture, layers:integer, task")
+	schema.Fields[2].Enum = []string{"Translation", "Parsing"}
+
+	calls := 0
+	client := &scriptedAIClient{respond: func(prompt string) string {
+		calls++
+		if calls == 1 {
+			// 缺少字段，应带错误说明重试
+			return `{"architecture": {"value": "Transformer", "sources": [2]}}`
+		}
+		return "```json\n" + `{"architecture": {"value": "Transformer", "sources": [2, 9]},
+			"layers": {"value": "6", "sources": [2]},
+			"task": {"value": "translation", "sources": [3]}}` + "\n```"
+	}}
+	extractor := NewExtractor(client, NewRAGPipeline(resultsDir), "json_object")
+
+	extraction, err := extractor.Extract(context.Background(), schema, result, false)
+	if err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	if len(client.prompts) != 2 || !strings.Contains(client.prompts[1], "缺少字段 layers") {
+		t.Errorf("不符合模式时应重试并说明错误: %q", client.prompts)
+	}
+	if extraction.ItemKey != "ABCD1234" || extraction.Cached {
+		t.Errorf("Extract() = %+v", extraction)
+	}
+
+	architecture := extraction.Value("architecture")
+	if architecture.Value != "Transformer" || len(architecture.Citations) != 1 || architecture.Citations[0].Page != 2 {
+		t.Errorf("越界的片段编号应被忽略, 引用应带页码: %+v", architecture)
+	}
+	if layers := extraction.Value("layers"); layers.Value != float64(6) {
+		t.Errorf("数字字符串应转换为整数: %#v", layers.Value)
+	}
+	if task := extraction.Value("task"); task.Value != "Translation" {
+		t.Errorf("enum应规范为定义的取值: %#v", task.Value)
+	}
+
+	again, err := extractor.Extract(context.Background(), schema, result, false)
+	if err != nil || !again.Cached || len(client.prompts) != 2 {
+		t.Errorf("模式和全文未变化时应使用已保存结果: cached=%v calls=%d err=%v", again.Cached, len(client.prompts), err)
+	}
+
+	// 模式变化后重新抽取
+	schema.Fields[0].Description = "模型架构"
+	if _, err := extractor.Extract(context.Background(), schema, result, false); err != nil || len(client.prompts) != 3 {
+		t.Errorf("模式变化后应重新抽取: calls=%d err=%v", len(client.prompts), err)
+	}
+}
+
+func TestCoerceExtractionValue(t *testing.T) {
+	tests := []struct {
+		field   ExtractionField
+		value   interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{ExtractionField{Name: "n", Type: "number"}, "1,024.5", 1024.5, false},
+		{ExtractionField{Name: "n", Type: "integer"}, 2.5, nil, true},
+		{ExtractionField{Name: "b", Type: "boolean"}, "true", true, false},
+		{ExtractionField{Name: "s", Type: "string"}, 3.0, nil, true},
+		{ExtractionField{Name: "s", Type: "string", Enum: []string{"A"}}, "B", nil, true},
+		{ExtractionField{Name: "s", Type: "string"}, nil, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := coerceExtractionValue(tt.field, tt.value)
+		if (err != nil) != tt.wantErr || (!tt.wantErr && got != tt.want) {
+			t.Errorf("coerceExtractionValue(%s, %#v) = %#v, %v", tt.field.Type, tt.value, got, err)
+		}
+	}
+
+	got, err := coerceExtractionValue(ExtractionField{Name: "a", Type: "array"}, "WMT 2014")
+	if items, ok := got.([]interface{}); err != nil || !ok || len(items) != 1 {
+		t.Errorf("单个字符串应包装为数组: %#v, %v", got, err)
+	}
+}
+
+func TestExtractionMatrixExport(t *testing.T) {
+	schema := &ExtractionSchema{Name: "matrix", Fields: []ExtractionField{{Name: "dataset"}, {Name: "size", Type: "integer"}}}
+	matrix := &ExtractionMatrix{Schema: schema, Rows: []ExtractionResult{
+		{Document: "a", Title: "Paper | A", Values: []ExtractionValue{
+			{Field: "dataset", Value: "WMT, 2014", Citations: []Citation{{ChunkID: "a#1", Page: 3, Section: "Data"}}},
+			{Field: "size", Value: float64(4500000)},
+		}},
+		{Document: "b", Title: "Paper B", Error: "AI抽取失败"},
+	}}
+
+	data, err := matrix.CSV(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if lines[0] != "document,title,item_key,dataset,dataset_source,size,size_source,error" {
+		t.Errorf("CSV表头 = %q", lines[0])
+	}
+	if lines[1] != `a,Paper | A,,"WMT, 2014",p.3 §Data,4500000,,` || !strings.HasSuffix(lines[2], "AI抽取失败") {
+		t.Errorf("CSV内容 = %q", lines[1:])
+	}
+
+	excel, _, ext, err := matrix.Export("excel")
+	if err != nil || !strings.HasPrefix(string(excel), "\ufeff") || !strings.Contains(string(excel), "\r\n") || ext != "csv" {
+		t.Errorf("excel导出应带BOM和CRLF: %q, %v", excel, err)
+	}
+
+	md := matrix.Markdown()
+	if !strings.Contains(md, "| Paper \\| A | WMT, 2014 <sub>p.3 §Data</sub> | 4500000 |") || !strings.Contains(md, "| Paper B | ⚠️ | ⚠️ |") {
+		t.Errorf("Markdown() = %s", md)
+	}
+
+	if _, _, _, err := matrix.Export("xls"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
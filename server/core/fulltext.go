@@ -0,0 +1,497 @@
+package core
+
+import (
+	"encoding/gob"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIndexDir 检索索引的默认目录
+const DefaultIndexDir = "data/index"
+
+// fullTextIndexFileName 全文索引文件名（含词项位置，体积较大，使用gob存储）
+const fullTextIndexFileName = "fulltext.gob"
+
+// 全文来源类型
+const (
+	FullTextKindResult = "result" // 解析结果的full.md
+	FullTextKindZotero = "zotero" // Zotero存储目录中的.zotero-ft-cache
+	FullTextKindNote   = "note"   // Zotero笔记（正文来自数据库）
+)
+
+// zoteroFullTextCacheName Zotero为附件提取的全文缓存文件名
+const zoteroFullTextCacheName = ".zotero-ft-cache"
+
+// FullTextSource 全文索引的一个来源文件
+type FullTextSource struct {
+	ID       string `json:"id"` // result:<结果目录名>、zotero:<附件Key> 或 note:<笔记Key>
+	Kind     string `json:"kind"`
+	ItemKey  string `json:"item_key,omitempty"`
+	Document string `json:"document,omitempty"` // 解析结果目录名
+	Title    string `json:"title"`
+	Path     string `json:"path"`
+	// Text Path为空时直接索引的正文（如Zotero笔记），随索引保存以便生成摘录
+	Text string `json:"-"`
+}
+
+// fullTextDoc 已索引的文档：词项及其出现位置
+type fullTextDoc struct {
+	FullTextSource
+	ModTime time.Time
+	Size    int64
+	Length  int
+	Terms   map[string][]int
+}
+
+// FullTextHit 全文搜索命中
+type FullTextHit struct {
+	FullTextSource
+	Score   float64     `json:"score"`
+	Snippet string      `json:"snippet"`
+	Item    *ZoteroItem `json:"item,omitempty"` // 由ZoteroDB.SearchFullText补充
+}
+
+// FullTextUpdateStats 增量更新统计
+type FullTextUpdateStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// FullTextIndex 基于倒排索引的全文检索（BM25排序，支持引号短语查询）
+type FullTextIndex struct {
+	path string
+
+	mu       sync.RWMutex
+	docs     map[string]*fullTextDoc
+	postings map[string][]*fullTextDoc
+	totalLen int
+}
+
+// OpenFullTextIndex 打开dir下的全文索引，不存在时创建空索引
+func OpenFullTextIndex(dir string) (*FullTextIndex, error) {
+	index := &FullTextIndex{
+		path: filepath.Join(dir, fullTextIndexFileName),
+		docs: make(map[string]*fullTextDoc),
+	}
+
+	file, err := os.Open(index.path)
+	if os.IsNotExist(err) {
+		index.rebuildPostings()
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取全文索引失败: %w", err)
+	}
+	defer file.Close()
+
+	var docs []*fullTextDoc
+	if err := gob.NewDecoder(file).Decode(&docs); err != nil {
+		log.Printf("⚠️ 全文索引损坏，将重建: %v", err)
+		docs = nil
+	}
+	for _, doc := range docs {
+		index.docs[doc.ID] = doc
+	}
+	index.rebuildPostings()
+	return index, nil
+}
+
+// Len 已索引的文档数
+func (idx *FullTextIndex) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.docs)
+}
+
+// Update 以sources为完整集合增量更新索引：只重新分词修改过的文件，删除不再存在的来源并保存
+func (idx *FullTextIndex) Update(sources []FullTextSource) (*FullTextUpdateStats, error) {
+	stats := &FullTextUpdateStats{}
+	wanted := make(map[string]bool, len(sources))
+
+	idx.mu.Lock()
+	for _, source := range sources {
+		wanted[source.ID] = true
+		switch idx.upsert(source) {
+		case upsertAdded:
+			stats.Added++
+		case upsertUpdated:
+			stats.Updated++
+		case upsertUnchanged:
+			stats.Unchanged++
+		}
+	}
+	for id := range idx.docs {
+		if !wanted[id] {
+			delete(idx.docs, id)
+			stats.Removed++
+		}
+	}
+	idx.rebuildPostings()
+	idx.mu.Unlock()
+
+	if stats.Added+stats.Updated+stats.Removed > 0 {
+		log.Printf("🔎 全文索引更新: 新增 %d，更新 %d，删除 %d，未变 %d", stats.Added, stats.Updated, stats.Removed, stats.Unchanged)
+	}
+	return stats, idx.Save()
+}
+
+// Add 增量添加或刷新单个来源并保存，不影响其他来源
+func (idx *FullTextIndex) Add(source FullTextSource) error {
+	idx.mu.Lock()
+	result := idx.upsert(source)
+	idx.rebuildPostings()
+	idx.mu.Unlock()
+
+	if result == upsertFailed {
+		return fmt.Errorf("索引全文失败: %s", source.Path)
+	}
+	return idx.Save()
+}
+
+// upsert 结果
+const (
+	upsertFailed = iota
+	upsertAdded
+	upsertUpdated
+	upsertUnchanged
+)
+
+// upsert 添加或更新来源（调用方持有写锁）；文件或正文未变化时只刷新标题等元数据
+func (idx *FullTextIndex) upsert(source FullTextSource) int {
+	existing, ok := idx.docs[source.ID]
+	doc := &fullTextDoc{FullTextSource: source, Terms: make(map[string][]int)}
+	var terms []string
+
+	if source.Path == "" {
+		if ok && existing.Text == source.Text {
+			existing.FullTextSource = source
+			return upsertUnchanged
+		}
+		doc.Size = int64(len(source.Text))
+		terms = tokenize(source.Text)
+	} else {
+		stat, err := os.Stat(source.Path)
+		if err != nil {
+			log.Printf("⚠️ 全文文件不可用 %s: %v", source.Path, err)
+			delete(idx.docs, source.ID)
+			return upsertFailed
+		}
+		if ok && existing.ModTime.Equal(stat.ModTime()) && existing.Size == stat.Size() {
+			existing.FullTextSource = source
+			return upsertUnchanged
+		}
+
+		data, err := os.ReadFile(source.Path)
+		if err != nil {
+			log.Printf("⚠️ 读取全文失败 %s: %v", source.Path, err)
+			return upsertFailed
+		}
+		doc.ModTime, doc.Size = stat.ModTime(), stat.Size()
+		terms = tokenize(string(data))
+	}
+
+	doc.Length = len(terms)
+	for pos, term := range terms {
+		doc.Terms[term] = append(doc.Terms[term], pos)
+	}
+	idx.docs[source.ID] = doc
+
+	if ok {
+		return upsertUpdated
+	}
+	return upsertAdded
+}
+
+// rebuildPostings 由各文档的词项重建倒排表（调用方持有写锁）
+func (idx *FullTextIndex) rebuildPostings() {
+	idx.postings = make(map[string][]*fullTextDoc)
+	idx.totalLen = 0
+	for _, doc := range idx.docs {
+		idx.totalLen += doc.Length
+		for term := range doc.Terms {
+			idx.postings[term] = append(idx.postings[term], doc)
+		}
+	}
+}
+
+// Save 原子写入索引文件
+func (idx *FullTextIndex) Save() error {
+	idx.mu.RLock()
+	docs := make([]*fullTextDoc, 0, len(idx.docs))
+	for _, doc := range idx.docs {
+		docs = append(docs, doc)
+	}
+	dir := filepath.Dir(idx.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		idx.mu.RUnlock()
+		return fmt.Errorf("创建索引目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, ".fulltext-*.tmp")
+	if err != nil {
+		idx.mu.RUnlock()
+		return fmt.Errorf("写入全文索引失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	err = gob.NewEncoder(tmp).Encode(docs)
+	idx.mu.RUnlock()
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("写入全文索引失败: %w", err)
+	}
+	return os.Rename(tmp.Name(), idx.path)
+}
+
+// fullTextQuery 解析后的查询：引号内为必须连续出现的短语，其余为普通词
+type fullTextQuery struct {
+	phrases [][]string
+	terms   []string
+	needles []string // 用于定位摘录的原始查询片段
+}
+
+var phrasePattern = regexp.MustCompile(`"([^"]+)"|“([^”]+)”`)
+
+// parseFullTextQuery 解析查询文本
+func parseFullTextQuery(query string) fullTextQuery {
+	var q fullTextQuery
+	for _, match := range phrasePattern.FindAllStringSubmatch(query, -1) {
+		phrase := match[1] + match[2]
+		if terms := tokenize(phrase); len(terms) > 0 {
+			q.phrases = append(q.phrases, terms)
+			q.needles = append(q.needles, phrase)
+		}
+	}
+	rest := phrasePattern.ReplaceAllString(query, " ")
+	q.terms = tokenize(rest)
+	q.needles = append(q.needles, strings.Fields(rest)...)
+	return q
+}
+
+// allTerms 查询中的全部词项（去重）
+func (q fullTextQuery) allTerms() []string {
+	var terms []string
+	for _, phrase := range q.phrases {
+		terms = append(terms, phrase...)
+	}
+	return uniqueTerms(append(terms, q.terms...))
+}
+
+// Search 全文搜索：有短语时只返回包含全部短语的文档，按BM25得分排序
+//
+// 同一条目同时有解析结果和Zotero全文缓存时只保留得分较高的一个
+func (idx *FullTextIndex) Search(query string, limit int) ([]FullTextHit, error) {
+	q := parseFullTextQuery(query)
+	terms := q.allTerms()
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("查询内容为空")
+	}
+
+	idx.mu.RLock()
+	n := float64(len(idx.docs))
+	avgLen := 1.0
+	if n > 0 && idx.totalLen > 0 {
+		avgLen = float64(idx.totalLen) / n
+	}
+
+	candidates := make(map[string]*fullTextDoc)
+	for _, term := range terms {
+		for _, doc := range idx.postings[term] {
+			candidates[doc.ID] = doc
+		}
+	}
+
+	var hits []FullTextHit
+	for _, doc := range candidates {
+		if !matchesPhrases(doc, q.phrases) {
+			continue
+		}
+		score := 0.0
+		for _, term := range terms {
+			freq := float64(len(doc.Terms[term]))
+			if freq == 0 {
+				continue
+			}
+			df := float64(len(idx.postings[term]))
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*float64(doc.Length)/avgLen))
+		}
+		hits = append(hits, FullTextHit{FullTextSource: doc.FullTextSource, Score: score})
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	hits = dedupeHitsByItem(hits)
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+
+	for i := range hits {
+		if hits[i].Path == "" {
+			hits[i].Snippet = makeSnippet(hits[i].Text, q.needles)
+		} else if data, err := os.ReadFile(hits[i].Path); err == nil {
+			hits[i].Snippet = makeSnippet(string(data), q.needles)
+		}
+	}
+	return hits, nil
+}
+
+// matchesPhrases 文档是否按顺序连续包含每个短语的全部词项
+func matchesPhrases(doc *fullTextDoc, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		found := false
+		for _, start := range doc.Terms[phrase[0]] {
+			found = true
+			for offset, term := range phrase[1:] {
+				if !containsPosition(doc.Terms[term], start+offset+1) {
+					found = false
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// containsPosition 在升序位置列表中查找
+func containsPosition(positions []int, pos int) bool {
+	i := sort.SearchInts(positions, pos)
+	return i < len(positions) && positions[i] == pos
+}
+
+// dedupeHitsByItem 同一条目只保留排序靠前的命中
+func dedupeHitsByItem(hits []FullTextHit) []FullTextHit {
+	seen := make(map[string]bool)
+	deduped := hits[:0]
+	for _, hit := range hits {
+		if hit.ItemKey != "" {
+			if seen[hit.ItemKey] {
+				continue
+			}
+			seen[hit.ItemKey] = true
+		}
+		deduped = append(deduped, hit)
+	}
+	return deduped
+}
+
+// snippetRadius 摘录中命中位置前后保留的字符数
+const snippetRadius = 60
+
+// makeSnippet 截取第一个查询片段附近的文本作为摘录
+func makeSnippet(text string, needles []string) string {
+	lower := strings.ToLower(text)
+	pos := -1
+	for _, needle := range needles {
+		if i := strings.Index(lower, strings.ToLower(needle)); i >= 0 && (pos < 0 || i < pos) {
+			pos = i
+		}
+	}
+	if pos < 0 {
+		pos = 0
+	}
+
+	runes := []rune(text)
+	center := len([]rune(text[:min(pos, len(text))]))
+	start := max(center-snippetRadius, 0)
+	end := min(center+snippetRadius, len(runes))
+	snippet := strings.Join(strings.Fields(string(runes[start:end])), " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return snippet
+}
+
+// ResultFullTextSources 列出解析结果中的full.md作为全文来源
+func ResultFullTextSources(resultsDir string) ([]FullTextSource, error) {
+	results, err := ListParsedResults(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+	sources := make([]FullTextSource, 0, len(results))
+	for _, result := range results {
+		sources = append(sources, resultFullTextSource(result))
+	}
+	return sources, nil
+}
+
+// CollectFullTextSources 汇总解析结果、Zotero全文缓存和Zotero笔记三类来源，zoteroDB为nil时只包含解析结果
+func CollectFullTextSources(zoteroDB *ZoteroDB, resultsDir string) ([]FullTextSource, error) {
+	sources, err := ResultFullTextSources(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+	if zoteroDB != nil {
+		cached, err := zoteroDB.FullTextCacheSources()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, cached...)
+		notes, err := zoteroDB.NoteFullTextSources()
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, notes...)
+	}
+	return sources, nil
+}
+
+// resultFullTextSource 解析结果对应的全文来源
+func resultFullTextSource(result ParsedResult) FullTextSource {
+	source := FullTextSource{
+		ID:       "result:" + result.Name,
+		Kind:     FullTextKindResult,
+		Document: result.Name,
+		Title:    result.Title(),
+		Path:     result.FullTextPath(),
+	}
+	if result.Info != nil {
+		source.ItemKey = result.Info.ItemKey
+	}
+	return source
+}
+
+// IndexParsedResult 将解析结果目录增量加入indexDir下的全文索引（为空时使用默认目录），供组织结果和关联条目后调用
+func IndexParsedResult(dir, indexDir string) error {
+	if indexDir == "" {
+		indexDir = DefaultIndexDir
+	}
+	result, err := GetParsedResult(filepath.Dir(dir), filepath.Base(dir))
+	if err != nil {
+		return err
+	}
+	index, err := OpenFullTextIndex(indexDir)
+	if err != nil {
+		return err
+	}
+	if err := index.Add(resultFullTextSource(*result)); err != nil {
+		return err
+	}
+	log.Printf("🔎 已加入全文索引: %s", result.Name)
+	return nil
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFullTextIndexSearch(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	other := filepath.Join(resultsDir, "folding_20240101")
+	os.MkdirAll(other, 0755)
+	os.WriteFile(filepath.Join(other, "full.md"), []byte("# Protein Folding\n\nResidue contacts predict protein structure. Attention over residues helps.\n"), 0644)
+
+	db := newFixtureZoteroDB(t)
+	cacheDir := filepath.Join(db.dataDir, "PDF00001")
+	os.MkdirAll(cacheDir, 0755)
+	os.WriteFile(filepath.Join(cacheDir, zoteroFullTextCacheName), []byte("Attention is all you need. Multi-head attention allows the model to attend jointly."), 0644)
+
+	sources, err := CollectFullTextSources(db, resultsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 3 {
+		t.Fatalf("应有2个解析结果和1个Zotero全文缓存, got %+v", sources)
+	}
+
+	index, err := OpenFullTextIndex(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := index.Update(sources); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// 同一条目的解析结果和Zotero全文缓存合并为一条
+	hits, err := db.SearchFullText(index, "attention", 10)
+	if err != nil {
+		t.Fatalf("SearchFullText() error = %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("SearchFullText(attention) = %+v", hits)
+	}
+	for _, hit := range hits {
+		if hit.ItemKey == "ABCD1234" && (hit.Item == nil || hit.Item.Year != 2017) {
+			t.Errorf("命中应补充条目信息: %+v", hit)
+		}
+	}
+
+	// 短语需连续出现
+	hits, _ = index.Search(`"multi-head attention"`, 10)
+	if len(hits) != 1 || hits[0].Kind != FullTextKindZotero || !strings.Contains(hits[0].Snippet, "Multi-head attention") {
+		t.Errorf("短语查询 = %+v", hits)
+	}
+	if hits, _ := index.Search(`"attention residues"`, 10); len(hits) != 0 {
+		t.Errorf("不连续的短语不应命中: %+v", hits)
+	}
+
+	// 中文双字切分
+	hits, _ = index.Search("机器翻译", 10)
+	if len(hits) != 1 || hits[0].Document != "attention_20240101" {
+		t.Errorf("中文查询 = %+v", hits)
+	}
+	hits, _ = index.Search(`“翻译任务”`, 10)
+	if len(hits) != 1 {
+		t.Errorf("中文短语查询 = %+v", hits)
+	}
+}
+
+func TestFullTextIndexIncremental(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	indexDir := t.TempDir()
+
+	index, _ := OpenFullTextIndex(indexDir)
+	sources, _ := ResultFullTextSources(resultsDir)
+	if _, err := index.Update(sources); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新打开后文件未变化则不重新分词
+	reopened, err := OpenFullTextIndex(indexDir)
+	if err != nil || reopened.Len() != 1 {
+		t.Fatalf("索引未持久化: Len() = %d, err = %v", reopened.Len(), err)
+	}
+	stats, _ := reopened.Update(sources)
+	if stats.Unchanged != 1 || stats.Added+stats.Updated != 0 {
+		t.Errorf("未变化时 stats = %+v", *stats)
+	}
+
+	fullPath := filepath.Join(resultsDir, "attention_20240101", "full.md")
+	os.WriteFile(fullPath, []byte("# Updated\n\nGraph neural networks."), 0644)
+	os.Chtimes(fullPath, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	stats, _ = reopened.Update(sources)
+	if stats.Updated != 1 {
+		t.Errorf("修改后 stats = %+v", *stats)
+	}
+	if hits, _ := reopened.Search("graph networks", 5); len(hits) != 1 {
+		t.Errorf("修改后的内容应可检索: %+v", hits)
+	}
+	if hits, _ := reopened.Search("transformer", 5); len(hits) != 0 {
+		t.Errorf("旧内容不应再命中: %+v", hits)
+	}
+
+	stats, _ = reopened.Update(nil)
+	if stats.Removed != 1 || reopened.Len() != 0 {
+		t.Errorf("删除来源后 stats = %+v, Len() = %d", *stats, reopened.Len())
+	}
+
+	if _, err := reopened.Search("  ", 5); err == nil {
+		t.Error("空查询应返回错误")
+	}
+}
+
+func TestOrganizeResultUsesIndexDir(t *testing.T) {
+	tmp := t.TempDir()
+	zipPath := filepath.Join(tmp, "attention.zip")
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := zip.NewWriter(file)
+	w, _ := archive.Create("full.md")
+	w.Write([]byte(ragFixtureMarkdown))
+	archive.Close()
+	file.Close()
+	pdfPath := filepath.Join(tmp, "Vaswani - 2017 - Attention.pdf")
+	os.WriteFile(pdfPath, []byte("%PDF-1.4"), 0644)
+
+	resultsDir, indexDir := filepath.Join(tmp, "results"), filepath.Join(tmp, "index")
+	if err := OrganizeResult(zipPath, pdfPath, resultsDir, indexDir); err != nil {
+		t.Fatal(err)
+	}
+	index, err := OpenFullTextIndex(indexDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := index.Search("self-attention", 10); len(hits) != 1 {
+		t.Errorf("配置的索引目录中应有新结果: %+v", hits)
+	}
+	if _, err := os.Stat(DefaultIndexDir); !os.IsNotExist(err) {
+		t.Errorf("不应写入默认索引目录 %s", DefaultIndexDir)
+	}
+}
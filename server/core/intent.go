@@ -0,0 +1,242 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Intent 用户查询的意图
+type Intent string
+
+// 支持的意图
+const (
+	IntentViewPDF  Intent = "view_pdf"           // 打开或预览某篇文献的PDF
+	IntentRelated  Intent = "related_literature" // 查找与文献或主题相关、相似的文献
+	IntentFullText Intent = "fulltext_search"    // 在已解析文献的全文中搜索
+	IntentSearch   Intent = "library_search"     // 按标题、作者、DOI等在文献库中查找条目
+	IntentAnalyze  Intent = "analyze"            // 总结或分析指定文献
+	IntentChat     Intent = "chat"               // 其他问题，交给AI对话回答
+)
+
+// Intents 全部意图及说明，用于AI分类提示
+var Intents = []struct {
+	Intent      Intent
+	Description string
+}{
+	{IntentViewPDF, "打开、查看或预览某篇文献的PDF原文"},
+	{IntentRelated, "查找、推荐与某篇文献或某个主题相关或相似的其他文献"},
+	{IntentFullText, "在已解析文献的全文内容中搜索词语或短语"},
+	{IntentSearch, "在文献库中按标题、作者、年份、DOI或分类查找条目"},
+	{IntentAnalyze, "总结、概括或分析指定的文献"},
+	{IntentChat, "其他问题：概念解释、针对文献内容的提问（如某个章节讲了什么）等"},
+}
+
+// intentClassifyTimeout AI分类的超时时间，超时后使用规则分类
+const intentClassifyTimeout = 15 * time.Second
+
+// IntentSlots 从查询中提取的参数
+type IntentSlots struct {
+	Document   string `json:"document,omitempty"`   // 文献标题或名称
+	DOI        string `json:"doi,omitempty"`        // DOI
+	Collection string `json:"collection,omitempty"` // Zotero分类
+	Question   string `json:"question,omitempty"`   // 去掉指令词后的查询内容
+}
+
+// IntentResult 意图分类结果
+type IntentResult struct {
+	Intent     Intent      `json:"intent"`
+	Slots      IntentSlots `json:"slots"`
+	Confidence float64     `json:"confidence"`
+	Source     string      `json:"source"` // ai 或 rules
+}
+
+var (
+	doiPattern = regexp.MustCompile(`\b10\.\d{4,9}/[^\s"'“”《》「」，。；\p{Han}]+`)
+	// quotedPattern 用书名号或引号标出的文献名
+	quotedPattern = regexp.MustCompile(`《([^》]+)》|“([^”]+)”|"([^"]+)"|「([^」]+)」`)
+	// collectionPatterns 分类名：in collection X / collection: X / 在X分类中
+	collectionPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`(?i)\b(?:in|from)\s+(?:the\s+|my\s+)?collection\s+["“]?([^"”,.?]+?)["”]?(?:\s*[,.?]|\s+(?:about|on|for|that|with)\b|$)`),
+		regexp.MustCompile(`(?i)\b(?:in|from)\s+(?:the\s+|my\s+)?["“]?([^"”\s]+)["”]?\s+collection\b`),
+		regexp.MustCompile(`(?i)\bcollection\s*[:：]\s*(\S+)`),
+		regexp.MustCompile(`[在从]\s*[「“"]?([^\s「」“”"，。]+?)[」”"]?\s*(?:分类|收藏夹|文件夹)`),
+		regexp.MustCompile(`[「“"]?([A-Za-z0-9][A-Za-z0-9_\-]*)[」”"]?\s*(?:分类|收藏夹|文件夹)`),
+	}
+
+	// intentFalseFriends 含有意图关键词但表达其他含义的短语，匹配规则前先移除
+	intentFalseFriends = regexp.MustCompile(`(?i)related\s+works?|相关工作|open[\s-]+(?:questions?|problems?|issues?|challenges?|source)|开放(?:性)?(?:问题|挑战)|开源`)
+	// documentPartPattern 询问文献内部某个部分，应由对话回答而不是搜索
+	documentPartPattern = regexp.MustCompile(`(?i)\b(?:section|paragraph|chapter|figure|table|appendix|equation)s?\b|章节|段落|小节|附录|图\d|表\d|公式`)
+
+	fullTextPattern = regexp.MustCompile(`(?i)全文|full[\s-]?text`)
+	relatedPattern  = regexp.MustCompile(`(?i)相关(?:的)?(?:文献|论文|研究|文章)|(?:相似|类似)(?:的)?(?:文献|论文|研究|文章|工作)|推荐.*(?:文献|论文|文章|阅读)|\brelated\s+(?:papers?|literature|articles?|studies|research|publications?)\b|\b(?:papers?|articles?|studies|literature|research|publications?|work)\s+(?:related|similar)\s+to\b|\bsimilar\s+(?:papers?|articles?|studies|research|publications?|work)\b|\brecommend\b`)
+	viewPattern     = regexp.MustCompile(`(?i)^(?:请|帮我|麻烦)?\s*(?:查看|预览|打开|阅读|(?:open|view|preview|read)(?:\s|$|[《“"「]|\p{Han}))`)
+	searchPattern   = regexp.MustCompile(`(?i)^(?:请|帮我|麻烦)?\s*(?:搜索|查找|检索|找一下|找找|找|搜|(?:search|find|look\s+up|look\s+for|list)(?:\s|$|[《“"「]|\p{Han}))|(?:查找|搜索|检索).*(?:文献|论文)|^(?:有哪些|有没有|哪些).*(?:文献|论文)|\b(?:papers?|articles?)\s+(?:by|from|published)\b|\bdoi\b|作者`)
+	analyzePattern  = regexp.MustCompile(`(?i)^(?:请|帮我|麻烦)?\s*(?:总结|概括|分析|归纳|解读|summari[sz]e|analy[sz]e|tl;?dr)|(?:总结|概括)一下|\bsummary\s+of\b|的(?:摘要|总结)$`)
+
+	// intentFillers 提取Question时移除的指令词
+	intentFillers = regexp.MustCompile(`(?i)^(?:请|帮我|麻烦)?\s*(?:全文搜索|全文检索|查看|预览|打开|阅读|搜索|查找|检索|找一下|找找|总结一下|概括一下|总结|概括|分析|归纳|推荐|search\s+(?:the\s+)?full[\s-]?text\s+for|full[\s-]?text\s+search\s+(?:for\s+)?|search\s+(?:my\s+library\s+)?for|search|find|look\s+up|look\s+for|open|view|preview|read|summari[sz]e|analy[sz]e)\s*(?:the\s+)?(?:pdf\s+(?:of|for)\s+)?|\s*(?:的)?(?:pdf|原文)$`)
+)
+
+// ClassifyIntentRules 基于规则的确定性意图分类，AI不可用或结果无效时使用
+func ClassifyIntentRules(query string) IntentResult {
+	query = strings.TrimSpace(query)
+	slots := extractIntentSlots(query)
+	text := intentFalseFriends.ReplaceAllString(query, " ")
+
+	result := IntentResult{Intent: IntentChat, Confidence: 0.5, Source: "rules"}
+	switch {
+	case fullTextPattern.MatchString(text):
+		result.Intent, result.Confidence = IntentFullText, 0.9
+	case relatedPattern.MatchString(text):
+		result.Intent, result.Confidence = IntentRelated, 0.8
+	case viewPattern.MatchString(text) && !documentPartPattern.MatchString(text):
+		result.Intent, result.Confidence = IntentViewPDF, 0.8
+	case searchPattern.MatchString(text) && !documentPartPattern.MatchString(text):
+		result.Intent, result.Confidence = IntentSearch, 0.7
+	case analyzePattern.MatchString(text):
+		result.Intent, result.Confidence = IntentAnalyze, 0.7
+	}
+
+	slots.Question = query
+	if result.Intent != IntentChat {
+		if stripped := strings.Trim(intentFillers.ReplaceAllString(query, ""), " ：:，,。.?？"); stripped != "" {
+			slots.Question = stripped
+		}
+	}
+	// 查看和分析的对象通常就是去掉指令词后的文献名
+	if slots.Document == "" && slots.DOI == "" && (result.Intent == IntentViewPDF || result.Intent == IntentAnalyze) && slots.Question != query {
+		slots.Document = slots.Question
+	}
+	result.Slots = slots
+	return result
+}
+
+// extractIntentSlots 提取DOI、引号中的文献名和分类名
+func extractIntentSlots(query string) IntentSlots {
+	var slots IntentSlots
+	if doi := doiPattern.FindString(query); doi != "" {
+		slots.DOI = strings.TrimRight(doi, ".,)")
+	}
+	if match := quotedPattern.FindStringSubmatch(query); match != nil {
+		for _, group := range match[1:] {
+			if group != "" {
+				slots.Document = strings.TrimSpace(group)
+				break
+			}
+		}
+	}
+	for _, pattern := range collectionPatterns {
+		if match := pattern.FindStringSubmatch(query); match != nil {
+			slots.Collection = strings.TrimSpace(match[1])
+			break
+		}
+	}
+	return slots
+}
+
+// IntentClassifier 意图分类器：优先使用AI，失败时回退到规则
+type IntentClassifier struct {
+	client AIClient // 为nil时只使用规则
+}
+
+// NewIntentClassifier 创建意图分类器，client为nil时只使用规则分类
+func NewIntentClassifier(client AIClient) *IntentClassifier {
+	return &IntentClassifier{client: client}
+}
+
+// Classify 判断查询意图并提取参数
+func (c *IntentClassifier) Classify(ctx context.Context, query string) IntentResult {
+	ctx = WithUsageFeature(ctx, FeatureIntent)
+	rules := ClassifyIntentRules(query)
+	if c.client == nil || strings.TrimSpace(query) == "" {
+		return rules
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, intentClassifyTimeout)
+	defer cancel()
+	result, err := c.classifyWithAI(ctx, query)
+	if err != nil {
+		log.Printf("⚠️ AI意图分类失败，使用规则分类: %v", err)
+		return rules
+	}
+
+	// 正则提取的DOI比模型更可靠，模型未给出的参数用规则结果补充
+	if rules.Slots.DOI != "" {
+		result.Slots.DOI = rules.Slots.DOI
+	}
+	if result.Slots.Document == "" {
+		result.Slots.Document = rules.Slots.Document
+	}
+	if result.Slots.Collection == "" {
+		result.Slots.Collection = rules.Slots.Collection
+	}
+	if result.Slots.Question == "" {
+		result.Slots.Question = rules.Slots.Question
+	}
+	return result
+}
+
+// classifyWithAI 请求模型以JSON返回意图和参数
+func (c *IntentClassifier) classifyWithAI(ctx context.Context, query string) (IntentResult, error) {
+	var intents strings.Builder
+	for _, item := range Intents {
+		intents.WriteString(fmt.Sprintf("- %s: %s\n", item.Intent, item.Description))
+	}
+	prompt := fmt.Sprintf(`判断用户查询的意图并提取参数。可选意图：
+%s
+注意："related work章节"、"open questions"等是在询问文献内容，属于chat。
+
+只输出JSON对象：{"intent": "意图", "document": "提到的文献标题或名称", "doi": "DOI", "collection": "Zotero分类名", "question": "去掉指令词后的查询内容", "confidence": 0到1之间的数}
+没有的字段输出空字符串。
+
+用户查询：%s`, intents.String(), query)
+
+	reply, err := completePrompt(ctx, c.client, prompt, 300)
+	if err != nil {
+		return IntentResult{}, err
+	}
+	var parsed struct {
+		Intent     string  `json:"intent"`
+		Document   string  `json:"document"`
+		DOI        string  `json:"doi"`
+		Collection string  `json:"collection"`
+		Question   string  `json:"question"`
+		Confidence float64 `json:"confidence"`
+	}
+	if err := decodeJSONReply(reply, &parsed); err != nil {
+		return IntentResult{}, fmt.Errorf("解析分类结果失败: %w", err)
+	}
+	intent := Intent(strings.TrimSpace(parsed.Intent))
+	if !intent.Valid() {
+		return IntentResult{}, fmt.Errorf("未知意图: %q", parsed.Intent)
+	}
+	if parsed.Confidence <= 0 || parsed.Confidence > 1 {
+		parsed.Confidence = 0.8
+	}
+	return IntentResult{
+		Intent: intent,
+		Slots: IntentSlots{
+			Document:   strings.TrimSpace(parsed.Document),
+			DOI:        strings.TrimSpace(parsed.DOI),
+			Collection: strings.TrimSpace(parsed.Collection),
+			Question:   strings.TrimSpace(parsed.Question),
+		},
+		Confidence: parsed.Confidence,
+		Source:     "ai",
+	}, nil
+}
+
+// Valid 是否为支持的意图
+func (i Intent) Valid() bool {
+	for _, item := range Intents {
+		if item.Intent == i {
+			return true
+		}
+	}
+	return false
+}
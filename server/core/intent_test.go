@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+// intentCase 标注好意图和参数的查询
+type intentCase struct {
+	Query      string `json:"query"`
+	Intent     Intent `json:"intent"`
+	Document   string `json:"document"`
+	DOI        string `json:"doi"`
+	Collection string `json:"collection"`
+}
+
+// minIntentAccuracy 规则分类在标注语料上的最低准确率
+const minIntentAccuracy = 0.95
+
+func loadIntentCorpus(t *testing.T) []intentCase {
+	t.Helper()
+	data, err := os.ReadFile("testdata/intents.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cases []intentCase
+	if err := json.Unmarshal(data, &cases); err != nil {
+		t.Fatal(err)
+	}
+	return cases
+}
+
+func TestClassifyIntentRulesAccuracy(t *testing.T) {
+	cases := loadIntentCorpus(t)
+	correct := 0
+	confusion := make(map[string]int)
+	for _, tc := range cases {
+		if !tc.Intent.Valid() {
+			t.Fatalf("语料中的意图无效: %+v", tc)
+		}
+		got := ClassifyIntentRules(tc.Query)
+		if got.Intent == tc.Intent {
+			correct++
+		} else {
+			confusion[string(tc.Intent)+" → "+string(got.Intent)]++
+			t.Logf("误判 %q: want %s, got %s", tc.Query, tc.Intent, got.Intent)
+		}
+
+		// 参数只在标注了的情况下检查
+		if tc.Document != "" && got.Slots.Document != tc.Document {
+			t.Errorf("%q 文献 = %q, want %q", tc.Query, got.Slots.Document, tc.Document)
+		}
+		if tc.DOI != "" && got.Slots.DOI != tc.DOI {
+			t.Errorf("%q DOI = %q, want %q", tc.Query, got.Slots.DOI, tc.DOI)
+		}
+		if tc.Collection != "" && got.Slots.Collection != tc.Collection {
+			t.Errorf("%q 分类 = %q, want %q", tc.Query, got.Slots.Collection, tc.Collection)
+		}
+	}
+
+	accuracy := float64(correct) / float64(len(cases))
+	t.Logf("规则分类准确率 %.1f%%（%d/%d），混淆: %v", accuracy*100, correct, len(cases), confusion)
+	if accuracy < minIntentAccuracy {
+		t.Errorf("规则分类准确率 %.2f 低于 %.2f", accuracy, minIntentAccuracy)
+	}
+}
+
+func TestClassifyIntentRulesQuestion(t *testing.T) {
+	got := ClassifyIntentRules("全文搜索 self attention")
+	if got.Slots.Question != "self attention" || got.Source != "rules" {
+		t.Errorf("全文搜索的查询内容 = %+v", got)
+	}
+	got = ClassifyIntentRules("什么是自注意力？")
+	if got.Slots.Question != "什么是自注意力？" {
+		t.Errorf("对话应保留原始问题: %+v", got)
+	}
+}
+
+// errorAIClient 总是返回错误的AI客户端
+type errorAIClient struct{ scriptedAIClient }
+
+func (c *errorAIClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	return nil, errors.New("服务不可用")
+}
+
+func TestIntentClassifierWithAI(t *testing.T) {
+	client := &fakeAIClient{reply: "```json\n" + `{"intent": "related_literature", "document": "BERT", "doi": "", "collection": "", "question": "与BERT相关的工作", "confidence": 0.92}` + "\n```"}
+	got := NewIntentClassifier(client).Classify(context.Background(), "有什么可以接着BERT读的？ 10.18653/v1/N19-1423")
+	if got.Intent != IntentRelated || got.Source != "ai" || got.Confidence != 0.92 || got.Slots.Document != "BERT" {
+		t.Errorf("Classify() = %+v", got)
+	}
+	if got.Slots.DOI != "10.18653/v1/N19-1423" {
+		t.Errorf("应补充正则提取的DOI: %+v", got.Slots)
+	}
+	if prompt := client.requests[0].Messages[0].Content; !strings.Contains(prompt, "fulltext_search") || !strings.Contains(prompt, "有什么可以接着BERT读的") {
+		t.Errorf("分类提示 = %s", prompt)
+	}
+
+	// 模型返回未知意图或请求失败时回退到规则
+	invalid := &fakeAIClient{reply: `{"intent": "open_pdf"}`}
+	if got := NewIntentClassifier(invalid).Classify(context.Background(), "find the related work section"); got.Intent != IntentChat || got.Source != "rules" {
+		t.Errorf("未知意图应回退到规则: %+v", got)
+	}
+	if got := NewIntentClassifier(&errorAIClient{}).Classify(context.Background(), "summarize BERT"); got.Intent != IntentAnalyze || got.Source != "rules" {
+		t.Errorf("请求失败应回退到规则: %+v", got)
+	}
+	if got := NewIntentClassifier(nil).Classify(context.Background(), "view BERT"); got.Intent != IntentViewPDF {
+		t.Errorf("无AI客户端时应使用规则: %+v", got)
+	}
+}
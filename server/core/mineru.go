@@ -23,7 +23,9 @@ type MinerUClient struct {
 	HTTPClient *http.Client
 	MaxRetry   int
 	Timeout    time.Duration
-	ResultsDir string // 解析结果存储目录
+	ResultsDir string      // 解析结果存储目录
+	IndexDir   string      // 全文索引目录
+	Summarizer *Summarizer // 为nil时解析后不生成摘要
 }
 
 // FileInfo 文件信息
@@ -109,6 +111,7 @@ func NewMinerUClientWithResultsDir(apiURL, token, resultsDir string) *MinerUClie
 		MaxRetry:   3,
 		Timeout:    3 * time.Minute,
 		ResultsDir: resultsDir,
+		IndexDir:   DefaultIndexDir,
 	}
 }
 
@@ -265,7 +268,7 @@ func (c *MinerUClient) ParsePDF(ctx context.Context, pdfPath string) (*ParseResu
 
 	// 同步组织文件，确保文件组织成功
 	log.Printf("开始组织文件: %s", zipPath)
-	if err := OrganizeResult(zipPath, pdfPath); err != nil {
+	if err := OrganizeResult(zipPath, pdfPath, c.ResultsDir, c.IndexDir); err != nil {
 		log.Printf("⚠️ 文件组织失败: %v", err)
 		// 不影响主流程，但记录错误
 	} else {
@@ -275,6 +278,51 @@ func (c *MinerUClient) ParsePDF(ctx context.Context, pdfPath string) (*ParseResu
 	return result, nil
 }
 
+// ParseItem 解析Zotero条目的PDF，并将结果目录关联到该条目
+func (c *MinerUClient) ParseItem(ctx context.Context, item *ZoteroItem) (*ParseResult, error) {
+	if item.PDFPath == "" {
+		return nil, fmt.Errorf("文献没有PDF附件: %s", item.Title)
+	}
+
+	result, err := c.ParsePDF(ctx, item.PDFPath)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := FindParsedResult(c.ResultsDir, item)
+	if err != nil || parsed == nil {
+		log.Printf("⚠️ 未找到 %s 的解析结果目录，跳过关联", item.Title)
+		return result, nil
+	}
+	if err := LinkResultToItem(parsed.Dir, item); err != nil {
+		log.Printf("⚠️ 关联解析结果失败: %v", err)
+	} else if err := IndexParsedResult(parsed.Dir, c.IndexDir); err != nil {
+		// 刷新索引中的条目Key
+		log.Printf("⚠️ 更新全文索引失败: %v", err)
+	}
+	c.summarizeResult(ctx, parsed.Dir)
+	return result, nil
+}
+
+// summarizeResult 为新解析的结果生成摘要和关键要点（summary.json），未配置摘要器时跳过
+func (c *MinerUClient) summarizeResult(ctx context.Context, dir string) {
+	if c.Summarizer == nil {
+		return
+	}
+	// 重新读取以获得刚关联的条目Key
+	result, err := GetParsedResult(filepath.Dir(dir), filepath.Base(dir))
+	if err != nil {
+		log.Printf("⚠️ 读取解析结果失败，跳过摘要: %v", err)
+		return
+	}
+	summary, err := c.Summarizer.Summarize(ctx, result, false)
+	if err != nil {
+		log.Printf("⚠️ 生成摘要失败: %v", err)
+		return
+	}
+	log.Printf("📝 已生成摘要: %s（%d 条要点）", result.Name, len(summary.KeyPoints))
+}
+
 // submitBatchTask 提交批量任务
 func (c *MinerUClient) submitBatchTask(ctx context.Context, fileName string) (*BatchResponse, error) {
 	payload := BatchRequest{
@@ -0,0 +1,465 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ZoteroNote Zotero中的笔记（子笔记挂在文献条目下，独立笔记没有父条目）
+type ZoteroNote struct {
+	ItemID    int    `json:"item_id"`
+	Key       string `json:"key"`
+	ParentID  int    `json:"parent_id,omitempty"`
+	ParentKey string `json:"parent_key,omitempty"`
+	Title     string `json:"title"`
+	// Markdown 由笔记HTML转换的正文
+	Markdown  string   `json:"markdown"`
+	HTML      string   `json:"html,omitempty"`
+	DateAdded string   `json:"date_added,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// notesQuery 笔记查询：itemNotes 也保存附件的备注，只取类型为note的条目；排除回收站中的笔记
+//
+// 由ZoteroFlow写回的摘要和抽取笔记不是用户的阅读笔记，在queryNotes中排除
+const notesQuery = `
+	SELECT n.itemID, i.key, COALESCE(n.parentItemID, 0), COALESCE(p.key, ''),
+		COALESCE(n.title, ''), COALESCE(n.note, ''), COALESCE(i.dateAdded, ''),
+		COALESCE((SELECT idv.value FROM itemData id
+			JOIN fieldsCombined fc ON fc.fieldID = id.fieldID AND fc.fieldName = 'title'
+			JOIN itemDataValues idv ON idv.valueID = id.valueID
+			WHERE id.itemID = n.parentItemID), '')
+	FROM itemNotes n
+	JOIN items i ON i.itemID = n.itemID
+	JOIN itemTypes t ON t.itemTypeID = i.itemTypeID AND t.typeName = 'note'
+	LEFT JOIN items p ON p.itemID = n.parentItemID`
+
+// noteRow 笔记及其父条目标题
+type noteRow struct {
+	ZoteroNote
+	parentTitle string
+}
+
+// queryNotes 按条件查询笔记并转换为Markdown；没有笔记表的数据库返回空列表
+func (z *ZoteroDB) queryNotes(where string, args ...interface{}) ([]noteRow, error) {
+	if !z.hasTable("itemNotes") {
+		return nil, nil
+	}
+	query := notesQuery + " WHERE " + where
+	if z.hasTable("deletedItems") {
+		query += " AND n.itemID NOT IN (SELECT itemID FROM deletedItems)"
+	}
+	rows, err := z.db.Query(query+" ORDER BY i.dateAdded, n.itemID", args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询笔记失败: %w", err)
+	}
+
+	var notes []noteRow
+	for rows.Next() {
+		var n noteRow
+		if err := rows.Scan(&n.ItemID, &n.Key, &n.ParentID, &n.ParentKey, &n.Title, &n.HTML, &n.DateAdded, &n.parentTitle); err != nil {
+			log.Printf("扫描笔记数据失败: %v", err)
+			continue
+		}
+		if isWritebackNote(n.HTML) {
+			continue
+		}
+		n.Markdown = NoteHTMLToMarkdown(n.HTML)
+		if n.Title == "" {
+			n.Title = noteTitle(n.Markdown)
+		}
+		notes = append(notes, n)
+	}
+	rows.Close()
+
+	for i := range notes {
+		if tags, err := z.getItemTags(notes[i].ItemID); err == nil {
+			notes[i].Tags = tags
+		}
+	}
+	return notes, nil
+}
+
+// GetItemNotes 读取文献条目的子笔记，按创建时间排列
+func (z *ZoteroDB) GetItemNotes(itemID int) ([]ZoteroNote, error) {
+	rows, err := z.queryNotes("n.parentItemID = ?", itemID)
+	if err != nil {
+		return nil, err
+	}
+	notes := make([]ZoteroNote, 0, len(rows))
+	for _, row := range rows {
+		notes = append(notes, row.ZoteroNote)
+	}
+	return notes, nil
+}
+
+// NoteFullTextSources 将所有笔记作为全文来源，子笔记的命中归属到父条目
+func (z *ZoteroDB) NoteFullTextSources() ([]FullTextSource, error) {
+	rows, err := z.queryNotes("1 = 1")
+	if err != nil {
+		return nil, err
+	}
+	var sources []FullTextSource
+	for _, n := range rows {
+		if strings.TrimSpace(n.Markdown) == "" {
+			continue
+		}
+		source := FullTextSource{
+			ID:      "note:" + n.Key,
+			Kind:    FullTextKindNote,
+			ItemKey: n.ParentKey,
+			Title:   n.parentTitle,
+			Text:    n.Markdown,
+		}
+		if source.ItemKey == "" {
+			source.ItemKey = n.Key // 独立笔记
+		}
+		if source.Title == "" {
+			source.Title = n.Title
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
+
+// noteTitle 与Zotero一致，取笔记第一行作为标题
+func noteTitle(markdown string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(markdown), "\n")
+	return truncateRunes(strings.TrimSpace(strings.TrimLeft(line, "#>-* ")), 80)
+}
+
+var (
+	noteTagPattern  = regexp.MustCompile(`(?s)<!--.*?-->|<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*)>`)
+	noteHrefPattern = regexp.MustCompile(`(?i)href\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	noteSpaces      = regexp.MustCompile(`\s+`)
+	noteBlankLines  = regexp.MustCompile(`\n{3,}`)
+	noteTrailing    = regexp.MustCompile(`[ \t]+\n`)
+)
+
+// noteList 正在转换的列表
+type noteList struct {
+	ordered bool
+	next    int
+}
+
+// noteFrame 引用或链接的内容先写入单独的缓冲区，结束时再整体加工
+type noteFrame struct {
+	strings.Builder
+	link bool
+	href string
+}
+
+// noteConverter HTML到Markdown的转换状态
+type noteConverter struct {
+	out   []*noteFrame
+	lists []noteList
+	pre   int
+	cells int
+	head  bool
+}
+
+// NoteHTMLToMarkdown 将Zotero笔记编辑器生成的HTML转换为Markdown
+//
+// 支持标题、段落、列表、引用、代码块、粗体斜体、链接和表格，其余标签（如高亮和引文的span）只保留文字
+func NoteHTMLToMarkdown(source string) string {
+	c := &noteConverter{out: []*noteFrame{{}}}
+	last := 0
+	for _, m := range noteTagPattern.FindAllStringSubmatchIndex(source, -1) {
+		c.text(source[last:m[0]])
+		last = m[1]
+		if m[4] < 0 {
+			continue // 注释
+		}
+		closing := m[3] > m[2]
+		c.tag(strings.ToLower(source[m[4]:m[5]]), source[m[6]:m[7]], closing)
+	}
+	c.text(source[last:])
+	for len(c.out) > 1 {
+		c.pop() // 未闭合的引用或链接
+	}
+
+	markdown := noteTrailing.ReplaceAllString(c.out[0].String(), "\n")
+	return strings.TrimSpace(noteBlankLines.ReplaceAllString(markdown, "\n\n"))
+}
+
+// current 当前写入的缓冲区
+func (c *noteConverter) current() *noteFrame {
+	return c.out[len(c.out)-1]
+}
+
+// text 写入文本：代码块中原样保留，其余合并空白
+func (c *noteConverter) text(raw string) {
+	if raw == "" {
+		return
+	}
+	text := html.UnescapeString(raw)
+	if c.pre > 0 {
+		c.current().WriteString(text)
+		return
+	}
+	text = noteSpaces.ReplaceAllString(text, " ")
+	if written := c.current().String(); written == "" || strings.HasSuffix(written, "\n") || strings.HasSuffix(written, " ") {
+		text = strings.TrimLeft(text, " ")
+	}
+	c.current().WriteString(text)
+}
+
+// newline 确保从新行开始
+func (c *noteConverter) newline() {
+	if written := c.current().String(); written != "" && !strings.HasSuffix(written, "\n") {
+		c.current().WriteString("\n")
+	}
+}
+
+// block 结束当前块，与下一块之间空一行
+func (c *noteConverter) block() {
+	if c.current().Len() > 0 {
+		c.current().WriteString("\n\n")
+	}
+}
+
+// pop 结束引用或链接缓冲区并将加工后的内容写回上一层
+func (c *noteConverter) pop() {
+	frame := c.current()
+	inner := frame.String()
+	c.out = c.out[:len(c.out)-1]
+	if frame.link {
+		href, text := frame.href, strings.TrimSpace(inner)
+		switch {
+		case href == "":
+			c.current().WriteString(text)
+		case text == "" || text == href:
+			c.current().WriteString("<" + href + ">")
+		default:
+			c.current().WriteString("[" + text + "](" + href + ")")
+		}
+		return
+	}
+
+	c.block()
+	lines := strings.Split(strings.TrimSpace(noteBlankLines.ReplaceAllString(inner, "\n\n")), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	c.current().WriteString(strings.Join(lines, "\n"))
+	c.block()
+}
+
+// tag 处理一个开始或结束标签
+func (c *noteConverter) tag(name, attrs string, closing bool) {
+	switch name {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		c.block()
+		if !closing {
+			c.current().WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+		}
+	case "p", "div":
+		if len(c.lists) > 0 {
+			if !closing && !strings.HasSuffix(c.current().String(), " ") {
+				c.newline() // 列表项中的第二段
+			}
+			return
+		}
+		c.block()
+	case "br":
+		c.current().WriteString("\n")
+	case "hr":
+		c.block()
+		c.current().WriteString("---")
+		c.block()
+	case "strong", "b":
+		c.current().WriteString("**")
+	case "em", "i":
+		c.current().WriteString("*")
+	case "s", "del", "strike":
+		c.current().WriteString("~~")
+	case "code":
+		if c.pre == 0 {
+			c.current().WriteString("`")
+		}
+	case "pre":
+		if closing {
+			c.pre--
+			c.newline()
+			c.current().WriteString("```")
+			c.block()
+			return
+		}
+		c.block()
+		c.current().WriteString("```\n")
+		c.pre++
+	case "blockquote":
+		if closing {
+			if len(c.out) > 1 && !c.current().link {
+				c.pop()
+			}
+			return
+		}
+		c.out = append(c.out, &noteFrame{})
+	case "a":
+		if closing {
+			if c.current().link {
+				c.pop()
+			}
+			return
+		}
+		frame := &noteFrame{link: true}
+		if m := noteHrefPattern.FindStringSubmatch(attrs); m != nil {
+			frame.href = html.UnescapeString(m[1] + m[2])
+		}
+		c.out = append(c.out, frame)
+	case "ul", "ol":
+		if closing {
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+			if len(c.lists) == 0 {
+				c.block()
+			}
+			return
+		}
+		if len(c.lists) == 0 {
+			c.block()
+		}
+		c.lists = append(c.lists, noteList{ordered: name == "ol", next: 1})
+	case "li":
+		if closing || len(c.lists) == 0 {
+			return
+		}
+		c.newline()
+		list := &c.lists[len(c.lists)-1]
+		marker := "- "
+		if list.ordered {
+			marker = fmt.Sprintf("%d. ", list.next)
+			list.next++
+		}
+		c.current().WriteString(strings.Repeat("  ", len(c.lists)-1) + marker)
+	case "table":
+		c.block()
+	case "tr":
+		if closing {
+			if c.head {
+				c.current().WriteString("\n|" + strings.Repeat(" --- |", c.cells))
+				c.head = false
+			}
+			return
+		}
+		c.newline()
+		c.current().WriteString("|")
+		c.cells = 0
+	case "td", "th":
+		if closing {
+			c.current().WriteString(" |")
+			return
+		}
+		c.current().WriteString(" ")
+		c.cells++
+		c.head = c.head || name == "th"
+	}
+}
+
+// noteContextBlock 一条笔记在系统提示中的写法
+func noteContextBlock(n ZoteroNote) string {
+	return fmt.Sprintf("--- 笔记：%s ---\n%s", n.Title, n.Markdown)
+}
+
+// formatNoteContext 系统提示中的笔记列表
+func formatNoteContext(notes []ZoteroNote) string {
+	blocks := make([]string, 0, len(notes))
+	for _, n := range notes {
+		blocks = append(blocks, noteContextBlock(n))
+	}
+	return strings.Join(blocks, "\n\n")
+}
+
+// rankNotes 按与问题的词项重叠排序（稳定排序，无重叠的保持创建顺序）
+func rankNotes(notes []ZoteroNote, query string) []ZoteroNote {
+	queryTerms := make(map[string]bool)
+	for _, term := range tokenize(query) {
+		queryTerms[term] = true
+	}
+	scores := make(map[int]int, len(notes))
+	for _, n := range notes {
+		for _, term := range uniqueTerms(tokenize(n.Markdown)) {
+			if queryTerms[term] {
+				scores[n.ItemID]++
+			}
+		}
+	}
+	ranked := append([]ZoteroNote(nil), notes...)
+	sort.SliceStable(ranked, func(i, j int) bool { return scores[ranked[i].ItemID] > scores[ranked[j].ItemID] })
+	return ranked
+}
+
+// minNoteExcerptTokens 剩余预算不足时不再截取笔记片段
+const minNoteExcerptTokens = 100
+
+// fitNotes 按顺序装入笔记，放不下的第一篇笔记截断到剩余预算，返回装入的笔记、使用的token数和是否发生了裁剪
+func fitNotes(notes []ZoteroNote, budget int) ([]ZoteroNote, int, bool) {
+	var fitted []ZoteroNote
+	used := 0
+	for _, n := range notes {
+		tokens := EstimateTokens(noteContextBlock(n))
+		if used+tokens <= budget {
+			fitted = append(fitted, n)
+			used += tokens
+			continue
+		}
+		remaining := budget - used - EstimateTokens(noteContextBlock(ZoteroNote{Title: n.Title}))
+		if remaining < minNoteExcerptTokens {
+			return fitted, used, true
+		}
+		// 按token与字符的比例估算截断长度，留出余量
+		runes := len([]rune(n.Markdown)) * remaining / tokens * 9 / 10
+		n.Markdown = truncateRunes(n.Markdown, runes)
+		fitted = append(fitted, n)
+		used += EstimateTokens(noteContextBlock(n))
+		return fitted, used, true
+	}
+	return fitted, used, false
+}
+
+// attachNotes 为指定的解析结果读取对应Zotero条目的子笔记，按与问题的相关度排序后放入上下文
+func (m *AIConversationManager) attachNotes(docCtx *DocumentContext, query string) {
+	if m.zoteroDB == nil || docCtx == nil || len(docCtx.Notes) > 0 {
+		return
+	}
+	var notes []ZoteroNote
+	for _, item := range m.documentItems(docCtx) {
+		found, err := m.zoteroDB.GetItemNotes(item.ItemID)
+		if err != nil {
+			log.Printf("读取笔记失败: %v", err)
+			continue
+		}
+		for _, n := range found {
+			if strings.TrimSpace(n.Markdown) != "" {
+				notes = append(notes, n)
+			}
+		}
+	}
+	if len(notes) > 0 {
+		log.Printf("📝 对话上下文包含 %d 篇笔记", len(notes))
+		docCtx.Notes = rankNotes(notes, query)
+	}
+}
+
+// RenderNotesMarkdown 将条目的子笔记导出为一个Markdown文档
+func RenderNotesMarkdown(item *ZoteroItem, notes []ZoteroNote) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# 笔记：%s\n", item.Title)
+	if len(notes) == 0 {
+		builder.WriteString("\n（没有笔记）\n")
+		return builder.String()
+	}
+	for _, n := range notes {
+		fmt.Fprintf(&builder, "\n---\n\n%s\n", n.Markdown)
+		if len(n.Tags) > 0 {
+			fmt.Fprintf(&builder, "\n#%s\n", strings.Join(n.Tags, " #"))
+		}
+	}
+	return builder.String()
+}
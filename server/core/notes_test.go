@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+// fixtureNotes 条目1的两篇子笔记、附件2自身的备注（不是笔记条目）、一篇独立笔记和一篇回收站中的笔记
+const fixtureNotes = `
+CREATE TABLE itemNotes (itemID INTEGER PRIMARY KEY, parentItemID INT, note TEXT, title TEXT);
+CREATE TABLE deletedItems (itemID INTEGER PRIMARY KEY, dateDeleted TEXT);
+INSERT INTO itemTypes VALUES (4, 'note');
+INSERT INTO items VALUES (20, 4, 'NOTE0001', '2024-03-01'), (21, 4, 'NOTE0002', '2024-03-02'), (22, 4, 'NOTE0003', '2024-03-03'), (23, 4, 'NOTE0004', '2024-03-04');
+INSERT INTO itemNotes VALUES
+	(20, 1, '<div data-schema-version="8"><h1>组会讨论</h1><p>Scaled dot-product 除以 <strong>√d<sub>k</sub></strong> 是为了稳定梯度。</p><ul><li><p>缺点：位置编码是<em>固定的</em></p></li><li><p>对比 <a href="https://arxiv.org/abs/1810.04805">BERT</a></p></li></ul></div>', '组会讨论'),
+	(21, 1, '<p>复现时 warmup 步数取 4000</p>', ''),
+	(2, 1, '<p>附件备注</p>', ''),
+	(22, NULL, '<h2>待读清单</h2><ol><li>Reformer</li><li>Longformer</li></ol>', '待读清单'),
+	(23, 1, '<p>已删除的笔记 obsolete</p>', '已删除');
+INSERT INTO deletedItems VALUES (23, '2024-03-05');
+INSERT INTO tags VALUES (3, 'reading-group');
+INSERT INTO itemTags VALUES (20, 3);
+`
+
+// addFixtureNotes 向测试数据库写入笔记
+func addFixtureNotes(t *testing.T, db *ZoteroDB) {
+	t.Helper()
+	raw, err := sql.Open("sqlite3", db.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Exec(fixtureNotes); err != nil {
+		t.Fatalf("写入笔记失败: %v", err)
+	}
+}
+
+func TestNoteHTMLToMarkdown(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"段落和标题", `<div data-schema-version="8"><h2>Method</h2><p>First  line<br>second&nbsp;line &amp; more</p><p>Next</p></div>`, "## Method\n\nFirst line\nsecond line & more\n\nNext"},
+		{"行内格式", `<p><strong>bold</strong>, <em>italic</em>, <s>gone</s> and <code>x := 1</code></p>`, "**bold**, *italic*, ~~gone~~ and `x := 1`"},
+		{"链接", `<p><a href="https://example.org/?a=1&amp;b=2">paper</a> <a href="https://x.org">https://x.org</a> <a>plain</a></p>`, "[paper](https://example.org/?a=1&b=2) <https://x.org> plain"},
+		{"嵌套列表", `<ul><li><p>one</p><ul><li><p>inner</p></li></ul></li><li><p>two</p></li></ul><ol><li>a</li><li>b</li></ol>`, "- one\n  - inner\n- two\n\n1. a\n2. b"},
+		{"引用", `<blockquote><p>quoted <span class="highlight" data-annotation="{}">text</span></p><p>second</p></blockquote><p>after</p>`, "> quoted text\n>\n> second\n\nafter"},
+		{"代码块", `<pre>func main() {
+	fmt.Println("&lt;hi&gt;")
+}</pre>`, "```\nfunc main() {\n\tfmt.Println(\"<hi>\")\n}\n```"},
+		{"表格", `<table><tr><th>Model</th><th>BLEU</th></tr><tr><td>Base</td><td>27.3</td></tr></table>`, "| Model | BLEU |\n| --- | --- |\n| Base | 27.3 |"},
+		{"注释和未闭合标签", `<!-- <p>hidden</p> --><blockquote><p>open`, "> open"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NoteHTMLToMarkdown(tt.html); got != tt.want {
+				t.Errorf("NoteHTMLToMarkdown() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetItemNotes(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	if notes, err := db.GetItemNotes(1); err != nil || len(notes) != 0 {
+		t.Fatalf("没有笔记表时应返回空列表: %v, %v", notes, err)
+	}
+
+	addFixtureNotes(t, db)
+	notes, err := db.GetItemNotes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 附件备注和回收站中的笔记不应出现
+	if len(notes) != 2 || notes[0].Key != "NOTE0001" || notes[1].Key != "NOTE0002" {
+		t.Fatalf("GetItemNotes() = %+v", notes)
+	}
+	first := notes[0]
+	if first.Title != "组会讨论" || first.ParentKey != "ABCD1234" || len(first.Tags) != 1 || first.Tags[0] != "reading-group" {
+		t.Errorf("笔记 = %+v", first)
+	}
+	if want := "# 组会讨论\n\nScaled dot-product 除以 **√dk** 是为了稳定梯度。\n\n- 缺点：位置编码是*固定的*\n- 对比 [BERT](https://arxiv.org/abs/1810.04805)"; first.Markdown != want {
+		t.Errorf("Markdown = %q", first.Markdown)
+	}
+	// 没有标题时取第一行
+	if notes[1].Title != "复现时 warmup 步数取 4000" {
+		t.Errorf("标题 = %q", notes[1].Title)
+	}
+
+	item, _ := db.GetItemByKey("ABCD1234")
+	markdown := RenderNotesMarkdown(item, notes)
+	if !strings.HasPrefix(markdown, "# 笔记：Attention Is All You Need\n") || !strings.Contains(markdown, "#reading-group") || !strings.Contains(markdown, "warmup") {
+		t.Errorf("RenderNotesMarkdown() =\n%s", markdown)
+	}
+}
+
+func TestNotesInFullTextSearch(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	db := newFixtureZoteroDB(t)
+	addFixtureNotes(t, db)
+
+	sources, err := CollectFullTextSources(db, resultsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes := 0
+	for _, source := range sources {
+		if source.Kind == FullTextKindNote {
+			notes++
+		}
+	}
+	if notes != 3 {
+		t.Fatalf("应索引3篇笔记: %+v", sources)
+	}
+
+	indexDir := t.TempDir()
+	index, _ := OpenFullTextIndex(indexDir)
+	if _, err := index.Update(sources); err != nil {
+		t.Fatal(err)
+	}
+
+	// 子笔记的命中归属到父条目
+	hits, err := db.SearchFullText(index, "warmup", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Kind != FullTextKindNote || hits[0].ItemKey != "ABCD1234" || hits[0].Item == nil ||
+		hits[0].Title != "Attention Is All You Need" || !strings.Contains(hits[0].Snippet, "warmup 步数取 4000") {
+		t.Errorf("笔记命中 = %+v", hits)
+	}
+	if hits, _ := index.Search("Longformer", 10); len(hits) != 1 || hits[0].ItemKey != "NOTE0003" || hits[0].Title != "待读清单" {
+		t.Errorf("独立笔记命中 = %+v", hits)
+	}
+	if hits, _ := index.Search("obsolete", 10); len(hits) != 0 {
+		t.Errorf("回收站中的笔记不应被索引: %+v", hits)
+	}
+
+	// 正文未变时不重新分词；重新打开索引后仍能生成摘录
+	stats, _ := index.Update(sources)
+	if stats.Unchanged != len(sources) {
+		t.Errorf("重复更新 = %+v", stats)
+	}
+	for i := range sources {
+		if sources[i].ID == "note:NOTE0002" {
+			sources[i].Text = "复现时 warmup 步数取 8000"
+		}
+	}
+	if stats, _ := index.Update(sources); stats.Updated != 1 {
+		t.Errorf("修改笔记后 = %+v", stats)
+	}
+	reopened, _ := OpenFullTextIndex(indexDir)
+	if hits, _ := reopened.Search("warmup", 10); len(hits) != 1 || !strings.Contains(hits[0].Snippet, "8000") {
+		t.Errorf("重新打开后 = %+v", hits)
+	}
+}
+
+func TestConversationIncludesNotes(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	addFixtureNotes(t, db)
+
+	client := &fakeAIClient{reply: "组会上讨论过缩放因子。"}
+	manager := NewAIConversationManager(client, db)
+	manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+	_, err := manager.StartConversationWithDocument(context.Background(), "我们对 warmup 有什么结论？", &DocumentContext{DocumentNames: []string{"attention_20240101"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	system := client.requests[0].Messages[0].Content
+	notesAt := strings.Index(system, "=== 我在Zotero中的阅读笔记 ===")
+	if notesAt < 0 || notesAt > strings.Index(system, "=== 相关文献片段") {
+		t.Fatalf("笔记应出现在检索片段之前:\n%s", system)
+	}
+	// 与问题相关的笔记排在最前
+	if !strings.Contains(system, "阅读笔记 ===\n--- 笔记：复现时 warmup 步数取 4000 ---\n复现时 warmup 步数取 4000\n\n--- 笔记：组会讨论 ---") {
+		t.Errorf("笔记格式或顺序不正确:\n%s", system)
+	}
+
+	// 放不下的笔记截断到剩余预算
+	long := ZoteroNote{ItemID: 1, Title: "长笔记", Markdown: strings.Repeat("attention ", 2000)}
+	fitted, used, trimmed := fitNotes([]ZoteroNote{{ItemID: 2, Title: "短", Markdown: "short"}, long, {ItemID: 3, Title: "后", Markdown: "later"}}, 500)
+	if !trimmed || len(fitted) != 2 || used > 500 || !strings.HasSuffix(fitted[1].Markdown, "…") {
+		t.Errorf("fitNotes() = %d 篇, %d tokens, trimmed=%v", len(fitted), used, trimmed)
+	}
+	if _, _, trimmed := fitNotes(fitted[:1], 500); trimmed {
+		t.Error("预算充足时不应裁剪")
+	}
+}
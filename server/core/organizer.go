@@ -21,14 +21,18 @@ type ParsedFileInfo struct {
 	Size     int64  `json:"size"`
 	Duration int64  `json:"duration"`
 	Path     string `json:"path"`
+	ItemKey  string `json:"item_key,omitempty"` // 对应的Zotero条目Key
 }
 
-// OrganizeResult 解压并组织文件 - 核心函数
-func OrganizeResult(zipPath, pdfPath string) error {
+// OrganizeResult 解压并组织文件到resultsDir - 核心函数，完成后加入indexDir下的全文索引
+func OrganizeResult(zipPath, pdfPath, resultsDir, indexDir string) error {
 	log.Printf("开始组织文件: %s", zipPath)
 
 	// 1. 创建目标目录
-	baseDir := "data/results"
+	baseDir := resultsDir
+	if baseDir == "" {
+		baseDir = "data/results"
+	}
 	title := extractTitle(pdfPath)
 	folderName := sanitizeFilename(fmt.Sprintf("%s_%s", title, time.Now().Format("20060102")))
 	targetDir := filepath.Join(baseDir, folderName)
@@ -69,6 +73,11 @@ func OrganizeResult(zipPath, pdfPath string) error {
 	// 软链接增加复杂性且不符合实际需求，已移除
 	log.Printf("跳过创建软链接（latest），保持简单架构")
 
+	// 8. 增量更新全文索引
+	if err := IndexParsedResult(targetDir, indexDir); err != nil {
+		log.Printf("更新全文索引失败: %v", err)
+	}
+
 	log.Printf("文件组织完成: %s", targetDir)
 	return nil
 }
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeParsedResult 在root下写入一篇解析结果（full.md和只含标题的meta.json），返回结果目录
+func writeParsedResult(t *testing.T, root, name, title, markdown string) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	meta, err := json.Marshal(map[string]string{"title": title})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFixtureFile(t, filepath.Join(dir, "full.md"), []byte(markdown))
+	writeFixtureFile(t, filepath.Join(dir, "meta.json"), meta)
+	return dir
+}
+
+// writeFixtureFile 创建父目录并写入测试文件，失败时终止测试
+func writeFixtureFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
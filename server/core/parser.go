@@ -18,14 +18,12 @@ type PDFParser struct {
 	cacheDir     string
 }
 
-// ParsedDocument 解析后的文档
+// ParsedDocument 解析后的文档（摘要和关键要点由MinerUClient.ParseItem写入解析结果目录的summary.json）
 type ParsedDocument struct {
 	ZoteroItem ZoteroItem `json:"zotero_item"`
 	ParseHash  string     `json:"parse_hash"`
-	Content    string     `json:"content"`    // Markdown格式内容
-	Summary    string     `json:"summary"`    // AI生成的摘要
-	KeyPoints  []string   `json:"key_points"` // 关键要点
-	ZipPath    string     `json:"zip_path"`   // ZIP文件路径
+	Content    string     `json:"content"`  // Markdown格式内容
+	ZipPath    string     `json:"zip_path"` // ZIP文件路径
 	ParseTime  time.Time  `json:"parse_time"`
 }
 
@@ -82,8 +80,6 @@ func (p *PDFParser) ParseDocument(ctx context.Context, itemID int, pdfPath strin
 		ZoteroItem: item[0],
 		ParseHash:  cacheKey,
 		Content:    "PDF解析完成，结果已保存",
-		Summary:    "AI摘要功能待实现",
-		KeyPoints:  []string{"关键要点提取待实现"},
 		ZipPath:    result.ZipPath,
 		ParseTime:  time.Now(),
 	}
@@ -0,0 +1,387 @@
+package core
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// 系统提示模板名，可在提示模板目录中用同名.tmpl文件覆盖
+const (
+	PromptChatSystem = "chat_system" // 文献对话的系统提示（检索片段由程序追加在其后）
+	PromptAssistant  = "assistant"   // 未检索到本地文献时的通用问答
+	PromptAnalyst    = "analyst"     // 相关文献分析
+	PromptToolSelect = "tool_select" // AI选择MCP工具，变量Tools为工具列表
+	PromptRAGSystem  = "rag_system"  // 仅依据检索片段回答，变量Context为编号的文献片段
+	PromptDocumentQA = "document_qa" // 依据单篇论文全文回答，变量Title和Context为标题和全文
+
+	PromptToolAnalysis        = "tool_analysis"         // 分析MCP工具结果的系统提示
+	PromptToolAnalysisRequest = "tool_analysis_request" // 分析MCP工具结果的提问，变量Question和Context为搜索词和工具结果
+)
+
+// DefaultPromptLanguage 未配置时的回答语言
+const DefaultPromptLanguage = "中文"
+
+var (
+	commandNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+	// commandDescription 命令模板首行的模板注释作为命令说明：{{/* 说明 */}}
+	commandDescription = regexp.MustCompile(`^\{\{-?\s*/\*\s*(.*?)\s*\*/\s*-?\}\}`)
+)
+
+// defaultSystemPrompts 内置系统提示模板
+var defaultSystemPrompts = map[string]string{
+	PromptChatSystem: `你是一个专业的学术文献助手，专门帮助用户分析和理解学术论文。请用{{.Language}}回答，并提供准确、有用的信息。
+
+你的主要功能包括：
+1. 分析论文内容和方法
+2. 解释专业术语和概念
+3. 比较不同研究方法的优缺点
+4. 提供研究建议和未来方向
+5. 协助文献综述和总结
+
+请基于提供的文献内容进行回答，保持专业、客观、有帮助的态度。`,
+	PromptAssistant: `你是一个专业的学术文献助手，能够帮助用户分析、搜索和回答关于学术文献的问题。请用{{.Language}}回答，保持专业和准确。`,
+	PromptAnalyst:   `你是一个专业的学术文献分析师，请基于提供的文献信息进行智能分析，并用{{.Language}}回答。`,
+	PromptToolSelect: `你是一个专业的学术研究助手，可以帮助用户查找和分析学术文献。你有以下MCP工具可以使用：
+
+{{.Tools}}
+
+## 明确的工具调用规则：
+
+**当用户询问以下内容时，必须使用对应的工具：**
+
+1. **搜索论文/文献** → 必须使用：
+   - "search_europe_pmc"（用于一般学术文献搜索）
+   - "search_arxiv_papers"（专门搜索预印本，包含arxiv、preprint关键词时）
+
+2. **获取论文详情** → 必须使用：
+   - "get_article_details"（当用户提供DOI或PMID时）
+
+3. **查找相似研究** → 必须使用：
+   - "get_similar_articles"（当用户询问"相似"、"类似"、"similar"研究时）
+
+4. **查找引用文献** → 必须使用：
+   - "get_citing_articles"（当用户询问"引用"、"cite"时）
+
+5. **期刊信息查询** → 必须使用：
+   - "get_journal_quality"（当用户询问期刊、影响因子、分区时）
+
+**回复格式：**
+如果需要使用工具，请严格按照：
+TOOL: <工具名>
+ARGS: <JSON格式的参数>
+
+如果不需要使用工具（比如询问概念、定义等），请直接回答用户问题。
+
+**重要提示：**
+- 不要猜测，严格按照上述规则选择工具
+- 参数必须是有效的JSON格式
+- keyword参数提取用户查询中的核心关键词
+- max_results建议使用3-10
+
+**示例：**
+用户：搜索机器学习相关论文
+TOOL: search_europe_pmc
+ARGS: {"keyword": "machine learning", "max_results": 5}
+
+用户：查找和10.1038/nature12373相似的研究
+TOOL: get_similar_articles
+ARGS: {"identifier": "10.1038/nature12373", "max_results": 3}
+
+用户：什么是深度学习？
+（直接回答，不需要工具）
+`,
+	PromptRAGSystem: `你是一个专业的学术文献助手。请仅依据下面提供的文献片段回答用户问题，用{{.Language}}作答。
+引用片段内容时在句末用[编号]标注来源（如[1]、[2][3]）；片段中没有相关信息时请明确说明，不要编造。
+
+=== 文献片段 ===
+{{.Context}}`,
+	PromptDocumentQA: `你是一个专业的学术文献助手。请仅根据下面的论文全文回答问题，用{{.Language}}作答，无法从原文得到答案时请明确说明。
+
+=== 论文《{{.Title}}》 ===
+{{.Context}}`,
+	PromptToolAnalysis: `你是一个专业的学术文献分析师，能够分析搜索结果并生成简洁、有用的摘要。请用{{.Language}}回答。`,
+	PromptToolAnalysisRequest: `请分析以下搜索结果，并生成一份简洁、用户友好的摘要报告。搜索关键词："{{.Question}}"
+
+搜索结果（原始数据）：
+{{.Context}}
+
+请提供：
+1. 对搜索结果的分析和总结
+2. 最重要的发现或亮点
+3. 相关性和质量评估
+4. 用{{.Language}}回答，保持专业和准确`,
+}
+
+// defaultCommands 内置命令模板，在对话中以 /名称 [补充说明] 调用
+var defaultCommands = map[string]string{
+	"summarize": `{{/* 总结文献的研究问题、方法、结果和结论 */}}请总结{{if .Title}}《{{.Title}}》{{else}}这篇文献{{end}}，依次说明研究问题、方法、主要结果和结论，用{{.Language}}回答，控制在300字以内。
+{{- if .Selection}}
+
+请重点关注以下内容：
+{{.Selection}}
+{{- end}}
+{{- if .Question}}
+
+补充要求：{{.Question}}
+{{- end}}`,
+	"critique": `{{/* 批判性评价文献的贡献、局限和可信度 */}}请以审稿人的视角批判性评价{{if .Title}}《{{.Title}}》{{else}}这篇文献{{end}}：
+1. 主要贡献及其新颖性
+2. 方法和实验设计的薄弱之处
+3. 结论是否有充分证据支持
+4. 值得追问的问题和改进建议
+请用{{.Language}}回答，引用原文时标注来源。
+{{- if .Selection}}
+
+请重点评价以下内容：
+{{.Selection}}
+{{- end}}
+{{- if .Question}}
+
+补充要求：{{.Question}}
+{{- end}}`,
+	"methods": `{{/* 提取文献的方法、数据集、评价指标和实验设置 */}}请梳理{{if .Title}}《{{.Title}}》{{else}}这篇文献{{end}}的研究方法，包括：模型或方法框架、数据集、评价指标、关键实验设置和超参数。原文未说明的项目请写"未说明"，用{{.Language}}回答。
+{{- if .Selection}}
+
+请重点关注以下内容：
+{{.Selection}}
+{{- end}}
+{{- if .Question}}
+
+补充要求：{{.Question}}
+{{- end}}`,
+}
+
+// PromptData 提示模板可用的变量
+type PromptData struct {
+	Title     string // 文献标题
+	Authors   string
+	Year      string
+	DOI       string
+	ItemKey   string
+	Abstract  string // 文献摘要（已生成summary.json时为TL;DR）
+	Selection string // 用户选中的文本
+	Question  string // 命令后附带的补充说明或用户问题
+	Language  string // 回答语言，为空时使用模板库的默认语言
+	Tools     string // 可用工具列表（仅tool_select）
+	Context   string // 文献片段、论文全文或工具结果（rag_system、document_qa、tool_analysis_request）
+}
+
+// DocumentPromptData 用解析结果的元数据填充提示变量
+func DocumentPromptData(result *ParsedResult) PromptData {
+	data := PromptData{Title: result.Title()}
+	if result.Info != nil {
+		data.Authors = result.Info.Authors
+		data.Year = result.Info.Date
+		data.ItemKey = result.Info.ItemKey
+	}
+	if summary, err := LoadPaperSummary(result.Dir); err == nil {
+		data.Abstract = summary.TLDR
+	}
+	return data
+}
+
+// PromptCommand 对话中可调用的命名命令
+type PromptCommand struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Custom      bool   `json:"custom"` // 来自提示模板目录
+}
+
+// PromptLibrary 系统提示和命令模板库：内置模板可被提示模板目录中的文件覆盖
+//
+// 目录结构：<dir>/<模板名>.tmpl 覆盖系统提示，<dir>/commands/<命令名>.tmpl 定义命令
+type PromptLibrary struct {
+	language string
+	system   map[string]*template.Template
+	defaults map[string]*template.Template
+	commands map[string]*template.Template
+	info     map[string]PromptCommand
+}
+
+// DefaultPromptLibrary 只包含内置模板的模板库
+func DefaultPromptLibrary() *PromptLibrary {
+	library, err := NewPromptLibrary("", DefaultPromptLanguage)
+	if err != nil {
+		panic(fmt.Sprintf("内置提示模板错误: %v", err)) // 内置模板由测试保证可解析
+	}
+	return library
+}
+
+// NewPromptLibrary 加载内置模板和dir中的自定义模板；dir为空或不存在时只使用内置模板
+func NewPromptLibrary(dir, language string) (*PromptLibrary, error) {
+	if language == "" {
+		language = DefaultPromptLanguage
+	}
+	library := &PromptLibrary{
+		language: language,
+		system:   make(map[string]*template.Template),
+		defaults: make(map[string]*template.Template),
+		commands: make(map[string]*template.Template),
+		info:     make(map[string]PromptCommand),
+	}
+	for name, text := range defaultSystemPrompts {
+		tmpl, err := template.New(name).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("解析内置模板 %s 失败: %w", name, err)
+		}
+		library.system[name] = tmpl
+		library.defaults[name] = tmpl
+	}
+	for name, text := range defaultCommands {
+		if err := library.addCommand(name, text, false); err != nil {
+			return nil, err
+		}
+	}
+	if dir == "" {
+		return library, nil
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	for _, path := range files {
+		name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+		if _, ok := defaultSystemPrompts[name]; !ok {
+			return nil, fmt.Errorf("未知的系统提示模板 %s（可用: %s）", filepath.Base(path), strings.Join(sortedKeys(defaultSystemPrompts), ", "))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取提示模板失败: %w", err)
+		}
+		tmpl, err := template.New(name).Parse(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("解析提示模板 %s 失败: %w", filepath.Base(path), err)
+		}
+		library.system[name] = tmpl
+	}
+
+	files, _ = filepath.Glob(filepath.Join(dir, "commands", "*.tmpl"))
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取命令模板失败: %w", err)
+		}
+		if err := library.addCommand(strings.TrimSuffix(filepath.Base(path), ".tmpl"), string(data), true); err != nil {
+			return nil, err
+		}
+	}
+	return library, nil
+}
+
+// LoadPromptLibrary 加载提示模板库，自定义模板有错误时记录日志并只使用内置模板
+func LoadPromptLibrary(dir, language string) *PromptLibrary {
+	library, err := NewPromptLibrary(dir, language)
+	if err != nil {
+		log.Printf("⚠️ 加载提示模板失败，使用内置模板: %v", err)
+		library, _ = NewPromptLibrary("", language)
+	}
+	return library
+}
+
+// addCommand 解析并登记命令模板，custom命令可覆盖同名内置命令
+func (l *PromptLibrary) addCommand(name, text string, custom bool) error {
+	name = strings.ToLower(name)
+	if !commandNamePattern.MatchString(name) {
+		return fmt.Errorf("无效的命令名: %s（仅限小写字母、数字、-和_）", name)
+	}
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return fmt.Errorf("解析命令模板 %s 失败: %w", name, err)
+	}
+	description := ""
+	if match := commandDescription.FindStringSubmatch(strings.TrimSpace(text)); match != nil {
+		description = match[1]
+	}
+	l.commands[name] = tmpl
+	l.info[name] = PromptCommand{Name: name, Description: description, Custom: custom}
+	return nil
+}
+
+// Language 默认回答语言
+func (l *PromptLibrary) Language() string {
+	return l.language
+}
+
+// System 渲染系统提示；自定义模板执行失败时记录日志并使用内置模板
+func (l *PromptLibrary) System(name string, data PromptData) string {
+	if data.Language == "" {
+		data.Language = l.language
+	}
+	tmpl, ok := l.system[name]
+	if !ok {
+		log.Printf("⚠️ 未知的提示模板: %s", name)
+		return ""
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		log.Printf("⚠️ 渲染提示模板 %s 失败，使用内置模板: %v", name, err)
+		out.Reset()
+		l.defaults[name].Execute(&out, data)
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// Commands 可用命令，按名称排序
+func (l *PromptLibrary) Commands() []PromptCommand {
+	commands := make([]PromptCommand, 0, len(l.info))
+	for _, name := range sortedKeys(l.info) {
+		commands = append(commands, l.info[name])
+	}
+	return commands
+}
+
+// ParseCommand 解析"/命令 补充说明"形式的输入；不是命令时ok为false
+func ParseCommand(input string) (name, args string, ok bool) {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, "/") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(input[1:], " ")
+	name = strings.ToLower(name)
+	if !commandNamePattern.MatchString(name) {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// Expand 将命令输入展开为发送给AI的提问；不是命令时原样返回，命令不存在时返回错误
+//
+// 命令后的补充说明写入data.Question
+func (l *PromptLibrary) Expand(input string, data PromptData) (string, bool, error) {
+	name, args, ok := ParseCommand(input)
+	if !ok {
+		return input, false, nil
+	}
+	tmpl, exists := l.commands[name]
+	if !exists {
+		names := make([]string, 0, len(l.info))
+		for _, command := range l.Commands() {
+			names = append(names, "/"+command.Name)
+		}
+		return "", true, fmt.Errorf("未知命令 /%s，可用命令: %s", name, strings.Join(names, " "))
+	}
+	if data.Language == "" {
+		data.Language = l.language
+	}
+	data.Question = args
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", true, fmt.Errorf("渲染命令 /%s 失败: %w", name, err)
+	}
+	return strings.TrimSpace(out.String()), true, nil
+}
+
+// sortedKeys map的键，按字母排序
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
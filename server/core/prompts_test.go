@@ -0,0 +1,144 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultPromptLibrary(t *testing.T) {
+	library := DefaultPromptLibrary()
+	for _, name := range []string{PromptChatSystem, PromptAssistant, PromptAnalyst, PromptRAGSystem, PromptDocumentQA, PromptToolAnalysis, PromptToolAnalysisRequest} {
+		if prompt := library.System(name, PromptData{}); !strings.Contains(prompt, "中文") {
+			t.Errorf("%s 应使用默认语言: %s", name, prompt)
+		}
+	}
+	if prompt := library.System(PromptToolSelect, PromptData{Tools: "- search_europe_pmc"}); !strings.Contains(prompt, "- search_europe_pmc") || !strings.Contains(prompt, "TOOL: <工具名>") {
+		t.Errorf("工具选择提示 = %s", prompt)
+	}
+	if prompt := library.System(PromptToolAnalysisRequest, PromptData{Question: "transformer", Context: "1. Attention"}); !strings.Contains(prompt, `搜索关键词："transformer"`) || !strings.Contains(prompt, "1. Attention") {
+		t.Errorf("工具结果分析提问 = %s", prompt)
+	}
+
+	var names []string
+	for _, command := range library.Commands() {
+		names = append(names, command.Name)
+		if command.Description == "" || command.Custom {
+			t.Errorf("内置命令 = %+v", command)
+		}
+	}
+	if strings.Join(names, ",") != "critique,methods,summarize" {
+		t.Errorf("Commands() = %v", names)
+	}
+}
+
+func TestPromptLibraryExpand(t *testing.T) {
+	library := DefaultPromptLibrary()
+
+	prompt, isCommand, err := library.Expand("/summarize 侧重实验", PromptData{Title: "Attention Is All You Need", Selection: "multi-head attention", Language: "English"})
+	if err != nil || !isCommand {
+		t.Fatalf("Expand() = %v, %v", isCommand, err)
+	}
+	for _, want := range []string{"《Attention Is All You Need》", "用English回答", "multi-head attention", "补充要求：侧重实验"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("展开结果缺少 %q:\n%s", want, prompt)
+		}
+	}
+	if strings.Contains(prompt, "/*") {
+		t.Errorf("命令说明注释不应出现在提问中: %s", prompt)
+	}
+
+	prompt, _, _ = library.Expand("/METHODS", PromptData{})
+	if !strings.Contains(prompt, "这篇文献") || strings.Contains(prompt, "补充要求") {
+		t.Errorf("无文献和补充说明时的展开结果 = %s", prompt)
+	}
+
+	if prompt, isCommand, _ := library.Expand("什么是注意力机制？", PromptData{}); isCommand || prompt != "什么是注意力机制？" {
+		t.Errorf("普通问题应原样返回: %q", prompt)
+	}
+	if _, _, err := library.Expand("/unknown", PromptData{}); err == nil || !strings.Contains(err.Error(), "/summarize") {
+		t.Errorf("未知命令应列出可用命令: %v", err)
+	}
+	if _, _, ok := ParseCommand("/usr/bin 路径"); ok {
+		t.Error("包含/的路径不应被当作命令")
+	}
+}
+
+func TestPromptLibraryCustomTemplates(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "commands"), 0755)
+	os.WriteFile(filepath.Join(dir, "chat_system.tmpl"), []byte("You are a reviewer. Answer in {{.Language}}.{{if .Title}} Paper: {{.Title}}{{end}}"), 0644)
+	os.WriteFile(filepath.Join(dir, "commands", "limitations.tmpl"), []byte("{{/* 列出局限性 */}}List the limitations of {{.Title}}."), 0644)
+	os.WriteFile(filepath.Join(dir, "commands", "summarize.tmpl"), []byte("TL;DR of {{.Title}} {{.Question}}"), 0644)
+
+	library, err := NewPromptLibrary(dir, "English")
+	if err != nil {
+		t.Fatalf("NewPromptLibrary() error = %v", err)
+	}
+	if got := library.System(PromptChatSystem, PromptData{Title: "BERT"}); got != "You are a reviewer. Answer in English. Paper: BERT" {
+		t.Errorf("自定义系统提示 = %q", got)
+	}
+	if got := library.System(PromptAssistant, PromptData{}); !strings.Contains(got, "English") {
+		t.Errorf("未覆盖的模板应使用内置模板: %s", got)
+	}
+	if got, _, _ := library.Expand("/summarize briefly", PromptData{Title: "BERT"}); got != "TL;DR of BERT briefly" {
+		t.Errorf("自定义命令应覆盖内置命令: %q", got)
+	}
+	commands := library.Commands()
+	if len(commands) != 4 || commands[1].Name != "limitations" || commands[1].Description != "列出局限性" || !commands[1].Custom {
+		t.Errorf("Commands() = %+v", commands)
+	}
+
+	// 对话系统提示使用自定义模板，检索片段追加在其后
+	manager := NewAIConversationManager(&fakeAIClient{reply: "ok"}, nil)
+	manager.SetPromptLibrary(library)
+	manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+	conv, err := manager.StartConversationWithDocument(context.Background(), "What is the Transformer?", &DocumentContext{DocumentNames: []string{"attention_20240101"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if system := conv.Messages[0].Content; !strings.HasPrefix(system, "You are a reviewer. Answer in English.") || !strings.Contains(system, "相关文献片段") {
+		t.Errorf("系统提示 = %s", system)
+	}
+
+	// 基于片段回答使用配置的语言和自定义rag_system模板
+	messages := BuildRAGMessages(library, "What is the Transformer?", nil)
+	if system := messages[0].Content; !strings.Contains(system, "用English作答") {
+		t.Errorf("RAG系统提示应使用配置的语言: %s", system)
+	}
+	os.WriteFile(filepath.Join(dir, "rag_system.tmpl"), []byte("Cite [n]. Sources:\n{{.Context}}"), 0644)
+	custom, _ := NewPromptLibrary(dir, "English")
+	pipeline := NewRAGPipeline(writeRAGFixture(t))
+	pipeline.SetPromptLibrary(custom)
+	client := &fakeAIClient{reply: "Self-attention [1]"}
+	if _, err := pipeline.Answer(context.Background(), client, "What layers does the Transformer use?", RAGOptions{TopK: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if system := client.requests[0].Messages[0].Content; !strings.HasPrefix(system, "Cite [n]. Sources:\n[1] 《Attention Is All You Need》") {
+		t.Errorf("自定义RAG系统提示 = %s", system)
+	}
+	os.Remove(filepath.Join(dir, "rag_system.tmpl"))
+
+	os.WriteFile(filepath.Join(dir, "unknown.tmpl"), []byte("x"), 0644)
+	if _, err := NewPromptLibrary(dir, ""); err == nil {
+		t.Error("未知的系统提示模板应返回错误")
+	}
+	os.Remove(filepath.Join(dir, "unknown.tmpl"))
+	os.WriteFile(filepath.Join(dir, "commands", "broken.tmpl"), []byte("{{.Title"), 0644)
+	if _, err := NewPromptLibrary(dir, ""); err == nil {
+		t.Error("模板语法错误应返回错误")
+	}
+	if library := LoadPromptLibrary(dir, ""); len(library.Commands()) != 3 {
+		t.Error("加载失败时应回退到内置模板")
+	}
+
+	// 自定义模板执行出错时回退到内置模板
+	os.Remove(filepath.Join(dir, "commands", "broken.tmpl"))
+	os.WriteFile(filepath.Join(dir, "assistant.tmpl"), []byte("{{.Missing}}"), 0644)
+	library, _ = NewPromptLibrary(dir, "")
+	if got := library.System(PromptAssistant, PromptData{}); !strings.Contains(got, "学术文献助手") {
+		t.Errorf("执行失败时应使用内置模板: %q", got)
+	}
+}
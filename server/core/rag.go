@@ -0,0 +1,438 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// RAG默认参数
+const (
+	DefaultRAGTopK        = 8
+	DefaultRAGTokenBudget = 6000
+)
+
+// RetrievedChunk 检索命中的文本块
+type RetrievedChunk struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Citation 引用标注：《标题》 §章节 p.页码
+func (c Chunk) Citation() string {
+	citation := "《" + c.Title + "》"
+	if c.Section != "" {
+		citation += " §" + c.Section
+	}
+	switch {
+	case c.Page > 0 && c.PageEnd > c.Page:
+		citation += fmt.Sprintf(" pp.%d-%d", c.Page, c.PageEnd)
+	case c.Page > 0:
+		citation += fmt.Sprintf(" p.%d", c.Page)
+	}
+	return citation
+}
+
+// Retriever 从候选文本块中检索与问题最相关的k个
+type Retriever interface {
+	Retrieve(ctx context.Context, query string, chunks []Chunk, k int) ([]RetrievedChunk, error)
+}
+
+// KeywordRetriever 基于BM25的关键词检索，英文按单词、中文按相邻双字切分
+type KeywordRetriever struct{}
+
+// BM25参数
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Retrieve 按BM25得分返回前k个命中的文本块
+func (KeywordRetriever) Retrieve(ctx context.Context, query string, chunks []Chunk, k int) ([]RetrievedChunk, error) {
+	queryTerms := uniqueTerms(tokenize(query))
+	if len(queryTerms) == 0 || len(chunks) == 0 {
+		return nil, nil
+	}
+
+	termFreqs := make([]map[string]int, len(chunks))
+	docFreq := make(map[string]int, len(queryTerms))
+	totalLen := 0
+	for i, chunk := range chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		terms := tokenize(chunk.Section + "\n" + chunk.Text)
+		tf := make(map[string]int)
+		for _, term := range terms {
+			tf[term]++
+		}
+		for _, term := range queryTerms {
+			if tf[term] > 0 {
+				docFreq[term]++
+			}
+		}
+		termFreqs[i] = tf
+		totalLen += len(terms)
+	}
+	avgLen := float64(totalLen) / float64(len(chunks))
+	n := float64(len(chunks))
+
+	var results []RetrievedChunk
+	for i, chunk := range chunks {
+		tf := termFreqs[i]
+		docLen := 0
+		for _, count := range tf {
+			docLen += count
+		}
+
+		score := 0.0
+		for _, term := range queryTerms {
+			freq := float64(tf[term])
+			if freq == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * freq * (bm25K1 + 1) / (freq + bm25K1*(1-bm25B+bm25B*float64(docLen)/avgLen))
+		}
+		if score > 0 {
+			results = append(results, RetrievedChunk{Chunk: chunk, Score: score})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if k > 0 && len(results) > k {
+		results = results[:k]
+	}
+	return results, nil
+}
+
+// tokenize 检索分词：英文和数字按单词小写，中日韩文字按相邻双字（单字成词时保留单字）
+func tokenize(text string) []string {
+	var terms []string
+	var word []rune
+	var cjkRun []rune
+
+	flushWord := func() {
+		if len(word) > 1 || (len(word) == 1 && unicode.IsDigit(word[0])) {
+			if w := string(word); !englishStopwords[w] {
+				terms = append(terms, w)
+			}
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjkRun) == 1:
+			terms = append(terms, string(cjkRun))
+		case len(cjkRun) > 1:
+			for i := 0; i+1 < len(cjkRun); i++ {
+				terms = append(terms, string(cjkRun[i:i+2]))
+			}
+		}
+		cjkRun = cjkRun[:0]
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjkRun = append(cjkRun, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, unicode.ToLower(r))
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return terms
+}
+
+// uniqueTerms 去重并保持顺序
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	var unique []string
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
+
+// englishStopwords 检索时忽略的常见英文虚词
+var englishStopwords = map[string]bool{
+	"the": true, "of": true, "and": true, "to": true, "in": true, "is": true, "are": true,
+	"for": true, "on": true, "with": true, "as": true, "by": true, "an": true, "be": true,
+	"this": true, "that": true, "it": true, "at": true, "from": true, "or": true, "we": true,
+	"what": true, "how": true, "which": true, "does": true, "do": true, "was": true, "were": true,
+}
+
+// PackChunks 按得分从高到低装入不超过budget个token的文本块（放不下的跳过，继续尝试更小的块）
+func PackChunks(chunks []RetrievedChunk, budget int) []RetrievedChunk {
+	if budget <= 0 {
+		return chunks
+	}
+	var packed []RetrievedChunk
+	used := 0
+	for _, chunk := range chunks {
+		tokens := chunk.Tokens
+		if tokens == 0 {
+			tokens = EstimateTokens(chunk.Text)
+		}
+		if used+tokens > budget {
+			continue
+		}
+		packed = append(packed, chunk)
+		used += tokens
+	}
+	return packed
+}
+
+// FormatChunkContext 将文本块格式化为带编号和出处的上下文，编号从1开始，供回答中以[n]引用
+func FormatChunkContext(chunks []RetrievedChunk) string {
+	var builder strings.Builder
+	for i, chunk := range chunks {
+		builder.WriteString(fmt.Sprintf("[%d] %s\n%s\n\n", i+1, chunk.Citation(), chunk.Text))
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// RAGOptions 检索参数，零值使用默认值
+type RAGOptions struct {
+	TopK        int
+	TokenBudget int
+	// Documents 限定检索的结果目录名，为空时检索全部解析结果
+	Documents []string
+}
+
+// RAGAnswer 基于检索片段的回答
+type RAGAnswer struct {
+	Question    string           `json:"question"`
+	Answer      string           `json:"answer"`
+	Chunks      []RetrievedChunk `json:"chunks"`
+	Citations   []Citation       `json:"citations"`
+	Unsupported []CitationFlag   `json:"unsupported,omitempty"` // 校验未通过的句子
+	Usage       UsageInfo        `json:"usage"`
+}
+
+// chunkedDocument 已分块的文献，full.md修改后重新分块
+type chunkedDocument struct {
+	modTime time.Time
+	chunks  []Chunk
+	summary DocumentSummary
+}
+
+// RAGPipeline 对解析结果的full.md分块、检索并组装上下文
+type RAGPipeline struct {
+	resultsDir string
+	retriever  Retriever
+	chunkOpts  ChunkOptions
+	prompts    *PromptLibrary
+
+	mu   sync.Mutex
+	docs map[string]*chunkedDocument
+}
+
+// NewRAGPipeline 创建RAG流水线，默认使用关键词检索
+func NewRAGPipeline(resultsDir string) *RAGPipeline {
+	return &RAGPipeline{
+		resultsDir: resultsDir,
+		retriever:  KeywordRetriever{},
+		prompts:    DefaultPromptLibrary(),
+		docs:       make(map[string]*chunkedDocument),
+	}
+}
+
+// SetRetriever 替换检索器
+func (p *RAGPipeline) SetRetriever(retriever Retriever) {
+	p.retriever = retriever
+}
+
+// SetPromptLibrary 设置回答时使用的提示模板库
+func (p *RAGPipeline) SetPromptLibrary(prompts *PromptLibrary) {
+	p.prompts = prompts
+}
+
+// ResultsDir 解析结果目录
+func (p *RAGPipeline) ResultsDir() string {
+	return p.resultsDir
+}
+
+// load 获取文献的分块结果，按full.md修改时间缓存
+func (p *RAGPipeline) load(result ParsedResult) (*chunkedDocument, error) {
+	stat, err := os.Stat(result.FullTextPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取全文失败: %w", err)
+	}
+
+	p.mu.Lock()
+	cached, ok := p.docs[result.Name]
+	p.mu.Unlock()
+	if ok && cached.modTime.Equal(stat.ModTime()) {
+		return cached, nil
+	}
+
+	chunks, err := ChunkParsedResult(&result, p.chunkOpts)
+	if err != nil {
+		return nil, err
+	}
+	content, _ := result.ReadFullText()
+	doc := &chunkedDocument{
+		modTime: stat.ModTime(),
+		chunks:  chunks,
+		summary: DocumentSummary{
+			Title:    result.Title(),
+			Authors:  extractAuthorsFromContent(content),
+			Abstract: extractAbstractFromContent(content),
+			Keywords: extractKeywordsFromContent(content),
+		},
+	}
+	if result.Info != nil && result.Info.Authors != "" {
+		doc.summary.Authors = result.Info.Authors
+	}
+
+	p.mu.Lock()
+	p.docs[result.Name] = doc
+	p.mu.Unlock()
+	return doc, nil
+}
+
+// selectResults 按名称筛选解析结果
+func (p *RAGPipeline) selectResults(documents []string) ([]ParsedResult, error) {
+	if len(documents) == 0 {
+		return ListParsedResults(p.resultsDir)
+	}
+	var results []ParsedResult
+	for _, name := range documents {
+		result, err := GetParsedResult(p.resultsDir, name)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
+}
+
+// Chunks 返回指定文献（为空时全部）的文本块
+func (p *RAGPipeline) Chunks(documents ...string) ([]Chunk, error) {
+	results, err := p.selectResults(documents)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []Chunk
+	for _, result := range results {
+		doc, err := p.load(result)
+		if err != nil {
+			log.Printf("⚠️ 文献分块失败 %s: %v", result.Name, err)
+			continue
+		}
+		chunks = append(chunks, doc.chunks...)
+	}
+	return chunks, nil
+}
+
+// Summaries 返回文本块所属文献的摘要信息（按首次出现顺序）
+func (p *RAGPipeline) Summaries(chunks []RetrievedChunk) []DocumentSummary {
+	var summaries []DocumentSummary
+	seen := make(map[string]bool)
+	for _, chunk := range chunks {
+		if seen[chunk.Document] {
+			continue
+		}
+		seen[chunk.Document] = true
+
+		p.mu.Lock()
+		doc, ok := p.docs[chunk.Document]
+		p.mu.Unlock()
+		summary := DocumentSummary{Title: chunk.Title}
+		if ok {
+			summary = doc.summary
+		}
+		summary.ID = len(summaries)
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// Retrieve 检索与问题最相关的top-k文本块，并按token预算截取
+func (p *RAGPipeline) Retrieve(ctx context.Context, query string, opts RAGOptions) ([]RetrievedChunk, error) {
+	if opts.TopK <= 0 {
+		opts.TopK = DefaultRAGTopK
+	}
+	if opts.TokenBudget <= 0 {
+		opts.TokenBudget = DefaultRAGTokenBudget
+	}
+
+	chunks, err := p.Chunks(opts.Documents...)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	retrieved, err := p.retriever.Retrieve(ctx, query, chunks, opts.TopK)
+	if err != nil {
+		return nil, fmt.Errorf("检索文本块失败: %w", err)
+	}
+	packed := PackChunks(retrieved, opts.TokenBudget)
+	log.Printf("📚 RAG检索: %d 个候选块，命中 %d 个，装入上下文 %d 个", len(chunks), len(retrieved), len(packed))
+	return packed, nil
+}
+
+// BuildRAGMessages 用rag_system模板构建基于检索片段回答的系统消息
+func BuildRAGMessages(prompts *PromptLibrary, question string, chunks []RetrievedChunk) []ChatMessage {
+	system := prompts.System(PromptRAGSystem, PromptData{Question: question, Context: FormatChunkContext(chunks)})
+	return []ChatMessage{
+		{Role: "system", Content: system, Timestamp: time.Now()},
+		{Role: "user", Content: question, Timestamp: time.Now()},
+	}
+}
+
+// Answer 检索相关片段并让AI基于片段回答，返回答案和使用的片段
+func (p *RAGPipeline) Answer(ctx context.Context, client AIClient, question string, opts RAGOptions) (*RAGAnswer, error) {
+	chunks, err := p.Retrieve(ctx, question, opts)
+	if err != nil {
+		return nil, err
+	}
+	return AnswerWithChunks(ctx, client, p.prompts, question, chunks)
+}
+
+// AnswerWithChunks 让AI基于已检索的片段回答，并生成引用列表和校验结果
+func AnswerWithChunks(ctx context.Context, client AIClient, prompts *PromptLibrary, question string, chunks []RetrievedChunk) (*RAGAnswer, error) {
+	ctx = WithUsageFeature(ctx, FeatureAsk)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("未在已解析的文献中找到与问题相关的内容")
+	}
+
+	resp, err := client.Chat(ctx, &AIRequest{Messages: BuildRAGMessages(prompts, question, chunks)})
+	if err != nil {
+		return nil, fmt.Errorf("AI 响应失败: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("AI 响应为空")
+	}
+
+	answer := resp.Choices[0].Message.Content
+	return &RAGAnswer{
+		Question:    question,
+		Answer:      answer,
+		Chunks:      chunks,
+		Citations:   CitationsFromChunks(chunks),
+		Unsupported: VerifyCitations(answer, chunks),
+		Usage:       resp.Usage,
+	}, nil
+}
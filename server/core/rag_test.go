@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeAIClient 返回固定回复并记录请求的AI客户端
+type fakeAIClient struct {
+	reply    string
+	requests []*AIRequest
+}
+
+func (c *fakeAIClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	c.requests = append(c.requests, req)
+	return &AIResponse{Choices: []Choice{{Message: ChatMessage{Role: "assistant", Content: c.reply}}}}, nil
+}
+
+func (c *fakeAIClient) ChatStream(ctx context.Context, req *AIRequest) (<-chan *Choice, error) {
+	resp, _ := c.Chat(ctx, req)
+	ch := make(chan *Choice, 1)
+	ch <- &resp.Choices[0]
+	close(ch)
+	return ch, nil
+}
+
+const ragFixtureMarkdown = `# Attention Is All You Need
+
+## Abstract
+
+The dominant sequence transduction models are based on recurrent networks.
+
+![](images/fig1.jpg)
+
+## Model Architecture
+
+The Transformer uses stacked self-attention and point-wise feed-forward layers.
+
+## 训练
+
+我们在机器翻译任务上训练模型，使用注意力机制替代循环结构。
+`
+
+// writeRAGFixture 写入带content_list.json页码信息的解析结果
+func writeRAGFixture(t *testing.T) string {
+	t.Helper()
+	resultsDir := t.TempDir()
+	dir := filepath.Join(resultsDir, "attention_20240101")
+	os.MkdirAll(dir, 0755)
+	os.WriteFile(filepath.Join(dir, "full.md"), []byte(ragFixtureMarkdown), 0644)
+	os.WriteFile(filepath.Join(dir, "meta.json"), []byte(`{"title":"Attention Is All You Need","item_key":"ABCD1234"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "paper_content_list.json"), []byte(`[
+		{"type":"text","text":"Attention Is All You Need","page_idx":0},
+		{"type":"text","text":"The dominant sequence transduction models are based on recurrent networks.","page_idx":0},
+		{"type":"text","text":"Model Architecture","page_idx":1},
+		{"type":"text","text":"The Transformer uses stacked self-attention and point-wise feed-forward layers.","page_idx":1},
+		{"type":"text","text":"训练","page_idx":2},
+		{"type":"text","text":"我们在机器翻译任务上训练模型，使用注意力机制替代循环结构。","page_idx":2}
+	]`), 0644)
+	return resultsDir
+}
+
+func TestChunkParsedResult(t *testing.T) {
+	result, err := GetParsedResult(writeRAGFixture(t), "attention_20240101")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chunks, err := ChunkParsedResult(result, ChunkOptions{})
+	if err != nil {
+		t.Fatalf("ChunkParsedResult() error = %v", err)
+	}
+	if len(chunks) != 3 {
+		t.Fatalf("应按章节切分为3块, got %d: %+v", len(chunks), chunks)
+	}
+
+	want := []struct {
+		section string
+		page    int
+	}{{"Abstract", 1}, {"Model Architecture", 2}, {"训练", 3}}
+	for i, w := range want {
+		c := chunks[i]
+		if c.Section != w.section || c.Page != w.page {
+			t.Errorf("chunk %d: section=%q page=%d, want %q p.%d", i, c.Section, c.Page, w.section, w.page)
+		}
+		if c.ID != "attention_20240101#"+string(rune('0'+i)) || c.ItemKey != "ABCD1234" || c.Title != "Attention Is All You Need" {
+			t.Errorf("chunk %d 元数据不正确: %+v", i, c)
+		}
+		if strings.Contains(c.Text, "![](") {
+			t.Errorf("图片引用不应进入文本块: %q", c.Text)
+		}
+	}
+	if got := chunks[1].Citation(); got != "《Attention Is All You Need》 §Model Architecture p.2" {
+		t.Errorf("Citation() = %q", got)
+	}
+}
+
+func TestChunkMarkdownSplitsLongParagraphs(t *testing.T) {
+	long := strings.Repeat("This sentence is about forty characters. ", 100)
+	chunks := chunkMarkdown("## Intro\n\n"+long, nil, ChunkOptions{TargetTokens: 100, MaxTokens: 120})
+	if len(chunks) < 5 {
+		t.Fatalf("长段落应被拆分, got %d 块", len(chunks))
+	}
+	for _, c := range chunks {
+		if c.Tokens > 120 || c.Section != "Intro" {
+			t.Errorf("块超出上限或章节错误: tokens=%d section=%q", c.Tokens, c.Section)
+		}
+	}
+}
+
+func TestKeywordRetriever(t *testing.T) {
+	chunks := []Chunk{
+		{ID: "a#0", Text: "Recurrent networks process tokens sequentially."},
+		{ID: "a#1", Text: "Self-attention relates all positions; attention weights are computed in parallel."},
+		{ID: "b#0", Text: "我们使用注意力机制替代循环结构。"},
+	}
+
+	results, err := KeywordRetriever{}.Retrieve(context.Background(), "how does attention work", chunks, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ID != "a#1" {
+		t.Errorf("英文检索结果 = %+v", results)
+	}
+
+	results, _ = KeywordRetriever{}.Retrieve(context.Background(), "注意力机制是什么", chunks, 2)
+	if len(results) == 0 || results[0].ID != "b#0" {
+		t.Errorf("中文二元组检索结果 = %+v", results)
+	}
+}
+
+func TestPackChunks(t *testing.T) {
+	chunks := []RetrievedChunk{
+		{Chunk: Chunk{ID: "1", Tokens: 300}},
+		{Chunk: Chunk{ID: "2", Tokens: 500}},
+		{Chunk: Chunk{ID: "3", Tokens: 100}},
+	}
+	packed := PackChunks(chunks, 450)
+	if len(packed) != 2 || packed[0].ID != "1" || packed[1].ID != "3" {
+		t.Errorf("PackChunks() = %+v", packed)
+	}
+}
+
+func TestRAGPipelineAnswer(t *testing.T) {
+	pipeline := NewRAGPipeline(writeRAGFixture(t))
+	client := &fakeAIClient{reply: "Transformer 使用自注意力 [1]"}
+
+	answer, err := pipeline.Answer(context.Background(), client, "What layers does the Transformer use?", RAGOptions{TopK: 2})
+	if err != nil {
+		t.Fatalf("Answer() error = %v", err)
+	}
+	if answer.Answer != client.reply || len(answer.Chunks) == 0 || answer.Chunks[0].Section != "Model Architecture" {
+		t.Errorf("Answer() = %+v", answer)
+	}
+
+	system := client.requests[0].Messages[0].Content
+	if !strings.Contains(system, "[1] 《Attention Is All You Need》 §Model Architecture p.2") {
+		t.Errorf("系统提示缺少带引用的片段:\n%s", system)
+	}
+
+	if _, err := pipeline.Answer(context.Background(), client, "quantum chromodynamics", RAGOptions{}); err == nil {
+		t.Error("无相关内容时应返回错误")
+	}
+}
+
+func TestConversationUsesRetrievedChunks(t *testing.T) {
+	client := &fakeAIClient{reply: "基于片段的回答"}
+	manager := NewAIConversationManager(client, nil)
+	manager.SetRAGPipeline(NewRAGPipeline(writeRAGFixture(t)))
+
+	conv, err := manager.StartConversation(context.Background(), "Transformer feed-forward layers", nil)
+	if err != nil {
+		t.Fatalf("StartConversation() error = %v", err)
+	}
+	last := conv.Messages[len(conv.Messages)-1]
+	if last.Metadata == nil || len(last.Metadata.Chunks) == 0 {
+		t.Fatalf("回复应记录使用的片段: %+v", last)
+	}
+
+	if _, err := manager.ContinueConversation(context.Background(), conv.ID, "注意力机制如何训练"); err != nil {
+		t.Fatalf("ContinueConversation() error = %v", err)
+	}
+	if conv.Context.Chunks[0].Section != "训练" || !strings.Contains(conv.Messages[0].Content, "§训练") {
+		t.Errorf("追问应重新检索片段: %+v", conv.Context.Chunks)
+	}
+}
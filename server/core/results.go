@@ -0,0 +1,146 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ParsedResult 已解析文献的结果目录
+type ParsedResult struct {
+	Name string          `json:"name"`
+	Dir  string          `json:"dir"`
+	Info *ParsedFileInfo `json:"info,omitempty"`
+}
+
+// FullTextPath full.md路径
+func (r *ParsedResult) FullTextPath() string {
+	return filepath.Join(r.Dir, "full.md")
+}
+
+// ReadFullText 读取解析得到的Markdown全文
+func (r *ParsedResult) ReadFullText() (string, error) {
+	data, err := os.ReadFile(r.FullTextPath())
+	if err != nil {
+		return "", fmt.Errorf("读取全文失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// Title 优先使用元数据中的标题
+func (r *ParsedResult) Title() string {
+	if r.Info != nil && r.Info.Title != "" {
+		return r.Info.Title
+	}
+	return r.Name
+}
+
+// ListParsedResults 列出结果目录下所有包含full.md的解析结果
+func ListParsedResults(resultsDir string) ([]ParsedResult, error) {
+	entries, err := os.ReadDir(resultsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取结果目录失败: %w", err)
+	}
+
+	var results []ParsedResult
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == "latest" {
+			continue
+		}
+		dir := filepath.Join(resultsDir, entry.Name())
+		if _, err := os.Stat(filepath.Join(dir, "full.md")); err != nil {
+			continue
+		}
+		results = append(results, ParsedResult{
+			Name: entry.Name(),
+			Dir:  dir,
+			Info: readMeta(filepath.Join(dir, "meta.json")),
+		})
+	}
+	return results, nil
+}
+
+// GetParsedResult 按目录名获取解析结果
+func GetParsedResult(resultsDir, name string) (*ParsedResult, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return nil, fmt.Errorf("无效的结果名称: %s", name)
+	}
+	dir := filepath.Join(resultsDir, name)
+	if _, err := os.Stat(filepath.Join(dir, "full.md")); err != nil {
+		return nil, fmt.Errorf("解析结果不存在: %s", name)
+	}
+	return &ParsedResult{Name: name, Dir: dir, Info: readMeta(filepath.Join(dir, "meta.json"))}, nil
+}
+
+// FindParsedResult 查找Zotero条目对应的解析结果，未解析时返回nil
+//
+// 优先匹配meta.json中记录的条目Key；旧结果没有Key时，按OrganizeResult的
+// 目录命名规则（PDF文件名前缀_日期）匹配，多个结果取最新的一个
+func FindParsedResult(resultsDir string, item *ZoteroItem) (*ParsedResult, error) {
+	results, err := ListParsedResults(resultsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	if item.ItemKey != "" {
+		for i := range results {
+			if results[i].Info != nil && results[i].Info.ItemKey == item.ItemKey {
+				return &results[i], nil
+			}
+		}
+	}
+
+	if item.PDFPath == "" {
+		return nil, nil
+	}
+	prefix := sanitizeFilename(extractTitle(item.PDFPath))
+	if prefix == "" {
+		return nil, nil
+	}
+
+	var candidates []ParsedResult
+	for _, result := range results {
+		// 已关联其他条目的结果不参与文件名匹配
+		if result.Info != nil && result.Info.ItemKey != "" && result.Info.ItemKey != item.ItemKey {
+			continue
+		}
+		if strings.HasPrefix(result.Name, prefix) {
+			candidates = append(candidates, result)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Name > candidates[j].Name })
+	return &candidates[0], nil
+}
+
+// LinkResultToItem 在解析结果的元数据中记录Zotero条目信息
+func LinkResultToItem(dir string, item *ZoteroItem) error {
+	metaFile := filepath.Join(dir, "meta.json")
+	info := readMeta(metaFile)
+	if info == nil {
+		info = &ParsedFileInfo{Path: dir}
+	}
+
+	info.ItemKey = item.ItemKey
+	if item.Title != "" {
+		info.Title = item.Title
+	}
+	if len(item.Authors) > 0 {
+		info.Authors = strings.Join(item.Authors, "; ")
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化元数据失败: %w", err)
+	}
+	return os.WriteFile(metaFile, data, 0644)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGetItemByKey(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+
+	item, err := db.GetItemByKey("ABCD1234")
+	if err != nil {
+		t.Fatalf("GetItemByKey() error = %v", err)
+	}
+	if item.Title != "Attention Is All You Need" || item.Year != 2017 || item.DOI != "10.5555/attention" {
+		t.Errorf("字段不正确: %+v", item)
+	}
+	if want := []string{"Ashish Vaswani", "Noam Shazeer"}; !reflect.DeepEqual(item.Authors, want) {
+		t.Errorf("Authors = %v, want %v", item.Authors, want)
+	}
+	if filepath.Base(item.PDFPath) != "attention.pdf" {
+		t.Errorf("PDFPath = %q", item.PDFPath)
+	}
+
+	if _, err := db.GetItemByKey("MISSING0"); err == nil {
+		t.Error("不存在的Key应返回错误")
+	}
+}
+
+func TestListCollections(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+
+	collections, err := db.ListCollections()
+	if err != nil {
+		t.Fatalf("ListCollections() error = %v", err)
+	}
+	if len(collections) != 2 || collections[0].Name != "Attention" || collections[0].ItemCount != 1 || collections[0].ParentID != 1 {
+		t.Errorf("ListCollections() = %+v", collections)
+	}
+}
+
+func TestCollectionItems(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+
+	items, err := db.CollectionItems("nlp", true)
+	if err != nil {
+		t.Fatalf("CollectionItems() error = %v", err)
+	}
+	if len(items) != 1 || items[0].ItemKey != "ABCD1234" || items[0].PDFPath == "" {
+		t.Errorf("包含子分类时 = %+v", items)
+	}
+	if items, _ := db.CollectionItems("COLL0001", false); len(items) != 0 {
+		t.Errorf("不含子分类时 = %+v", items)
+	}
+	if _, err := db.CollectionItems("missing", true); err == nil {
+		t.Error("不存在的分类应返回错误")
+	}
+}
+
+func TestTagItems(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+
+	items, err := db.TagItems("Transformer")
+	if err != nil {
+		t.Fatalf("TagItems() error = %v", err)
+	}
+	if len(items) != 1 || items[0].ItemKey != "ABCD1234" {
+		t.Errorf("TagItems() = %+v", items)
+	}
+	if items, _ := db.TagItems("missing"); len(items) != 0 {
+		t.Errorf("不存在的标签 = %+v", items)
+	}
+}
+
+func TestFindParsedResult(t *testing.T) {
+	resultsDir := t.TempDir()
+	writeResult := func(name string) string {
+		dir := filepath.Join(resultsDir, name)
+		os.MkdirAll(dir, 0755)
+		os.WriteFile(filepath.Join(dir, "full.md"), []byte("# "+name), 0644)
+		return dir
+	}
+	writeResult("attention_20240101")
+	newer := writeResult("attention_20240301")
+	writeResult("other_paper_20240101")
+
+	item := &ZoteroItem{ItemKey: "ABCD1234", Title: "Attention Is All You Need", PDFPath: "/storage/attention.pdf"}
+
+	// 旧结果按目录命名规则匹配，取最新的一个
+	parsed, err := FindParsedResult(resultsDir, item)
+	if err != nil || parsed == nil || parsed.Dir != newer {
+		t.Fatalf("FindParsedResult() = %+v, %v", parsed, err)
+	}
+
+	// 关联后按条目Key匹配，即使PDF文件名变化
+	older := filepath.Join(resultsDir, "attention_20240101")
+	if err := LinkResultToItem(older, item); err != nil {
+		t.Fatal(err)
+	}
+	parsed, _ = FindParsedResult(resultsDir, &ZoteroItem{ItemKey: "ABCD1234", PDFPath: "/storage/renamed.pdf"})
+	if parsed == nil || parsed.Dir != older || parsed.Title() != item.Title {
+		t.Errorf("按Key匹配失败: %+v", parsed)
+	}
+
+	if parsed, _ := FindParsedResult(resultsDir, &ZoteroItem{PDFPath: "/storage/unknown.pdf"}); parsed != nil {
+		t.Errorf("未解析文献应返回nil, got %+v", parsed)
+	}
+}
@@ -0,0 +1,612 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 文献综述参数
+const (
+	// DefaultReviewMaxPapers 单次综述纳入的最多文献数
+	DefaultReviewMaxPapers = 30
+	// reviewMaxClusters 自动分组时的最多主题数
+	reviewMaxClusters = 6
+	// reviewKMeansIterations k-means最大迭代次数
+	reviewKMeansIterations = 20
+	// reviewFieldRunes 提示中每篇文献各部分摘要的长度上限
+	reviewFieldRunes = 300
+)
+
+// 综述生成阶段，用于进度报告
+const (
+	ReviewStageSummarize  = "summarize"
+	ReviewStageCluster    = "cluster"
+	ReviewStageTheme      = "theme"
+	ReviewStageSynthesize = "synthesize"
+	ReviewStageDone       = "done"
+)
+
+// ReviewScope 综述的文献范围：分类、标签和全文查询三选一
+type ReviewScope struct {
+	Collection string `json:"collection,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Query      string `json:"query,omitempty"`
+}
+
+// Validate 检查是否恰好指定了一种范围
+func (s ReviewScope) Validate() error {
+	count := 0
+	for _, v := range []string{s.Collection, s.Tag, s.Query} {
+		if strings.TrimSpace(v) != "" {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("请在分类、标签和查询中指定一种文献范围")
+	}
+	return nil
+}
+
+// String 范围的可读描述
+func (s ReviewScope) String() string {
+	switch {
+	case s.Collection != "":
+		return "分类 " + s.Collection
+	case s.Tag != "":
+		return "标签 " + s.Tag
+	default:
+		return fmt.Sprintf("查询“%s”", s.Query)
+	}
+}
+
+// ResolveReviewScope 查找范围内已解析的文献，返回解析结果和因未解析而跳过的条目标题
+//
+// 分类和标签需要zoteroDB；查询先增量更新indexDir下的全文索引，再取命中的解析结果
+func ResolveReviewScope(scope ReviewScope, zoteroDB *ZoteroDB, resultsDir, indexDir string, limit int) ([]ParsedResult, []string, error) {
+	if err := scope.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultReviewMaxPapers
+	}
+
+	var items []ZoteroItem
+	var err error
+	switch {
+	case scope.Query != "":
+		return queryReviewResults(scope.Query, zoteroDB, resultsDir, indexDir, limit)
+	case zoteroDB == nil:
+		return nil, nil, fmt.Errorf("按分类或标签选取文献需要连接Zotero数据库")
+	case scope.Collection != "":
+		items, err = zoteroDB.CollectionItems(scope.Collection, true)
+	default:
+		items, err = zoteroDB.TagItems(scope.Tag)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results []ParsedResult
+	var skipped []string
+	for i := range items {
+		result, err := FindParsedResult(resultsDir, &items[i])
+		if err != nil || result == nil {
+			skipped = append(skipped, items[i].Title)
+			continue
+		}
+		if len(results) < limit {
+			results = append(results, *result)
+		}
+	}
+	return results, skipped, nil
+}
+
+// queryReviewResults 全文检索选取文献，Zotero全文缓存的命中按条目Key对应到解析结果
+func queryReviewResults(query string, zoteroDB *ZoteroDB, resultsDir, indexDir string, limit int) ([]ParsedResult, []string, error) {
+	index, err := OpenFullTextIndex(indexDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources, err := CollectFullTextSources(zoteroDB, resultsDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := index.Update(sources); err != nil {
+		return nil, nil, err
+	}
+	hits, err := index.Search(query, limit*2)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+	var results []ParsedResult
+	var skipped []string
+	for _, hit := range hits {
+		var result *ParsedResult
+		if hit.Kind == FullTextKindResult {
+			result, err = GetParsedResult(resultsDir, hit.Document)
+		} else if hit.ItemKey != "" {
+			result, err = FindParsedResult(resultsDir, &ZoteroItem{ItemKey: hit.ItemKey})
+		}
+		if err != nil || result == nil {
+			skipped = append(skipped, hit.Title)
+			continue
+		}
+		if seen[result.Name] || len(results) >= limit {
+			continue
+		}
+		seen[result.Name] = true
+		results = append(results, *result)
+	}
+	return results, skipped, nil
+}
+
+// ReviewPaper 综述纳入的一篇文献，Ref为参考文献编号
+type ReviewPaper struct {
+	Ref      int           `json:"ref"`
+	Document string        `json:"document"`
+	ItemKey  string        `json:"item_key,omitempty"`
+	Title    string        `json:"title"`
+	Authors  []string      `json:"authors,omitempty"`
+	Year     int           `json:"year,omitempty"`
+	DOI      string        `json:"doi,omitempty"`
+	Summary  *PaperSummary `json:"summary,omitempty"`
+
+	result ParsedResult
+}
+
+// NewReviewPapers 为解析结果补充书目信息，zoteroDB为nil或条目不存在时只使用解析结果的标题
+func NewReviewPapers(results []ParsedResult, zoteroDB *ZoteroDB) []ReviewPaper {
+	papers := make([]ReviewPaper, len(results))
+	for i, result := range results {
+		paper := ReviewPaper{Document: result.Name, Title: result.Title(), result: result}
+		if result.Info != nil && result.Info.ItemKey != "" {
+			paper.ItemKey = result.Info.ItemKey
+			if zoteroDB != nil {
+				if item, err := zoteroDB.GetItemByKey(paper.ItemKey); err == nil {
+					paper.Title = item.Title
+					paper.Authors = item.Authors
+					paper.Year = item.Year
+					paper.DOI = item.DOI
+				}
+			}
+		}
+		papers[i] = paper
+	}
+	return papers
+}
+
+// Reference 参考文献条目：作者 (年份). 标题. DOI
+func (p *ReviewPaper) Reference() string {
+	var sb strings.Builder
+	if len(p.Authors) > 0 {
+		authors := p.Authors
+		if len(authors) > 3 {
+			authors = authors[:3]
+		}
+		sb.WriteString(strings.Join(authors, ", "))
+		if len(p.Authors) > 3 {
+			sb.WriteString(" 等")
+		}
+		sb.WriteString(" ")
+	}
+	if p.Year > 0 {
+		sb.WriteString(fmt.Sprintf("(%d). ", p.Year))
+	}
+	sb.WriteString(p.Title + ".")
+	if p.DOI != "" {
+		sb.WriteString(" https://doi.org/" + p.DOI)
+	}
+	return sb.String()
+}
+
+// ReviewCluster 一个主题分组
+type ReviewCluster struct {
+	Theme   string `json:"theme"`
+	Summary string `json:"summary"` // 以[编号]引用参考文献
+	Refs    []int  `json:"refs"`
+}
+
+// LiteratureReview 结构化的文献综述
+type LiteratureReview struct {
+	Title          string          `json:"title"`
+	Scope          string          `json:"scope"`
+	Overview       string          `json:"overview"`
+	Clusters       []ReviewCluster `json:"clusters"`
+	Agreements     []string        `json:"agreements"`
+	Contradictions []string        `json:"contradictions"`
+	Gaps           []string        `json:"gaps"`
+	Papers         []ReviewPaper   `json:"papers"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ReviewOptions 综述生成选项
+type ReviewOptions struct {
+	Title    string `json:"title,omitempty"`
+	Clusters int    `json:"clusters,omitempty"` // 主题数，0表示按文献数自动确定
+	Force    bool   `json:"force,omitempty"`    // 忽略缓存重新生成单篇摘要
+}
+
+// ReviewProgress 综述生成进度
+type ReviewProgress struct {
+	Stage   string `json:"stage"`
+	Done    int    `json:"done"`
+	Total   int    `json:"total"`
+	Message string `json:"message"`
+}
+
+// ReviewGenerator 多文献综述生成器：单篇摘要 → 按主题聚类 → 主题小结 → 综合分析
+type ReviewGenerator struct {
+	client     AIClient
+	summarizer *Summarizer
+	embedder   EmbeddingProvider
+}
+
+// NewReviewGenerator 创建综述生成器，embedder为nil时使用本地哈希嵌入聚类
+func NewReviewGenerator(client AIClient, summarizer *Summarizer, embedder EmbeddingProvider) *ReviewGenerator {
+	if embedder == nil {
+		embedder = NewLocalEmbeddingProvider(0)
+	}
+	return &ReviewGenerator{client: client, summarizer: summarizer, embedder: embedder}
+}
+
+// Generate 生成综述；progress可为nil。摘要失败的文献不纳入综述，参考文献按纳入顺序编号
+func (g *ReviewGenerator) Generate(ctx context.Context, scope string, papers []ReviewPaper, opts ReviewOptions, progress func(ReviewProgress)) (*LiteratureReview, error) {
+	ctx = WithUsageFeature(ctx, FeatureReview)
+	report := func(stage string, done, total int, message string) {
+		if progress != nil {
+			progress(ReviewProgress{Stage: stage, Done: done, Total: total, Message: message})
+		}
+	}
+	if len(papers) == 0 {
+		return nil, fmt.Errorf("没有可用于综述的已解析文献")
+	}
+
+	// 1. 单篇摘要（使用summary.json缓存）
+	var included []ReviewPaper
+	for i := range papers {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		paper := papers[i]
+		summary, err := g.summarizer.Summarize(ctx, &paper.result, opts.Force)
+		if err != nil {
+			log.Printf("⚠️ 摘要失败，不纳入综述 %s: %v", paper.Document, err)
+			report(ReviewStageSummarize, i+1, len(papers), "摘要失败: "+paper.Title)
+			continue
+		}
+		paper.Summary = summary
+		paper.Ref = len(included) + 1
+		included = append(included, paper)
+		report(ReviewStageSummarize, i+1, len(papers), paper.Title)
+	}
+	if len(included) == 0 {
+		return nil, fmt.Errorf("所有文献摘要均失败")
+	}
+
+	// 2. 按摘要聚类
+	report(ReviewStageCluster, 0, 1, "按主题聚类")
+	groups, err := g.cluster(ctx, included, opts.Clusters)
+	if err != nil {
+		return nil, err
+	}
+	report(ReviewStageCluster, 1, 1, fmt.Sprintf("分为 %d 个主题", len(groups)))
+
+	// 3. 主题小结
+	review := &LiteratureReview{Title: opts.Title, Scope: scope, Papers: included, CreatedAt: time.Now()}
+	for i, group := range groups {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		cluster := g.summarizeCluster(ctx, included, group, i+1)
+		review.Clusters = append(review.Clusters, cluster)
+		report(ReviewStageTheme, i+1, len(groups), cluster.Theme)
+	}
+
+	// 4. 共识、分歧和研究空白
+	report(ReviewStageSynthesize, 0, 1, "综合分析")
+	if err := g.synthesize(ctx, review); err != nil {
+		return nil, err
+	}
+	report(ReviewStageDone, 1, 1, review.Title)
+	return review, nil
+}
+
+// reviewPaperText 用于聚类的文献文本
+func reviewPaperText(p *ReviewPaper) string {
+	s := p.Summary
+	return strings.Join(append([]string{p.Title, s.TLDR, s.Methods, s.Results}, s.KeyPoints...), "\n")
+}
+
+// cluster 嵌入各篇摘要并分组，嵌入服务失败时退回本地哈希嵌入
+func (g *ReviewGenerator) cluster(ctx context.Context, papers []ReviewPaper, k int) ([][]int, error) {
+	texts := make([]string, len(papers))
+	for i := range papers {
+		texts[i] = reviewPaperText(&papers[i])
+	}
+	vectors, err := g.embedder.Embed(ctx, texts)
+	if err != nil || len(vectors) != len(texts) {
+		log.Printf("⚠️ 嵌入失败，使用本地嵌入聚类: %v", err)
+		if vectors, err = NewLocalEmbeddingProvider(0).Embed(ctx, texts); err != nil {
+			return nil, err
+		}
+	}
+	if k <= 0 {
+		k = autoClusterCount(len(papers))
+	}
+	return clusterVectors(vectors, k), nil
+}
+
+// autoClusterCount 按文献数确定主题数：约为sqrt(n/2)，少于4篇时不分组
+func autoClusterCount(n int) int {
+	if n < 4 {
+		return 1
+	}
+	k := int(math.Round(math.Sqrt(float64(n) / 2)))
+	return max(2, min(k, reviewMaxClusters))
+}
+
+// clusterVectors 以余弦相似度做k-means分组，最远点初始化使结果可复现；返回按组大小降序的下标分组
+func clusterVectors(vectors [][]float32, k int) [][]int {
+	n := len(vectors)
+	k = max(1, min(k, n))
+
+	// 最远点初始化：每次选取与已有中心最不相似的向量
+	centers := [][]float32{vectors[0]}
+	for len(centers) < k {
+		best, bestSim := -1, math.Inf(1)
+		for i, v := range vectors {
+			nearest := math.Inf(-1)
+			for _, c := range centers {
+				nearest = math.Max(nearest, cosineSimilarity(v, c))
+			}
+			if nearest < bestSim {
+				best, bestSim = i, nearest
+			}
+		}
+		centers = append(centers, vectors[best])
+	}
+
+	assign := make([]int, n)
+	for iter := 0; iter < reviewKMeansIterations; iter++ {
+		changed := iter == 0
+		for i, v := range vectors {
+			nearest, bestSim := 0, math.Inf(-1)
+			for c, center := range centers {
+				if sim := cosineSimilarity(v, center); sim > bestSim {
+					nearest, bestSim = c, sim
+				}
+			}
+			if assign[i] != nearest {
+				assign[i] = nearest
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		for c := range centers {
+			sum := make([]float32, len(vectors[0]))
+			for i, v := range vectors {
+				if assign[i] != c {
+					continue
+				}
+				for d := range v {
+					sum[d] += v[d]
+				}
+			}
+			centers[c] = normalizeVector(sum)
+		}
+	}
+
+	groups := make([][]int, k)
+	for i, c := range assign {
+		groups[c] = append(groups[c], i)
+	}
+	var result [][]int
+	for _, group := range groups {
+		if len(group) > 0 {
+			result = append(result, group)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// formatReviewPaper 提示中的单篇文献材料
+func formatReviewPaper(p *ReviewPaper) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("[%d] %s", p.Ref, p.Title))
+	if p.Year > 0 {
+		sb.WriteString(fmt.Sprintf(" (%d)", p.Year))
+	}
+	sb.WriteString("\n")
+	s := p.Summary
+	for _, field := range []struct{ label, text string }{
+		{"TL;DR", s.TLDR},
+		{"方法", s.Methods},
+		{"结果", s.Results},
+		{"局限", s.Limitations},
+	} {
+		if field.text != "" {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", field.label, truncateRunes(field.text, reviewFieldRunes)))
+		}
+	}
+	return sb.String()
+}
+
+// summarizeCluster 生成主题名称和小结，AI失败时用各篇TL;DR拼接
+func (g *ReviewGenerator) summarizeCluster(ctx context.Context, papers []ReviewPaper, group []int, n int) ReviewCluster {
+	cluster := ReviewCluster{}
+	allowed := make(map[int]bool)
+	var material strings.Builder
+	for _, i := range group {
+		paper := &papers[i]
+		cluster.Refs = append(cluster.Refs, paper.Ref)
+		allowed[paper.Ref] = true
+		material.WriteString(formatReviewPaper(paper) + "\n")
+	}
+
+	prompt := fmt.Sprintf(`以下文献被归为同一主题：
+
+%s
+请为这组文献起一个简短的主题名称，并写一段200-400字的主题小结：概括共同的研究问题、主要方法路线和代表性结论，比较各篇的异同。
+每个论断后用[编号]标注所依据的文献，只能引用上面列出的编号。
+只输出JSON：{"theme": "主题名称", "summary": "小结"}`, material.String())
+
+	var parsed struct {
+		Theme   string `json:"theme"`
+		Summary string `json:"summary"`
+	}
+	reply, err := completePrompt(ctx, g.client, prompt, 1200)
+	if err == nil {
+		err = decodeJSONReply(reply, &parsed)
+	}
+	if err != nil || parsed.Theme == "" || parsed.Summary == "" {
+		log.Printf("⚠️ 主题小结生成失败，使用文献摘要拼接: %v", err)
+		cluster.Theme = fmt.Sprintf("主题 %d", n)
+		var sb strings.Builder
+		for _, i := range group {
+			sb.WriteString(fmt.Sprintf("%s [%d] ", papers[i].Summary.TLDR, papers[i].Ref))
+		}
+		cluster.Summary = strings.TrimSpace(sb.String())
+		return cluster
+	}
+	cluster.Theme = strings.TrimSpace(parsed.Theme)
+	cluster.Summary = filterCitations(strings.TrimSpace(parsed.Summary), allowed)
+	return cluster
+}
+
+// synthesize 基于主题小结和各篇结论归纳共识、分歧和研究空白
+func (g *ReviewGenerator) synthesize(ctx context.Context, review *LiteratureReview) error {
+	allowed := make(map[int]bool)
+	var material strings.Builder
+	for i, cluster := range review.Clusters {
+		material.WriteString(fmt.Sprintf("### 主题%d：%s\n%s\n\n", i+1, cluster.Theme, cluster.Summary))
+	}
+	material.WriteString("### 各篇文献\n")
+	for i := range review.Papers {
+		allowed[review.Papers[i].Ref] = true
+		material.WriteString(formatReviewPaper(&review.Papers[i]) + "\n")
+	}
+
+	prompt := fmt.Sprintf(`以下是%s的 %d 篇文献按主题整理的材料：
+
+%s
+请撰写文献综述的综合部分：
+- title: 综述标题
+- overview: 300字以内的总体概述，说明研究现状和各主题之间的关系
+- agreements: 多篇文献的共识（每条一句）
+- contradictions: 文献之间的分歧或相互矛盾的结论（每条说明各方观点）
+- gaps: 尚未解决的问题和研究空白（结合各篇局限性）
+每条陈述后用[编号]标注依据的文献，只能引用上面列出的编号；没有可写内容的项返回空数组。
+只输出JSON：{"title": "", "overview": "", "agreements": [], "contradictions": [], "gaps": []}`,
+		review.Scope, len(review.Papers), material.String())
+
+	reply, err := completePrompt(ctx, g.client, prompt, 2000)
+	if err != nil {
+		return fmt.Errorf("综述综合分析失败: %w", err)
+	}
+	var parsed struct {
+		Title          string   `json:"title"`
+		Overview       string   `json:"overview"`
+		Agreements     []string `json:"agreements"`
+		Contradictions []string `json:"contradictions"`
+		Gaps           []string `json:"gaps"`
+	}
+	if err := decodeJSONReply(reply, &parsed); err != nil {
+		return fmt.Errorf("解析综述综合分析失败: %w", err)
+	}
+
+	if review.Title == "" {
+		review.Title = strings.TrimSpace(parsed.Title)
+	}
+	if review.Title == "" {
+		review.Title = review.Scope + " 文献综述"
+	}
+	review.Overview = filterCitations(strings.TrimSpace(parsed.Overview), allowed)
+	clean := func(items []string) []string {
+		result := []string{}
+		for _, item := range items {
+			if item = filterCitations(strings.TrimSpace(item), allowed); item != "" {
+				result = append(result, item)
+			}
+		}
+		return result
+	}
+	review.Agreements = clean(parsed.Agreements)
+	review.Contradictions = clean(parsed.Contradictions)
+	review.Gaps = clean(parsed.Gaps)
+	return nil
+}
+
+// citationRun 连续的引用标记（如 [1][2]、[1, 2]）连同前导空白
+var citationRun = regexp.MustCompile(`\s*(?:\[\d+(?:\s*[,，]\s*\d+)*\])+`)
+
+// filterCitations 去除文本中不在allowed内的引用编号，连续标记合并为一个，全部无效时连同前导空白删除
+func filterCitations(text string, allowed map[int]bool) string {
+	return citationRun.ReplaceAllStringFunc(text, func(marker string) string {
+		var kept []string
+		for _, n := range CitedNumbers(marker) {
+			if allowed[n] {
+				kept = append(kept, strconv.Itoa(n))
+			}
+		}
+		if len(kept) == 0 {
+			return ""
+		}
+		prefix := marker[:len(marker)-len(strings.TrimLeft(marker, " \t\n"))]
+		return prefix + "[" + strings.Join(kept, ", ") + "]"
+	})
+}
+
+// Markdown 渲染综述：概述、主题分析、共识、分歧、研究空白和参考文献
+func (r *LiteratureReview) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", r.Title))
+	sb.WriteString(fmt.Sprintf("> 范围：%s · %d 篇文献 · 生成于 %s\n", r.Scope, len(r.Papers), r.CreatedAt.Format("2006-01-02 15:04")))
+	if r.Overview != "" {
+		sb.WriteString("\n## 概述\n\n" + r.Overview + "\n")
+	}
+
+	sb.WriteString("\n## 主题分析\n")
+	for i, cluster := range r.Clusters {
+		refs := make([]string, len(cluster.Refs))
+		for j, ref := range cluster.Refs {
+			refs[j] = fmt.Sprintf("[%d]", ref)
+		}
+		sb.WriteString(fmt.Sprintf("\n### %d. %s\n\n%s\n\n*涉及文献：%s*\n", i+1, cluster.Theme, cluster.Summary, strings.Join(refs, " ")))
+	}
+
+	for _, section := range []struct {
+		heading string
+		items   []string
+	}{
+		{"共识", r.Agreements},
+		{"分歧", r.Contradictions},
+		{"研究空白", r.Gaps},
+	} {
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n", section.heading))
+		if len(section.items) == 0 {
+			sb.WriteString("（未发现）\n")
+			continue
+		}
+		for _, item := range section.items {
+			sb.WriteString("- " + item + "\n")
+		}
+	}
+
+	sb.WriteString("\n## 参考文献\n\n")
+	for i := range r.Papers {
+		sb.WriteString(fmt.Sprintf("[%d] %s\n", r.Papers[i].Ref, r.Papers[i].Reference()))
+	}
+	return sb.String()
+}
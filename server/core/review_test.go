@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// writeReviewFixture 创建两组主题各两篇的解析结果
+func writeReviewFixture(t *testing.T) string {
+	t.Helper()
+	resultsDir := t.TempDir()
+	papers := []struct{ name, title, text string }{
+		{"a_attention", "Attention Is All You Need", "Transformer self attention for machine translation."},
+		{"b_gnn_molecule", "Graph Networks for Molecules", "Graph neural network message passing predicts molecule properties."},
+		{"c_bert", "BERT Pretraining", "Transformer self attention pretraining improves translation and language understanding."},
+		{"d_gnn_chem", "Neural Message Passing for Chemistry", "Graph neural network message passing on molecule graphs."},
+	}
+	for _, p := range papers {
+		writeParsedResult(t, resultsDir, p.name, p.title, "# "+p.title+"\n\n"+p.text+"\n")
+	}
+	return resultsDir
+}
+
+// reviewReply 模拟综述各阶段的模型回复
+func reviewReply(prompt string) string {
+	switch {
+	case strings.Contains(prompt, `"agreements"`):
+		return `{"title": "注意力与图网络综述", "overview": "两条路线并行发展 [1][99]。",
+			"agreements": ["消息传递有效 [2, 4]"], "contradictions": [], "gaps": ["缺少统一基准 [42]"]}`
+	case strings.Contains(prompt, `"theme"`):
+		if strings.Contains(prompt, "Graph") {
+			return `{"theme": "图神经网络", "summary": "消息传递用于分子性质预测 [2][4]，与注意力无关 [1]。"}`
+		}
+		return `{"theme": "自注意力", "summary": "Transformer取代循环结构 [1, 3]。"}`
+	case strings.Contains(prompt, `"tldr"`):
+		if strings.Contains(prompt, "Graph") {
+			return `{"tldr": "Graph neural network message passing for molecule", "methods": "message passing graph", "results": "molecule properties", "key_points": ["graph", "molecule"]}`
+		}
+		return `{"tldr": "Transformer self attention translation", "methods": "self attention transformer", "results": "translation quality", "key_points": ["attention", "transformer"]}`
+	}
+	return "笔记"
+}
+
+func TestReviewGenerator(t *testing.T) {
+	resultsDir := writeReviewFixture(t)
+	results, err := ListParsedResults(resultsDir)
+	if err != nil || len(results) != 4 {
+		t.Fatalf("ListParsedResults() = %d, %v", len(results), err)
+	}
+	client := &scriptedAIClient{respond: reviewReply}
+	generator := NewReviewGenerator(client, NewSummarizer(client, ""), nil)
+
+	var stages []string
+	review, err := generator.Generate(context.Background(), "标签 test", NewReviewPapers(results, nil), ReviewOptions{}, func(p ReviewProgress) {
+		stages = append(stages, p.Stage)
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	if len(review.Clusters) != 2 {
+		t.Fatalf("应分为2个主题, got %+v", review.Clusters)
+	}
+	for _, cluster := range review.Clusters {
+		if len(cluster.Refs) != 2 {
+			t.Errorf("主题 %s 应包含2篇文献: %v", cluster.Theme, cluster.Refs)
+		}
+		if cluster.Theme == "图神经网络" && strings.Contains(cluster.Summary, "[1]") {
+			t.Errorf("主题小结不应引用组外文献: %q", cluster.Summary)
+		}
+	}
+	if review.Title != "注意力与图网络综述" || review.Overview != "两条路线并行发展 [1]。" {
+		t.Errorf("无效引用应被去除: title=%q overview=%q", review.Title, review.Overview)
+	}
+	if len(review.Agreements) != 1 || len(review.Contradictions) != 0 || review.Gaps[0] != "缺少统一基准" {
+		t.Errorf("综合分析 = %+v / %+v / %+v", review.Agreements, review.Contradictions, review.Gaps)
+	}
+	if stages[0] != ReviewStageSummarize || stages[len(stages)-1] != ReviewStageDone {
+		t.Errorf("进度阶段 = %v", stages)
+	}
+
+	md := review.Markdown()
+	for _, want := range []string{"# 注意力与图网络综述", "## 主题分析", "## 分歧\n\n（未发现）", "## 参考文献", "[4] Neural Message Passing for Chemistry."} {
+		if !strings.Contains(md, want) {
+			t.Errorf("Markdown() 缺少 %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestClusterVectors(t *testing.T) {
+	vectors := [][]float32{{1, 0}, {0, 1}, {0.9, 0.1}, {0.1, 0.9}, {0.95, 0.05}}
+	groups := clusterVectors(vectors, 2)
+	if len(groups) != 2 || fmt.Sprint(groups[0]) != "[0 2 4]" || fmt.Sprint(groups[1]) != "[1 3]" {
+		t.Errorf("clusterVectors() = %v", groups)
+	}
+	if groups := clusterVectors(vectors, 10); len(groups) != 5 {
+		t.Errorf("k超过向量数时每个向量一组, got %v", groups)
+	}
+
+	for n, want := range map[int]int{1: 1, 3: 1, 4: 2, 18: 3, 200: reviewMaxClusters} {
+		if got := autoClusterCount(n); got != want {
+			t.Errorf("autoClusterCount(%d) = %d, want %d", n, got, want)
+		}
+	}
+}
+
+func TestResolveReviewScope(t *testing.T) {
+	db := newFixtureZoteroDB(t)
+	resultsDir := writeRAGFixture(t)
+
+	for _, scope := range []ReviewScope{{Tag: "transformer"}, {Collection: "NLP"}, {Query: "Transformer"}} {
+		results, _, err := ResolveReviewScope(scope, db, resultsDir, t.TempDir(), 0)
+		if err != nil {
+			t.Fatalf("ResolveReviewScope(%s) error = %v", scope, err)
+		}
+		if len(results) != 1 || results[0].Name != "attention_20240101" {
+			t.Errorf("ResolveReviewScope(%s) = %+v", scope, results)
+		}
+	}
+
+	if _, _, err := ResolveReviewScope(ReviewScope{Tag: "a", Query: "b"}, db, resultsDir, t.TempDir(), 0); err == nil {
+		t.Error("同时指定多种范围应返回错误")
+	}
+	if _, _, err := ResolveReviewScope(ReviewScope{Tag: "transformer"}, nil, resultsDir, t.TempDir(), 0); err == nil {
+		t.Error("按标签选取时缺少Zotero数据库应返回错误")
+	}
+
+	papers := NewReviewPapers([]ParsedResult{{Name: "attention_20240101", Info: &ParsedFileInfo{ItemKey: "ABCD1234"}}}, db)
+	if got := papers[0].Reference(); got != "Ashish Vaswani, Noam Shazeer (2017). Attention Is All You Need. https://doi.org/10.5555/attention" {
+		t.Errorf("Reference() = %q", got)
+	}
+}
+
+func TestFilterCitations(t *testing.T) {
+	allowed := map[int]bool{1: true, 3: true}
+	tests := map[string]string{
+		"结论 [1][2]。":   "结论 [1]。",
+		"结论 [2][3]。":   "结论 [3]。",
+		"结论 [1][3]。":   "结论 [1, 3]。",
+		"结论 [2, 3]。":   "结论 [3]。",
+		"结论 [5]。":      "结论。",
+		"没有引用":         "没有引用",
+		"多处 [1] 和 [3]": "多处 [1] 和 [3]",
+	}
+	for input, want := range tests {
+		if got := filterCitations(input, allowed); got != want {
+			t.Errorf("filterCitations(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 摘要流水线参数
+const (
+	// DefaultSummaryCacheDir 按内容哈希缓存摘要的目录
+	DefaultSummaryCacheDir = "data/cache/summaries"
+	// summaryPromptVersion 提示词变化时递增，使旧缓存失效
+	summaryPromptVersion = 1
+	// summaryMapTokens map阶段每批文本的token上限；全文不超过此大小时直接生成摘要
+	summaryMapTokens = 3000
+	// summaryReduceTokens reduce阶段输入的token上限，超出时先合并笔记
+	summaryReduceTokens = 8000
+	// summaryMaxCollapse 合并笔记的最大轮数
+	summaryMaxCollapse = 3
+)
+
+// PaperSummary 文献的结构化摘要
+type PaperSummary struct {
+	Document    string    `json:"document"`
+	ItemKey     string    `json:"item_key,omitempty"`
+	Title       string    `json:"title"`
+	TLDR        string    `json:"tldr"`
+	Background  string    `json:"background"`
+	Methods     string    `json:"methods"`
+	Results     string    `json:"results"`
+	Limitations string    `json:"limitations"`
+	KeyPoints   []string  `json:"key_points"`
+	ContentHash string    `json:"content_hash"`
+	Version     int       `json:"version"`
+	Sections    int       `json:"sections"` // map阶段处理的文本批次数
+	CreatedAt   time.Time `json:"created_at"`
+
+	Cached bool `json:"-"` // 本次是否命中缓存
+}
+
+// Summarizer 对解析结果做map-reduce摘要：分批提炼笔记，再汇总为结构化摘要
+type Summarizer struct {
+	client   AIClient
+	cacheDir string
+}
+
+// NewSummarizer 创建摘要器，cacheDir为空时只使用结果目录中的summary.json作为缓存
+func NewSummarizer(client AIClient, cacheDir string) *Summarizer {
+	return &Summarizer{client: client, cacheDir: cacheDir}
+}
+
+// summaryContentHash 全文和提示词版本的哈希
+func summaryContentHash(content string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("v%d\n%s", summaryPromptVersion, content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadPaperSummary 读取结果目录中的summary.json，不存在时返回nil
+func LoadPaperSummary(dir string) (*PaperSummary, error) {
+	data, err := os.ReadFile(filepath.Join(dir, "summary.json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取摘要失败: %w", err)
+	}
+	var summary PaperSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("解析摘要失败: %w", err)
+	}
+	return &summary, nil
+}
+
+// Summarize 生成解析结果的摘要并写入summary.md和summary.json
+//
+// 全文内容未变化时直接返回缓存，force为true时重新生成
+func (s *Summarizer) Summarize(ctx context.Context, result *ParsedResult, force bool) (*PaperSummary, error) {
+	ctx = WithUsageFeature(ctx, FeatureSummarize)
+	content, err := result.ReadFullText()
+	if err != nil {
+		return nil, err
+	}
+	hash := summaryContentHash(content)
+
+	if !force {
+		if cached := s.cached(result, hash); cached != nil {
+			cached.Cached = true
+			return cached, nil
+		}
+	}
+	if s.client == nil {
+		return nil, fmt.Errorf("AI客户端未配置，无法生成摘要")
+	}
+
+	chunks, err := ChunkParsedResult(result, ChunkOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("文献 %s 没有可摘要的内容", result.Name)
+	}
+
+	log.Printf("📝 开始摘要: %s（%d 个文本块）", result.Title(), len(chunks))
+	batches := batchChunks(chunks, summaryMapTokens)
+	var material string
+	if len(batches) == 1 {
+		material = formatSummaryBatch(batches[0])
+	} else {
+		notes, err := s.mapNotes(ctx, result.Title(), batches)
+		if err != nil {
+			return nil, err
+		}
+		material, err = s.collapseNotes(ctx, result.Title(), notes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	summary, err := s.reduce(ctx, result.Title(), material)
+	if err != nil {
+		return nil, err
+	}
+	summary.Document = result.Name
+	summary.Title = result.Title()
+	if result.Info != nil {
+		summary.ItemKey = result.Info.ItemKey
+	}
+	summary.ContentHash = hash
+	summary.Version = summaryPromptVersion
+	summary.Sections = len(batches)
+	summary.CreatedAt = time.Now()
+
+	if err := s.save(result, summary); err != nil {
+		return nil, err
+	}
+	log.Printf("✅ 摘要完成: %s", result.Title())
+	return summary, nil
+}
+
+// cached 查找与全文哈希一致的已有摘要：先看结果目录，再看缓存目录
+func (s *Summarizer) cached(result *ParsedResult, hash string) *PaperSummary {
+	if summary, err := LoadPaperSummary(result.Dir); err == nil && summary != nil && summary.ContentHash == hash {
+		return summary
+	}
+	if s.cacheDir == "" {
+		return nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.cacheDir, hash+".json"))
+	if err != nil {
+		return nil
+	}
+	var summary PaperSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil
+	}
+	// 同一内容可能换了目录名（重新解析），以当前结果为准并补写结果目录
+	summary.Document = result.Name
+	summary.Title = result.Title()
+	if err := s.save(result, &summary); err != nil {
+		log.Printf("⚠️ 写入摘要失败: %v", err)
+	}
+	return &summary
+}
+
+// save 写入结果目录的summary.json、summary.md和哈希缓存
+func (s *Summarizer) save(result *ParsedResult, summary *PaperSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化摘要失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(result.Dir, "summary.json"), data, 0644); err != nil {
+		return fmt.Errorf("写入summary.json失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(result.Dir, "summary.md"), []byte(summary.Markdown()), 0644); err != nil {
+		return fmt.Errorf("写入summary.md失败: %w", err)
+	}
+	if s.cacheDir != "" {
+		if err := os.MkdirAll(s.cacheDir, 0755); err != nil {
+			return fmt.Errorf("创建摘要缓存目录失败: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(s.cacheDir, summary.ContentHash+".json"), data, 0644); err != nil {
+			return fmt.Errorf("写入摘要缓存失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// batchChunks 将连续的文本块按token上限分批
+func batchChunks(chunks []Chunk, maxTokens int) [][]Chunk {
+	var batches [][]Chunk
+	var current []Chunk
+	used := 0
+	for _, chunk := range chunks {
+		tokens := chunk.Tokens
+		if tokens == 0 {
+			tokens = EstimateTokens(chunk.Text)
+		}
+		if len(current) > 0 && used+tokens > maxTokens {
+			batches = append(batches, current)
+			current, used = nil, 0
+		}
+		current = append(current, chunk)
+		used += tokens
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// formatSummaryBatch 按章节拼接一批文本块
+func formatSummaryBatch(batch []Chunk) string {
+	var sb strings.Builder
+	section := ""
+	for _, chunk := range batch {
+		if chunk.Section != section && chunk.Section != "" {
+			section = chunk.Section
+			sb.WriteString("\n## " + section + "\n\n")
+		}
+		sb.WriteString(chunk.Text + "\n\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// mapNotes map阶段：逐批提炼要点笔记
+func (s *Summarizer) mapNotes(ctx context.Context, title string, batches [][]Chunk) ([]string, error) {
+	notes := make([]string, 0, len(batches))
+	for i, batch := range batches {
+		prompt := fmt.Sprintf(`下面是论文《%s》的第 %d/%d 部分。请用中文列出这部分的要点笔记，涵盖研究背景与动机、方法细节、实验设置、数据和结果数字、作者承认的局限。只记录原文中的信息，不超过300字。
+
+%s`, title, i+1, len(batches), formatSummaryBatch(batch))
+		note, err := s.complete(ctx, prompt, 800)
+		if err != nil {
+			return nil, fmt.Errorf("摘要第 %d/%d 部分失败: %w", i+1, len(batches), err)
+		}
+		notes = append(notes, fmt.Sprintf("### 第%d部分\n%s", i+1, note))
+	}
+	return notes, nil
+}
+
+// collapseNotes 笔记总量超出reduce上限时分组合并，最多合并summaryMaxCollapse轮
+func (s *Summarizer) collapseNotes(ctx context.Context, title string, notes []string) (string, error) {
+	for round := 0; round < summaryMaxCollapse && EstimateTokens(strings.Join(notes, "\n\n")) > summaryReduceTokens; round++ {
+		var groups [][]string
+		var current []string
+		used := 0
+		for _, note := range notes {
+			tokens := EstimateTokens(note)
+			if len(current) > 0 && used+tokens > summaryReduceTokens/2 {
+				groups = append(groups, current)
+				current, used = nil, 0
+			}
+			current = append(current, note)
+			used += tokens
+		}
+		groups = append(groups, current)
+
+		merged := make([]string, 0, len(groups))
+		for i, group := range groups {
+			prompt := fmt.Sprintf("请将下面关于论文《%s》的多段笔记合并为一份不超过400字的中文笔记，保留具体的方法、数据和结果数字：\n\n%s", title, strings.Join(group, "\n\n"))
+			note, err := s.complete(ctx, prompt, 1000)
+			if err != nil {
+				return "", fmt.Errorf("合并笔记失败: %w", err)
+			}
+			merged = append(merged, fmt.Sprintf("### 笔记%d\n%s", i+1, note))
+		}
+		notes = merged
+	}
+	return strings.Join(notes, "\n\n"), nil
+}
+
+// summaryJSON reduce阶段要求模型返回的结构
+type summaryJSON struct {
+	TLDR        string   `json:"tldr"`
+	Background  string   `json:"background"`
+	Methods     string   `json:"methods"`
+	Results     string   `json:"results"`
+	Limitations string   `json:"limitations"`
+	KeyPoints   []string `json:"key_points"`
+}
+
+// reduce 汇总阶段：生成TL;DR、结构化摘要和关键要点
+func (s *Summarizer) reduce(ctx context.Context, title, material string) (*PaperSummary, error) {
+	prompt := fmt.Sprintf(`请根据下面论文《%s》的内容生成中文结构化摘要，只输出JSON，不要输出其他文字：
+{
+  "tldr": "一句话概括论文做了什么、得到什么结论（不超过60字）",
+  "background": "研究背景与要解决的问题",
+  "methods": "方法与实验设计",
+  "results": "主要结果（保留关键数字）",
+  "limitations": "局限性；原文未提及时写\"原文未明确说明\"",
+  "key_points": ["3到6条关键要点"]
+}
+
+内容：
+%s`, title, material)
+
+	reply, err := s.complete(ctx, prompt, 1500)
+	if err != nil {
+		return nil, fmt.Errorf("生成摘要失败: %w", err)
+	}
+	var parsed summaryJSON
+	if err := decodeJSONReply(reply, &parsed); err != nil {
+		return nil, fmt.Errorf("解析摘要结果失败: %w", err)
+	}
+	if parsed.TLDR == "" {
+		return nil, fmt.Errorf("摘要结果缺少tldr字段")
+	}
+	return &PaperSummary{
+		TLDR:        parsed.TLDR,
+		Background:  parsed.Background,
+		Methods:     parsed.Methods,
+		Results:     parsed.Results,
+		Limitations: parsed.Limitations,
+		KeyPoints:   parsed.KeyPoints,
+	}, nil
+}
+
+// complete 发送单轮请求并返回回复文本
+func (s *Summarizer) complete(ctx context.Context, prompt string, maxTokens int) (string, error) {
+	return completePrompt(ctx, s.client, prompt, maxTokens)
+}
+
+// completePrompt 发送单轮低温度请求，返回去除首尾空白的回复文本
+func completePrompt(ctx context.Context, client AIClient, prompt string, maxTokens int) (string, error) {
+	resp, err := client.Chat(ctx, &AIRequest{
+		Messages:    []ChatMessage{{Role: "user", Content: prompt}},
+		MaxTokens:   maxTokens,
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", fmt.Errorf("AI响应为空")
+	}
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
+// decodeJSONReply 从模型回复中解析JSON对象，容忍```json代码块和前后的说明文字
+func decodeJSONReply(reply string, v interface{}) error {
+	start := strings.Index(reply, "{")
+	end := strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("回复中没有JSON对象")
+	}
+	return json.Unmarshal([]byte(reply[start:end+1]), v)
+}
+
+// Markdown 渲染为summary.md
+func (p *PaperSummary) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", p.Title))
+	sb.WriteString(fmt.Sprintf("> **TL;DR** %s\n", p.TLDR))
+	for _, section := range []struct{ heading, text string }{
+		{"研究背景", p.Background},
+		{"方法", p.Methods},
+		{"结果", p.Results},
+		{"局限性", p.Limitations},
+	} {
+		if section.text == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n\n%s\n", section.heading, section.text))
+	}
+	if len(p.KeyPoints) > 0 {
+		sb.WriteString("\n## 关键要点\n\n")
+		for _, point := range p.KeyPoints {
+			sb.WriteString("- " + point + "\n")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n---\n*生成于 %s*\n", p.CreatedAt.Format("2006-01-02 15:04")))
+	return sb.String()
+}
+
+// BatchSummaryStats 批量摘要统计
+type BatchSummaryStats struct {
+	Summarized int `json:"summarized"`
+	Cached     int `json:"cached"`
+	Failed     int `json:"failed"`
+}
+
+// SummarizeAll 依次摘要多个解析结果，单篇失败不影响其余；progress可为nil
+func (s *Summarizer) SummarizeAll(ctx context.Context, results []ParsedResult, force bool, progress func(done, total int, result *ParsedResult, summary *PaperSummary, err error)) BatchSummaryStats {
+	var stats BatchSummaryStats
+	for i := range results {
+		if ctx.Err() != nil {
+			break
+		}
+		summary, err := s.Summarize(ctx, &results[i], force)
+		switch {
+		case err != nil:
+			stats.Failed++
+			log.Printf("⚠️ 摘要失败 %s: %v", results[i].Name, err)
+		case summary.Cached:
+			stats.Cached++
+		default:
+			stats.Summarized++
+		}
+		if progress != nil {
+			progress(i+1, len(results), &results[i], summary, err)
+		}
+	}
+	return stats
+}
@@ -0,0 +1,152 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedAIClient 按提示内容返回不同回复的测试客户端
+type scriptedAIClient struct {
+	respond func(prompt string) string
+	prompts []string
+}
+
+func (c *scriptedAIClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	prompt := req.Messages[len(req.Messages)-1].Content
+	c.prompts = append(c.prompts, prompt)
+	return &AIResponse{Choices: []Choice{{Message: ChatMessage{Role: "assistant", Content: c.respond(prompt)}}}}, nil
+}
+
+func (c *scriptedAIClient) ChatStream(ctx context.Context, req *AIRequest) (<-chan *Choice, error) {
+	resp, _ := c.Chat(ctx, req)
+	ch := make(chan *Choice, 1)
+	ch <- &resp.Choices[0]
+	close(ch)
+	return ch, nil
+}
+
+// summaryReply 模拟模型的摘要回复：reduce阶段返回带代码块的JSON，其余返回笔记
+func summaryReply(prompt string) string {
+	if strings.Contains(prompt, `"tldr"`) {
+		return "好的，摘要如下：\n```json\n" + `{"tldr":"提出完全基于注意力的Transformer","background":"循环网络难以并行","methods":"堆叠自注意力","results":"翻译质量更好","limitations":"原文未明确说明","key_points":["自注意力","可并行"]}` + "\n```"
+	}
+	return "笔记：使用自注意力。"
+}
+
+func TestSummarizerCachesByContentHash(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	cacheDir := t.TempDir()
+	client := &scriptedAIClient{respond: summaryReply}
+	summarizer := NewSummarizer(client, cacheDir)
+
+	result, _ := GetParsedResult(resultsDir, "attention_20240101")
+	summary, err := summarizer.Summarize(context.Background(), result, false)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.TLDR != "提出完全基于注意力的Transformer" || len(summary.KeyPoints) != 2 || summary.ItemKey != "ABCD1234" || summary.Cached {
+		t.Errorf("Summarize() = %+v", summary)
+	}
+	if len(client.prompts) != 1 {
+		t.Errorf("短文献应直接汇总, 调用 %d 次", len(client.prompts))
+	}
+	md, err := os.ReadFile(filepath.Join(result.Dir, "summary.md"))
+	if err != nil || !strings.Contains(string(md), "**TL;DR** 提出完全基于注意力的Transformer") || !strings.Contains(string(md), "## 局限性") {
+		t.Errorf("summary.md = %s, %v", md, err)
+	}
+
+	// 内容未变化时使用结果目录中的摘要
+	again, err := summarizer.Summarize(context.Background(), result, false)
+	if err != nil || !again.Cached || len(client.prompts) != 1 {
+		t.Errorf("应命中缓存: cached=%v calls=%d err=%v", again.Cached, len(client.prompts), err)
+	}
+
+	// 同一内容换了目录名时使用哈希缓存
+	copyDir := filepath.Join(resultsDir, "attention_copy")
+	os.MkdirAll(copyDir, 0755)
+	data, _ := os.ReadFile(result.FullTextPath())
+	os.WriteFile(filepath.Join(copyDir, "full.md"), data, 0644)
+	copied, _ := GetParsedResult(resultsDir, "attention_copy")
+	fromCache, err := summarizer.Summarize(context.Background(), copied, false)
+	if err != nil || !fromCache.Cached || fromCache.Document != "attention_copy" || len(client.prompts) != 1 {
+		t.Errorf("应命中哈希缓存: %+v, calls=%d, err=%v", fromCache, len(client.prompts), err)
+	}
+	if _, err := os.Stat(filepath.Join(copyDir, "summary.json")); err != nil {
+		t.Error("命中哈希缓存时应补写summary.json")
+	}
+
+	if _, err := summarizer.Summarize(context.Background(), result, true); err != nil || len(client.prompts) != 2 {
+		t.Errorf("force应重新生成: calls=%d err=%v", len(client.prompts), err)
+	}
+}
+
+func TestSummarizerMapReduce(t *testing.T) {
+	resultsDir := t.TempDir()
+	dir := filepath.Join(resultsDir, "long_paper")
+	os.MkdirAll(dir, 0755)
+	var content strings.Builder
+	for i := 0; i < 6; i++ {
+		content.WriteString(fmt.Sprintf("# Section %d\n\n", i))
+		for j := 0; j < 12; j++ {
+			content.WriteString(strings.Repeat(fmt.Sprintf("Sentence %d of section %d describes the experiment. ", j, i), 6) + "\n\n")
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "full.md"), []byte(content.String()), 0644)
+
+	client := &scriptedAIClient{respond: summaryReply}
+	result, _ := GetParsedResult(resultsDir, "long_paper")
+	summary, err := NewSummarizer(client, "").Summarize(context.Background(), result, false)
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if summary.Sections < 2 {
+		t.Fatalf("长文献应分批处理, Sections = %d", summary.Sections)
+	}
+	if len(client.prompts) != summary.Sections+1 {
+		t.Errorf("调用次数 = %d, want %d（每批一次 + 汇总一次）", len(client.prompts), summary.Sections+1)
+	}
+	reducePrompt := client.prompts[len(client.prompts)-1]
+	if !strings.Contains(reducePrompt, "### 第1部分") || !strings.Contains(reducePrompt, fmt.Sprintf("### 第%d部分", summary.Sections)) {
+		t.Error("汇总阶段应包含所有部分的笔记")
+	}
+
+	stats := NewSummarizer(client, "").SummarizeAll(context.Background(), []ParsedResult{*result}, false, nil)
+	if stats.Cached != 1 {
+		t.Errorf("SummarizeAll() = %+v", stats)
+	}
+}
+
+func TestDecodeJSONReply(t *testing.T) {
+	var v struct {
+		A int `json:"a"`
+	}
+	if err := decodeJSONReply("结果如下 ```json\n{\"a\": 3}\n``` 完毕", &v); err != nil || v.A != 3 {
+		t.Errorf("decodeJSONReply() = %+v, %v", v, err)
+	}
+	if err := decodeJSONReply("没有JSON", &v); err == nil {
+		t.Error("没有JSON时应返回错误")
+	}
+}
+
+func TestMinerUClientSummarizesParsedResult(t *testing.T) {
+	resultsDir := writeRAGFixture(t)
+	dir := filepath.Join(resultsDir, "attention_20240101")
+	client := NewMinerUClientWithResultsDir("", "", resultsDir)
+
+	// 未配置摘要器时不调用AI
+	client.summarizeResult(context.Background(), dir)
+	if summary, _ := LoadPaperSummary(dir); summary != nil {
+		t.Fatalf("未配置摘要器时不应生成摘要: %+v", summary)
+	}
+
+	client.Summarizer = NewSummarizer(&scriptedAIClient{respond: summaryReply}, t.TempDir())
+	client.summarizeResult(context.Background(), dir)
+	summary, err := LoadPaperSummary(dir)
+	if err != nil || summary == nil || summary.TLDR != "提出完全基于注意力的Transformer" || len(summary.KeyPoints) != 2 || summary.ItemKey != "ABCD1234" {
+		t.Errorf("解析后的摘要 = %+v, %v", summary, err)
+	}
+}
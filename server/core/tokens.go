@@ -0,0 +1,26 @@
+package core
+
+import (
+	"unicode"
+)
+
+// EstimateTokens 粗略估算文本的token数：中日韩字符约1个token，其余字符约4个一个token
+//
+// 不依赖具体模型的分词器，用于上下文预算等不需要精确计数的场景
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// isCJK 是否为中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
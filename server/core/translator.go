@@ -0,0 +1,492 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 翻译参数
+const (
+	// translateBatchTokens 单次请求翻译的原文token上限，同一章节的段落合并发送
+	translateBatchTokens = 1500
+	// translateRetries 译文格式或占位符不正确时的重试次数
+	translateRetries = 1
+)
+
+// 翻译块类型
+const (
+	blockHeading  = "heading"
+	blockText     = "text"
+	blockVerbatim = "verbatim" // 公式、代码、表格、图片等原样保留
+)
+
+var (
+	validLanguage = regexp.MustCompile(`^[A-Za-z]{2,3}(?:-[A-Za-z0-9]{2,8})?$`)
+	// protectedInline 段落中需要原样保留的行内内容：行内公式、代码、图片和链接、引用标记、URL
+	protectedInline    = regexp.MustCompile(`\$[^$\n]+\$|\\\(.+?\\\)|` + "`[^`\n]+`" + `|!?\[[^\]\n]*\]\([^)\n]*\)|\[\d+(?:\s*[,，\-–]\s*\d+)*\]|https?://[^\s)]+`)
+	placeholderPattern = regexp.MustCompile(`⟦(\d+)⟧`)
+)
+
+// languageNames 常用语言代码对应的提示用名称
+var languageNames = map[string]string{
+	"zh": "简体中文",
+	"en": "English",
+	"ja": "日本語",
+	"ko": "한국어",
+	"de": "Deutsch",
+	"fr": "Français",
+}
+
+// translationBlock full.md中的一个块
+type translationBlock struct {
+	Kind   string
+	Prefix string // 标题的#前缀
+	Text   string
+}
+
+// ID 块内容的哈希，用于断点续译时复用已有译文
+func (b translationBlock) ID() string {
+	sum := sha256.Sum256([]byte(b.Kind + "\x00" + b.Text))
+	return hex.EncodeToString(sum[:8])
+}
+
+// splitTranslationBlocks 按空行和Markdown结构切分全文：标题和段落需要翻译，其余块原样保留
+func splitTranslationBlocks(content string) []translationBlock {
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	var blocks []translationBlock
+	var paragraph []string
+
+	flush := func() {
+		if text := strings.TrimSpace(strings.Join(paragraph, "\n")); text != "" {
+			blocks = append(blocks, translationBlock{Kind: blockText, Text: text})
+		}
+		paragraph = nil
+	}
+	// collect 收集从第i行开始直到end返回true的行，作为原样保留的块
+	collect := func(i int, end func(j int, line string) bool) int {
+		j := i
+		for j < len(lines) && !end(j, lines[j]) {
+			j++
+		}
+		if j >= len(lines) {
+			j = len(lines) - 1
+		}
+		blocks = append(blocks, translationBlock{Kind: blockVerbatim, Text: strings.Join(lines[i:j+1], "\n")})
+		return j
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+		case strings.HasPrefix(trimmed, "```"), strings.HasPrefix(trimmed, "~~~"):
+			flush()
+			fence := trimmed[:3]
+			i = collect(i, func(j int, l string) bool { return j > i && strings.HasPrefix(strings.TrimSpace(l), fence) })
+		case strings.HasPrefix(trimmed, "$$"):
+			flush()
+			if len(trimmed) > 2 && strings.HasSuffix(trimmed, "$$") && len(trimmed) >= 4 {
+				blocks = append(blocks, translationBlock{Kind: blockVerbatim, Text: line})
+				continue
+			}
+			i = collect(i, func(j int, l string) bool { return j > i && strings.HasSuffix(strings.TrimSpace(l), "$$") })
+		case strings.HasPrefix(strings.ToLower(trimmed), "<table"):
+			flush()
+			i = collect(i, func(j int, l string) bool { return strings.Contains(strings.ToLower(l), "</table>") })
+		case strings.HasPrefix(trimmed, "|"):
+			flush()
+			i = collect(i, func(j int, l string) bool {
+				return j+1 >= len(lines) || !strings.HasPrefix(strings.TrimSpace(lines[j+1]), "|")
+			})
+		case imagePattern.MatchString(trimmed):
+			flush()
+			blocks = append(blocks, translationBlock{Kind: blockVerbatim, Text: line})
+		case headingPattern.MatchString(trimmed):
+			flush()
+			hashes := trimmed[:strings.IndexFunc(trimmed, func(r rune) bool { return r != '#' })]
+			blocks = append(blocks, translationBlock{Kind: blockHeading, Prefix: hashes + " ", Text: headingPattern.FindStringSubmatch(trimmed)[1]})
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return blocks
+}
+
+// protectInline 将行内公式、引用等替换为⟦n⟧占位符，返回替换后的文本和原内容
+func protectInline(text string) (string, []string) {
+	var protected []string
+	masked := protectedInline.ReplaceAllStringFunc(text, func(match string) string {
+		protected = append(protected, match)
+		return fmt.Sprintf("⟦%d⟧", len(protected)-1)
+	})
+	return masked, protected
+}
+
+// restoreInline 还原占位符；占位符缺失、重复或多出时返回错误
+func restoreInline(text string, protected []string) (string, error) {
+	counts := make(map[int]int)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(match[1])
+		counts[n]++
+	}
+	for i := range protected {
+		if counts[i] != 1 {
+			return "", fmt.Errorf("占位符⟦%d⟧出现 %d 次", i, counts[i])
+		}
+	}
+	if len(counts) != len(protected) {
+		return "", fmt.Errorf("译文包含多余的占位符")
+	}
+	return placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		n, _ := strconv.Atoi(placeholderPattern.FindStringSubmatch(match)[1])
+		return protected[n]
+	}), nil
+}
+
+// Glossary 术语表：原文术语 → 译名
+type Glossary map[string]string
+
+// LoadGlossary 读取术语表：.json为对象格式，其他文件每行"术语<TAB>译名"或"术语 = 译名"，#开头为注释
+func LoadGlossary(path string) (Glossary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取术语表失败: %w", err)
+	}
+	glossary := make(Glossary)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &glossary); err != nil {
+			return nil, fmt.Errorf("解析术语表失败: %w", err)
+		}
+		return glossary, nil
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sep := "\t"
+		if !strings.Contains(line, sep) {
+			sep = "="
+		}
+		parts := strings.SplitN(line, sep, 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("术语表第 %d 行格式错误: %s", lineNo, line)
+		}
+		glossary[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return glossary, nil
+}
+
+// Relevant 文本中出现的术语（不区分大小写），按术语排序
+func (g Glossary) Relevant(text string) []string {
+	lower := strings.ToLower(text)
+	var terms []string
+	for term := range g {
+		if strings.Contains(lower, strings.ToLower(term)) {
+			terms = append(terms, term)
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// Hash 术语表内容的哈希，术语表变化后已有译文失效
+func (g Glossary) Hash() string {
+	if len(g) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(g) // map按键排序序列化
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// translationState 断点续译状态，保存在结果目录的translation.<lang>.json
+type translationState struct {
+	Lang         string            `json:"lang"`
+	GlossaryHash string            `json:"glossary_hash,omitempty"`
+	Segments     map[string]string `json:"segments"` // 块ID → 译文
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+// TranslationResult 翻译结果
+type TranslationResult struct {
+	Lang          string `json:"lang"`
+	Path          string `json:"path"`           // full.<lang>.md
+	BilingualPath string `json:"bilingual_path"` // full.<lang>.bilingual.md
+	Blocks        int    `json:"blocks"`         // 需要翻译的块数
+	Translated    int    `json:"translated"`     // 本次新翻译
+	Reused        int    `json:"reused"`         // 复用已有译文
+	Failed        int    `json:"failed"`         // 翻译失败、保留原文
+}
+
+// Translator 逐章节翻译full.md，保留Markdown结构
+type Translator struct {
+	client   AIClient
+	glossary Glossary
+}
+
+// NewTranslator 创建翻译器，glossary可为nil
+func NewTranslator(client AIClient, glossary Glossary) *Translator {
+	return &Translator{client: client, glossary: glossary}
+}
+
+// TranslationPaths 译文和对照文件的路径
+func TranslationPaths(result *ParsedResult, lang string) (string, string) {
+	return filepath.Join(result.Dir, "full."+lang+".md"), filepath.Join(result.Dir, "full."+lang+".bilingual.md")
+}
+
+// translationStatePath 续译状态文件路径
+func translationStatePath(result *ParsedResult, lang string) string {
+	return filepath.Join(result.Dir, "translation."+lang+".json")
+}
+
+// Translate 翻译解析结果的全文到lang（如zh、en），每批完成后保存进度，中断后再次运行从断点继续
+//
+// force为true时丢弃已有译文；progress可为nil
+func (t *Translator) Translate(ctx context.Context, result *ParsedResult, lang string, force bool, progress func(done, total int)) (*TranslationResult, error) {
+	ctx = WithUsageFeature(ctx, FeatureTranslate)
+	if !validLanguage.MatchString(lang) {
+		return nil, fmt.Errorf("无效的语言代码: %s", lang)
+	}
+	content, err := result.ReadFullText()
+	if err != nil {
+		return nil, err
+	}
+	blocks := splitTranslationBlocks(content)
+
+	state := t.loadState(result, lang)
+	if force || state.GlossaryHash != t.glossary.Hash() {
+		if len(state.Segments) > 0 && !force {
+			log.Printf("📖 术语表已变化，重新翻译 %s", result.Name)
+		}
+		state = &translationState{Lang: lang, GlossaryHash: t.glossary.Hash(), Segments: make(map[string]string)}
+	}
+
+	stats := &TranslationResult{Lang: lang}
+	var pending [][]translationBlock
+	var batch []translationBlock
+	batchTokens := 0
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		if block.Kind == blockVerbatim {
+			continue
+		}
+		stats.Blocks++
+		id := block.ID()
+		if _, ok := state.Segments[id]; ok || seen[id] {
+			stats.Reused++
+			continue
+		}
+		seen[id] = true
+		// 新章节开始或超出批次大小时分批
+		tokens := EstimateTokens(block.Text)
+		if len(batch) > 0 && (block.Kind == blockHeading || batchTokens+tokens > translateBatchTokens) {
+			pending = append(pending, batch)
+			batch, batchTokens = nil, 0
+		}
+		batch = append(batch, block)
+		batchTokens += tokens
+	}
+	if len(batch) > 0 {
+		pending = append(pending, batch)
+	}
+
+	done := stats.Reused
+	for _, batch := range pending {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("翻译中断，已保存进度: %w", err)
+		}
+		translations, err := t.translateBatch(ctx, batch, lang)
+		if err != nil {
+			return nil, fmt.Errorf("翻译中断，已保存进度: %w", err)
+		}
+		for _, block := range batch {
+			if translated, ok := translations[block.ID()]; ok {
+				state.Segments[block.ID()] = translated
+				stats.Translated++
+			} else {
+				stats.Failed++
+			}
+		}
+		if err := t.saveState(result, state); err != nil {
+			return nil, err
+		}
+		done += len(batch)
+		if progress != nil {
+			progress(done, stats.Blocks)
+		}
+	}
+
+	stats.Path, stats.BilingualPath = TranslationPaths(result, lang)
+	translatedDoc, bilingual := renderTranslation(blocks, state.Segments)
+	if err := os.WriteFile(stats.Path, []byte(translatedDoc), 0644); err != nil {
+		return nil, fmt.Errorf("写入译文失败: %w", err)
+	}
+	if err := os.WriteFile(stats.BilingualPath, []byte(bilingual), 0644); err != nil {
+		return nil, fmt.Errorf("写入对照译文失败: %w", err)
+	}
+	return stats, nil
+}
+
+// translateBatch 翻译一批块，返回块ID → 译文；单个块占位符不完整时保留原文（不写入结果），下次运行重试
+func (t *Translator) translateBatch(ctx context.Context, batch []translationBlock, lang string) (map[string]string, error) {
+	input := make(map[string]string, len(batch))
+	protected := make(map[string][]string, len(batch))
+	var source strings.Builder
+	for i, block := range batch {
+		key := strconv.Itoa(i + 1)
+		input[key], protected[key] = protectInline(block.Text)
+		source.WriteString(block.Text + "\n")
+	}
+	inputJSON, _ := json.MarshalIndent(input, "", "  ")
+
+	langName := languageNames[strings.ToLower(strings.SplitN(lang, "-", 2)[0])]
+	if langName == "" {
+		langName = lang
+	}
+	var glossaryLines strings.Builder
+	for _, term := range t.glossary.Relevant(source.String()) {
+		glossaryLines.WriteString(fmt.Sprintf("- %s → %s\n", term, t.glossary[term]))
+	}
+	prompt := fmt.Sprintf(`将下面JSON中每个值翻译为%s。要求：
+- 学术语体，术语前后一致，不要增删内容
+- ⟦数字⟧是公式、代码、引用等占位符，必须原样保留在译文的对应位置
+- 保留Markdown行内格式（如**粗体**、*斜体*、列表符号）
+`, langName)
+	if glossaryLines.Len() > 0 {
+		prompt += "- 以下术语必须使用指定译名：\n" + glossaryLines.String()
+	}
+	prompt += "只输出JSON对象，键与输入相同，值为译文。\n\n" + string(inputJSON)
+
+	messages := []ChatMessage{
+		{Role: "system", Content: "你是专业的学术论文翻译。"},
+		{Role: "user", Content: prompt},
+	}
+	result := make(map[string]string, len(batch))
+	for attempt := 0; attempt <= translateRetries; attempt++ {
+		resp, err := t.client.Chat(ctx, &AIRequest{Messages: messages, Temperature: 0.2})
+		if err != nil {
+			return nil, fmt.Errorf("AI翻译失败: %w", err)
+		}
+		if len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("AI响应为空")
+		}
+		reply := resp.Choices[0].Message.Content
+
+		var output map[string]string
+		if err := decodeJSONReply(reply, &output); err != nil {
+			log.Printf("⚠️ 译文格式错误（第%d次）: %v", attempt+1, err)
+			messages = append(messages,
+				ChatMessage{Role: "assistant", Content: reply},
+				ChatMessage{Role: "user", Content: "输出不是有效的JSON对象，请只输出JSON。"})
+			continue
+		}
+
+		var problems []string
+		for i, block := range batch {
+			key := strconv.Itoa(i + 1)
+			if _, ok := result[block.ID()]; ok {
+				continue
+			}
+			translated := strings.TrimSpace(output[key])
+			if translated == "" {
+				problems = append(problems, fmt.Sprintf("缺少键 %s", key))
+				continue
+			}
+			restored, err := restoreInline(translated, protected[key])
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("键 %s: %v", key, err))
+				continue
+			}
+			result[block.ID()] = restored
+		}
+		if len(problems) == 0 {
+			break
+		}
+		log.Printf("⚠️ 部分译文不完整（第%d次）: %s", attempt+1, strings.Join(problems, "；"))
+		messages = append(messages,
+			ChatMessage{Role: "assistant", Content: reply},
+			ChatMessage{Role: "user", Content: "以下问题需要修正：" + strings.Join(problems, "；") + "。请重新输出完整的JSON。"})
+	}
+	return result, nil
+}
+
+// renderTranslation 生成译文和对照文本：对照文本中每段原文后紧跟译文（引用块），原样保留的块只出现一次
+func renderTranslation(blocks []translationBlock, segments map[string]string) (string, string) {
+	var translated, bilingual []string
+	for _, block := range blocks {
+		text, ok := segments[block.ID()]
+		if !ok {
+			text = block.Text
+		}
+		switch block.Kind {
+		case blockVerbatim:
+			translated = append(translated, block.Text)
+			bilingual = append(bilingual, block.Text)
+		case blockHeading:
+			translated = append(translated, block.Prefix+text)
+			if ok && text != block.Text {
+				bilingual = append(bilingual, block.Prefix+block.Text+" / "+text)
+			} else {
+				bilingual = append(bilingual, block.Prefix+block.Text)
+			}
+		default:
+			translated = append(translated, text)
+			bilingual = append(bilingual, block.Text)
+			if ok {
+				bilingual = append(bilingual, "> "+strings.ReplaceAll(text, "\n", "\n> "))
+			}
+		}
+	}
+	return strings.Join(translated, "\n\n") + "\n", strings.Join(bilingual, "\n\n") + "\n"
+}
+
+// loadState 读取续译状态，不存在或损坏时返回空状态
+func (t *Translator) loadState(result *ParsedResult, lang string) *translationState {
+	state := &translationState{Lang: lang, GlossaryHash: t.glossary.Hash(), Segments: make(map[string]string)}
+	data, err := os.ReadFile(translationStatePath(result, lang))
+	if err != nil {
+		return state
+	}
+	var saved translationState
+	if err := json.Unmarshal(data, &saved); err != nil || saved.Segments == nil {
+		log.Printf("⚠️ 翻译进度文件损坏，重新翻译: %v", err)
+		return state
+	}
+	return &saved
+}
+
+// saveState 原子写入续译状态
+func (t *Translator) saveState(result *ParsedResult, state *translationState) error {
+	state.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化翻译进度失败: %w", err)
+	}
+	path := translationStatePath(result, state.Lang)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("保存翻译进度失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("保存翻译进度失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,245 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const translationFixture = `# Attention
+
+## Introduction
+
+The Transformer uses self-attention $QK^T$ as shown in [12].
+See https://example.com/paper for details.
+
+$$
+\mathrm{Attention}(Q,K,V) = \mathrm{softmax}(QK^T)V
+$$
+
+![Figure 1](images/fig1.png)
+
+| Model | BLEU |
+|-------|------|
+| Transformer | 28.4 |
+
+` + "```python\nprint('attention')\n```" + `
+
+## Results
+
+The model achieves 28.4 BLEU.
+`
+
+// writeTranslationFixture 写入包含公式、图片、表格和代码的解析结果
+func writeTranslationFixture(t *testing.T) *ParsedResult {
+	t.Helper()
+	root := t.TempDir()
+	writeParsedResult(t, root, "attention_20240101", "Attention", translationFixture)
+	result, err := GetParsedResult(root, "attention_20240101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// translationReply 模拟翻译：每个值加上"译:"前缀，占位符原样保留
+func translationReply(prompt string) string {
+	var input map[string]string
+	decodeJSONReply(prompt[strings.LastIndex(prompt, "值为译文。"):], &input)
+	output := make(map[string]string, len(input))
+	for key, text := range input {
+		output[key] = "译:" + text
+	}
+	data, _ := json.Marshal(output)
+	return "```json\n" + string(data) + "\n```"
+}
+
+// failingAIClient 前若干次调用成功，之后返回错误，模拟翻译中断
+type failingAIClient struct {
+	scriptedAIClient
+	remaining int
+}
+
+func (c *failingAIClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	if c.remaining <= 0 {
+		return nil, errors.New("连接中断")
+	}
+	c.remaining--
+	return c.scriptedAIClient.Chat(ctx, req)
+}
+
+func TestSplitTranslationBlocks(t *testing.T) {
+	blocks := splitTranslationBlocks(translationFixture)
+	var kinds []string
+	for _, block := range blocks {
+		kinds = append(kinds, block.Kind)
+	}
+	want := []string{blockHeading, blockHeading, blockText, blockVerbatim, blockVerbatim, blockVerbatim, blockVerbatim, blockHeading, blockText}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("块类型 = %v, want %v", kinds, want)
+	}
+	if blocks[1].Prefix != "## " || blocks[1].Text != "Introduction" {
+		t.Errorf("标题块 = %+v", blocks[1])
+	}
+	if !strings.HasPrefix(blocks[3].Text, "$$") || !strings.HasSuffix(blocks[3].Text, "$$") {
+		t.Errorf("公式块应完整保留: %q", blocks[3].Text)
+	}
+	if strings.Count(blocks[5].Text, "\n") != 2 {
+		t.Errorf("表格块应包含全部行: %q", blocks[5].Text)
+	}
+}
+
+func TestProtectInlineRoundTrip(t *testing.T) {
+	text := "Self-attention $QK^T$ and `code` in [12] and [3, 4], see [link](http://a.b) or https://x.y/z."
+	masked, protected := protectInline(text)
+	for _, kept := range []string{"$QK^T$", "`code`", "[12]", "[3, 4]", "[link](http://a.b)", "https://x.y/z."} {
+		if strings.Contains(masked, kept) {
+			t.Errorf("%q 应被替换为占位符: %s", kept, masked)
+		}
+	}
+	restored, err := restoreInline(masked, protected)
+	if err != nil || restored != text {
+		t.Errorf("restoreInline() = %q, %v", restored, err)
+	}
+	if _, err := restoreInline(strings.Replace(masked, "⟦0⟧", "", 1), protected); err == nil {
+		t.Error("占位符丢失时应返回错误")
+	}
+	if _, err := restoreInline(masked+"⟦9⟧", protected); err == nil {
+		t.Error("多余占位符应返回错误")
+	}
+}
+
+func TestTranslatePreservesStructure(t *testing.T) {
+	result := writeTranslationFixture(t)
+	client := &scriptedAIClient{respond: translationReply}
+	translator := NewTranslator(client, Glossary{"self-attention": "自注意力", "BLEU": "BLEU值", "dropout": "随机失活"})
+
+	stats, err := translator.Translate(context.Background(), result, "zh", false, nil)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if stats.Blocks != 5 || stats.Translated != 5 || stats.Failed != 0 {
+		t.Errorf("统计 = %+v", stats)
+	}
+	// 每个章节单独发送
+	if len(client.prompts) != 3 {
+		t.Errorf("应按章节分3批翻译, got %d", len(client.prompts))
+	}
+	if !strings.Contains(client.prompts[1], "self-attention → 自注意力") || strings.Contains(client.prompts[1], "dropout") {
+		t.Errorf("提示应只包含相关术语: %s", client.prompts[1])
+	}
+	if !strings.Contains(client.prompts[1], "简体中文") {
+		t.Error("提示应包含目标语言名称")
+	}
+
+	data, _ := os.ReadFile(stats.Path)
+	translated := string(data)
+	for _, want := range []string{
+		"## 译:Introduction",
+		"译:The Transformer uses self-attention $QK^T$ as shown in [12].",
+		"https://example.com/paper",
+		`\mathrm{softmax}(QK^T)V`,
+		"![Figure 1](images/fig1.png)",
+		"| Transformer | 28.4 |",
+		"print('attention')",
+	} {
+		if !strings.Contains(translated, want) {
+			t.Errorf("译文缺少 %q:\n%s", want, translated)
+		}
+	}
+	if filepath.Base(stats.Path) != "full.zh.md" {
+		t.Errorf("译文路径 = %s", stats.Path)
+	}
+
+	data, _ = os.ReadFile(stats.BilingualPath)
+	bilingual := string(data)
+	if !strings.Contains(bilingual, "## Results / 译:Results") || !strings.Contains(bilingual, "The model achieves 28.4 BLEU.\n\n> 译:The model achieves 28.4 BLEU.") {
+		t.Errorf("对照文本 = %s", bilingual)
+	}
+	if strings.Count(bilingual, "![Figure 1]") != 1 {
+		t.Error("原样保留的块在对照文本中只应出现一次")
+	}
+
+	if _, err := translator.Translate(context.Background(), result, "zh; rm", false, nil); err == nil {
+		t.Error("无效语言代码应返回错误")
+	}
+}
+
+func TestTranslateResumesAfterInterruption(t *testing.T) {
+	result := writeTranslationFixture(t)
+	client := &failingAIClient{scriptedAIClient: scriptedAIClient{respond: translationReply}, remaining: 1}
+
+	if _, err := NewTranslator(client, nil).Translate(context.Background(), result, "zh", false, nil); err == nil {
+		t.Fatal("客户端中断时应返回错误")
+	}
+
+	client.remaining = 10
+	stats, err := NewTranslator(client, nil).Translate(context.Background(), result, "zh", false, nil)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if stats.Reused != 1 || stats.Translated != 4 {
+		t.Errorf("应复用中断前的译文: %+v", stats)
+	}
+
+	// 术语表变化后重新翻译
+	stats, err = NewTranslator(client, Glossary{"BLEU": "BLEU值"}).Translate(context.Background(), result, "zh", false, nil)
+	if err != nil || stats.Reused != 0 || stats.Translated != 5 {
+		t.Errorf("术语表变化应使旧译文失效: %+v, %v", stats, err)
+	}
+}
+
+func TestTranslateKeepsOriginalWhenPlaceholdersLost(t *testing.T) {
+	result := writeTranslationFixture(t)
+	// 模型丢掉所有占位符：含公式和引用的段落保留原文，其余正常翻译
+	var last string
+	client := &scriptedAIClient{respond: func(prompt string) string {
+		if strings.Contains(prompt, "值为译文。") {
+			last = placeholderPattern.ReplaceAllString(translationReply(prompt), "")
+		}
+		return last
+	}}
+
+	stats, err := NewTranslator(client, nil).Translate(context.Background(), result, "zh", false, nil)
+	if err != nil {
+		t.Fatalf("Translate() error = %v", err)
+	}
+	if stats.Failed != 1 || stats.Translated != 4 {
+		t.Errorf("统计 = %+v", stats)
+	}
+	if len(client.prompts) != 4 || !strings.Contains(client.prompts[2], "占位符") {
+		t.Errorf("占位符丢失时应要求模型修正一次: %d 次调用", len(client.prompts))
+	}
+	data, _ := os.ReadFile(stats.Path)
+	if !strings.Contains(string(data), "The Transformer uses self-attention $QK^T$ as shown in [12].") {
+		t.Errorf("失败的段落应保留原文:\n%s", data)
+	}
+}
+
+func TestLoadGlossary(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "glossary.txt")
+	os.WriteFile(path, []byte("# 术语表\nself-attention\t自注意力\nencoder = 编码器\n\n"), 0644)
+	glossary, err := LoadGlossary(path)
+	if err != nil || glossary["self-attention"] != "自注意力" || glossary["encoder"] != "编码器" {
+		t.Errorf("LoadGlossary() = %v, %v", glossary, err)
+	}
+	if terms := glossary.Relevant("The Encoder stacks layers"); len(terms) != 1 || terms[0] != "encoder" {
+		t.Errorf("Relevant() = %v", terms)
+	}
+
+	jsonPath := filepath.Join(dir, "glossary.json")
+	os.WriteFile(jsonPath, []byte(`{"decoder":"解码器"}`), 0644)
+	if glossary, err := LoadGlossary(jsonPath); err != nil || glossary["decoder"] != "解码器" {
+		t.Errorf("LoadGlossary(json) = %v, %v", glossary, err)
+	}
+
+	os.WriteFile(path, []byte("no separator\n"), 0644)
+	if _, err := LoadGlossary(path); err == nil {
+		t.Error("格式错误的行应返回错误")
+	}
+}
@@ -0,0 +1,480 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 用量记录的功能分类，通过WithUsageFeature写入context
+const (
+	FeatureChat         = "chat"
+	FeatureAsk          = "ask"
+	FeatureSummarize    = "summarize"
+	FeatureExtract      = "extract"
+	FeatureReview       = "review"
+	FeatureCompare      = "compare"
+	FeatureTranslate    = "translate"
+	FeatureIntent       = "intent"
+	FeatureToolSelect   = "tool-select"
+	FeatureToolAnalysis = "tool-analysis" // 分析MCP工具返回的结果
+	FeatureRelated      = "related"       // 相关文献分析
+	FeatureEval         = "eval"
+	FeatureOther        = "other"
+)
+
+// 超出预算时的处理方式
+const (
+	BudgetActionBlock     = "block"     // 拒绝请求
+	BudgetActionDowngrade = "downgrade" // 改用备用模型
+)
+
+// ErrBudgetExceeded 超出用量预算且处理方式为block
+var ErrBudgetExceeded = errors.New("AI用量已超出预算")
+
+type usageFeatureKey struct{}
+
+// WithUsageFeature 标记context中的AI调用所属功能；已标记时保留外层的功能（如综述中的摘要仍记为review）
+func WithUsageFeature(ctx context.Context, feature string) context.Context {
+	if _, ok := ctx.Value(usageFeatureKey{}).(string); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, usageFeatureKey{}, feature)
+}
+
+// usageFeature context中标记的功能，未标记时为other
+func usageFeature(ctx context.Context) string {
+	if feature, ok := ctx.Value(usageFeatureKey{}).(string); ok {
+		return feature
+	}
+	return FeatureOther
+}
+
+// UsageRecord 一次AI调用的用量
+type UsageRecord struct {
+	Time             time.Time `json:"time"`
+	Provider         string    `json:"provider"`
+	Model            string    `json:"model"`
+	Feature          string    `json:"feature"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	LatencyMS        int64     `json:"latency_ms"`
+	Estimated        bool      `json:"estimated,omitempty"`       // 流式响应没有用量字段，按文本估算
+	DowngradedFrom   string    `json:"downgraded_from,omitempty"` // 因超出预算改用备用模型
+	Error            string    `json:"error,omitempty"`
+}
+
+// UsageTotals 一段时间内的用量合计
+type UsageTotals struct {
+	Requests         int   `json:"requests"`
+	Errors           int   `json:"errors"`
+	PromptTokens     int   `json:"prompt_tokens"`
+	CompletionTokens int   `json:"completion_tokens"`
+	TotalTokens      int   `json:"total_tokens"`
+	LatencyMS        int64 `json:"latency_ms"` // 总延迟，平均值见AvgLatencyMS
+}
+
+// add 累加一条记录
+func (t *UsageTotals) add(record UsageRecord) {
+	t.Requests++
+	if record.Error != "" {
+		t.Errors++
+	}
+	t.PromptTokens += record.PromptTokens
+	t.CompletionTokens += record.CompletionTokens
+	t.TotalTokens += record.TotalTokens
+	t.LatencyMS += record.LatencyMS
+}
+
+// AvgLatencyMS 平均延迟（毫秒）
+func (t UsageTotals) AvgLatencyMS() int64 {
+	if t.Requests == 0 {
+		return 0
+	}
+	return t.LatencyMS / int64(t.Requests)
+}
+
+// UsageGroup 按维度分组的用量
+type UsageGroup struct {
+	Key string `json:"key"`
+	UsageTotals
+}
+
+// UsageReport 用量报告
+type UsageReport struct {
+	From   time.Time    `json:"from"`
+	To     time.Time    `json:"to"`
+	By     string       `json:"by"`
+	Total  UsageTotals  `json:"total"`
+	Groups []UsageGroup `json:"groups"`
+}
+
+// UsageBudget 用量预算，值为0表示不限制
+type UsageBudget struct {
+	DailyTokens     int    `json:"daily_tokens"`
+	MonthlyTokens   int    `json:"monthly_tokens"`
+	DailyRequests   int    `json:"daily_requests"`
+	MonthlyRequests int    `json:"monthly_requests"`
+	Action          string `json:"action"`         // block 或 downgrade
+	FallbackModel   string `json:"fallback_model"` // downgrade时使用的模型
+}
+
+// BudgetStatus 当前用量与预算
+type BudgetStatus struct {
+	Budget   UsageBudget `json:"budget"`
+	Today    UsageTotals `json:"today"`
+	Month    UsageTotals `json:"month"`
+	Exceeded string      `json:"exceeded,omitempty"` // 超出的预算项说明，未超出时为空
+}
+
+// exceeded 返回超出的预算项，未超出时返回空字符串
+func (b UsageBudget) exceeded(today, month UsageTotals) string {
+	switch {
+	case b.DailyTokens > 0 && today.TotalTokens >= b.DailyTokens:
+		return fmt.Sprintf("今日token %d/%d", today.TotalTokens, b.DailyTokens)
+	case b.MonthlyTokens > 0 && month.TotalTokens >= b.MonthlyTokens:
+		return fmt.Sprintf("本月token %d/%d", month.TotalTokens, b.MonthlyTokens)
+	case b.DailyRequests > 0 && today.Requests >= b.DailyRequests:
+		return fmt.Sprintf("今日请求 %d/%d", today.Requests, b.DailyRequests)
+	case b.MonthlyRequests > 0 && month.Requests >= b.MonthlyRequests:
+		return fmt.Sprintf("本月请求 %d/%d", month.Requests, b.MonthlyRequests)
+	}
+	return ""
+}
+
+// UsageLedger 用量账本，按月写入 <dir>/YYYY-MM.jsonl
+//
+// 本月和当日合计缓存在内存中；文件被其他进程（如同时运行的CLI和Web服务）追加后重新统计
+type UsageLedger struct {
+	dir string
+
+	mu      sync.Mutex
+	month   string // 缓存对应的月份
+	size    int64  // 缓存对应的文件大小
+	records []UsageRecord
+	nowFunc func() time.Time
+}
+
+// NewUsageLedger 创建用量账本
+func NewUsageLedger(dir string) *UsageLedger {
+	return &UsageLedger{dir: dir, nowFunc: time.Now}
+}
+
+// 进程内按目录共享的用量账本，避免多个账本实例并发追加同一文件
+var (
+	sharedLedgersMu sync.Mutex
+	sharedLedgers   = make(map[string]*UsageLedger)
+)
+
+// SharedUsageLedger 获取dir对应的进程内共享账本
+func SharedUsageLedger(dir string) *UsageLedger {
+	key := filepath.Clean(dir)
+	sharedLedgersMu.Lock()
+	defer sharedLedgersMu.Unlock()
+	ledger, ok := sharedLedgers[key]
+	if !ok {
+		ledger = NewUsageLedger(dir)
+		sharedLedgers[key] = ledger
+	}
+	return ledger
+}
+
+// monthPath 指定月份的账本文件
+func (l *UsageLedger) monthPath(month string) string {
+	return filepath.Join(l.dir, month+".jsonl")
+}
+
+// Record 追加一条用量记录
+func (l *UsageLedger) Record(record UsageRecord) error {
+	if record.Time.IsZero() {
+		record.Time = l.nowFunc()
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("序列化用量记录失败: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return fmt.Errorf("创建用量目录失败: %w", err)
+	}
+	month := record.Time.Format("2006-01")
+	l.refresh(month)
+	file, err := os.OpenFile(l.monthPath(month), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("写入用量记录失败: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("写入用量记录失败: %w", err)
+	}
+	if month == l.month {
+		l.records = append(l.records, record)
+		l.size += int64(len(data) + 1)
+	}
+	return nil
+}
+
+// refresh 月份变化或文件被其他进程修改时重新读取本月记录，调用方持有锁
+func (l *UsageLedger) refresh(month string) {
+	var size int64
+	if info, err := os.Stat(l.monthPath(month)); err == nil {
+		size = info.Size()
+	}
+	if month == l.month && size == l.size {
+		return
+	}
+	records, err := readUsageRecords(l.monthPath(month))
+	if err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+	l.records = records
+	l.month, l.size = month, size
+}
+
+// readUsageRecords 读取一个账本文件，跳过损坏的行
+func readUsageRecords(path string) ([]UsageRecord, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取用量记录失败: %w", err)
+	}
+	defer file.Close()
+
+	var records []UsageRecord
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record UsageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// Status 今日和本月的用量及预算状态
+func (l *UsageLedger) Status(budget UsageBudget) BudgetStatus {
+	now := l.nowFunc()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refresh(now.Format("2006-01"))
+
+	status := BudgetStatus{Budget: budget}
+	day := now.Format("2006-01-02")
+	for _, record := range l.records {
+		status.Month.add(record)
+		if record.Time.In(now.Location()).Format("2006-01-02") == day {
+			status.Today.add(record)
+		}
+	}
+	status.Exceeded = budget.exceeded(status.Today, status.Month)
+	return status
+}
+
+// Report 统计[from, to)内的用量，by为feature、model、provider、day或month
+func (l *UsageLedger) Report(from, to time.Time, by string) (*UsageReport, error) {
+	keyOf := map[string]func(UsageRecord) string{
+		"feature":  func(r UsageRecord) string { return r.Feature },
+		"model":    func(r UsageRecord) string { return r.Model },
+		"provider": func(r UsageRecord) string { return r.Provider },
+		"day":      func(r UsageRecord) string { return r.Time.In(from.Location()).Format("2006-01-02") },
+		"month":    func(r UsageRecord) string { return r.Time.In(from.Location()).Format("2006-01") },
+	}[by]
+	if keyOf == nil {
+		return nil, fmt.Errorf("不支持的分组: %s（可选 feature、model、provider、day、month）", by)
+	}
+
+	report := &UsageReport{From: from, To: to, By: by}
+	groups := make(map[string]*UsageGroup)
+	for month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, from.Location()); month.Before(to); month = month.AddDate(0, 1, 0) {
+		records, err := readUsageRecords(l.monthPath(month.Format("2006-01")))
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.Time.Before(from) || !record.Time.Before(to) {
+				continue
+			}
+			report.Total.add(record)
+			key := keyOf(record)
+			if groups[key] == nil {
+				groups[key] = &UsageGroup{Key: key}
+			}
+			groups[key].add(record)
+		}
+	}
+	for _, group := range groups {
+		report.Groups = append(report.Groups, *group)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if by == "day" || by == "month" {
+			return report.Groups[i].Key < report.Groups[j].Key
+		}
+		return report.Groups[i].TotalTokens > report.Groups[j].TotalTokens
+	})
+	return report, nil
+}
+
+// MeteredClient 记录每次调用用量并执行预算限制的AIClient包装
+type MeteredClient struct {
+	inner    AIClient
+	ledger   *UsageLedger
+	budget   UsageBudget
+	provider string
+	model    string // 请求未指定模型时客户端使用的默认模型
+}
+
+// NewMeteredClient 包装AI客户端；provider一般为API地址的主机名，model为默认模型
+func NewMeteredClient(inner AIClient, ledger *UsageLedger, budget UsageBudget, provider, model string) *MeteredClient {
+	return &MeteredClient{inner: inner, ledger: ledger, budget: budget, provider: provider, model: model}
+}
+
+// MeteredClientConfig 创建带用量记录的AI客户端所需的配置
+type MeteredClientConfig struct {
+	APIKey   string
+	BaseURL  string
+	Model    string
+	UsageDir string // 用量账本目录，同一目录的客户端共用一个账本
+	Budget   UsageBudget
+}
+
+// NewMeteredClientFromConfig 创建记录用量并执行预算限制的GLM客户端
+func NewMeteredClientFromConfig(cfg MeteredClientConfig) *MeteredClient {
+	return NewMeteredClient(
+		NewGLMClient(cfg.APIKey, cfg.BaseURL, cfg.Model),
+		SharedUsageLedger(cfg.UsageDir),
+		cfg.Budget,
+		ProviderFromBaseURL(cfg.BaseURL),
+		cfg.Model,
+	)
+}
+
+// ProviderFromBaseURL 从API地址提取用于记录的服务商名称
+func ProviderFromBaseURL(baseURL string) string {
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Host != "" {
+		return parsed.Host
+	}
+	return baseURL
+}
+
+// admit 检查预算：超出时按配置拒绝或改用备用模型，返回被替换的原模型
+func (c *MeteredClient) admit(req *AIRequest) (string, error) {
+	status := c.ledger.Status(c.budget)
+	if status.Exceeded == "" {
+		return "", nil
+	}
+	if c.budget.Action == BudgetActionDowngrade && c.budget.FallbackModel != "" {
+		original := req.Model
+		if original == "" {
+			original = c.model
+		}
+		if original != c.budget.FallbackModel {
+			log.Printf("💸 AI用量超出预算（%s），改用 %s", status.Exceeded, c.budget.FallbackModel)
+			req.Model = c.budget.FallbackModel
+			return original, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("%w（%s）", ErrBudgetExceeded, status.Exceeded)
+}
+
+// record 写入一次调用的用量，写入失败只记录日志
+func (c *MeteredClient) record(ctx context.Context, req *AIRequest, model, downgradedFrom string, usage UsageInfo, estimated bool, start time.Time, callErr error) {
+	if model == "" {
+		model = req.Model
+	}
+	if model == "" {
+		model = c.model
+	}
+	record := UsageRecord{
+		Time:             start,
+		Provider:         c.provider,
+		Model:            model,
+		Feature:          usageFeature(ctx),
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+		LatencyMS:        time.Since(start).Milliseconds(),
+		Estimated:        estimated,
+		DowngradedFrom:   downgradedFrom,
+	}
+	if record.TotalTokens == 0 {
+		record.TotalTokens = record.PromptTokens + record.CompletionTokens
+	}
+	if callErr != nil {
+		record.Error = callErr.Error()
+	}
+	if err := c.ledger.Record(record); err != nil {
+		log.Printf("⚠️ 记录AI用量失败: %v", err)
+	}
+}
+
+// Chat 检查预算后调用并记录用量
+func (c *MeteredClient) Chat(ctx context.Context, req *AIRequest) (*AIResponse, error) {
+	downgradedFrom, err := c.admit(req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.inner.Chat(ctx, req)
+	if err != nil {
+		c.record(ctx, req, "", downgradedFrom, UsageInfo{}, false, start, err)
+		return nil, err
+	}
+	c.record(ctx, req, resp.Model, downgradedFrom, resp.Usage, false, start, nil)
+	return resp, nil
+}
+
+// ChatStream 检查预算后调用；流式响应没有用量字段，结束时按文本估算token
+func (c *MeteredClient) ChatStream(ctx context.Context, req *AIRequest) (<-chan *Choice, error) {
+	downgradedFrom, err := c.admit(req)
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	inner, err := c.inner.ChatStream(ctx, req)
+	if err != nil {
+		c.record(ctx, req, "", downgradedFrom, UsageInfo{}, false, start, err)
+		return nil, err
+	}
+
+	prompt := 0
+	for _, msg := range req.Messages {
+		prompt += EstimateTokens(msg.Content)
+	}
+	out := make(chan *Choice, cap(inner))
+	go func() {
+		defer close(out)
+		completion := 0
+		for choice := range inner {
+			if choice.Delta != nil {
+				completion += EstimateTokens(choice.Delta.Content)
+			} else {
+				completion += EstimateTokens(choice.Message.Content)
+			}
+			select {
+			case out <- choice:
+			case <-ctx.Done():
+				c.record(ctx, req, "", downgradedFrom, UsageInfo{PromptTokens: prompt, CompletionTokens: completion}, true, start, ctx.Err())
+				return
+			}
+		}
+		c.record(ctx, req, "", downgradedFrom, UsageInfo{PromptTokens: prompt, CompletionTokens: completion}, true, start, nil)
+	}()
+	return out, nil
+}
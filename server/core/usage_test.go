@@ -0,0 +1,178 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLedger 创建时钟固定在给定时间的用量账本
+func newTestLedger(t *testing.T, now time.Time) *UsageLedger {
+	t.Helper()
+	ledger := NewUsageLedger(t.TempDir())
+	ledger.nowFunc = func() time.Time { return now }
+	return ledger
+}
+
+func TestUsageLedgerReport(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+	ledger := newTestLedger(t, now)
+	records := []UsageRecord{
+		{Time: now.AddDate(0, -1, 0), Provider: "open.bigmodel.cn", Model: "glm-4.6", Feature: FeatureChat, PromptTokens: 100, CompletionTokens: 50, LatencyMS: 300},
+		{Time: now.Add(-time.Hour), Provider: "open.bigmodel.cn", Model: "glm-4.6", Feature: FeatureSummarize, PromptTokens: 1000, CompletionTokens: 200, LatencyMS: 900},
+		{Time: now, Provider: "open.bigmodel.cn", Model: "glm-4-flash", Feature: FeatureChat, PromptTokens: 10, CompletionTokens: 5, LatencyMS: 100, Error: "timeout"},
+	}
+	for _, record := range records {
+		record.TotalTokens = record.PromptTokens + record.CompletionTokens
+		if err := ledger.Record(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report, err := ledger.Report(now.AddDate(0, -2, 0), now.Add(time.Minute), "feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Total.Requests != 3 || report.Total.TotalTokens != 1365 || report.Total.Errors != 1 {
+		t.Errorf("合计 = %+v", report.Total)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != FeatureSummarize || report.Groups[1].Requests != 2 {
+		t.Errorf("按功能分组 = %+v", report.Groups)
+	}
+	if avg := report.Groups[1].AvgLatencyMS(); avg != 200 {
+		t.Errorf("平均延迟 = %d", avg)
+	}
+
+	// 只统计时间范围内的记录，按月分组时按时间排序
+	report, _ = ledger.Report(now.AddDate(0, -2, 0), now.Add(time.Minute), "month")
+	if len(report.Groups) != 2 || report.Groups[0].Key != "2024-02" || report.Groups[1].Requests != 2 {
+		t.Errorf("按月分组 = %+v", report.Groups)
+	}
+	report, _ = ledger.Report(now.Add(-2*time.Hour), now, "model")
+	if report.Total.Requests != 1 || report.Groups[0].Key != "glm-4.6" {
+		t.Errorf("时间范围过滤 = %+v", report)
+	}
+	if _, err := ledger.Report(now, now, "user"); err == nil {
+		t.Error("未知的分组方式应返回错误")
+	}
+
+	// 其他进程追加的记录在下次统计时生效
+	other := NewUsageLedger(ledger.dir)
+	other.Record(UsageRecord{Time: now, Feature: FeatureAsk, TotalTokens: 7})
+	if status := ledger.Status(UsageBudget{}); status.Today.Requests != 3 || status.Month.TotalTokens != 1222 {
+		t.Errorf("Status() = %+v", status)
+	}
+}
+
+func TestMeteredClientRecordsUsage(t *testing.T) {
+	now := time.Now()
+	ledger := newTestLedger(t, now)
+	inner := &fakeAIClient{reply: "答案"}
+	client := NewMeteredClient(inner, ledger, UsageBudget{}, ProviderFromBaseURL("https://open.bigmodel.cn/api/paas/v4"), "glm-4.6")
+
+	ctx := WithUsageFeature(context.Background(), FeatureReview)
+	ctx = WithUsageFeature(ctx, FeatureSummarize) // 综述中的摘要仍记为review
+	if _, err := client.Chat(ctx, &AIRequest{Messages: []ChatMessage{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Chat(context.Background(), &AIRequest{Model: "glm-4-air"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, _ := ledger.Report(now.Add(-time.Minute), now.Add(time.Minute), "feature")
+	if report.Total.Requests != 2 || len(report.Groups) != 2 {
+		t.Fatalf("Report() = %+v", report)
+	}
+	keys := report.Groups[0].Key + "," + report.Groups[1].Key
+	if !strings.Contains(keys, FeatureReview) || !strings.Contains(keys, FeatureOther) {
+		t.Errorf("功能标记 = %s", keys)
+	}
+	report, _ = ledger.Report(now.Add(-time.Minute), now.Add(time.Minute), "model")
+	if len(report.Groups) != 2 {
+		t.Errorf("未指定模型时应记录默认模型: %+v", report.Groups)
+	}
+	report, _ = ledger.Report(now.Add(-time.Minute), now.Add(time.Minute), "provider")
+	if report.Groups[0].Key != "open.bigmodel.cn" {
+		t.Errorf("服务商 = %+v", report.Groups)
+	}
+}
+
+func TestMeteredClientBudget(t *testing.T) {
+	now := time.Now()
+	ledger := newTestLedger(t, now)
+	ledger.Record(UsageRecord{Time: now, Model: "glm-4.6", Feature: FeatureChat, TotalTokens: 900})
+
+	inner := &fakeAIClient{reply: "ok"}
+	blocking := NewMeteredClient(inner, ledger, UsageBudget{DailyTokens: 1000}, "test", "glm-4.6")
+	if _, err := blocking.Chat(context.Background(), &AIRequest{}); err != nil {
+		t.Fatalf("未超出预算时应正常调用: %v", err)
+	}
+
+	// fakeAIClient返回的用量为0，再记一笔使当日用量超出预算
+	ledger.Record(UsageRecord{Time: now, Model: "glm-4.6", Feature: FeatureChat, TotalTokens: 200})
+	_, err := blocking.Chat(context.Background(), &AIRequest{})
+	if !errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), "今日token") {
+		t.Errorf("超出预算应拒绝请求: %v", err)
+	}
+	if _, err := blocking.ChatStream(context.Background(), &AIRequest{}); !errors.Is(err, ErrBudgetExceeded) {
+		t.Errorf("流式请求同样受预算限制: %v", err)
+	}
+	if len(inner.requests) != 1 {
+		t.Errorf("被拒绝的请求不应发出: %d", len(inner.requests))
+	}
+
+	downgrading := NewMeteredClient(inner, ledger, UsageBudget{MonthlyTokens: 1000, Action: BudgetActionDowngrade, FallbackModel: "glm-4-flash"}, "test", "glm-4.6")
+	if _, err := downgrading.Chat(context.Background(), &AIRequest{}); err != nil {
+		t.Fatalf("降级时不应报错: %v", err)
+	}
+	if got := inner.requests[len(inner.requests)-1].Model; got != "glm-4-flash" {
+		t.Errorf("降级后的模型 = %q", got)
+	}
+	report, _ := ledger.Report(now.Add(-time.Minute), now.Add(time.Minute), "model")
+	found := false
+	for _, group := range report.Groups {
+		found = found || group.Key == "glm-4-flash"
+	}
+	if !found {
+		t.Errorf("应按实际使用的模型记录: %+v", report.Groups)
+	}
+}
+
+func TestMeteredClientStreamEstimate(t *testing.T) {
+	now := time.Now()
+	ledger := newTestLedger(t, now)
+	client := NewMeteredClient(&fakeAIClient{reply: "The Transformer relies entirely on attention."}, ledger, UsageBudget{}, "test", "glm-4.6")
+
+	stream, err := client.ChatStream(WithUsageFeature(context.Background(), FeatureChat), &AIRequest{Messages: []ChatMessage{{Role: "user", Content: "What is the Transformer?"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range stream {
+	}
+
+	status := ledger.Status(UsageBudget{})
+	if status.Today.Requests != 1 || status.Today.PromptTokens == 0 || status.Today.CompletionTokens == 0 {
+		t.Errorf("流式调用应按文本估算用量: %+v", status.Today)
+	}
+	records, _ := readUsageRecords(ledger.monthPath(now.Format("2006-01")))
+	if len(records) != 1 || !records[0].Estimated || records[0].Feature != FeatureChat {
+		t.Errorf("记录 = %+v", records)
+	}
+}
+
+func TestSharedUsageLedger(t *testing.T) {
+	dir := t.TempDir()
+	ledger := SharedUsageLedger(dir)
+	if SharedUsageLedger(dir+"/") != ledger || SharedUsageLedger(t.TempDir()) == ledger {
+		t.Error("同一目录应共用一个账本，不同目录各自独立")
+	}
+
+	// 同一配置创建的客户端写入同一账本
+	cfg := MeteredClientConfig{BaseURL: "https://open.bigmodel.cn/api/paas/v4", Model: "glm-4.6", UsageDir: dir, Budget: UsageBudget{DailyRequests: 5}}
+	first, second := NewMeteredClientFromConfig(cfg), NewMeteredClientFromConfig(cfg)
+	if first.ledger != ledger || second.ledger != ledger || first.provider != "open.bigmodel.cn" || first.budget.DailyRequests != 5 {
+		t.Errorf("NewMeteredClientFromConfig() = %+v", first)
+	}
+}
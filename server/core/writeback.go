@@ -0,0 +1,567 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 写回参数
+const (
+	// DefaultZoteroAPIURL Zotero Web API 地址，可替换为实现同一协议的本地服务
+	DefaultZoteroAPIURL = "https://api.zotero.org"
+	// writebackStateFile 结果目录中记录已写回笔记Key的文件
+	writebackStateFile = "zotero_writeback.json"
+	// zoteroAPIVersion 使用的Web API版本
+	zoteroAPIVersion = "3"
+	// writebackNoteAttr 写回笔记根元素上的标记属性，读取笔记时据此排除ZoteroFlow自己生成的内容
+	writebackNoteAttr = "data-zoteroflow"
+)
+
+// 写回的笔记类型，抽取结果为 extraction:<模式名>
+const (
+	WritebackSummary   = "summary"
+	WritebackKeyPoints = "key_points"
+	writebackExtract   = "extraction:"
+)
+
+// 写回操作
+const (
+	WritebackCreate = "create"
+	WritebackUpdate = "update"
+	WritebackSkip   = "skip"
+	WritebackTag    = "tag"
+)
+
+// ErrZoteroItemNotFound 条目在Zotero中不存在（或已被永久删除）
+var ErrZoteroItemNotFound = errors.New("Zotero条目不存在")
+
+// ZoteroWebAPI Zotero Web API v3 客户端，写回只通过API进行，从不直接修改zotero.sqlite
+type ZoteroWebAPI struct {
+	BaseURL    string // https://api.zotero.org 或兼容的本地服务
+	Library    string // users/<用户ID> 或 groups/<群组ID>
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// NewZoteroWebAPI 创建Web API客户端，baseURL为空时使用DefaultZoteroAPIURL
+func NewZoteroWebAPI(baseURL, library, apiKey string) *ZoteroWebAPI {
+	if baseURL == "" {
+		baseURL = DefaultZoteroAPIURL
+	}
+	return &ZoteroWebAPI{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		Library:    strings.Trim(library, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ZoteroAPIItem Web API返回的条目
+type ZoteroAPIItem struct {
+	Key     string                 `json:"key"`
+	Version int                    `json:"version"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// Tags 条目的标签名
+func (i *ZoteroAPIItem) Tags() []string {
+	list, _ := i.Data["tags"].([]interface{})
+	tags := make([]string, 0, len(list))
+	for _, entry := range list {
+		if tag, ok := entry.(map[string]interface{}); ok {
+			if name, ok := tag["tag"].(string); ok {
+				tags = append(tags, name)
+			}
+		}
+	}
+	return tags
+}
+
+// do 发送请求，返回状态码和响应内容；非2xx状态码返回错误
+func (c *ZoteroWebAPI) do(ctx context.Context, method, path string, body interface{}, header map[string]string) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, fmt.Errorf("序列化请求失败: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+"/"+c.Library+path, reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Zotero-API-Version", zoteroAPIVersion)
+	if c.APIKey != "" {
+		req.Header.Set("Zotero-API-Key", c.APIKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("请求Zotero API失败: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, data, fmt.Errorf("Zotero API返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return resp.StatusCode, data, nil
+}
+
+// GetItem 读取条目的当前版本和数据
+func (c *ZoteroWebAPI) GetItem(ctx context.Context, key string) (*ZoteroAPIItem, error) {
+	status, data, err := c.do(ctx, http.MethodGet, "/items/"+key, nil, nil)
+	if status == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrZoteroItemNotFound, key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var item ZoteroAPIItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("解析条目失败: %w", err)
+	}
+	if item.Version == 0 {
+		if version, ok := item.Data["version"].(float64); ok {
+			item.Version = int(version)
+		}
+	}
+	return &item, nil
+}
+
+// zoteroWriteResponse 创建条目的响应
+type zoteroWriteResponse struct {
+	Successful map[string]ZoteroAPIItem `json:"successful"`
+	Failed     map[string]struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"failed"`
+}
+
+// CreateNote 在父条目下创建子笔记，返回笔记Key
+func (c *ZoteroWebAPI) CreateNote(ctx context.Context, parentKey, noteHTML string) (string, error) {
+	note := map[string]interface{}{
+		"itemType":   "note",
+		"parentItem": parentKey,
+		"note":       noteHTML,
+		"tags":       []interface{}{},
+	}
+	_, data, err := c.do(ctx, http.MethodPost, "/items", []interface{}{note}, nil)
+	if err != nil {
+		return "", err
+	}
+	var resp zoteroWriteResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("解析创建结果失败: %w", err)
+	}
+	if failed, ok := resp.Failed["0"]; ok {
+		return "", fmt.Errorf("创建笔记失败（%d）: %s", failed.Code, failed.Message)
+	}
+	created, ok := resp.Successful["0"]
+	if !ok || created.Key == "" {
+		return "", fmt.Errorf("创建笔记失败: 响应中没有笔记Key")
+	}
+	return created.Key, nil
+}
+
+// patchItem 按版本号修改条目字段，版本不一致时API返回412
+func (c *ZoteroWebAPI) patchItem(ctx context.Context, key string, version int, fields map[string]interface{}) error {
+	_, _, err := c.do(ctx, http.MethodPatch, "/items/"+key, fields, map[string]string{
+		"If-Unmodified-Since-Version": fmt.Sprint(version),
+	})
+	return err
+}
+
+// UpdateNote 替换笔记内容；笔记已被删除时返回ErrZoteroItemNotFound
+func (c *ZoteroWebAPI) UpdateNote(ctx context.Context, key, noteHTML string) error {
+	item, err := c.GetItem(ctx, key)
+	if err != nil {
+		return err
+	}
+	if deleted, _ := item.Data["deleted"].(bool); deleted {
+		return fmt.Errorf("%w: %s 在回收站中", ErrZoteroItemNotFound, key)
+	}
+	return c.patchItem(ctx, key, item.Version, map[string]interface{}{"note": noteHTML})
+}
+
+// AddTags 为条目添加标签（保留已有标签），返回实际新增的标签
+func (c *ZoteroWebAPI) AddTags(ctx context.Context, key string, tags []string) ([]string, error) {
+	item, err := c.GetItem(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	existing := item.Tags()
+	missing := missingTags(existing, tags)
+	if len(missing) == 0 {
+		return nil, nil
+	}
+
+	list, _ := item.Data["tags"].([]interface{})
+	merged := append([]interface{}(nil), list...)
+	for _, tag := range missing {
+		merged = append(merged, map[string]interface{}{"tag": tag})
+	}
+	if err := c.patchItem(ctx, key, item.Version, map[string]interface{}{"tags": merged}); err != nil {
+		return nil, err
+	}
+	return missing, nil
+}
+
+// missingTags wanted中不在existing里的标签
+func missingTags(existing, wanted []string) []string {
+	have := make(map[string]bool, len(existing))
+	for _, tag := range existing {
+		have[tag] = true
+	}
+	var missing []string
+	for _, tag := range wanted {
+		if tag = strings.TrimSpace(tag); tag != "" && !have[tag] {
+			have[tag] = true
+			missing = append(missing, tag)
+		}
+	}
+	return missing
+}
+
+// WritebackNote 一篇待写回的子笔记
+type WritebackNote struct {
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	HTML  string `json:"html"`
+}
+
+// Hash 笔记内容的哈希，用于判断是否需要更新
+func (n WritebackNote) Hash() string {
+	sum := sha256.Sum256([]byte(n.HTML))
+	return hex.EncodeToString(sum[:])
+}
+
+// WritebackNoteState 已写回笔记的Key和写回时的内容哈希
+type WritebackNoteState struct {
+	Key       string    `json:"key"`
+	Hash      string    `json:"hash"`
+	WrittenAt time.Time `json:"written_at"`
+}
+
+// WritebackState 结果目录中的写回记录：笔记类型对应的Zotero笔记Key和内容哈希
+type WritebackState struct {
+	ItemKey   string                        `json:"item_key"`
+	Notes     map[string]WritebackNoteState `json:"notes"`
+	Tags      []string                      `json:"tags,omitempty"`
+	UpdatedAt time.Time                     `json:"updated_at"`
+}
+
+// LoadWritebackState 读取写回记录，不存在时返回空记录
+func LoadWritebackState(dir string) (*WritebackState, error) {
+	state := &WritebackState{Notes: make(map[string]WritebackNoteState)}
+	data, err := os.ReadFile(filepath.Join(dir, writebackStateFile))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取写回记录失败: %w", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("解析写回记录失败: %w", err)
+	}
+	if state.Notes == nil {
+		state.Notes = make(map[string]WritebackNoteState)
+	}
+	return state, nil
+}
+
+// save 写入写回记录
+func (s *WritebackState) save(dir string) error {
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化写回记录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, writebackStateFile), data, 0644); err != nil {
+		return fmt.Errorf("写入写回记录失败: %w", err)
+	}
+	return nil
+}
+
+// BuildWritebackNotes 由结果目录中的摘要和抽取结果生成待写回的笔记
+//
+// schemas为空时包含全部已保存的抽取结果；抽取失败的结果不写回
+func BuildWritebackNotes(result *ParsedResult, schemas []string) ([]WritebackNote, error) {
+	var notes []WritebackNote
+	summary, err := LoadPaperSummary(result.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if summary != nil {
+		notes = append(notes, summaryNote(summary))
+		if len(summary.KeyPoints) > 0 {
+			notes = append(notes, keyPointsNote(summary))
+		}
+	}
+
+	if len(schemas) == 0 {
+		paths, _ := filepath.Glob(filepath.Join(result.Dir, "extractions", "*.json"))
+		for _, path := range paths {
+			schemas = append(schemas, strings.TrimSuffix(filepath.Base(path), ".json"))
+		}
+		sort.Strings(schemas)
+	}
+	for _, schema := range schemas {
+		extraction, err := LoadExtraction(result, schema)
+		if err != nil {
+			return nil, err
+		}
+		if extraction != nil && extraction.Error == "" {
+			notes = append(notes, extractionNote(extraction))
+		}
+	}
+	return notes, nil
+}
+
+// noteParagraphs 将空行分隔的文本转为HTML段落
+func noteParagraphs(text string) string {
+	var builder strings.Builder
+	for _, paragraph := range strings.Split(strings.TrimSpace(text), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			builder.WriteString("<p>" + html.EscapeString(paragraph) + "</p>")
+		}
+	}
+	return builder.String()
+}
+
+// writebackFooter 笔记末尾的生成说明
+func writebackFooter(createdAt time.Time) string {
+	return fmt.Sprintf("<p><em>由 ZoteroFlow 生成于 %s，重新写回时会被覆盖</em></p>", createdAt.Format("2006-01-02 15:04"))
+}
+
+// writebackNoteOpen 写回笔记的根元素，带有标记属性
+func writebackNoteOpen(kind string) string {
+	return fmt.Sprintf(`<div data-schema-version="8" %s="%s">`, writebackNoteAttr, html.EscapeString(kind))
+}
+
+// isWritebackNote 笔记是否由ZoteroFlow写回生成
+func isWritebackNote(noteHTML string) bool {
+	return strings.Contains(noteHTML, writebackNoteAttr+"=")
+}
+
+// summaryNote 摘要笔记
+func summaryNote(s *PaperSummary) WritebackNote {
+	var builder strings.Builder
+	title := "ZoteroFlow 摘要"
+	builder.WriteString(writebackNoteOpen(WritebackSummary) + "<h1>" + title + "</h1>")
+	if s.TLDR != "" {
+		builder.WriteString("<p><strong>TL;DR</strong> " + html.EscapeString(s.TLDR) + "</p>")
+	}
+	for _, section := range []struct{ heading, text string }{
+		{"研究背景", s.Background},
+		{"方法", s.Methods},
+		{"结果", s.Results},
+		{"局限性", s.Limitations},
+	} {
+		if section.text != "" {
+			builder.WriteString("<h2>" + section.heading + "</h2>" + noteParagraphs(section.text))
+		}
+	}
+	builder.WriteString(writebackFooter(s.CreatedAt) + "</div>")
+	return WritebackNote{Kind: WritebackSummary, Title: title, HTML: builder.String()}
+}
+
+// keyPointsNote 关键要点笔记
+func keyPointsNote(s *PaperSummary) WritebackNote {
+	var builder strings.Builder
+	title := "ZoteroFlow 关键要点"
+	builder.WriteString(writebackNoteOpen(WritebackKeyPoints) + "<h1>" + title + "</h1><ul>")
+	for _, point := range s.KeyPoints {
+		builder.WriteString("<li><p>" + html.EscapeString(point) + "</p></li>")
+	}
+	builder.WriteString("</ul>" + writebackFooter(s.CreatedAt) + "</div>")
+	return WritebackNote{Kind: WritebackKeyPoints, Title: title, HTML: builder.String()}
+}
+
+// extractionNote 抽取结果笔记：矩阵中这篇文献的一行，按字段展开为表格
+func extractionNote(e *ExtractionResult) WritebackNote {
+	var builder strings.Builder
+	title := "ZoteroFlow 抽取：" + e.Schema
+	builder.WriteString(writebackNoteOpen(writebackExtract+e.Schema) + "<h1>" + html.EscapeString(title) + "</h1>")
+	builder.WriteString("<table><tr><th>字段</th><th>值</th><th>来源</th></tr>")
+	for i := range e.Values {
+		value := &e.Values[i]
+		cell := formatExtractionValue(value.Value)
+		if value.Value == nil {
+			cell = "未提及"
+		}
+		builder.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s</td></tr>",
+			html.EscapeString(value.Field), html.EscapeString(cell), html.EscapeString(sourceText(value))))
+	}
+	builder.WriteString("</table>" + writebackFooter(e.CreatedAt) + "</div>")
+	return WritebackNote{Kind: writebackExtract + e.Schema, Title: title, HTML: builder.String()}
+}
+
+// WritebackOptions 写回选项
+type WritebackOptions struct {
+	DryRun  bool     // 只生成计划，不调用API也不更新记录
+	Tags    []string // 为条目添加的标签
+	Schemas []string // 写回的抽取模式，为空时写回全部
+}
+
+// WritebackAction 一项计划或已执行的写回操作
+type WritebackAction struct {
+	Kind    string   `json:"kind"` // 笔记类型，标签操作为 tags
+	Op      string   `json:"op"`   // create、update、skip、tag
+	Title   string   `json:"title,omitempty"`
+	NoteKey string   `json:"note_key,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// WritebackResult 一篇文献的写回结果
+type WritebackResult struct {
+	Document string            `json:"document"`
+	ItemKey  string            `json:"item_key"`
+	DryRun   bool              `json:"dry_run"`
+	Actions  []WritebackAction `json:"actions"`
+}
+
+// Changed 是否有（或将有）实际写入
+func (r *WritebackResult) Changed() bool {
+	for _, action := range r.Actions {
+		if action.Op != WritebackSkip && action.Error == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ZoteroWriter 将解析结果的摘要、要点和抽取结果写回Zotero
+type ZoteroWriter struct {
+	api *ZoteroWebAPI
+}
+
+// NewZoteroWriter 创建写回器，api为nil时只能以DryRun运行
+func NewZoteroWriter(api *ZoteroWebAPI) *ZoteroWriter {
+	return &ZoteroWriter{api: api}
+}
+
+// WriteBack 写回一篇文献：按记录的笔记Key更新已写回的笔记，内容未变时跳过，然后添加标签
+//
+// 每次成功写入后立即保存记录，中途失败后重新运行不会产生重复笔记
+func (w *ZoteroWriter) WriteBack(ctx context.Context, result *ParsedResult, opts WritebackOptions) (*WritebackResult, error) {
+	if result.Info == nil || result.Info.ItemKey == "" {
+		return nil, fmt.Errorf("%s 未关联Zotero条目，无法写回", result.Name)
+	}
+	if w.api == nil && !opts.DryRun {
+		return nil, fmt.Errorf("未配置Zotero API")
+	}
+	notes, err := BuildWritebackNotes(result, opts.Schemas)
+	if err != nil {
+		return nil, err
+	}
+	state, err := LoadWritebackState(result.Dir)
+	if err != nil {
+		return nil, err
+	}
+	if state.ItemKey != "" && state.ItemKey != result.Info.ItemKey {
+		// 结果改为关联其他条目，旧笔记Key不再适用
+		log.Printf("⚠️ %s 关联的条目已从 %s 变为 %s，重新创建笔记", result.Name, state.ItemKey, result.Info.ItemKey)
+		state = &WritebackState{Notes: make(map[string]WritebackNoteState)}
+	}
+	state.ItemKey = result.Info.ItemKey
+
+	report := &WritebackResult{Document: result.Name, ItemKey: result.Info.ItemKey, DryRun: opts.DryRun}
+	for _, note := range notes {
+		action := w.writeNote(ctx, result, state, note, opts.DryRun)
+		report.Actions = append(report.Actions, action)
+	}
+
+	if missing := missingTags(state.Tags, opts.Tags); len(missing) > 0 {
+		action := WritebackAction{Kind: "tags", Op: WritebackTag, Tags: missing}
+		if !opts.DryRun {
+			if _, err := w.api.AddTags(ctx, result.Info.ItemKey, missing); err != nil {
+				action.Error = err.Error()
+			} else {
+				state.Tags = append(state.Tags, missing...)
+				if err := state.save(result.Dir); err != nil {
+					return report, err
+				}
+			}
+		}
+		report.Actions = append(report.Actions, action)
+	}
+	return report, nil
+}
+
+// writeNote 创建或更新一篇笔记；已记录的笔记被删除时重新创建
+func (w *ZoteroWriter) writeNote(ctx context.Context, result *ParsedResult, state *WritebackState, note WritebackNote, dryRun bool) WritebackAction {
+	hash := note.Hash()
+	previous, written := state.Notes[note.Kind]
+	action := WritebackAction{Kind: note.Kind, Title: note.Title, NoteKey: previous.Key}
+	switch {
+	case written && previous.Hash == hash:
+		action.Op = WritebackSkip
+		return action
+	case written:
+		action.Op = WritebackUpdate
+	default:
+		action.Op = WritebackCreate
+	}
+	if dryRun {
+		return action
+	}
+
+	if action.Op == WritebackUpdate {
+		err := w.api.UpdateNote(ctx, previous.Key, note.HTML)
+		if errors.Is(err, ErrZoteroItemNotFound) {
+			log.Printf("⚠️ 笔记 %s 已在Zotero中删除，重新创建", previous.Key)
+			action.Op, err = WritebackCreate, nil
+		}
+		if err != nil {
+			action.Error = err.Error()
+			return action
+		}
+	}
+	if action.Op == WritebackCreate {
+		key, err := w.api.CreateNote(ctx, result.Info.ItemKey, note.HTML)
+		if err != nil {
+			action.Error = err.Error()
+			return action
+		}
+		action.NoteKey = key
+	}
+
+	state.Notes[note.Kind] = WritebackNoteState{Key: action.NoteKey, Hash: hash, WrittenAt: time.Now()}
+	if err := state.save(result.Dir); err != nil {
+		action.Error = err.Error()
+	}
+	return action
+}
+
+// SplitCommaList 拆分逗号分隔的列表（如标签、模式名），忽略空项
+func SplitCommaList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
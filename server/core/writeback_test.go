@@ -0,0 +1,320 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeZoteroAPI 内存中的Zotero Web API，按版本号校验修改
+type fakeZoteroAPI struct {
+	mu      sync.Mutex
+	items   map[string]map[string]interface{}
+	version int
+	writes  int
+	failNew bool
+}
+
+func newFakeZoteroAPI() *fakeZoteroAPI {
+	return &fakeZoteroAPI{items: map[string]map[string]interface{}{
+		"ABCD1234": {"key": "ABCD1234", "version": 1.0, "itemType": "journalArticle", "tags": []interface{}{map[string]interface{}{"tag": "transformer"}}},
+	}, version: 1}
+}
+
+func (f *fakeZoteroAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Zotero-API-Key") != "secret" || r.Header.Get("Zotero-API-Version") != "3" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/users/42/items")
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		f.writes++
+		var batch []map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&batch)
+		if f.failNew {
+			json.NewEncoder(w).Encode(map[string]interface{}{"failed": map[string]interface{}{"0": map[string]interface{}{"code": 400, "message": "parent item not found"}}})
+			return
+		}
+		f.version++
+		key := fmt.Sprintf("NOTE%04d", f.version)
+		batch[0]["key"], batch[0]["version"] = key, float64(f.version)
+		f.items[key] = batch[0]
+		json.NewEncoder(w).Encode(map[string]interface{}{"successful": map[string]interface{}{"0": map[string]interface{}{"key": key, "version": f.version, "data": batch[0]}}})
+	case r.Method == http.MethodGet:
+		item, ok := f.items[strings.TrimPrefix(path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"key": item["key"], "version": item["version"], "data": item})
+	case r.Method == http.MethodPatch:
+		f.writes++
+		item, ok := f.items[strings.TrimPrefix(path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("If-Unmodified-Since-Version") != strconv.Itoa(int(item["version"].(float64))) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		var fields map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&fields)
+		for k, v := range fields {
+			item[k] = v
+		}
+		f.version++
+		item["version"] = float64(f.version)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "unsupported", http.StatusBadRequest)
+	}
+}
+
+// writeWritebackFixture 在RAG测试结果中写入摘要和一个抽取结果
+func writeWritebackFixture(t *testing.T) *ParsedResult {
+	t.Helper()
+	result, err := GetParsedResult(writeRAGFixture(t), "attention_20240101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &PaperSummary{
+		Document: result.Name, Title: "Attention Is All You Need", TLDR: "Attention replaces recurrence.",
+		Methods: "Stacked self-attention <encoder>.\n\nMulti-head attention.", KeyPoints: []string{"No RNN", "Parallel training"},
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+	data, _ := json.Marshal(summary)
+	writeFixtureFile(t, filepath.Join(result.Dir, "summary.json"), data)
+
+	extraction := ExtractionResult{Schema: "methods", Document: result.Name, Title: summary.Title, CreatedAt: summary.CreatedAt, Values: []ExtractionValue{
+		{Field: "dataset", Value: "WMT 2014", Citations: []Citation{{Page: 3, Section: "Training"}}},
+		{Field: "sample_size", Value: nil},
+	}}
+	data, _ = json.Marshal(extraction)
+	writeFixtureFile(t, filepath.Join(result.Dir, "extractions", "methods.json"), data)
+	failed := ExtractionResult{Schema: "broken", Error: "timeout"}
+	data, _ = json.Marshal(failed)
+	writeFixtureFile(t, filepath.Join(result.Dir, "extractions", "broken.json"), data)
+	return result
+}
+
+func TestBuildWritebackNotes(t *testing.T) {
+	result := writeWritebackFixture(t)
+	notes, err := BuildWritebackNotes(result, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	for _, note := range notes {
+		kinds = append(kinds, note.Kind)
+	}
+	// 抽取失败的结果不写回
+	if strings.Join(kinds, ",") != "summary,key_points,extraction:methods" {
+		t.Fatalf("笔记类型 = %v", kinds)
+	}
+
+	// 写回的HTML能被笔记读取还原
+	summary := NoteHTMLToMarkdown(notes[0].HTML)
+	for _, want := range []string{"# ZoteroFlow 摘要", "**TL;DR** Attention replaces recurrence.", "## 方法\n\nStacked self-attention <encoder>.\n\nMulti-head attention.", "生成于 2024-05-01 10:00"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("摘要笔记缺少 %q:\n%s", want, summary)
+		}
+	}
+	if !strings.Contains(NoteHTMLToMarkdown(notes[1].HTML), "- No RNN\n- Parallel training") {
+		t.Errorf("要点笔记 = %s", notes[1].HTML)
+	}
+	if table := NoteHTMLToMarkdown(notes[2].HTML); !strings.Contains(table, "| dataset | WMT 2014 | p.3 §Training |") || !strings.Contains(table, "| sample_size | 未提及 |") {
+		t.Errorf("抽取笔记:\n%s", table)
+	}
+
+	if notes, _ := BuildWritebackNotes(result, []string{"missing"}); len(notes) != 2 {
+		t.Errorf("指定不存在的模式时只写回摘要: %+v", notes)
+	}
+}
+
+func TestZoteroWriterWriteBack(t *testing.T) {
+	fake := newFakeZoteroAPI()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	result := writeWritebackFixture(t)
+	writer := NewZoteroWriter(NewZoteroWebAPI(server.URL+"/", "users/42", "secret"))
+	opts := WritebackOptions{Tags: []string{"zf:parsed", "transformer"}}
+	ctx := context.Background()
+
+	ops := func(report *WritebackResult) string {
+		var parts []string
+		for _, action := range report.Actions {
+			parts = append(parts, action.Kind+"="+action.Op)
+			if action.Error != "" {
+				t.Errorf("%s 失败: %s", action.Kind, action.Error)
+			}
+		}
+		return strings.Join(parts, ",")
+	}
+
+	// 预览不调用API也不写记录
+	preview, err := NewZoteroWriter(nil).WriteBack(ctx, result, WritebackOptions{DryRun: true, Tags: opts.Tags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(preview); got != "summary=create,key_points=create,extraction:methods=create,tags=tag" || !preview.Changed() {
+		t.Errorf("预览 = %s", got)
+	}
+	if _, err := os.Stat(filepath.Join(result.Dir, writebackStateFile)); !os.IsNotExist(err) {
+		t.Error("预览不应写入记录")
+	}
+
+	report, err := writer.WriteBack(ctx, result, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(report); got != "summary=create,key_points=create,extraction:methods=create,tags=tag" {
+		t.Errorf("首次写回 = %s", got)
+	}
+	summaryKey := report.Actions[0].NoteKey
+	if note := fake.items[summaryKey]; note == nil || note["parentItem"] != "ABCD1234" || note["itemType"] != "note" {
+		t.Fatalf("笔记 = %+v", note)
+	}
+	parent := &ZoteroAPIItem{Data: fake.items["ABCD1234"]}
+	if tags := parent.Tags(); strings.Join(tags, ",") != "transformer,zf:parsed" {
+		t.Errorf("条目标签 = %v", tags)
+	}
+
+	// 重复写回不产生任何写入
+	writes := fake.writes
+	again, err := writer.WriteBack(ctx, result, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(again); got != "summary=skip,key_points=skip,extraction:methods=skip" || again.Changed() || fake.writes != writes {
+		t.Errorf("重复写回 = %s, 写入 %d 次", got, fake.writes-writes)
+	}
+
+	// 摘要变化时更新原笔记；被删除的要点笔记重新创建
+	keyPointsKey := report.Actions[1].NoteKey
+	delete(fake.items, keyPointsKey)
+	summary, _ := LoadPaperSummary(result.Dir)
+	summary.TLDR, summary.KeyPoints = "Updated.", []string{"Only attention"}
+	data, _ := json.Marshal(summary)
+	os.WriteFile(filepath.Join(result.Dir, "summary.json"), data, 0644)
+
+	updated, err := writer.WriteBack(ctx, result, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ops(updated); got != "summary=update,key_points=create,extraction:methods=skip" {
+		t.Errorf("更新 = %s", got)
+	}
+	if updated.Actions[0].NoteKey != summaryKey || !strings.Contains(fake.items[summaryKey]["note"].(string), "Updated.") {
+		t.Errorf("摘要笔记应原地更新: %+v", updated.Actions[0])
+	}
+	if updated.Actions[1].NoteKey == keyPointsKey || fake.items[updated.Actions[1].NoteKey] == nil {
+		t.Errorf("要点笔记应重新创建: %+v", updated.Actions[1])
+	}
+
+	state, _ := LoadWritebackState(result.Dir)
+	if state.ItemKey != "ABCD1234" || state.Notes[WritebackKeyPoints].Key != updated.Actions[1].NoteKey || len(state.Tags) != 2 {
+		t.Errorf("写回记录 = %+v", state)
+	}
+}
+
+func TestZoteroWriterErrors(t *testing.T) {
+	fake := newFakeZoteroAPI()
+	fake.failNew = true
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	result := writeWritebackFixture(t)
+	ctx := context.Background()
+
+	// 创建失败的笔记不记录，之后可以重试
+	report, err := NewZoteroWriter(NewZoteroWebAPI(server.URL, "users/42", "secret")).WriteBack(ctx, result, WritebackOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Actions) != 3 || !strings.Contains(report.Actions[0].Error, "parent item not found") {
+		t.Errorf("失败的写回 = %+v", report.Actions)
+	}
+	if state, _ := LoadWritebackState(result.Dir); len(state.Notes) != 0 {
+		t.Errorf("失败的笔记不应记录: %+v", state)
+	}
+
+	// 密钥错误
+	report, _ = NewZoteroWriter(NewZoteroWebAPI(server.URL, "users/42", "wrong")).WriteBack(ctx, result, WritebackOptions{})
+	if !strings.Contains(report.Actions[0].Error, "403") {
+		t.Errorf("密钥错误 = %+v", report.Actions[0])
+	}
+
+	// 未关联条目或未配置API
+	if _, err := NewZoteroWriter(nil).WriteBack(ctx, &ParsedResult{Name: "x", Dir: t.TempDir()}, WritebackOptions{DryRun: true}); err == nil {
+		t.Error("未关联条目时应返回错误")
+	}
+	if _, err := NewZoteroWriter(nil).WriteBack(ctx, result, WritebackOptions{}); err == nil {
+		t.Error("未配置API时只能预览")
+	}
+}
+
+// 写回的笔记同步回本地数据库后，不应被当作用户的阅读笔记读入对话和全文索引
+func TestWritebackNotesExcludedFromReadingNotes(t *testing.T) {
+	result := writeWritebackFixture(t)
+	notes, err := BuildWritebackNotes(result, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newFixtureZoteroDB(t)
+	addFixtureNotes(t, db)
+	raw, err := sql.Open("sqlite3", db.dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	for i, note := range notes {
+		itemID := 30 + i
+		if _, err := raw.Exec(`INSERT INTO items VALUES (?, 4, ?, '2024-06-01')`, itemID, fmt.Sprintf("ZFNOTE%02d", i)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := raw.Exec(`INSERT INTO itemNotes VALUES (?, 1, ?, ?)`, itemID, note.HTML, note.Title); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userNotes, err := db.GetItemNotes(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(userNotes) != 2 || userNotes[0].Key != "NOTE0001" || userNotes[1].Key != "NOTE0002" {
+		t.Errorf("GetItemNotes() 不应包含写回的笔记: %+v", userNotes)
+	}
+	sources, err := db.NoteFullTextSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, source := range sources {
+		if strings.HasPrefix(source.ID, "note:ZFNOTE") {
+			t.Errorf("写回的笔记不应进入全文索引: %s", source.ID)
+		}
+	}
+
+	client := &fakeAIClient{reply: "ok"}
+	manager := NewAIConversationManager(client, db)
+	manager.SetRAGPipeline(NewRAGPipeline(filepath.Dir(result.Dir)))
+	if _, err := manager.StartConversationWithDocument(context.Background(), "Attention 的要点？", &DocumentContext{DocumentNames: []string{result.Name}}); err != nil {
+		t.Fatal(err)
+	}
+	if system := client.requests[0].Messages[0].Content; strings.Contains(system, "ZoteroFlow 摘要") || strings.Contains(system, "Attention replaces recurrence") {
+		t.Errorf("写回的摘要不应作为阅读笔记出现在对话上下文:\n%s", system)
+	}
+}
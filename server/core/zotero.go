@@ -14,6 +14,7 @@ import (
 // ZoteroItem 简化的Zotero文献项结构 (30行)
 type ZoteroItem struct {
 	ItemID   int      `json:"item_id"`
+	ItemKey  string   `json:"item_key"`
 	Title    string   `json:"title"`
 	Authors  []string `json:"authors"`
 	Year     int      `json:"year"`
@@ -23,6 +24,7 @@ type ZoteroItem struct {
 	PDFName  string   `json:"pdf_name"`
 	DOI      string   `json:"doi"`
 	Extra    string   `json:"extra"`
+	Abstract string   `json:"abstract,omitempty"`
 }
 
 // SearchResult 搜索结果 - 扩展 ZoteroItem
@@ -88,6 +90,7 @@ func (z *ZoteroDB) GetItemsWithPDF(limit int) ([]ZoteroItem, error) {
 	query := `
 	SELECT DISTINCT
 		i.itemID,
+		i.key,
 		COALESCE(idv.value, '') as title,
 		it.typeName as item_type,
 		ia.path as attachment_path,
@@ -122,6 +125,7 @@ func (z *ZoteroDB) GetItemsWithPDF(limit int) ([]ZoteroItem, error) {
 
 		err := rows.Scan(
 			&item.ItemID,
+			&item.ItemKey,
 			&item.Title,
 			&item.ItemType,
 			&attachmentPath,
@@ -215,6 +219,7 @@ func (z *ZoteroDB) getItemsWithPDFFallback(limit int) ([]ZoteroItem, error) {
 		}
 
 		// 设置基本信息
+		item.ItemKey = itemKey
 		item.Title = fmt.Sprintf("文献 #%d", item.ItemID)
 		item.Authors = []string{"未知作者"}
 		item.Year = 0
@@ -659,6 +664,7 @@ func (z *ZoteroDB) SearchByTitle(query string, limit int) ([]SearchResult, error
 	fullQuery := `
 		SELECT DISTINCT
 			i.itemID,
+			i.key,
 			COALESCE(title_val.value, '') as title,
 			COALESCE(creator_val.value, '') as creators,
 			it.typeName as item_type,
@@ -698,6 +704,7 @@ func (z *ZoteroDB) SearchByTitle(query string, limit int) ([]SearchResult, error
 
 		err := rows.Scan(
 			&item.ItemID,
+			&item.ItemKey,
 			&item.Title,
 			&creators,
 			&item.ItemType,
@@ -755,6 +762,67 @@ func (z *ZoteroDB) SearchByTitle(query string, limit int) ([]SearchResult, error
 	return results, nil
 }
 
+// FullTextCacheSources 列出存储目录中Zotero已提取的附件全文（.zotero-ft-cache）
+func (z *ZoteroDB) FullTextCacheSources() ([]FullTextSource, error) {
+	rows, err := z.db.Query(`
+		SELECT att.key, COALESCE(parent.key, ''),
+			COALESCE((SELECT idv.value FROM itemData id
+				JOIN fieldsCombined fc ON fc.fieldID = id.fieldID AND fc.fieldName = 'title'
+				JOIN itemDataValues idv ON idv.valueID = id.valueID
+				WHERE id.itemID = COALESCE(ia.parentItemID, ia.itemID)), '')
+		FROM itemAttachments ia
+		JOIN items att ON att.itemID = ia.itemID
+		LEFT JOIN items parent ON parent.itemID = ia.parentItemID`)
+	if err != nil {
+		return nil, fmt.Errorf("查询附件失败: %w", err)
+	}
+	defer rows.Close()
+
+	var sources []FullTextSource
+	for rows.Next() {
+		var attachmentKey, itemKey, title string
+		if err := rows.Scan(&attachmentKey, &itemKey, &title); err != nil {
+			log.Printf("扫描附件数据失败: %v", err)
+			continue
+		}
+		path := filepath.Join(z.dataDir, attachmentKey, zoteroFullTextCacheName)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		if itemKey == "" {
+			itemKey = attachmentKey // 独立附件
+		}
+		sources = append(sources, FullTextSource{
+			ID:      "zotero:" + attachmentKey,
+			Kind:    FullTextKindZotero,
+			ItemKey: itemKey,
+			Title:   title,
+			Path:    path,
+		})
+	}
+	return sources, nil
+}
+
+// SearchFullText 在全文索引中搜索，并为命中补充对应的Zotero条目信息
+func (z *ZoteroDB) SearchFullText(index *FullTextIndex, query string, limit int) ([]FullTextHit, error) {
+	hits, err := index.Search(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hits {
+		if hits[i].ItemKey == "" {
+			continue
+		}
+		if item, err := z.GetItemByKey(hits[i].ItemKey); err == nil {
+			hits[i].Item = item
+			if hits[i].Title == "" {
+				hits[i].Title = item.Title
+			}
+		}
+	}
+	return hits, nil
+}
+
 // calculateSearchScore 计算搜索评分
 func calculateSearchScore(title, query string) float64 {
 	title = strings.ToLower(title)
@@ -781,3 +849,290 @@ func calculateSearchScore(title, query string) float64 {
 
 	return 30.0 // 包含匹配
 }
+
+// ZoteroCollection Zotero分类
+type ZoteroCollection struct {
+	CollectionID int    `json:"collection_id"`
+	Key          string `json:"key"`
+	Name         string `json:"name"`
+	ParentID     int    `json:"parent_id,omitempty"`
+	ItemCount    int    `json:"item_count"`
+}
+
+// GetItemByKey 按条目Key获取完整的文献信息
+func (z *ZoteroDB) GetItemByKey(itemKey string) (*ZoteroItem, error) {
+	return z.getItem("i.key = ?", itemKey)
+}
+
+// ResolveItem 按条目Key或解析结果目录名查找文献条目
+func (z *ZoteroDB) ResolveItem(ref, resultsDir string) (*ZoteroItem, error) {
+	if item, err := z.GetItemByKey(ref); err == nil {
+		return item, nil
+	}
+	result, err := GetParsedResult(resultsDir, ref)
+	if err != nil {
+		return nil, fmt.Errorf("未找到条目或解析结果: %s", ref)
+	}
+	if result.Info == nil || result.Info.ItemKey == "" {
+		return nil, fmt.Errorf("解析结果 %s 没有关联的Zotero条目", ref)
+	}
+	return z.GetItemByKey(result.Info.ItemKey)
+}
+
+// GetItemByID 按条目ID获取完整的文献信息
+func (z *ZoteroDB) GetItemByID(itemID int) (*ZoteroItem, error) {
+	return z.getItem("i.itemID = ?", itemID)
+}
+
+// ListItems 列出所有常规文献条目的Key、标题和摘要（不含附件、笔记和批注）
+func (z *ZoteroDB) ListItems() ([]ZoteroItem, error) {
+	rows, err := z.db.Query(`
+		SELECT i.itemID, i.key, it.typeName,
+			COALESCE(MAX(CASE WHEN fc.fieldName = 'title' THEN idv.value END), ''),
+			COALESCE(MAX(CASE WHEN fc.fieldName = 'abstractNote' THEN idv.value END), '')
+		FROM items i
+		JOIN itemTypes it ON it.itemTypeID = i.itemTypeID
+		LEFT JOIN itemData id ON id.itemID = i.itemID
+		LEFT JOIN fieldsCombined fc ON fc.fieldID = id.fieldID
+		LEFT JOIN itemDataValues idv ON idv.valueID = id.valueID
+		WHERE it.typeName NOT IN ('attachment', 'note', 'annotation')
+		GROUP BY i.itemID
+		ORDER BY i.itemID`)
+	if err != nil {
+		return nil, fmt.Errorf("查询文献列表失败: %w", err)
+	}
+	defer rows.Close()
+
+	var items []ZoteroItem
+	for rows.Next() {
+		var item ZoteroItem
+		if err := rows.Scan(&item.ItemID, &item.ItemKey, &item.ItemType, &item.Title, &item.Abstract); err != nil {
+			log.Printf("扫描文献数据失败: %v", err)
+			continue
+		}
+		if item.Title == "" {
+			item.Title = fmt.Sprintf("文献 #%d", item.ItemID)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// getItem 查询单个条目的字段、作者、标签和PDF附件
+func (z *ZoteroDB) getItem(where string, arg interface{}) (*ZoteroItem, error) {
+	var item ZoteroItem
+	err := z.db.QueryRow(`
+		SELECT i.itemID, i.key, it.typeName
+		FROM items i
+		JOIN itemTypes it ON it.itemTypeID = i.itemTypeID
+		WHERE `+where, arg).Scan(&item.ItemID, &item.ItemKey, &item.ItemType)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("文献不存在: %v", arg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询文献失败: %w", err)
+	}
+
+	// 字段值
+	rows, err := z.db.Query(`
+		SELECT fc.fieldName, idv.value
+		FROM itemData id
+		JOIN fieldsCombined fc ON id.fieldID = fc.fieldID
+		JOIN itemDataValues idv ON id.valueID = idv.valueID
+		WHERE id.itemID = ?`, item.ItemID)
+	if err != nil {
+		return nil, fmt.Errorf("查询文献字段失败: %w", err)
+	}
+	for rows.Next() {
+		var field, value string
+		if err := rows.Scan(&field, &value); err != nil {
+			continue
+		}
+		switch field {
+		case "title":
+			item.Title = value
+		case "DOI":
+			item.DOI = value
+		case "extra":
+			item.Extra = value
+		case "abstractNote":
+			item.Abstract = value
+		case "date":
+			if len(value) >= 4 {
+				fmt.Sscanf(value[:4], "%d", &item.Year)
+			}
+		}
+	}
+	rows.Close()
+
+	if item.Title == "" {
+		item.Title = fmt.Sprintf("文献 #%d", item.ItemID)
+	}
+
+	// 作者
+	item.Authors = []string{}
+	creatorRows, err := z.db.Query(`
+		SELECT COALESCE(c.firstName, ''), COALESCE(c.lastName, '')
+		FROM itemCreators ic
+		JOIN creators c ON ic.creatorID = c.creatorID
+		WHERE ic.itemID = ?
+		ORDER BY ic.orderIndex`, item.ItemID)
+	if err == nil {
+		for creatorRows.Next() {
+			var first, last string
+			if err := creatorRows.Scan(&first, &last); err != nil {
+				continue
+			}
+			if name := strings.TrimSpace(first + " " + last); name != "" {
+				item.Authors = append(item.Authors, name)
+			}
+		}
+		creatorRows.Close()
+	} else {
+		log.Printf("查询作者失败: %v", err)
+	}
+
+	// 标签
+	if tags, err := z.getItemTags(item.ItemID); err == nil && tags != nil {
+		item.Tags = tags
+	} else {
+		item.Tags = []string{}
+	}
+
+	// PDF附件
+	var attachmentPath string
+	err = z.db.QueryRow(`
+		SELECT COALESCE(path, '')
+		FROM itemAttachments
+		WHERE parentItemID = ? AND contentType = 'application/pdf'
+		LIMIT 1`, item.ItemID).Scan(&attachmentPath)
+	if err == nil && attachmentPath != "" {
+		item.PDFPath = z.buildPDFPath(attachmentPath)
+		item.PDFName = z.extractFilenameFromPath(attachmentPath)
+	}
+
+	return &item, nil
+}
+
+// ListCollections 列出所有分类及其文献数量
+func (z *ZoteroDB) ListCollections() ([]ZoteroCollection, error) {
+	rows, err := z.db.Query(`
+		SELECT c.collectionID, c.key, c.collectionName,
+			COALESCE(c.parentCollectionID, 0), COUNT(ci.itemID)
+		FROM collections c
+		LEFT JOIN collectionItems ci ON c.collectionID = ci.collectionID
+		GROUP BY c.collectionID
+		ORDER BY c.collectionName`)
+	if err != nil {
+		return nil, fmt.Errorf("查询分类失败: %w", err)
+	}
+	defer rows.Close()
+
+	var collections []ZoteroCollection
+	for rows.Next() {
+		var col ZoteroCollection
+		if err := rows.Scan(&col.CollectionID, &col.Key, &col.Name, &col.ParentID, &col.ItemCount); err != nil {
+			log.Printf("扫描分类数据失败: %v", err)
+			continue
+		}
+		collections = append(collections, col)
+	}
+	return collections, nil
+}
+
+// CollectionItems 列出分类（按Key或名称匹配）中的常规文献条目，includeSubcollections为true时包含子分类
+func (z *ZoteroDB) CollectionItems(keyOrName string, includeSubcollections bool) ([]ZoteroItem, error) {
+	collections, err := z.ListCollections()
+	if err != nil {
+		return nil, err
+	}
+
+	var root *ZoteroCollection
+	for i := range collections {
+		if collections[i].Key == keyOrName || strings.EqualFold(collections[i].Name, keyOrName) {
+			root = &collections[i]
+			break
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("分类不存在: %s", keyOrName)
+	}
+
+	ids := []int{root.CollectionID}
+	if includeSubcollections {
+		for i := 0; i < len(ids); i++ {
+			for _, col := range collections {
+				if col.ParentID == ids[i] {
+					ids = append(ids, col.CollectionID)
+				}
+			}
+		}
+	}
+
+	seen := make(map[int]bool)
+	var items []ZoteroItem
+	for _, id := range ids {
+		rows, err := z.db.Query(`
+			SELECT ci.itemID
+			FROM collectionItems ci
+			JOIN items i ON i.itemID = ci.itemID
+			JOIN itemTypes it ON it.itemTypeID = i.itemTypeID
+			WHERE ci.collectionID = ? AND it.typeName NOT IN ('attachment', 'note', 'annotation')`, id)
+		if err != nil {
+			return nil, fmt.Errorf("查询分类文献失败: %w", err)
+		}
+		var itemIDs []int
+		for rows.Next() {
+			var itemID int
+			if err := rows.Scan(&itemID); err == nil && !seen[itemID] {
+				seen[itemID] = true
+				itemIDs = append(itemIDs, itemID)
+			}
+		}
+		rows.Close()
+
+		for _, itemID := range itemIDs {
+			item, err := z.GetItemByID(itemID)
+			if err != nil {
+				log.Printf("读取文献 %d 失败: %v", itemID, err)
+				continue
+			}
+			items = append(items, *item)
+		}
+	}
+	return items, nil
+}
+
+// TagItems 列出带有指定标签（不区分大小写）的常规文献条目
+func (z *ZoteroDB) TagItems(tag string) ([]ZoteroItem, error) {
+	rows, err := z.db.Query(`
+		SELECT DISTINCT i.itemID
+		FROM itemTags itg
+		JOIN tags t ON t.tagID = itg.tagID
+		JOIN items i ON i.itemID = itg.itemID
+		JOIN itemTypes it ON it.itemTypeID = i.itemTypeID
+		WHERE t.name = ? COLLATE NOCASE AND it.typeName NOT IN ('attachment', 'note', 'annotation')
+		ORDER BY i.itemID`, tag)
+	if err != nil {
+		return nil, fmt.Errorf("查询标签文献失败: %w", err)
+	}
+	var itemIDs []int
+	for rows.Next() {
+		var itemID int
+		if err := rows.Scan(&itemID); err == nil {
+			itemIDs = append(itemIDs, itemID)
+		}
+	}
+	rows.Close()
+
+	var items []ZoteroItem
+	for _, itemID := range itemIDs {
+		item, err := z.GetItemByID(itemID)
+		if err != nil {
+			log.Printf("读取文献 %d 失败: %v", itemID, err)
+			continue
+		}
+		items = append(items, *item)
+	}
+	return items, nil
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fixtureSchema 测试用的最小Zotero数据库结构
+const fixtureSchema = `
+CREATE TABLE itemTypes (itemTypeID INTEGER PRIMARY KEY, typeName TEXT);
+CREATE TABLE items (itemID INTEGER PRIMARY KEY, itemTypeID INT, key TEXT, dateAdded TEXT);
+CREATE TABLE fieldsCombined (fieldID INTEGER PRIMARY KEY, fieldName TEXT);
+CREATE TABLE itemDataValues (valueID INTEGER PRIMARY KEY, value TEXT);
+CREATE TABLE itemData (itemID INT, fieldID INT, valueID INT);
+CREATE TABLE itemAttachments (itemID INTEGER PRIMARY KEY, parentItemID INT, contentType TEXT, path TEXT);
+CREATE TABLE tags (tagID INTEGER PRIMARY KEY, name TEXT);
+CREATE TABLE itemTags (itemID INT, tagID INT);
+CREATE TABLE creators (creatorID INTEGER PRIMARY KEY, firstName TEXT, lastName TEXT);
+CREATE TABLE itemCreators (itemID INT, creatorID INT, orderIndex INT);
+CREATE TABLE collections (collectionID INTEGER PRIMARY KEY, collectionName TEXT, parentCollectionID INT, key TEXT);
+CREATE TABLE collectionItems (collectionID INT, itemID INT);
+
+INSERT INTO itemTypes VALUES (1, 'journalArticle'), (2, 'attachment');
+INSERT INTO fieldsCombined VALUES (1, 'title'), (2, 'DOI'), (3, 'date'), (4, 'abstractNote');
+
+INSERT INTO items VALUES (1, 1, 'ABCD1234', '2024-01-01'), (2, 2, 'PDF00001', '2024-01-01');
+INSERT INTO itemDataValues VALUES (1, 'Attention Is All You Need'), (2, '10.5555/attention'), (3, '2017-06-12'), (4, 'We propose the Transformer.');
+INSERT INTO itemData VALUES (1, 1, 1), (1, 2, 2), (1, 3, 3), (1, 4, 4);
+INSERT INTO itemAttachments VALUES (2, 1, 'application/pdf', 'storage:attention.pdf');
+INSERT INTO tags VALUES (1, 'transformer');
+INSERT INTO itemTags VALUES (1, 1);
+INSERT INTO creators VALUES (1, 'Ashish', 'Vaswani'), (2, 'Noam', 'Shazeer');
+INSERT INTO itemCreators VALUES (1, 2, 1), (1, 1, 0);
+INSERT INTO collections VALUES (1, 'NLP', NULL, 'COLL0001'), (2, 'Attention', 1, 'COLL0002');
+INSERT INTO collectionItems VALUES (2, 1);
+`
+
+// newFixtureZoteroDB 创建包含一篇带PDF文献的临时Zotero数据库
+func newFixtureZoteroDB(t *testing.T) *ZoteroDB {
+	t.Helper()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "zotero.sqlite")
+	dataDir := filepath.Join(dir, "storage")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "attention.pdf"), []byte("%PDF-1.4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := raw.Exec(fixtureSchema); err != nil {
+		t.Fatalf("创建测试数据库失败: %v", err)
+	}
+	raw.Close()
+
+	db, err := NewZoteroDB(dbPath, dataDir)
+	if err != nil {
+		t.Fatalf("NewZoteroDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
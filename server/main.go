@@ -22,6 +22,7 @@ func main() {
 		showVer = flag.Bool("version", false, "显示版本信息")
 	)
 	flag.Parse()
+	cli.Version = version
 
 	if *help {
 		handler := cli.NewCommandHandler(nil)
@@ -2,8 +2,8 @@ package mcp
 
 import (
 	"context"
-	"crypto/md5"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"regexp"
@@ -28,63 +28,22 @@ type ToolCall struct {
 	Server    string                 `json:"server"`
 	Tool      string                 `json:"tool"`
 	Arguments map[string]interface{} `json:"arguments"`
-}
-
-// CachedResult 缓存结果
-type CachedResult struct {
-	Response *MCPResponse
-	Time     time.Time
-	TTL      time.Duration
-}
 
-// ToolCallCache 工具调用缓存
-type ToolCallCache struct {
-	cache map[string]*CachedResult
-	mutex sync.RWMutex
-}
-
-// NewToolCallCache 创建工具调用缓存
-func NewToolCallCache() *ToolCallCache {
-	return &ToolCallCache{
-		cache: make(map[string]*CachedResult),
-	}
+	// Confirmed 用户已确认调用（用于策略中要求确认的工具）
+	Confirmed bool `json:"confirmed,omitempty"`
 }
 
-// Get 获取缓存结果
-func (c *ToolCallCache) Get(key string) (*MCPResponse, bool) {
-	c.mutex.RLock()
-	defer c.mutex.RUnlock()
-
-	if result, exists := c.cache[key]; exists {
-		if time.Since(result.Time) < result.TTL {
-			log.Printf("🎯 缓存命中: %s", key)
-			return result.Response, true
-		}
-		// 过期，删除
-		delete(c.cache, key)
-	}
-	return nil, false
+// ConfirmationRequiredError 工具调用需要用户确认后才能执行
+type ConfirmationRequiredError struct {
+	Call *ToolCall
 }
 
-// Set 设置缓存结果
-func (c *ToolCallCache) Set(key string, response *MCPResponse, ttl time.Duration) {
-	c.mutex.Lock()
-	defer c.mutex.Unlock()
-
-	c.cache[key] = &CachedResult{
-		Response: response,
-		Time:     time.Now(),
-		TTL:      ttl,
-	}
-	log.Printf("💾 缓存设置: %s (TTL: %v)", key, ttl)
+func (e *ConfirmationRequiredError) Error() string {
+	return fmt.Sprintf("调用工具 %s.%s 需要用户确认", e.Call.Server, e.Call.Tool)
 }
 
-// generateCacheKey 生成缓存键
-func (amb *AIMCPBridge) generateCacheKey(toolCall *ToolCall) string {
-	// 简单的哈希：工具名+参数
-	return fmt.Sprintf("%s.%s:%s", toolCall.Server, toolCall.Tool,
-		fmt.Sprintf("%x", md5.Sum([]byte(fmt.Sprintf("%v", toolCall.Arguments)))))
-}
+// maxArgumentRepairs AI生成的参数未通过校验时，反馈错误请其修正的最大次数
+const maxArgumentRepairs = 2
 
 // AIMCPBridge AI与MCP的桥接器
 type AIMCPBridge struct {
@@ -93,25 +52,46 @@ type AIMCPBridge struct {
 	mcpManager  *MCPManager
 	managerOnce sync.Once
 	initError   error
-	cache       *ToolCallCache
+	ownsManager bool // 是否由桥接器创建并负责关闭管理器
+	confirm     func(*ToolCall) bool
+	prompts     *core.PromptLibrary
 }
 
 // NewAIMCPBridge 创建AI-MCP桥接器
 func NewAIMCPBridge(aiClient core.AIClient, config *config.Config) *AIMCPBridge {
+	prompts := core.DefaultPromptLibrary()
+	if config != nil {
+		prompts = core.LoadPromptLibrary(config.PromptsDir, config.PromptLanguage)
+	}
 	return &AIMCPBridge{
-		aiClient: aiClient,
-		config:   config,
-		cache:    NewToolCallCache(),
+		aiClient:    aiClient,
+		config:      config,
+		prompts:     prompts,
+		ownsManager: true,
 	}
 }
 
+// NewAIMCPBridgeWithManager 使用共享的MCP管理器创建桥接器（Close时不关闭管理器）
+func NewAIMCPBridgeWithManager(aiClient core.AIClient, config *config.Config, manager *MCPManager) *AIMCPBridge {
+	bridge := NewAIMCPBridge(aiClient, config)
+	bridge.mcpManager = manager
+	bridge.ownsManager = false
+	bridge.managerOnce.Do(func() {})
+	return bridge
+}
+
+// SetConfirmHandler 设置调用前确认函数，返回false表示用户拒绝；
+// 未设置时需要确认的调用返回ConfirmationRequiredError，由调用方确认后以Confirmed重新调用
+func (amb *AIMCPBridge) SetConfirmHandler(confirm func(*ToolCall) bool) {
+	amb.confirm = confirm
+}
+
 // GetAvailableTools 获取所有可用的MCP工具
 func (amb *AIMCPBridge) GetAvailableTools() ([]MCPTool, error) {
-	manager, err := NewMCPManager("mcp/mcp_config.json")
+	manager, err := amb.getMCPManager()
 	if err != nil {
-		return nil, fmt.Errorf("创建MCP管理器失败: %w", err)
+		return nil, err
 	}
-	defer manager.Close()
 
 	var allTools []MCPTool
 
@@ -129,7 +109,19 @@ func (amb *AIMCPBridge) GetAvailableTools() ([]MCPTool, error) {
 		log.Printf("获取context7工具失败: %v", err)
 	}
 
-	return allTools, nil
+	// 提供资源和提示模板的服务器额外暴露read_resource和get_prompt
+	allTools = append(allTools, amb.getResourceTools(manager)...)
+	allTools = append(allTools, amb.getPromptTools(manager)...)
+
+	// 过滤被服务器策略禁止的工具，避免AI选择
+	allowed := allTools[:0]
+	for _, tool := range allTools {
+		if manager.ToolAllowed(tool.Server, tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+
+	return allowed, nil
 }
 
 // getArticleMCPTools 获取article-mcp工具
@@ -252,8 +244,12 @@ func (amb *AIMCPBridge) SelectTool(message string) (*ToolCall, *string, error) {
 
 	// 1. 快速工具选择（基于测试脚本的成功逻辑）
 	if toolCall := amb.quickToolSelection(message); toolCall != nil {
-		log.Printf("⚡ 快速匹配到工具: %s.%s", toolCall.Server, toolCall.Tool)
-		return toolCall, nil, nil
+		if err := amb.ValidateToolCall(toolCall); err != nil {
+			log.Printf("⚠️ 快速匹配的工具不可用，改由AI选择: %v", err)
+		} else {
+			log.Printf("⚡ 快速匹配到工具: %s.%s", toolCall.Server, toolCall.Tool)
+			return toolCall, nil, nil
+		}
 	}
 
 	// 2. AI工具选择（处理复杂情况）
@@ -398,65 +394,98 @@ func (amb *AIMCPBridge) aiBasedSelection(message string) (*ToolCall, string, err
 
 	// 2. 构建明确的AI提示（初级版本：明确指定工具调用规则）
 	toolsPrompt := amb.formatToolsForAI(tools)
-	systemPrompt := fmt.Sprintf(`你是一个专业的学术研究助手，可以帮助用户查找和分析学术文献。你有以下MCP工具可以使用：
+	systemPrompt := amb.prompts.System(core.PromptToolSelect, core.PromptData{Tools: toolsPrompt})
 
-%s
-
-## 明确的工具调用规则：
-
-**当用户询问以下内容时，必须使用对应的工具：**
-
-1. **搜索论文/文献** → 必须使用：
-   - "search_europe_pmc"（用于一般学术文献搜索）
-   - "search_arxiv_papers"（专门搜索预印本，包含arxiv、preprint关键词时）
-
-2. **获取论文详情** → 必须使用：
-   - "get_article_details"（当用户提供DOI或PMID时）
-
-3. **查找相似研究** → 必须使用：
-   - "get_similar_articles"（当用户询问"相似"、"类似"、"similar"研究时）
-
-4. **查找引用文献** → 必须使用：
-   - "get_citing_articles"（当用户询问"引用"、"cite"时）
-
-5. **期刊信息查询** → 必须使用：
-   - "get_journal_quality"（当用户询问期刊、影响因子、分区时）
-
-**回复格式：**
-如果需要使用工具，请严格按照：
-TOOL: <工具名>
-ARGS: <JSON格式的参数>
+	// 3. AI分析
+	messages := []core.ChatMessage{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: message},
+	}
+	aiResponse := amb.chatForToolSelection(messages)
 
-如果不需要使用工具（比如询问概念、定义等），请直接回答用户问题。
+	// 4. 解析并校验结果，参数不符合inputSchema时把错误反馈给AI修正
+	for attempt := 0; ; attempt++ {
+		toolCall := amb.parseToolSelection(aiResponse)
+		if toolCall == nil {
+			return nil, aiResponse, nil
+		}
 
-**重要提示：**
-- 不要猜测，严格按照上述规则选择工具
-- 参数必须是有效的JSON格式
-- keyword参数提取用户查询中的核心关键词
-- max_results建议使用3-10
+		err := amb.ValidateToolCall(toolCall)
+		if err == nil {
+			return toolCall, aiResponse, nil
+		}
 
-**示例：**
-用户：搜索机器学习相关论文
-TOOL: search_europe_pmc
-ARGS: {"keyword": "machine learning", "max_results": 5}
+		var argErr *ArgumentError
+		if !errors.As(err, &argErr) || attempt >= maxArgumentRepairs {
+			return nil, aiResponse, err
+		}
 
-用户：查找和10.1038/nature12373相似的研究
-TOOL: get_similar_articles
-ARGS: {"identifier": "10.1038/nature12373", "max_results": 3}
+		log.Printf("🔁 [AI-MCP校验] 参数未通过校验，请AI修正 (第%d次): %v", attempt+1, err)
+		messages = append(messages,
+			core.ChatMessage{Role: "assistant", Content: aiResponse},
+			core.ChatMessage{Role: "user", Content: fmt.Sprintf(
+				"工具调用参数校验失败：\n- %s\n请根据工具的参数说明修正后，严格按照 TOOL/ARGS 格式重新输出。",
+				strings.Join(argErr.Problems, "\n- "))},
+		)
+		aiResponse = amb.chatForToolSelection(messages)
+	}
+}
+
+// ValidateToolCall 调用前检查工具是否可用，并按服务器声明的inputSchema校验参数
+//
+// 服务器未提供tools/list时跳过校验，由服务器自行报错
+func (amb *AIMCPBridge) ValidateToolCall(toolCall *ToolCall) error {
+	if toolCall.Tool == readResourceTool {
+		problems := ValidateArguments(legacyArgumentsSchema(map[string]interface{}{
+			"uri": map[string]interface{}{"type": "string", "minLength": 1, "required": true},
+		}), toolCall.Arguments)
+		if len(problems) > 0 {
+			return &ArgumentError{Server: toolCall.Server, Tool: toolCall.Tool, Problems: problems}
+		}
+		return nil
+	}
+	if toolCall.Tool == getPromptTool {
+		problems := ValidateArguments(legacyArgumentsSchema(map[string]interface{}{
+			"name":      map[string]interface{}{"type": "string", "minLength": 1, "required": true},
+			"arguments": map[string]interface{}{"type": "object"},
+		}), toolCall.Arguments)
+		if len(problems) > 0 {
+			return &ArgumentError{Server: toolCall.Server, Tool: toolCall.Tool, Problems: problems}
+		}
+		return nil
+	}
 
-用户：什么是深度学习？
-（直接回答，不需要工具）
-`, toolsPrompt)
+	manager, err := amb.getMCPManager()
+	if err != nil {
+		return fmt.Errorf("获取MCP管理器失败: %w", err)
+	}
+	if !manager.ToolAllowed(toolCall.Server, toolCall.Tool) {
+		return &PolicyError{Server: toolCall.Server, Tool: toolCall.Tool, Reason: "服务器策略不允许"}
+	}
+	if err := manager.StartServer(toolCall.Server); err != nil {
+		return fmt.Errorf("启动MCP服务器失败: %w", err)
+	}
 
-	// 3. AI分析
-	aiResponse := amb.callAIForToolSelection(message, systemPrompt)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 
-	// 4. 解析结果
-	if toolCall := amb.parseToolSelection(aiResponse); toolCall != nil {
-		return toolCall, aiResponse, nil
+	schema, found, err := manager.ToolSchema(ctx, toolCall.Server, toolCall.Tool)
+	if err != nil {
+		log.Printf("⚠️ [AI-MCP校验] 无法获取 %s 的工具列表，跳过参数校验: %v", toolCall.Server, err)
+		return nil
+	}
+	if !found {
+		return &ArgumentError{
+			Server:   toolCall.Server,
+			Tool:     toolCall.Tool,
+			Problems: []string{fmt.Sprintf("服务器 %s 未提供该工具", toolCall.Server)},
+		}
 	}
 
-	return nil, aiResponse, nil
+	if problems := ValidateArguments(schema, toolCall.Arguments); len(problems) > 0 {
+		return &ArgumentError{Server: toolCall.Server, Tool: toolCall.Tool, Problems: problems}
+	}
+	return nil
 }
 
 // extractKeyword 提取关键词
@@ -631,26 +660,15 @@ func (amb *AIMCPBridge) formatToolsForAI(tools []MCPTool) string {
 	return builder.String()
 }
 
-// callAIForToolSelection 调用AI进行工具选择
-func (amb *AIMCPBridge) callAIForToolSelection(message, systemPrompt string) string {
-	messages := []core.ChatMessage{
-		{
-			Role:    "system",
-			Content: systemPrompt,
-		},
-		{
-			Role:    "user",
-			Content: message,
-		},
-	}
-
+// chatForToolSelection 调用AI进行工具选择（含参数修正的多轮反馈）
+func (amb *AIMCPBridge) chatForToolSelection(messages []core.ChatMessage) string {
 	req := &core.AIRequest{
 		Model:     amb.config.AIModel,
 		Messages:  messages,
 		MaxTokens: 300, // 限制长度，避免冗长的回复
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(amb.config.AITimeout)*time.Second)
+	ctx, cancel := context.WithTimeout(core.WithUsageFeature(context.Background(), core.FeatureToolSelect), time.Duration(amb.config.AITimeout)*time.Second)
 	defer cancel()
 
 	response, err := amb.aiClient.Chat(ctx, req)
@@ -720,7 +738,7 @@ func (amb *AIMCPBridge) getAvailableToolList() []MCPTool {
 // getMCPManager 获取或创建MCP管理器（连接复用）
 func (amb *AIMCPBridge) getMCPManager() (*MCPManager, error) {
 	amb.managerOnce.Do(func() {
-		manager, err := NewMCPManager("mcp/mcp_config.json")
+		manager, err := NewMCPManager(DefaultConfigFile)
 		if err != nil {
 			amb.initError = fmt.Errorf("创建MCP管理器失败: %w", err)
 			return
@@ -743,32 +761,52 @@ func (amb *AIMCPBridge) CallTool(toolCall *ToolCall) (*MCPResponse, error) {
 	log.Printf("🤖 [AI-MCP] 开始调用工具: %s.%s", toolCall.Server, toolCall.Tool)
 	log.Printf("📥 [AI-MCP输入] 工具参数: %s", amb.formatArgumentsForLog(toolCall.Arguments))
 
-	// 生成缓存键
-	cacheKey := amb.generateCacheKey(toolCall)
-	log.Printf("🔑 [AI-MCP缓存] 缓存键: %s", cacheKey)
+	// 获取MCP管理器（复用连接）
+	log.Printf("🔧 [AI-MCP管理器] 获取MCP管理器...")
+	manager, err := amb.getMCPManager()
+	if err != nil {
+		log.Printf("❌ [AI-MCP错误] 获取MCP管理器失败: %v", err)
+		return nil, fmt.Errorf("获取MCP管理器失败: %w", err)
+	}
+	log.Printf("✅ [AI-MCP管理器] MCP管理器获取成功")
+
+	if !manager.ToolAllowed(toolCall.Server, toolCall.Tool) {
+		return nil, &PolicyError{Server: toolCall.Server, Tool: toolCall.Tool, Reason: "服务器策略不允许"}
+	}
 
-	// 检查缓存（对于搜索类工具，缓存5分钟）
-	if amb.shouldUseCache(toolCall.Tool) {
-		if cachedResponse, found := amb.cache.Get(cacheKey); found {
+	// 检查缓存（TTL在mcp_config.json的cacheTTL中按工具配置；只有通过校验的调用才会写入缓存，
+	// 命中时无需启动服务器）
+	cache := manager.Cache()
+	ttl := manager.CacheTTL(toolCall.Server, toolCall.Tool)
+	useCache := cache != nil && ttl > 0 && !isBridgeTool(toolCall.Tool)
+	if useCache {
+		if cachedResponse, found := cache.Get(toolCall); found {
 			duration := time.Since(startTime)
 			log.Printf("🎯 [AI-MCP缓存] 缓存命中，节省耗时: %v", duration)
 			log.Printf("📤 [AI-MCP输出] 返回缓存结果")
 			return cachedResponse, nil
-		} else {
-			log.Printf("🔍 [AI-MCP缓存] 缓存未命中，执行实际调用")
 		}
+		log.Printf("🔍 [AI-MCP缓存] 缓存未命中，执行实际调用")
 	} else {
 		log.Printf("⚡ [AI-MCP缓存] 工具 %s 不使用缓存", toolCall.Tool)
 	}
 
-	// 获取MCP管理器（复用连接）
-	log.Printf("🔧 [AI-MCP管理器] 获取MCP管理器...")
-	manager, err := amb.getMCPManager()
-	if err != nil {
-		log.Printf("❌ [AI-MCP错误] 获取MCP管理器失败: %v", err)
-		return nil, fmt.Errorf("获取MCP管理器失败: %w", err)
+	// 参数校验和调用前确认
+	if err := amb.ValidateToolCall(toolCall); err != nil {
+		log.Printf("❌ [AI-MCP校验] %v", err)
+		return nil, err
+	}
+	if err := amb.confirmToolCall(toolCall); err != nil {
+		log.Printf("🛑 [AI-MCP确认] %v", err)
+		return nil, err
+	}
+
+	switch toolCall.Tool {
+	case readResourceTool:
+		return amb.callReadResource(toolCall)
+	case getPromptTool:
+		return amb.callGetPrompt(toolCall)
 	}
-	log.Printf("✅ [AI-MCP管理器] MCP管理器获取成功")
 
 	// 启动对应的服务器（如果未启动）
 	log.Printf("🚀 [AI-MCP服务器] 启动服务器: %s", toolCall.Server)
@@ -802,43 +840,42 @@ func (amb *AIMCPBridge) CallTool(toolCall *ToolCall) (*MCPResponse, error) {
 		log.Printf("📊 [AI-MCP统计] 成功统计 - 响应大小: %d 字节, 耗时: %v", resultSize, duration)
 	}
 
-	// 设置缓存
-	if amb.shouldUseCache(toolCall.Tool) {
-		// 搜索类工具缓存5分钟，其他工具缓存30分钟
-		ttl := 30 * time.Minute
-		if strings.Contains(toolCall.Tool, "search") {
-			ttl = 5 * time.Minute
+	// 设置缓存（工具返回isError的结果不缓存）
+	if useCache && !isErrorResult(response) {
+		if err := cache.Set(toolCall, response, ttl); err != nil {
+			log.Printf("⚠️ [AI-MCP缓存] 缓存结果失败: %v", err)
+		} else {
+			log.Printf("💾 [AI-MCP缓存] 结果已缓存，TTL: %v", ttl)
 		}
-		amb.cache.Set(cacheKey, response, ttl)
-		log.Printf("💾 [AI-MCP缓存] 结果已缓存，TTL: %v", ttl)
 	}
 
 	log.Printf("🎉 [AI-MCP完成] 工具调用流程完成")
 	return response, nil
 }
 
-// shouldUseCache 判断是否应该使用缓存
-func (amb *AIMCPBridge) shouldUseCache(toolName string) bool {
-	// 搜索类工具使用缓存
-	cacheableTools := []string{
-		"search_europe_pmc",
-		"search_arxiv_papers",
-		"get_article_details",
-		"get_similar_articles",
+// confirmToolCall 策略要求确认的工具在调用前征求用户同意
+func (amb *AIMCPBridge) confirmToolCall(toolCall *ToolCall) error {
+	if toolCall.Confirmed || isBridgeTool(toolCall.Tool) {
+		return nil
 	}
-
-	for _, cacheable := range cacheableTools {
-		if toolName == cacheable {
-			return true
-		}
+	manager, err := amb.getMCPManager()
+	if err != nil || !manager.RequiresConfirmation(toolCall.Server, toolCall.Tool) {
+		return nil
 	}
 
-	return false
+	if amb.confirm == nil {
+		return &ConfirmationRequiredError{Call: toolCall}
+	}
+	if !amb.confirm(toolCall) {
+		return &PolicyError{Server: toolCall.Server, Tool: toolCall.Tool, Reason: "用户拒绝调用"}
+	}
+	toolCall.Confirmed = true
+	return nil
 }
 
 // Close 关闭桥接器
 func (amb *AIMCPBridge) Close() error {
-	if amb.mcpManager != nil {
+	if amb.mcpManager != nil && amb.ownsManager {
 		return amb.mcpManager.Close()
 	}
 	return nil
@@ -0,0 +1,245 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"zoteroflow2-server/core"
+)
+
+// 桥接器内置的工具，由桥接器转换为resources/read和prompts/get，不经过服务器的tools/call
+const (
+	readResourceTool = "read_resource"
+	getPromptTool    = "get_prompt"
+)
+
+// maxListedResources 工具描述中列出的资源（提示模板）数量上限
+const maxListedResources = 20
+
+// isBridgeTool 是否为桥接器内置的只读工具：不缓存、不需要确认
+func isBridgeTool(name string) bool {
+	return name == readResourceTool || name == getPromptTool
+}
+
+// capableServers 返回已启动且声明了指定能力的服务器
+func (amb *AIMCPBridge) capableServers(manager *MCPManager, capability string) []string {
+	var servers []string
+	for _, name := range manager.ListActiveServers() {
+		if manager.HasCapability(name, capability) {
+			servers = append(servers, name)
+		}
+	}
+	sort.Strings(servers)
+	return servers
+}
+
+// ReadResource 读取资源并返回文本内容
+func (amb *AIMCPBridge) ReadResource(server, uri string) (string, error) {
+	manager, err := amb.getMCPManager()
+	if err != nil {
+		return "", err
+	}
+	if err := manager.StartServer(server); err != nil {
+		return "", fmt.Errorf("启动MCP服务器失败: %w", err)
+	}
+
+	log.Printf("📖 [AI-MCP资源] 读取 %s 的资源: %s", server, uri)
+	contents, err := manager.ReadResource(context.Background(), server, uri)
+	if err != nil {
+		return "", err
+	}
+
+	var parts []string
+	for _, content := range contents {
+		switch {
+		case content.Text != "":
+			parts = append(parts, content.Text)
+		case content.Blob != "":
+			parts = append(parts, fmt.Sprintf("[二进制资源 %s (%s)，未展开]", content.URI, content.MimeType))
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("资源 %s 没有可读取的文本内容", uri)
+	}
+	return strings.Join(parts, "\n\n"), nil
+}
+
+// GetPrompt 展开服务器上的提示模板，转换为可直接发送给AI的对话消息
+func (amb *AIMCPBridge) GetPrompt(server, name string, arguments map[string]string) ([]core.ChatMessage, error) {
+	manager, err := amb.getMCPManager()
+	if err != nil {
+		return nil, err
+	}
+	if err := manager.StartServer(server); err != nil {
+		return nil, fmt.Errorf("启动MCP服务器失败: %w", err)
+	}
+
+	result, err := manager.GetPrompt(context.Background(), server, name, arguments)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []core.ChatMessage
+	for _, msg := range result.Messages {
+		content := msg.Content.Text
+		if msg.Content.Type == "resource" && msg.Content.Resource != nil {
+			content = msg.Content.Resource.Text
+		}
+		if content == "" {
+			continue
+		}
+		messages = append(messages, core.ChatMessage{
+			Role:      msg.Role,
+			Content:   content,
+			Timestamp: time.Now(),
+		})
+	}
+	return messages, nil
+}
+
+// getResourceTools 为提供资源的服务器生成read_resource工具，供AI选择
+func (amb *AIMCPBridge) getResourceTools(manager *MCPManager) []MCPTool {
+	var tools []MCPTool
+	for _, server := range amb.capableServers(manager, "resources") {
+		resources, err := manager.ListResources(context.Background(), server)
+		if err != nil || len(resources) == 0 {
+			continue
+		}
+
+		var desc strings.Builder
+		desc.WriteString("读取服务器提供的资源（如论文全文），可用资源:")
+		for i, resource := range resources {
+			if i >= maxListedResources {
+				desc.WriteString(fmt.Sprintf("\n    ... 共 %d 个资源", len(resources)))
+				break
+			}
+			desc.WriteString(fmt.Sprintf("\n    %s - %s", resource.URI, resource.Name))
+		}
+
+		tools = append(tools, MCPTool{
+			Server: server,
+			Name:   readResourceTool,
+			Desc:   desc.String(),
+			Arguments: map[string]interface{}{
+				"uri": map[string]interface{}{
+					"type":        "string",
+					"description": "资源URI",
+					"required":    true,
+				},
+			},
+		})
+	}
+	return tools
+}
+
+// callReadResource 执行AI选择的read_resource工具，结果包装为工具响应
+func (amb *AIMCPBridge) callReadResource(toolCall *ToolCall) (*MCPResponse, error) {
+	uri, _ := toolCall.Arguments["uri"].(string)
+	if uri == "" {
+		return nil, fmt.Errorf("read_resource 缺少 uri 参数")
+	}
+
+	text, err := amb.ReadResource(toolCall.Server, uri)
+	if err != nil {
+		return nil, fmt.Errorf("读取资源失败 (服务器: %s): %w", toolCall.Server, err)
+	}
+
+	result, _ := json.Marshal(text)
+	return &MCPResponse{JSONRPC: "2.0", Result: result}, nil
+}
+
+// getPromptTools 为提供提示模板的服务器生成get_prompt工具，供AI选择
+func (amb *AIMCPBridge) getPromptTools(manager *MCPManager) []MCPTool {
+	var tools []MCPTool
+	for _, server := range amb.capableServers(manager, "prompts") {
+		prompts, err := manager.ListPrompts(context.Background(), server)
+		if err != nil || len(prompts) == 0 {
+			continue
+		}
+		tools = append(tools, MCPTool{
+			Server: server,
+			Name:   getPromptTool,
+			Desc:   describePrompts(prompts),
+			Arguments: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "提示模板名称",
+					"required":    true,
+				},
+				"arguments": map[string]interface{}{
+					"type":        "object",
+					"description": "模板参数，如 {\"doi\": \"10.1038/nature12373\"}",
+				},
+			},
+		})
+	}
+	return tools
+}
+
+// describePrompts 生成get_prompt工具的说明，列出模板及其参数
+func describePrompts(prompts []Prompt) string {
+	var desc strings.Builder
+	desc.WriteString("获取服务器提供的提示模板（按参数展开后的指令），可用模板:")
+	for i, prompt := range prompts {
+		if i >= maxListedResources {
+			desc.WriteString(fmt.Sprintf("\n    ... 共 %d 个模板", len(prompts)))
+			break
+		}
+		desc.WriteString("\n    " + prompt.Name)
+		if prompt.Description != "" {
+			desc.WriteString(" - " + prompt.Description)
+		}
+		var args []string
+		for _, arg := range prompt.Arguments {
+			if arg.Required {
+				args = append(args, arg.Name+"(必填)")
+			} else {
+				args = append(args, arg.Name)
+			}
+		}
+		if len(args) > 0 {
+			desc.WriteString("，参数: " + strings.Join(args, ", "))
+		}
+	}
+	return desc.String()
+}
+
+// formatPromptMessages 将展开的提示模板转换为工具结果文本
+func formatPromptMessages(messages []core.ChatMessage) string {
+	parts := make([]string, 0, len(messages))
+	for _, msg := range messages {
+		parts = append(parts, fmt.Sprintf("[%s]\n%s", msg.Role, msg.Content))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// callGetPrompt 执行AI选择的get_prompt工具，结果包装为工具响应
+func (amb *AIMCPBridge) callGetPrompt(toolCall *ToolCall) (*MCPResponse, error) {
+	name, _ := toolCall.Arguments["name"].(string)
+	if name == "" {
+		return nil, fmt.Errorf("get_prompt 缺少 name 参数")
+	}
+	arguments := make(map[string]string)
+	if raw, ok := toolCall.Arguments["arguments"].(map[string]interface{}); ok {
+		for key, value := range raw {
+			arguments[key] = fmt.Sprint(value)
+		}
+	}
+
+	log.Printf("📝 [AI-MCP提示] 展开 %s 的提示模板: %s", toolCall.Server, name)
+	messages, err := amb.GetPrompt(toolCall.Server, name, arguments)
+	if err != nil {
+		return nil, fmt.Errorf("获取提示模板失败 (服务器: %s): %w", toolCall.Server, err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("提示模板 %s 没有可用的文本内容", name)
+	}
+
+	result, _ := json.Marshal(formatPromptMessages(messages))
+	return &MCPResponse{JSONRPC: "2.0", Result: result}, nil
+}
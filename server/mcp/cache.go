@@ -0,0 +1,535 @@
+package mcp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存默认设置
+const (
+	DefaultCacheDir        = "data/cache/mcp"
+	defaultCacheMaxSizeMB  = 100
+	defaultCacheMaxEntries = 5000
+	metricsFlushInterval   = 30 * time.Second
+)
+
+// CacheSettings 工具结果缓存的全局设置
+type CacheSettings struct {
+	// Enabled 为false时关闭缓存，未设置时默认开启
+	Enabled    *bool  `json:"enabled,omitempty"`
+	Dir        string `json:"dir,omitempty"`
+	MaxSizeMB  int    `json:"maxSizeMB,omitempty"`
+	MaxEntries int    `json:"maxEntries,omitempty"`
+}
+
+// cacheEntry 磁盘上的缓存条目，每个条目一个文件，文件修改时间作为最近访问时间
+type cacheEntry struct {
+	Key       string       `json:"key"`
+	Server    string       `json:"server"`
+	Tool      string       `json:"tool"`
+	CreatedAt time.Time    `json:"createdAt"`
+	ExpiresAt time.Time    `json:"expiresAt"`
+	Response  *MCPResponse `json:"response"`
+}
+
+// ToolCacheMetrics 单个工具的缓存计数
+type ToolCacheMetrics struct {
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Sets      int64 `json:"sets"`
+	Evictions int64 `json:"evictions"`
+}
+
+// hitRate 命中率
+func (m ToolCacheMetrics) hitRate() float64 {
+	if m.Hits+m.Misses == 0 {
+		return 0
+	}
+	return float64(m.Hits) / float64(m.Hits+m.Misses)
+}
+
+// ToolCacheStats 单个工具的缓存统计
+type ToolCacheStats struct {
+	Tool    string `json:"tool"` // server.tool
+	Entries int    `json:"entries"`
+	Bytes   int64  `json:"bytes"`
+	ToolCacheMetrics
+	HitRate float64 `json:"hitRate"`
+}
+
+// CacheStats 缓存整体统计
+type CacheStats struct {
+	Dir        string           `json:"dir"`
+	Entries    int              `json:"entries"`
+	Bytes      int64            `json:"bytes"`
+	MaxBytes   int64            `json:"maxBytes"`
+	MaxEntries int              `json:"maxEntries"`
+	Tools      []ToolCacheStats `json:"tools"`
+}
+
+// ToolCallCache 持久化的工具调用结果缓存
+//
+// 条目保存在磁盘上，多个请求和进程共享；超过容量时按最近访问时间淘汰（LRU）。
+// 命中计数先在内存中累加，定期（以及Stats和Close时）合并写入磁盘（metrics.json），
+// CLI和Web服务看到的是同一份统计。
+type ToolCallCache struct {
+	dir        string
+	maxBytes   int64
+	maxEntries int
+
+	mu sync.Mutex // 串行化本进程内的写入、淘汰和计数更新
+
+	// entries/bytes 本进程跟踪的条目数和总大小，超过容量时才扫描目录淘汰；
+	// 其他进程写入的条目在下一次扫描时计入
+	entries int
+	bytes   int64
+
+	pending   map[string]ToolCacheMetrics // 尚未写入磁盘的计数增量
+	lastFlush time.Time
+}
+
+// NewToolCallCache 创建工具调用缓存，maxBytes/maxEntries不大于0时使用默认值
+func NewToolCallCache(dir string, maxBytes int64, maxEntries int) (*ToolCallCache, error) {
+	if dir == "" {
+		dir = DefaultCacheDir
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultCacheMaxSizeMB << 20
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "entries"), 0755); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	c := &ToolCallCache{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		maxEntries: maxEntries,
+		pending:    make(map[string]ToolCacheMetrics),
+		lastFlush:  time.Now(),
+	}
+	files, err := c.scan(false)
+	if err != nil {
+		return nil, fmt.Errorf("扫描缓存失败: %w", err)
+	}
+	c.track(files)
+	return c, nil
+}
+
+// newToolCallCacheFromSettings 按配置创建缓存，配置关闭时返回nil
+func newToolCallCacheFromSettings(settings CacheSettings) (*ToolCallCache, error) {
+	if settings.Enabled != nil && !*settings.Enabled {
+		return nil, nil
+	}
+	return NewToolCallCache(os.ExpandEnv(settings.Dir), int64(settings.MaxSizeMB)<<20, settings.MaxEntries)
+}
+
+// cacheKey 生成缓存键：服务器、工具和参数（JSON序列化时键已排序）
+func cacheKey(toolCall *ToolCall) string {
+	args, _ := json.Marshal(toolCall.Arguments)
+	sum := sha256.Sum256(args)
+	return fmt.Sprintf("%s.%s:%s", toolCall.Server, toolCall.Tool, hex.EncodeToString(sum[:8]))
+}
+
+// entryPath 缓存键对应的文件
+func (c *ToolCallCache) entryPath(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, "entries", hex.EncodeToString(sum[:])+".json")
+}
+
+// Get 获取缓存结果，命中时刷新访问时间
+func (c *ToolCallCache) Get(toolCall *ToolCall) (*MCPResponse, bool) {
+	key := cacheKey(toolCall)
+	path := c.entryPath(key)
+
+	entry, err := readCacheEntry(path)
+	if err != nil || entry.Key != key {
+		c.record(toolCall.Server, toolCall.Tool, func(m *ToolCacheMetrics) { m.Misses++ })
+		return nil, false
+	}
+
+	now := time.Now()
+	if now.After(entry.ExpiresAt) {
+		c.remove(path)
+		c.record(toolCall.Server, toolCall.Tool, func(m *ToolCacheMetrics) { m.Misses++ })
+		return nil, false
+	}
+
+	os.Chtimes(path, now, now)
+	c.record(toolCall.Server, toolCall.Tool, func(m *ToolCacheMetrics) { m.Hits++ })
+	log.Printf("🎯 缓存命中: %s", key)
+	return entry.Response, true
+}
+
+// Set 写入缓存结果，随后按容量淘汰最久未访问的条目
+func (c *ToolCallCache) Set(toolCall *ToolCall, response *MCPResponse, ttl time.Duration) error {
+	if ttl <= 0 || response == nil {
+		return nil
+	}
+
+	key := cacheKey(toolCall)
+	now := time.Now()
+	data, err := json.Marshal(cacheEntry{
+		Key:       key,
+		Server:    toolCall.Server,
+		Tool:      toolCall.Tool,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Response:  response,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化缓存条目失败: %w", err)
+	}
+	if int64(len(data)) > c.maxBytes {
+		return fmt.Errorf("结果大小 %d 字节超过缓存容量", len(data))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.entryPath(key)
+	if info, err := os.Stat(path); err == nil {
+		c.entries--
+		c.bytes -= info.Size()
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("写入缓存失败: %w", err)
+	}
+	c.entries++
+	c.bytes += int64(len(data))
+	c.updateMetrics(toolCall.Server+"."+toolCall.Tool, func(m *ToolCacheMetrics) { m.Sets++ })
+	log.Printf("💾 缓存设置: %s (TTL: %v)", key, ttl)
+
+	if c.entries <= c.maxEntries && c.bytes <= c.maxBytes {
+		return nil
+	}
+	return c.evict()
+}
+
+// track 按扫描结果重置跟踪的条目数和总大小（调用方持有c.mu或尚未共享c）
+func (c *ToolCallCache) track(files []cachedFile) {
+	c.entries = len(files)
+	c.bytes = 0
+	for _, f := range files {
+		c.bytes += f.size
+	}
+}
+
+// remove 删除过期条目并更新跟踪的容量
+func (c *ToolCallCache) remove(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if os.Remove(path) == nil {
+		c.entries--
+		c.bytes -= info.Size()
+	}
+}
+
+// cachedFile 淘汰和统计时扫描到的条目文件
+type cachedFile struct {
+	path     string
+	size     int64
+	accessed time.Time
+	entry    *cacheEntry // 仅在readEntries时加载
+}
+
+// scan 列出全部条目文件，readEntries为true时同时读取内容并清理过期或损坏的条目
+func (c *ToolCallCache) scan(readEntries bool) ([]cachedFile, error) {
+	paths, err := filepath.Glob(filepath.Join(c.dir, "entries", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	files := make([]cachedFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue // 可能已被其他进程删除
+		}
+		file := cachedFile{path: path, size: info.Size(), accessed: info.ModTime()}
+		if readEntries {
+			entry, err := readCacheEntry(path)
+			if err != nil || now.After(entry.ExpiresAt) {
+				os.Remove(path)
+				continue
+			}
+			file.entry = entry
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// evict 按最近访问时间从旧到新淘汰条目，直到满足容量限制（调用方持有c.mu）
+//
+// 只在跟踪的容量超限时调用；只读取文件元数据，过期条目在读取时或统计时清理
+func (c *ToolCallCache) evict() error {
+	files, err := c.scan(false)
+	if err != nil {
+		return fmt.Errorf("扫描缓存失败: %w", err)
+	}
+	defer func() { c.track(files) }()
+
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].accessed.Before(files[j].accessed) })
+	for len(files) > 0 && (len(files) > c.maxEntries || total > c.maxBytes) {
+		oldest := files[0]
+		files = files[1:]
+		entry, _ := readCacheEntry(oldest.path)
+		if err := os.Remove(oldest.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("淘汰缓存条目失败: %w", err)
+		}
+		total -= oldest.size
+		if entry != nil {
+			c.updateMetrics(entry.Server+"."+entry.Tool, func(m *ToolCacheMetrics) { m.Evictions++ })
+			log.Printf("🧹 缓存淘汰: %s", entry.Key)
+		}
+	}
+	return nil
+}
+
+// Clear 删除缓存条目；tool为空时清除服务器的全部条目，server和tool都为空时清空缓存
+func (c *ToolCallCache) Clear(server, tool string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.scan(true)
+	if err != nil {
+		return 0, fmt.Errorf("扫描缓存失败: %w", err)
+	}
+
+	c.track(files)
+
+	removed := 0
+	for _, f := range files {
+		if (server != "" && f.entry.Server != server) || (tool != "" && f.entry.Tool != tool) {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return removed, fmt.Errorf("删除缓存条目失败: %w", err)
+		}
+		c.entries--
+		c.bytes -= f.size
+		removed++
+	}
+	return removed, nil
+}
+
+// Stats 统计缓存占用和各工具的命中情况
+func (c *ToolCallCache) Stats() (*CacheStats, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files, err := c.scan(true)
+	if err != nil {
+		return nil, fmt.Errorf("扫描缓存失败: %w", err)
+	}
+	c.track(files)
+	c.flushMetrics()
+
+	stats := &CacheStats{Dir: c.dir, MaxBytes: c.maxBytes, MaxEntries: c.maxEntries}
+	byTool := make(map[string]*ToolCacheStats)
+	toolStats := func(name string) *ToolCacheStats {
+		if s, ok := byTool[name]; ok {
+			return s
+		}
+		s := &ToolCacheStats{Tool: name}
+		byTool[name] = s
+		return s
+	}
+
+	for _, f := range files {
+		s := toolStats(f.entry.Server + "." + f.entry.Tool)
+		s.Entries++
+		s.Bytes += f.size
+		stats.Entries++
+		stats.Bytes += f.size
+	}
+	for name, m := range c.loadMetrics() {
+		s := toolStats(name)
+		s.ToolCacheMetrics = m
+		s.HitRate = m.hitRate()
+	}
+
+	for _, s := range byTool {
+		stats.Tools = append(stats.Tools, *s)
+	}
+	sort.Slice(stats.Tools, func(i, j int) bool { return stats.Tools[i].Tool < stats.Tools[j].Tool })
+	return stats, nil
+}
+
+// ResetMetrics 清零命中统计
+func (c *ToolCallCache) ResetMetrics() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = make(map[string]ToolCacheMetrics)
+	err := os.Remove(c.metricsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (c *ToolCallCache) metricsPath() string {
+	return filepath.Join(c.dir, "metrics.json")
+}
+
+// loadMetrics 读取持久化的计数，文件不存在或损坏时返回空统计
+func (c *ToolCallCache) loadMetrics() map[string]ToolCacheMetrics {
+	metrics := make(map[string]ToolCacheMetrics)
+	if data, err := os.ReadFile(c.metricsPath()); err == nil {
+		json.Unmarshal(data, &metrics)
+	}
+	return metrics
+}
+
+// record 加锁更新计数
+func (c *ToolCallCache) record(server, tool string, update func(*ToolCacheMetrics)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.updateMetrics(server+"."+tool, update)
+}
+
+// updateMetrics 在内存中累加计数，距上次写入超过metricsFlushInterval时合并到磁盘（调用方持有c.mu）
+func (c *ToolCallCache) updateMetrics(name string, update func(*ToolCacheMetrics)) {
+	m := c.pending[name]
+	update(&m)
+	c.pending[name] = m
+
+	if time.Since(c.lastFlush) >= metricsFlushInterval {
+		c.flushMetrics()
+	}
+}
+
+// flushMetrics 把内存中的计数增量读-改-写合并到计数文件（调用方持有c.mu；
+// 多进程同时写入时个别计数可能丢失，不影响缓存本身）
+func (c *ToolCallCache) flushMetrics() {
+	c.lastFlush = time.Now()
+	if len(c.pending) == 0 {
+		return
+	}
+
+	metrics := c.loadMetrics()
+	for name, delta := range c.pending {
+		m := metrics[name]
+		m.Hits += delta.Hits
+		m.Misses += delta.Misses
+		m.Sets += delta.Sets
+		m.Evictions += delta.Evictions
+		metrics[name] = m
+	}
+
+	data, err := json.MarshalIndent(metrics, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := writeFileAtomic(c.metricsPath(), data); err != nil {
+		log.Printf("⚠️ 写入缓存统计失败: %v", err)
+		return
+	}
+	c.pending = make(map[string]ToolCacheMetrics)
+}
+
+// Close 把尚未写入的命中计数写入磁盘
+func (c *ToolCallCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushMetrics()
+	return nil
+}
+
+// readCacheEntry 读取条目文件
+func readCacheEntry(path string) (*cacheEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Response == nil {
+		return nil, fmt.Errorf("缓存条目缺少响应")
+	}
+	return &entry, nil
+}
+
+// writeFileAtomic 先写临时文件再重命名，避免其他进程读到半写入的内容
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), ".json")+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// isErrorResult 工具结果是否带有isError标记
+func isErrorResult(response *MCPResponse) bool {
+	var result struct {
+		IsError bool `json:"isError"`
+	}
+	return response != nil && json.Unmarshal(response.Result, &result) == nil && result.IsError
+}
+
+// Cache 返回按globalSettings.cache创建的共享缓存，关闭或创建失败时返回nil
+func (m *MCPManager) Cache() *ToolCallCache {
+	m.cacheOnce.Do(func() {
+		m.mu.RLock()
+		settings := m.config.GlobalSettings.Cache
+		m.mu.RUnlock()
+
+		cache, err := newToolCallCacheFromSettings(settings)
+		if err != nil {
+			log.Printf("⚠️ 工具结果缓存不可用: %v", err)
+			return
+		}
+		m.cache = cache
+	})
+	return m.cache
+}
+
+// CacheTTL 返回工具结果的缓存时间，未在服务器的cacheTTL中配置时不缓存
+func (m *MCPManager) CacheTTL(serverName, toolName string) time.Duration {
+	m.mu.RLock()
+	config, ok := m.config.MCPServers[serverName]
+	m.mu.RUnlock()
+	if !ok {
+		return 0
+	}
+
+	seconds, _ := lookupToolPattern(config.CacheTTL, toolName)
+	return time.Duration(seconds) * time.Second
+}
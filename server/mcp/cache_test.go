@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestToolCallCachePersistsAcrossInstances(t *testing.T) {
+	dir := t.TempDir()
+	call := &ToolCall{Server: "article-mcp", Tool: "search_europe_pmc", Arguments: map[string]interface{}{"keyword": "bert"}}
+	response := &MCPResponse{JSONRPC: "2.0", ID: 1, Result: json.RawMessage(`{"content":[]}`)}
+
+	first, err := NewToolCallCache(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := first.Get(call); ok {
+		t.Fatal("空缓存不应命中")
+	}
+	if err := first.Set(call, response, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 另一个实例（模拟另一个请求或进程）读取同一目录
+	second, _ := NewToolCallCache(dir, 0, 0)
+	got, ok := second.Get(call)
+	if !ok || string(got.Result) != `{"content":[]}` {
+		t.Fatalf("Get() = %+v, %v", got, ok)
+	}
+
+	// 参数不同视为不同条目
+	other := &ToolCall{Server: call.Server, Tool: call.Tool, Arguments: map[string]interface{}{"keyword": "gpt"}}
+	if _, ok := second.Get(other); ok {
+		t.Error("不同参数不应命中")
+	}
+
+	stats, err := second.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Entries != 1 || len(stats.Tools) != 1 {
+		t.Fatalf("Stats() = %+v", stats)
+	}
+	if tool := stats.Tools[0]; tool.Hits != 1 || tool.Misses != 2 || tool.Sets != 1 || tool.HitRate != 1.0/3 {
+		t.Errorf("工具统计 = %+v", tool)
+	}
+}
+
+func TestToolCallCacheBuffersMetrics(t *testing.T) {
+	cache, err := NewToolCallCache(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := &ToolCall{Server: "s", Tool: "search"}
+	cache.Set(call, &MCPResponse{Result: json.RawMessage(`"ok"`)}, time.Hour)
+	for i := 0; i < 3; i++ {
+		cache.Get(call)
+	}
+
+	// 查询只更新内存中的计数，不应每次改写metrics.json
+	if _, err := os.Stat(cache.metricsPath()); !os.IsNotExist(err) {
+		t.Fatalf("刷新前不应写入计数文件: %v", err)
+	}
+	if cache.entries != 1 || cache.bytes == 0 {
+		t.Errorf("跟踪容量 = %d 条, %d 字节", cache.entries, cache.bytes)
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+	metrics := cache.loadMetrics()["s.search"]
+	if metrics.Hits != 3 || metrics.Sets != 1 {
+		t.Errorf("Close后的计数 = %+v", metrics)
+	}
+}
+
+func TestToolCallCacheExpiresAndEvicts(t *testing.T) {
+	cache, err := NewToolCallCache(t.TempDir(), 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := &MCPResponse{Result: json.RawMessage(`"ok"`)}
+	call := func(keyword string) *ToolCall {
+		return &ToolCall{Server: "s", Tool: "search", Arguments: map[string]interface{}{"keyword": keyword}}
+	}
+
+	cache.Set(call("expired"), response, time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	if _, ok := cache.Get(call("expired")); ok {
+		t.Error("过期条目不应命中")
+	}
+
+	// 依次写入a、b，访问a后写入c，应淘汰最久未访问的b
+	past := time.Now().Add(-time.Hour)
+	cache.Set(call("a"), response, time.Hour)
+	os.Chtimes(cache.entryPath(cacheKey(call("a"))), past, past)
+	cache.Set(call("b"), response, time.Hour)
+	os.Chtimes(cache.entryPath(cacheKey(call("b"))), past.Add(time.Minute), past.Add(time.Minute))
+	if _, ok := cache.Get(call("a")); !ok {
+		t.Fatal("a应命中")
+	}
+	cache.Set(call("c"), response, time.Hour)
+
+	if _, ok := cache.Get(call("b")); ok {
+		t.Error("b应被淘汰")
+	}
+	for _, keyword := range []string{"a", "c"} {
+		if _, ok := cache.Get(call(keyword)); !ok {
+			t.Errorf("%s应保留", keyword)
+		}
+	}
+	entries, _ := filepath.Glob(filepath.Join(cache.dir, "entries", "*.json"))
+	if len(entries) != 2 {
+		t.Errorf("条目数 = %d, want 2", len(entries))
+	}
+}
+
+func TestToolCallCacheClearByTool(t *testing.T) {
+	cache, _ := NewToolCallCache(t.TempDir(), 0, 0)
+	response := &MCPResponse{Result: json.RawMessage(`"ok"`)}
+	cache.Set(&ToolCall{Server: "article-mcp", Tool: "search_europe_pmc"}, response, time.Hour)
+	cache.Set(&ToolCall{Server: "article-mcp", Tool: "get_article_details"}, response, time.Hour)
+
+	removed, err := cache.Clear("", "search_europe_pmc")
+	if err != nil || removed != 1 {
+		t.Fatalf("Clear() = %d, %v", removed, err)
+	}
+	if _, ok := cache.Get(&ToolCall{Server: "article-mcp", Tool: "get_article_details"}); !ok {
+		t.Error("其他工具的缓存不应被清除")
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	manager := &MCPManager{config: &MCPConfig{MCPServers: map[string]MCPServerConfig{
+		"article-mcp": {CacheTTL: map[string]int{"search_*": 300, "search_arxiv_papers": 60}},
+	}}}
+
+	tests := []struct {
+		server, tool string
+		want         time.Duration
+	}{
+		{"article-mcp", "search_europe_pmc", 5 * time.Minute},
+		{"article-mcp", "search_arxiv_papers", time.Minute},
+		{"article-mcp", "get_article_details", 0},
+		{"unknown", "search_europe_pmc", 0},
+	}
+	for _, tt := range tests {
+		if got := manager.CacheTTL(tt.server, tt.tool); got != tt.want {
+			t.Errorf("CacheTTL(%s, %s) = %v, want %v", tt.server, tt.tool, got, tt.want)
+		}
+	}
+}
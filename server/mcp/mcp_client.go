@@ -0,0 +1,531 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MCP协议版本
+const protocolVersion = "2024-11-05"
+
+// ErrClientClosed 客户端连接已关闭
+var ErrClientClosed = errors.New("MCP客户端连接已关闭")
+
+// NotificationHandler 服务器通知处理函数（在读取协程中调用，应尽快返回）
+type NotificationHandler func(method string, params json.RawMessage)
+
+// ProgressFunc 工具调用进度回调
+type ProgressFunc func(progress, total float64, message string)
+
+// MCPClient MCP客户端
+// 传输层收到的消息统一按请求ID分发，允许同一服务器上并发执行多个调用
+type MCPClient struct {
+	config    MCPServerConfig
+	transport transport
+	cmd       *exec.Cmd
+	stderr    io.Reader
+	process   *os.Process
+	timeout   time.Duration
+
+	nextID atomic.Int64
+
+	mu           sync.Mutex // 保护以下字段
+	active       bool
+	pending      map[int64]*pendingCall
+	handlers     map[string][]NotificationHandler
+	capabilities json.RawMessage
+	tools        map[string]ToolInfo // tools/list缓存，服务器通知列表变化时清空
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// pendingCall 等待响应的请求
+type pendingCall struct {
+	method   string
+	ch       chan *MCPResponse
+	progress ProgressFunc
+}
+
+// rpcMessage 通用JSON-RPC消息（响应、通知或服务器发起的请求）
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *MCPError       `json:"error,omitempty"`
+}
+
+// newMCPClient 基于传输层创建客户端并开始接收消息
+func newMCPClient(config MCPServerConfig, t transport) (*MCPClient, error) {
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	client := &MCPClient{
+		config:    config,
+		transport: t,
+		timeout:   timeout,
+		active:    true,
+		pending:   make(map[int64]*pendingCall),
+		handlers:  make(map[string][]NotificationHandler),
+		done:      make(chan struct{}),
+	}
+
+	if err := t.start(client.dispatch, client.markClosed); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// dispatch 分发一条消息（由传输层调用）
+func (c *MCPClient) dispatch(line []byte) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+	if line[0] == '[' {
+		// JSON-RPC批量消息
+		var batch []json.RawMessage
+		if err := json.Unmarshal(line, &batch); err != nil {
+			log.Printf("JSON解析错误: %v, 原始行: %s", err, string(line))
+			return
+		}
+		for _, item := range batch {
+			c.dispatch(item)
+		}
+		return
+	}
+	if line[0] != '{' {
+		// 部分服务器会向stdout输出非协议内容
+		log.Printf("MCP非JSON输出: %s", string(line))
+		return
+	}
+
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		log.Printf("JSON解析错误: %v, 原始行: %s", err, string(line))
+		return
+	}
+
+	hasID := len(msg.ID) > 0 && string(msg.ID) != "null"
+	switch {
+	case msg.Method != "" && hasID:
+		c.handleServerRequest(&msg)
+	case msg.Method != "":
+		c.handleNotification(msg.Method, msg.Params)
+	case hasID:
+		c.handleResponse(&msg)
+	default:
+		log.Printf("⚠️ [MCP读取] 无法识别的消息: %s", string(line))
+	}
+}
+
+// handleResponse 将响应投递给对应的等待者
+func (c *MCPClient) handleResponse(msg *rpcMessage) {
+	id, err := parseRequestID(msg.ID)
+	if err != nil {
+		log.Printf("⚠️ [MCP读取] 无效的响应ID %s: %v", string(msg.ID), err)
+		return
+	}
+
+	c.mu.Lock()
+	call, exists := c.pending[id]
+	if exists {
+		delete(c.pending, id)
+	}
+	c.mu.Unlock()
+
+	if !exists {
+		// 已超时或已取消的请求，丢弃迟到的响应
+		log.Printf("⚠️ [MCP读取] 丢弃未知请求的响应 (ID: %d)", id)
+		return
+	}
+
+	call.ch <- &MCPResponse{
+		JSONRPC: msg.JSONRPC,
+		ID:      int(id),
+		Result:  msg.Result,
+		Error:   msg.Error,
+	}
+}
+
+// handleNotification 处理服务器通知
+func (c *MCPClient) handleNotification(method string, params json.RawMessage) {
+	switch method {
+	case "notifications/progress":
+		c.handleProgress(params)
+	case "notifications/tools/list_changed":
+		c.mu.Lock()
+		c.tools = nil
+		c.mu.Unlock()
+	case "notifications/message":
+		var logMsg struct {
+			Level  string          `json:"level"`
+			Logger string          `json:"logger"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(params, &logMsg); err == nil {
+			log.Printf("📝 [MCP日志] [%s] %s %s", logMsg.Level, logMsg.Logger, string(logMsg.Data))
+		}
+	}
+
+	c.mu.Lock()
+	handlers := append([]NotificationHandler(nil), c.handlers[method]...)
+	handlers = append(handlers, c.handlers["*"]...)
+	c.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(method, params)
+	}
+}
+
+// handleProgress 将进度通知路由到发起请求的调用方
+func (c *MCPClient) handleProgress(params json.RawMessage) {
+	var progress struct {
+		ProgressToken json.RawMessage `json:"progressToken"`
+		Progress      float64         `json:"progress"`
+		Total         float64         `json:"total"`
+		Message       string          `json:"message"`
+	}
+	if err := json.Unmarshal(params, &progress); err != nil {
+		return
+	}
+
+	id, err := parseRequestID(progress.ProgressToken)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	call, exists := c.pending[id]
+	c.mu.Unlock()
+
+	if exists && call.progress != nil {
+		call.progress(progress.Progress, progress.Total, progress.Message)
+	}
+}
+
+// handleServerRequest 响应服务器发起的请求
+func (c *MCPClient) handleServerRequest(msg *rpcMessage) {
+	reply := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      msg.ID,
+	}
+
+	switch msg.Method {
+	case "ping":
+		reply["result"] = map[string]interface{}{}
+	default:
+		reply["error"] = map[string]interface{}{
+			"code":    -32601,
+			"message": "Method not found: " + msg.Method,
+		}
+	}
+
+	if err := c.send(context.Background(), reply); err != nil {
+		log.Printf("⚠️ [MCP读取] 回复服务器请求 %s 失败: %v", msg.Method, err)
+	}
+}
+
+// markClosed 标记连接关闭，唤醒所有等待中的调用
+func (c *MCPClient) markClosed() {
+	c.closeOnce.Do(func() {
+		c.mu.Lock()
+		c.active = false
+		c.pending = make(map[int64]*pendingCall)
+		c.mu.Unlock()
+		close(c.done)
+	})
+}
+
+// OnNotification 注册通知处理函数，method为"*"时接收所有通知
+func (c *MCPClient) OnNotification(method string, handler NotificationHandler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[method] = append(c.handlers[method], handler)
+}
+
+// Call 发送JSON-RPC请求并等待对应ID的响应
+func (c *MCPClient) Call(ctx context.Context, method string, params map[string]interface{}) (*MCPResponse, error) {
+	return c.call(ctx, method, params, nil)
+}
+
+// call 发送请求；progress不为空时附带progressToken以接收进度通知
+func (c *MCPClient) call(ctx context.Context, method string, params map[string]interface{}, progress ProgressFunc) (*MCPResponse, error) {
+	if !c.IsActive() {
+		return nil, ErrClientClosed
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	id := c.nextID.Add(1)
+	if progress != nil {
+		if params == nil {
+			params = map[string]interface{}{}
+		}
+		params["_meta"] = map[string]interface{}{"progressToken": id}
+	}
+
+	call := &pendingCall{
+		method:   method,
+		ch:       make(chan *MCPResponse, 1),
+		progress: progress,
+	}
+
+	c.mu.Lock()
+	c.pending[id] = call
+	c.mu.Unlock()
+
+	req := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  method,
+	}
+	if params != nil {
+		req["params"] = params
+	}
+
+	if err := c.send(ctx, req); err != nil {
+		c.removePending(id)
+		return nil, err
+	}
+
+	select {
+	case response := <-call.ch:
+		return response, nil
+	case <-c.done:
+		return nil, ErrClientClosed
+	case <-ctx.Done():
+		c.removePending(id)
+		c.cancelRequest(id, ctx.Err())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("等待 %s 响应超时 (ID: %d): %w", method, id, ctx.Err())
+		}
+		return nil, fmt.Errorf("请求 %s 已取消 (ID: %d): %w", method, id, ctx.Err())
+	}
+}
+
+// removePending 移除等待中的请求
+func (c *MCPClient) removePending(id int64) {
+	c.mu.Lock()
+	delete(c.pending, id)
+	c.mu.Unlock()
+}
+
+// cancelRequest 通知服务器放弃处理指定请求
+func (c *MCPClient) cancelRequest(id int64, reason error) {
+	params := map[string]interface{}{"requestId": id}
+	if reason != nil {
+		params["reason"] = reason.Error()
+	}
+	if err := c.Notify("notifications/cancelled", params); err != nil {
+		log.Printf("⚠️ [MCP取消] 发送取消通知失败 (ID: %d): %v", id, err)
+	}
+}
+
+// Notify 发送通知（无需响应）
+func (c *MCPClient) Notify(method string, params map[string]interface{}) error {
+	notif := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+	}
+	if params != nil {
+		notif["params"] = params
+	}
+	return c.send(context.Background(), notif)
+}
+
+// send 序列化并通过传输层发送一条消息
+func (c *MCPClient) send(ctx context.Context, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	log.Printf("📤 发送JSON: %s", string(data))
+
+	if err := c.transport.send(ctx, data); err != nil {
+		return fmt.Errorf("发送数据失败: %w", err)
+	}
+	return nil
+}
+
+// initialize 初始化MCP连接
+func (c *MCPClient) initialize(ctx context.Context) error {
+	response, err := c.Call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": protocolVersion,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo": map[string]interface{}{
+			"name":    "zoteroflow2",
+			"version": "1.0.0",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("未收到初始化响应: %w", err)
+	}
+
+	if response.Error != nil {
+		return fmt.Errorf("初始化失败: %s", response.Error.Message)
+	}
+
+	var result struct {
+		Capabilities json.RawMessage `json:"capabilities"`
+	}
+	if err := json.Unmarshal(response.Result, &result); err == nil {
+		c.mu.Lock()
+		c.capabilities = result.Capabilities
+		c.mu.Unlock()
+	}
+
+	if err := c.Notify("notifications/initialized", nil); err != nil {
+		return err
+	}
+
+	// 部分传输层（如Streamable HTTP）在会话建立后开启服务器推送通道
+	if hook, ok := c.transport.(initializedHook); ok {
+		hook.initialized()
+	}
+	return nil
+}
+
+// CallTool 调用工具
+func (c *MCPClient) CallTool(toolName string, arguments map[string]interface{}) (*MCPResponse, error) {
+	return c.CallToolContext(context.Background(), toolName, arguments, nil)
+}
+
+// CallToolContext 调用工具，ctx取消时通知服务器放弃该请求
+func (c *MCPClient) CallToolContext(ctx context.Context, toolName string, arguments map[string]interface{}, progress ProgressFunc) (*MCPResponse, error) {
+	if !c.IsActive() {
+		return nil, fmt.Errorf("MCP客户端未激活")
+	}
+
+	// 记录详细的调用信息
+	log.Printf("🔧 [MCP调用] 开始调用工具: %s", toolName)
+	log.Printf("📥 [MCP输入] 工具参数: %s", formatJSON(arguments))
+	log.Printf("⏳ [MCP等待] 等待工具响应，超时时间: %v", c.timeout)
+
+	response, err := c.call(ctx, "tools/call", map[string]interface{}{
+		"name":      toolName,
+		"arguments": arguments,
+	}, progress)
+	if err != nil {
+		log.Printf("❌ [MCP错误] 工具调用失败: %v", err)
+		return nil, fmt.Errorf("工具调用失败: %w", err)
+	}
+
+	if response.Error != nil {
+		log.Printf("❌ [MCP错误] 服务器返回错误:\n%s", formatJSON(response.Error))
+		return nil, fmt.Errorf("MCP错误: %s", response.Error.Message)
+	}
+
+	// 记录成功信息
+	if response.Result != nil {
+		log.Printf("✅ [MCP成功] 工具调用成功，响应大小: %d 字节 (ID: %d)", len(response.Result), response.ID)
+
+		// 尝试解析并记录关键结果信息
+		if resultMap, ok := parseJSONToMap(response.Result); ok {
+			if content, exists := resultMap["content"]; exists {
+				if contentArray, ok := content.([]interface{}); ok && len(contentArray) > 0 {
+					if firstItem, ok := contentArray[0].(map[string]interface{}); ok {
+						if text, ok := firstItem["text"].(string); ok {
+							// 限制显示长度，避免日志过长
+							displayText := text
+							if len(displayText) > 500 {
+								displayText = displayText[:500] + "..."
+							}
+							log.Printf("📄 [MCP结果] 内容摘要: %s", displayText)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return response, nil
+}
+
+// PendingCalls 返回正在等待响应的请求数
+func (c *MCPClient) PendingCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.pending)
+}
+
+// Close 关闭客户端
+func (c *MCPClient) Close() error {
+	c.mu.Lock()
+	c.active = false
+	c.mu.Unlock()
+
+	if c.transport != nil {
+		c.transport.close()
+	}
+
+	if c.cmd != nil && c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	}
+
+	c.markClosed()
+	return nil
+}
+
+// Done 返回连接关闭时关闭的通道
+func (c *MCPClient) Done() <-chan struct{} {
+	return c.done
+}
+
+// PID 返回服务器进程ID（非子进程时为0）
+func (c *MCPClient) PID() int {
+	if c.process == nil {
+		return 0
+	}
+	return c.process.Pid
+}
+
+// ExitStatus 描述服务器进程的退出状态
+func (c *MCPClient) ExitStatus() string {
+	if c.cmd == nil || c.cmd.ProcessState == nil {
+		return "连接已关闭"
+	}
+	return c.cmd.ProcessState.String()
+}
+
+// IsActive 检查客户端是否活跃
+func (c *MCPClient) IsActive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// parseRequestID 解析数字或字符串形式的请求ID
+func parseRequestID(raw json.RawMessage) (int64, error) {
+	var num json.Number
+	if err := json.Unmarshal(raw, &num); err == nil {
+		return num.Int64()
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(str, 10, 64)
+}
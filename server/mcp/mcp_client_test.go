@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer 基于管道的MCP服务器桩，按需控制响应顺序
+type fakeServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	writeMu  sync.Mutex
+	received chan rpcMessage
+}
+
+func newFakeServerPair(t *testing.T) (*MCPClient, *fakeServer) {
+	t.Helper()
+
+	clientToServerR, clientToServerW := io.Pipe()
+	serverToClientR, serverToClientW := io.Pipe()
+
+	server := &fakeServer{
+		in:       bufio.NewReader(clientToServerR),
+		out:      serverToClientW,
+		received: make(chan rpcMessage, 16),
+	}
+	go func() {
+		for {
+			line, err := server.in.ReadBytes('\n')
+			if err != nil {
+				return
+			}
+			var msg rpcMessage
+			if json.Unmarshal(line, &msg) == nil {
+				server.received <- msg
+			}
+		}
+	}()
+
+	client, err := newMCPClient(MCPServerConfig{Timeout: 5}, newStdioTransport(clientToServerW, serverToClientR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		serverToClientW.Close()
+	})
+	return client, server
+}
+
+func (s *fakeServer) write(t *testing.T, msg map[string]interface{}) {
+	t.Helper()
+	data, _ := json.Marshal(msg)
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.out.Write(append(data, '\n')); err != nil {
+		t.Errorf("写入响应失败: %v", err)
+	}
+}
+
+func (s *fakeServer) next(t *testing.T) rpcMessage {
+	t.Helper()
+	select {
+	case msg := <-s.received:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("等待客户端消息超时")
+		return rpcMessage{}
+	}
+}
+
+func TestMCPClientDemultiplexesByID(t *testing.T) {
+	client, server := newFakeServerPair(t)
+
+	type result struct {
+		tool string
+		text string
+	}
+	results := make(chan result, 2)
+	for _, tool := range []string{"first", "second"} {
+		go func(tool string) {
+			resp, err := client.CallToolContext(context.Background(), tool, nil, nil)
+			if err != nil {
+				t.Errorf("调用 %s 失败: %v", tool, err)
+				results <- result{tool: tool}
+				return
+			}
+			var payload struct {
+				Tool string `json:"tool"`
+			}
+			json.Unmarshal(resp.Result, &payload)
+			results <- result{tool: tool, text: payload.Tool}
+		}(tool)
+	}
+
+	reqA := server.next(t)
+	reqB := server.next(t)
+
+	// 先发送通知，再以相反顺序返回响应
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "method": "notifications/message", "params": map[string]interface{}{"level": "info", "data": "hello"}})
+	for _, req := range []rpcMessage{reqB, reqA} {
+		var params struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(req.Params, &params)
+		server.write(t, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{"tool": params.Name}})
+	}
+
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.tool != r.text {
+			t.Errorf("调用 %s 收到了 %s 的响应", r.tool, r.text)
+		}
+	}
+}
+
+func TestMCPClientProgressAndCancellation(t *testing.T) {
+	client, server := newFakeServerPair(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	progressCh := make(chan float64, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := client.CallToolContext(ctx, "slow", nil, func(progress, total float64, message string) {
+			progressCh <- progress
+		})
+		errCh <- err
+	}()
+
+	req := server.next(t)
+	var params struct {
+		Meta struct {
+			ProgressToken json.RawMessage `json:"progressToken"`
+		} `json:"_meta"`
+	}
+	json.Unmarshal(req.Params, &params)
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "method": "notifications/progress", "params": map[string]interface{}{"progressToken": params.Meta.ProgressToken, "progress": 0.5, "total": 1}})
+
+	select {
+	case p := <-progressCh:
+		if p != 0.5 {
+			t.Errorf("进度 = %v, want 0.5", p)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("未收到进度回调")
+	}
+
+	cancel()
+	if err := <-errCh; err == nil {
+		t.Fatal("取消后调用应返回错误")
+	}
+
+	cancelled := server.next(t)
+	if cancelled.Method != "notifications/cancelled" {
+		t.Fatalf("期望 notifications/cancelled，收到 %s", cancelled.Method)
+	}
+
+	// 迟到的响应不应影响后续调用
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{}})
+	if n := client.PendingCalls(); n != 0 {
+		t.Errorf("PendingCalls() = %d, want 0", n)
+	}
+}
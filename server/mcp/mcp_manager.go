@@ -1,13 +1,13 @@
 package mcp
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -22,6 +22,23 @@ type MCPServerConfig struct {
 	RetryAttempts int      `json:"retryAttempts"`
 	Description   string   `json:"description"`
 	Tools         []string `json:"tools"`
+
+	// 进程环境：Env的值支持${VAR}引用当前环境变量，Cwd为工作目录
+	Env map[string]string `json:"env,omitempty"`
+	Cwd string            `json:"cwd,omitempty"`
+
+	// 远程服务器：Transport为http(Streamable HTTP)或sse(旧版HTTP+SSE)，默认stdio；
+	// Headers和BearerToken的值同样支持${VAR}
+	Transport   string            `json:"transport,omitempty"`
+	URL         string            `json:"url,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	BearerToken string            `json:"bearerToken,omitempty"`
+
+	// Policy 工具允许/禁止列表、频率限制和调用前确认
+	Policy *ToolPolicy `json:"policy,omitempty"`
+
+	// CacheTTL 按工具名（支持通配符）配置结果缓存秒数，未配置的工具不缓存
+	CacheTTL map[string]int `json:"cacheTTL,omitempty"`
 }
 
 // MCPConfig MCP配置文件
@@ -32,37 +49,37 @@ type MCPConfig struct {
 		MaxRetryAttempts int    `json:"maxRetryAttempts"`
 		EnableLogging    bool   `json:"enableLogging"`
 		LogLevel         string `json:"logLevel"`
+		// 健康检查间隔（秒）
+		HealthCheckInterval int `json:"healthCheckInterval"`
+		// 每个服务器保留的stderr行数
+		StderrBufferLines int `json:"stderrBufferLines"`
+		// 工具结果磁盘缓存
+		Cache CacheSettings `json:"cache"`
 	} `json:"globalSettings"`
 }
 
+// DefaultConfigFile 默认MCP配置文件路径
+const DefaultConfigFile = "mcp/mcp_config.json"
+
 // MCPManager MCP管理器
 type MCPManager struct {
-	config     *MCPConfig
-	clients    map[string]*MCPClient
-	configFile string
-	mu         sync.RWMutex
-}
-
-// MCPClient MCP客户端
-type MCPClient struct {
-	config  MCPServerConfig
-	cmd     *exec.Cmd
-	stdin   io.WriteCloser
-	stdout  io.Reader
-	stderr  io.Reader
-	scanner *bufio.Scanner
-	process *os.Process
-	active  bool
-	timeout time.Duration
-	nextID  int
-	mu      sync.Mutex
+	config      *MCPConfig
+	clients     map[string]*MCPClient
+	supervisors map[string]*serverSupervisor
+	configFile  string
+	limiter     *rateLimiter
+	cache       *ToolCallCache
+	cacheOnce   sync.Once
+	mu          sync.RWMutex
 }
 
 // NewMCPManager 创建MCP管理器
 func NewMCPManager(configFile string) (*MCPManager, error) {
 	manager := &MCPManager{
-		clients:    make(map[string]*MCPClient),
-		configFile: configFile,
+		clients:     make(map[string]*MCPClient),
+		supervisors: make(map[string]*serverSupervisor),
+		configFile:  configFile,
+		limiter:     newRateLimiter(),
 	}
 
 	// 加载配置
@@ -92,162 +109,248 @@ func (m *MCPManager) loadConfig() error {
 	return nil
 }
 
-// StartServer 启动指定的MCP服务器
+// StartServer 启动指定的MCP服务器，并由监督器负责健康检查和崩溃重启
 func (m *MCPManager) StartServer(name string) error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	supervisor, exists := m.supervisors[name]
+	if !exists {
+		// 检查配置
+		serverConfig, ok := m.config.MCPServers[name]
+		if !ok {
+			m.mu.Unlock()
+			return fmt.Errorf("未找到MCP服务器配置: %s", name)
+		}
 
-	// 检查是否已经存在
-	if client, exists := m.clients[name]; exists {
-		if client.active {
-			log.Printf("MCP服务器 %s 已经在运行，复用现有连接", name)
-			return nil
+		if !serverConfig.Enabled {
+			m.mu.Unlock()
+			return fmt.Errorf("MCP服务器 %s 已禁用", name)
 		}
-		client.Close()
-		delete(m.clients, name)
+
+		supervisor = newServerSupervisor(m, name, serverConfig)
+		m.supervisors[name] = supervisor
 	}
+	m.mu.Unlock()
 
-	// 检查配置
-	serverConfig, exists := m.config.MCPServers[name]
-	if !exists {
-		return fmt.Errorf("未找到MCP服务器配置: %s", name)
+	if err := supervisor.ensureRunning(); err != nil {
+		return fmt.Errorf("创建MCP客户端失败: %w", err)
+	}
+	return nil
+}
+
+// createClient 按配置的传输方式创建MCP客户端
+func (m *MCPManager) createClient(name string, config MCPServerConfig, stderrBuf *ringBuffer) (*MCPClient, error) {
+	switch kind := transportKind(config); kind {
+	case TransportStdio:
+		return m.createStdioClient(name, config, stderrBuf)
+	case TransportHTTP, TransportSSE:
+		return m.createRemoteClient(name, kind, config)
+	default:
+		return nil, fmt.Errorf("不支持的MCP传输方式: %s", config.Transport)
 	}
+}
 
-	if !serverConfig.Enabled {
-		return fmt.Errorf("MCP服务器 %s 已禁用", name)
+// createRemoteClient 连接远程HTTP MCP服务器
+func (m *MCPManager) createRemoteClient(name, kind string, config MCPServerConfig) (*MCPClient, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("MCP服务器 %s 未配置url", name)
 	}
+	config.URL = os.ExpandEnv(config.URL)
 
-	// 创建客户端
-	client, err := m.createClient(name, serverConfig)
-	if err != nil {
-		return fmt.Errorf("创建MCP客户端失败: %w", err)
+	var t transport
+	if kind == TransportSSE {
+		t = newSSETransport(config)
+	} else {
+		t = newStreamableHTTPTransport(config)
 	}
 
-	m.clients[name] = client
-	return nil
-}
+	client, err := newMCPClient(config, t)
+	if err != nil {
+		t.close()
+		return nil, fmt.Errorf("连接MCP服务器失败: %w", err)
+	}
 
-// createClient 创建MCP客户端
-func (m *MCPManager) createClient(name string, config MCPServerConfig) (*MCPClient, error) {
-	client := &MCPClient{
-		config:  config,
-		timeout: time.Duration(config.Timeout) * time.Second,
-		nextID:  1,
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	if err := client.initialize(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("初始化MCP连接失败: %w", err)
 	}
 
+	log.Printf("✅ MCP服务器 %s 已连接 (%s: %s)", name, kind, config.URL)
+	return client, nil
+}
+
+// createStdioClient 启动本地子进程MCP服务器
+func (m *MCPManager) createStdioClient(name string, config MCPServerConfig, stderrBuf *ringBuffer) (*MCPClient, error) {
 	// 构建命令
 	args := append(config.Args, []string{}...)
 	cmd := exec.Command(config.Command, args...)
+	cmd.Env = buildServerEnv(config.Env)
+	if config.Cwd != "" {
+		cmd.Dir = os.ExpandEnv(config.Cwd)
+	}
 
 	// 创建管道
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return nil, fmt.Errorf("创建stdin管道失败: %w", err)
 	}
-	client.stdin = stdin
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		stdin.Close()
 		return nil, fmt.Errorf("创建stdout管道失败: %w", err)
 	}
-	client.stdout = stdout
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		stdin.Close()
 		return nil, fmt.Errorf("创建stderr管道失败: %w", err)
 	}
-	client.stderr = stderr
 
 	// 启动进程
 	if err := cmd.Start(); err != nil {
 		stdin.Close()
 		return nil, fmt.Errorf("启动MCP服务器失败: %w", err)
 	}
-	client.cmd = cmd
-	client.process = cmd.Process
 
-	// 等待服务器启动
-	time.Sleep(2 * time.Second)
-	client.active = true
-	client.scanner = bufio.NewScanner(client.stdout)
+	// 持续读取stderr，避免管道写满阻塞服务器，同时保留最近输出供排查
+	go stderrBuf.capture(name, stderr, m.config.GlobalSettings.LogLevel == "debug")
 
-	// 设置更大的缓冲区以处理大型JSON响应
-	buf := make([]byte, 0, 64*1024)
-	client.scanner.Buffer(buf, 1024*1024)
+	client, err := newMCPClient(config, newStdioTransport(stdin, stdout))
+	if err != nil {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+		return nil, err
+	}
+	client.cmd = cmd
+	client.process = cmd.Process
+	client.stderr = stderr
 
-	// 初始化MCP连接
-	if err := client.initialize(); err != nil {
+	// 初始化MCP连接（等待initialize响应即可确认服务器就绪，无需固定休眠）
+	ctx, cancel := context.WithTimeout(context.Background(), client.timeout)
+	defer cancel()
+	if err := client.initialize(ctx); err != nil {
 		client.Close()
+		if tail := stderrBuf.Tail(5); len(tail) > 0 {
+			return nil, fmt.Errorf("初始化MCP连接失败: %w (stderr: %s)", err, strings.Join(tail, " | "))
+		}
 		return nil, fmt.Errorf("初始化MCP连接失败: %w", err)
 	}
 
+	log.Printf("✅ MCP服务器 %s 已连接 (PID: %d)", name, cmd.Process.Pid)
 	return client, nil
 }
 
-// initialize 初始化MCP连接
-func (c *MCPClient) initialize() error {
-	// 发送初始化请求（使用map确保格式一致）
-	initReq := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      1,
-		"method":  "initialize",
-		"params": map[string]interface{}{
-			"protocolVersion": "2024-11-05",
-			"capabilities": map[string]interface{}{
-				"tools": map[string]interface{}{},
-			},
-			"clientInfo": map[string]interface{}{
-				"name":    "zoteroflow2",
-				"version": "1.0.0",
-			},
-		},
-	}
-
-	if err := c.sendRequest(initReq); err != nil {
-		return fmt.Errorf("发送初始化请求失败: %w", err)
-	}
-
-	// 等待并读取初始化响应
-	for i := 0; i < 5; i++ {
-		response := c.readResponseWithTimeout(5 * time.Second)
-		if response == nil {
-			if i == 0 {
-				return fmt.Errorf("未收到初始化响应")
-			}
+// buildServerEnv 在当前进程环境基础上叠加服务器配置的环境变量
+func buildServerEnv(extra map[string]string) []string {
+	env := os.Environ()
+	for key, value := range extra {
+		env = append(env, key+"="+os.ExpandEnv(value))
+	}
+	return env
+}
+
+// setClient 登记可用的客户端连接
+func (m *MCPManager) setClient(name string, client *MCPClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[name] = client
+}
+
+// removeClient 移除指定的客户端连接（仅当仍是同一连接时）
+func (m *MCPManager) removeClient(name string, client *MCPClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.clients[name] == client {
+		delete(m.clients, name)
+	}
+}
+
+// healthCheckInterval 健康检查间隔
+func (m *MCPManager) healthCheckInterval() time.Duration {
+	if seconds := m.config.GlobalSettings.HealthCheckInterval; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultHealthCheckInterval
+}
+
+// retryAttempts 服务器崩溃后的最大重启次数
+func (m *MCPManager) retryAttempts(config MCPServerConfig) int {
+	if config.RetryAttempts > 0 {
+		return config.RetryAttempts
+	}
+	if m.config.GlobalSettings.MaxRetryAttempts > 0 {
+		return m.config.GlobalSettings.MaxRetryAttempts
+	}
+	return 3
+}
+
+// Status 返回所有已配置服务器的运行状态
+func (m *MCPManager) Status() []ServerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.config.MCPServers))
+	for name := range m.config.MCPServers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	statuses := make([]ServerStatus, 0, len(names))
+	for _, name := range names {
+		if supervisor, exists := m.supervisors[name]; exists {
+			statuses = append(statuses, supervisor.status(20))
 			continue
 		}
 
-		if response.Error != nil {
-			return fmt.Errorf("初始化失败: %s", response.Error.Message)
-		}
+		config := m.config.MCPServers[name]
+		statuses = append(statuses, ServerStatus{
+			Name:        name,
+			Description: config.Description,
+			Transport:   transportKind(config),
+			URL:         config.URL,
+			Enabled:     config.Enabled,
+			State:       StateStopped,
+		})
+	}
+	return statuses
+}
 
-		// 如果是初始化响应，发送initialized通知
-		if response.ID == 1 {
-			notifReq := map[string]interface{}{
-				"jsonrpc": "2.0",
-				"method":  "notifications/initialized",
-			}
-			c.sendRequest(notifReq)
-			return nil
+// StderrTail 返回指定服务器最近的stderr输出
+func (m *MCPManager) StderrTail(name string, lines int) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	supervisor, exists := m.supervisors[name]
+	if !exists {
+		if _, configured := m.config.MCPServers[name]; !configured {
+			return nil, fmt.Errorf("未找到MCP服务器配置: %s", name)
 		}
+		return []string{}, nil
 	}
-
-	return fmt.Errorf("初始化超时")
+	return supervisor.stderr.Tail(lines), nil
 }
 
 // CallTool 调用MCP工具
 func (m *MCPManager) CallTool(serverName, toolName string, arguments map[string]interface{}) (*MCPResponse, error) {
-	m.mu.RLock()
-	client, exists := m.clients[serverName]
-	m.mu.RUnlock()
+	return m.CallToolContext(context.Background(), serverName, toolName, arguments, nil)
+}
 
-	if !exists {
-		return nil, fmt.Errorf("MCP服务器 %s 未启动", serverName)
+// CallToolContext 调用MCP工具，支持取消和进度回调
+func (m *MCPManager) CallToolContext(ctx context.Context, serverName, toolName string, arguments map[string]interface{}, progress ProgressFunc) (*MCPResponse, error) {
+	if err := m.checkPolicy(serverName, toolName); err != nil {
+		log.Printf("🚫 [MCP策略] %v", err)
+		return nil, err
+	}
+
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
 	}
 
-	return client.CallTool(toolName, arguments)
+	return client.CallToolContext(ctx, toolName, arguments, progress)
 }
 
 // StopServer 停止指定的MCP服务器
@@ -255,12 +358,13 @@ func (m *MCPManager) StopServer(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	client, exists := m.clients[name]
+	supervisor, exists := m.supervisors[name]
 	if !exists {
 		return fmt.Errorf("MCP服务器 %s 未找到", name)
 	}
 
-	client.Close()
+	supervisor.stop()
+	delete(m.supervisors, name)
 	delete(m.clients, name)
 	return nil
 }
@@ -272,7 +376,7 @@ func (m *MCPManager) ListActiveServers() []string {
 
 	var servers []string
 	for name, client := range m.clients {
-		if client.active {
+		if client.IsActive() {
 			servers = append(servers, name)
 		}
 	}
@@ -294,9 +398,20 @@ func (m *MCPManager) GetServerConfig(name string) (*MCPServerConfig, error) {
 
 // Close 关闭所有连接
 func (m *MCPManager) Close() error {
+	// 缓存创建在cacheOnce中完成，先等待其结束再写出命中计数（Cache()内部会获取m.mu）
+	m.cacheOnce.Do(func() {})
+	if m.cache != nil {
+		m.cache.Close()
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	for name, supervisor := range m.supervisors {
+		supervisor.stop()
+		delete(m.supervisors, name)
+	}
+
 	for name, client := range m.clients {
 		client.Close()
 		delete(m.clients, name)
@@ -323,174 +438,9 @@ type MCPResponse struct {
 
 // MCPError MCP错误
 type MCPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Data    string `json:"data,omitempty"`
-}
-
-// CallTool 调用工具
-func (c *MCPClient) CallTool(toolName string, arguments map[string]interface{}) (*MCPResponse, error) {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	if !c.active {
-		return nil, fmt.Errorf("MCP客户端未激活")
-	}
-
-	// 记录详细的调用信息
-	log.Printf("🔧 [MCP调用] 开始调用工具: %s", toolName)
-	log.Printf("📥 [MCP输入] 工具参数: %s", formatJSON(arguments))
-
-	// 构建工具调用请求（确保与测试脚本格式一致）
-	callReq := map[string]interface{}{
-		"jsonrpc": "2.0",
-		"id":      c.nextID,
-		"method":  "tools/call",
-		"params": map[string]interface{}{
-			"name":      toolName,
-			"arguments": arguments,
-		},
-	}
-
-	requestJSON, err := json.MarshalIndent(callReq, "", "  ")
-	if err != nil {
-		log.Printf("⚠️ [MCP警告] 序列化请求数据失败: %v", err)
-		requestJSON = []byte("序列化失败")
-	}
-	log.Printf("📤 [MCP请求] 完整请求数据 (ID: %d):\n%s", c.nextID, string(requestJSON))
-	c.nextID++
-
-	// 发送请求
-	if err := c.sendRequest(callReq); err != nil {
-		log.Printf("❌ [MCP错误] 发送工具调用请求失败: %v", err)
-		return nil, fmt.Errorf("发送工具调用请求失败: %w", err)
-	}
-
-	log.Printf("⏳ [MCP等待] 等待工具响应，超时时间: %v", c.timeout)
-
-	// 读取响应
-	response := c.readResponseWithTimeout(c.timeout)
-	if response == nil {
-		log.Printf("❌ [MCP错误] 读取工具调用响应超时")
-		return nil, fmt.Errorf("读取工具调用响应超时")
-	}
-
-	// 记录响应信息
-	responseJSON, err := json.MarshalIndent(response, "", "  ")
-	if err != nil {
-		log.Printf("⚠️ [MCP警告] 序列化响应数据失败: %v", err)
-		responseJSON = []byte("序列化失败")
-	}
-	log.Printf("📥 [MCP响应] 完整响应数据:\n%s", string(responseJSON))
-
-	if response.Error != nil {
-		errorJSON, err := json.MarshalIndent(response.Error, "", "  ")
-		if err != nil {
-			log.Printf("⚠️ [MCP警告] 序列化错误数据失败: %v", err)
-			errorJSON = []byte("序列化失败")
-		}
-		log.Printf("❌ [MCP错误] 服务器返回错误:\n%s", string(errorJSON))
-		return nil, fmt.Errorf("MCP错误: %s", response.Error.Message)
-	}
-
-	// 记录成功信息
-	if response.Result != nil {
-		resultSize := len(response.Result)
-		log.Printf("✅ [MCP成功] 工具调用成功，响应大小: %d 字节", resultSize)
-
-		// 尝试解析并记录关键结果信息
-		if resultMap, ok := parseJSONToMap(response.Result); ok {
-			if content, exists := resultMap["content"]; exists {
-				if contentArray, ok := content.([]interface{}); ok && len(contentArray) > 0 {
-					if firstItem, ok := contentArray[0].(map[string]interface{}); ok {
-						if text, ok := firstItem["text"].(string); ok {
-							// 限制显示长度，避免日志过长
-							displayText := text
-							if len(displayText) > 500 {
-								displayText = displayText[:500] + "..."
-							}
-							log.Printf("📄 [MCP结果] 内容摘要: %s", displayText)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return response, nil
-}
-
-// sendRequest 发送请求（支持map和struct格式）
-func (c *MCPClient) sendRequest(req interface{}) error {
-	data, err := json.Marshal(req)
-	if err != nil {
-		return fmt.Errorf("序列化请求失败: %w", err)
-	}
-
-	log.Printf("📤 发送JSON: %s", string(data))
-
-	_, err = c.stdin.Write(append(data, '\n'))
-	if err != nil {
-		return fmt.Errorf("发送数据失败: %w", err)
-	}
-
-	return nil
-}
-
-// readResponseWithTimeout 读取响应（带超时）
-func (c *MCPClient) readResponseWithTimeout(timeout time.Duration) *MCPResponse {
-	done := make(chan *MCPResponse)
-
-	go func() {
-		for c.scanner.Scan() {
-			line := strings.TrimSpace(c.scanner.Text())
-			// 尝试解析任何看起来像JSON的行
-			if strings.HasPrefix(line, "{") || strings.Contains(line, `"jsonrpc"`) {
-				var response MCPResponse
-				if err := json.Unmarshal([]byte(line), &response); err == nil {
-					done <- &response
-					return
-				} else {
-					// 记录解析错误以便调试
-					log.Printf("JSON解析错误: %v, 原始行: %s", err, line)
-				}
-			}
-		}
-		done <- nil
-	}()
-
-	select {
-	case response := <-done:
-		return response
-	case <-time.After(timeout):
-		return nil
-	}
-}
-
-// Close 关闭客户端
-func (c *MCPClient) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-
-	c.active = false
-
-	if c.stdin != nil {
-		c.stdin.Close()
-	}
-
-	if c.cmd != nil && c.cmd.Process != nil {
-		c.cmd.Process.Kill()
-		c.cmd.Wait()
-	}
-
-	return nil
-}
-
-// IsActive 检查客户端是否活跃
-func (c *MCPClient) IsActive() bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	return c.active
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
 }
 
 // formatJSON 格式化JSON为可读字符串
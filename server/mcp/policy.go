@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ToolPolicy 服务器级工具调用策略，工具名支持通配符（如 search_*）
+type ToolPolicy struct {
+	// AllowTools 非空时仅允许列出的工具；DenyTools优先于AllowTools
+	AllowTools []string `json:"allowTools,omitempty"`
+	DenyTools  []string `json:"denyTools,omitempty"`
+
+	// RateLimits 按工具名限制调用频率
+	RateLimits map[string]RateLimit `json:"rateLimits,omitempty"`
+
+	// ConfirmTools 调用前需要用户确认的工具（如会写入数据或产生费用的工具）
+	ConfirmTools []string `json:"confirmTools,omitempty"`
+}
+
+// RateLimit 在PerSeconds秒内最多调用MaxCalls次
+type RateLimit struct {
+	MaxCalls   int `json:"maxCalls"`
+	PerSeconds int `json:"perSeconds"`
+}
+
+// PolicyError 工具调用被策略拒绝
+type PolicyError struct {
+	Server string
+	Tool   string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("工具 %s.%s 被策略禁止: %s", e.Server, e.Tool, e.Reason)
+}
+
+// RateLimitError 工具调用超过频率限制
+type RateLimitError struct {
+	Server     string
+	Tool       string
+	Limit      RateLimit
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("工具 %s.%s 超过调用频率限制（%d次/%d秒），请 %v 后重试",
+		e.Server, e.Tool, e.Limit.MaxCalls, e.Limit.PerSeconds, e.RetryAfter.Round(time.Second))
+}
+
+// matchTool 工具名是否匹配任一模式
+func matchTool(patterns []string, tool string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, tool); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allows 策略是否允许调用工具，不允许时返回原因
+func (p *ToolPolicy) allows(tool string) (bool, string) {
+	if p == nil {
+		return true, ""
+	}
+	if matchTool(p.DenyTools, tool) {
+		return false, "位于denyTools中"
+	}
+	if len(p.AllowTools) > 0 && !matchTool(p.AllowTools, tool) {
+		return false, "不在allowTools中"
+	}
+	return true, ""
+}
+
+// rateLimit 返回适用于工具的频率限制
+func (p *ToolPolicy) rateLimit(tool string) (RateLimit, bool) {
+	if p == nil {
+		return RateLimit{}, false
+	}
+	limit, ok := lookupToolPattern(p.RateLimits, tool)
+	return limit, ok && limit.MaxCalls > 0 && limit.PerSeconds > 0
+}
+
+// lookupToolPattern 按工具名查找配置，精确名称优先于通配符（按模式排序保证结果稳定）
+func lookupToolPattern[T any](values map[string]T, tool string) (T, bool) {
+	if v, ok := values[tool]; ok {
+		return v, true
+	}
+
+	patterns := make([]string, 0, len(values))
+	for pattern := range values {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, tool); err == nil && ok {
+			return values[pattern], true
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// rateLimiter 滑动窗口限流器，按"服务器/工具"计数
+type rateLimiter struct {
+	mu    sync.Mutex
+	calls map[string][]time.Time
+	now   func() time.Time
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		calls: make(map[string][]time.Time),
+		now:   time.Now,
+	}
+}
+
+// allow 在窗口内尚有余量时记录本次调用；否则返回需要等待的时间
+func (r *rateLimiter) allow(key string, limit RateLimit) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	window := time.Duration(limit.PerSeconds) * time.Second
+
+	recent := r.calls[key][:0]
+	for _, t := range r.calls[key] {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= limit.MaxCalls {
+		r.calls[key] = recent
+		return false, recent[0].Add(window).Sub(now)
+	}
+	r.calls[key] = append(recent, now)
+	return true, 0
+}
+
+// serverPolicy 获取服务器配置的策略
+func (m *MCPManager) serverPolicy(serverName string) *ToolPolicy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if config, ok := m.config.MCPServers[serverName]; ok {
+		return config.Policy
+	}
+	return nil
+}
+
+// ToolAllowed 工具是否被服务器策略允许
+func (m *MCPManager) ToolAllowed(serverName, toolName string) bool {
+	ok, _ := m.serverPolicy(serverName).allows(toolName)
+	return ok
+}
+
+// RequiresConfirmation 工具调用前是否需要用户确认
+func (m *MCPManager) RequiresConfirmation(serverName, toolName string) bool {
+	policy := m.serverPolicy(serverName)
+	return policy != nil && matchTool(policy.ConfirmTools, toolName)
+}
+
+// checkPolicy 调用前检查允许/禁止列表和频率限制
+func (m *MCPManager) checkPolicy(serverName, toolName string) error {
+	policy := m.serverPolicy(serverName)
+	if ok, reason := policy.allows(toolName); !ok {
+		return &PolicyError{Server: serverName, Tool: toolName, Reason: reason}
+	}
+	if limit, ok := policy.rateLimit(toolName); ok {
+		if allowed, wait := m.limiter.allow(serverName+"/"+toolName, limit); !allowed {
+			return &RateLimitError{Server: serverName, Tool: toolName, Limit: limit, RetryAfter: wait}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package mcp
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateArguments(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"keyword":     map[string]interface{}{"type": "string", "minLength": 1},
+			"max_results": map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 50},
+			"source":      map[string]interface{}{"enum": []interface{}{"pmc", "arxiv"}},
+		},
+		"required":             []interface{}{"keyword"},
+		"additionalProperties": false,
+	}
+
+	tests := []struct {
+		name string
+		args map[string]interface{}
+		want []string
+	}{
+		{"合法参数", map[string]interface{}{"keyword": "bert", "max_results": 5}, nil},
+		{"缺少必需参数", map[string]interface{}{"max_results": 5}, []string{"缺少必需参数 keyword"}},
+		{"整数类型", map[string]interface{}{"keyword": "bert", "max_results": 2.5}, []string{"max_results: 应为 integer"}},
+		{"数值范围", map[string]interface{}{"keyword": "bert", "max_results": 100}, []string{"max_results: 不能大于 50"}},
+		{"枚举", map[string]interface{}{"keyword": "bert", "source": "pubmed"}, []string{`source: 取值必须是 "pmc", "arxiv" 之一`}},
+		{"额外参数", map[string]interface{}{"keyword": "bert", "limit": 3}, []string{"不支持的参数 limit"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := ValidateArguments(schema, tt.args)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("ValidateArguments() = %q, want %q", problems, tt.want)
+			}
+			for i, want := range tt.want {
+				if !strings.HasPrefix(problems[i], want) {
+					t.Errorf("problems[%d] = %q, want prefix %q", i, problems[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestLegacyArgumentsSchema(t *testing.T) {
+	schema := legacyArgumentsSchema(map[string]interface{}{
+		"identifier":  map[string]interface{}{"type": "string", "required": true},
+		"max_results": map[string]interface{}{"type": "integer", "default": 3},
+	})
+
+	if problems := ValidateArguments(schema, map[string]interface{}{"max_results": 3}); len(problems) != 1 {
+		t.Errorf("缺少identifier时问题 = %q", problems)
+	}
+	if problems := ValidateArguments(schema, map[string]interface{}{"identifier": "10.1/x"}); len(problems) != 0 {
+		t.Errorf("合法参数问题 = %q", problems)
+	}
+}
+
+func TestToolPolicy(t *testing.T) {
+	manager := &MCPManager{
+		config: &MCPConfig{MCPServers: map[string]MCPServerConfig{
+			"lab": {Policy: &ToolPolicy{
+				AllowTools:   []string{"search_*", "save_note"},
+				DenyTools:    []string{"search_internal"},
+				ConfirmTools: []string{"save_*"},
+				RateLimits:   map[string]RateLimit{"search_*": {MaxCalls: 2, PerSeconds: 60}},
+			}},
+			"open": {},
+		}},
+		limiter: newRateLimiter(),
+	}
+	now := time.Unix(1700000000, 0)
+	manager.limiter.now = func() time.Time { return now }
+
+	var policyErr *PolicyError
+	if err := manager.checkPolicy("lab", "search_internal"); !errors.As(err, &policyErr) {
+		t.Errorf("denyTools 未生效: %v", err)
+	}
+	if err := manager.checkPolicy("lab", "delete_item"); !errors.As(err, &policyErr) {
+		t.Errorf("allowTools 未生效: %v", err)
+	}
+	if !manager.ToolAllowed("open", "anything") {
+		t.Error("未配置策略的服务器应允许所有工具")
+	}
+	if !manager.RequiresConfirmation("lab", "save_note") || manager.RequiresConfirmation("lab", "search_pmc") {
+		t.Error("confirmTools 匹配错误")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := manager.checkPolicy("lab", "search_pmc"); err != nil {
+			t.Fatalf("第%d次调用被拒绝: %v", i+1, err)
+		}
+	}
+	var rateErr *RateLimitError
+	if err := manager.checkPolicy("lab", "search_pmc"); !errors.As(err, &rateErr) || rateErr.RetryAfter != time.Minute {
+		t.Fatalf("超过频率限制时 error = %v", err)
+	}
+	if err := manager.checkPolicy("lab", "search_arxiv"); err != nil {
+		t.Errorf("不同工具应分别计数: %v", err)
+	}
+
+	now = now.Add(61 * time.Second)
+	if err := manager.checkPolicy("lab", "search_pmc"); err != nil {
+		t.Errorf("窗口过后应恢复: %v", err)
+	}
+}
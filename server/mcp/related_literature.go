@@ -184,7 +184,7 @@ func findLocalDocuments(identifier string, cfg *config.Config) ([]DocumentSummar
 // searchGlobalLiterature 搜索全球文献
 func searchGlobalLiterature(identifier string, cfg *config.Config) ([]DocumentSummary, error) {
 	// 创建MCP管理器
-	manager, err := NewMCPManager("mcp/mcp_config.json")
+	manager, err := NewMCPManager(DefaultConfigFile)
 	if err != nil {
 		return nil, fmt.Errorf("创建MCP管理器失败: %w", err)
 	}
@@ -268,7 +268,7 @@ func performAIAnalysis(identifier, question string, localDocs, globalDocs []Docu
 	}
 
 	// 创建AI客户端
-	client := core.NewGLMClient(cfg.AIAPIKey, cfg.AIBaseURL, cfg.AIModel)
+	client := NewAIClient(cfg)
 
 	// 构建分析上下文
 	analysisContext := buildAnalysisContext(identifier, question, localDocs, globalDocs)
@@ -277,7 +277,7 @@ func performAIAnalysis(identifier, question string, localDocs, globalDocs []Docu
 	messages := []core.ChatMessage{
 		{
 			Role:    "system",
-			Content: "你是一个专业的学术文献分析师，请基于提供的文献信息进行智能分析和回答。",
+			Content: core.LoadPromptLibrary(cfg.PromptsDir, cfg.PromptLanguage).System(core.PromptAnalyst, core.PromptData{}),
 		},
 		{
 			Role:    "user",
@@ -293,7 +293,7 @@ func performAIAnalysis(identifier, question string, localDocs, globalDocs []Docu
 	}
 
 	// 设置100秒超时
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+	ctx, cancel := context.WithTimeout(core.WithUsageFeature(context.Background(), core.FeatureRelated), 100*time.Second)
 	defer cancel()
 
 	response, err := client.Chat(ctx, req)
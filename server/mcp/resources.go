@@ -0,0 +1,222 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Resource MCP资源
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+// ResourceContent resources/read返回的资源内容，文本资源使用Text，二进制资源使用Base64编码的Blob
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// Prompt MCP提示模板
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+// PromptArgument 提示模板参数
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+// PromptMessage prompts/get返回的消息
+type PromptMessage struct {
+	Role    string `json:"role"`
+	Content struct {
+		Type     string           `json:"type"`
+		Text     string           `json:"text,omitempty"`
+		Resource *ResourceContent `json:"resource,omitempty"`
+	} `json:"content"`
+}
+
+// PromptResult prompts/get的结果
+type PromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+// maxListPages 分页列举的最大页数，防止服务器返回循环游标
+const maxListPages = 50
+
+// HasCapability 服务器是否在初始化时声明了指定能力（如resources、prompts）
+func (c *MCPClient) HasCapability(name string) bool {
+	c.mu.Lock()
+	raw := c.capabilities
+	c.mu.Unlock()
+
+	var capabilities map[string]json.RawMessage
+	if json.Unmarshal(raw, &capabilities) != nil {
+		return false
+	}
+	_, ok := capabilities[name]
+	return ok
+}
+
+// request 发送请求并将结果解码到out
+func (c *MCPClient) request(ctx context.Context, method string, params map[string]interface{}, out interface{}) error {
+	if !c.IsActive() {
+		return fmt.Errorf("MCP客户端未激活")
+	}
+
+	response, err := c.Call(ctx, method, params)
+	if err != nil {
+		return fmt.Errorf("%s 调用失败: %w", method, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("%s 返回错误: %s", method, response.Error.Message)
+	}
+	if err := json.Unmarshal(response.Result, out); err != nil {
+		return fmt.Errorf("解析 %s 结果失败: %w", method, err)
+	}
+	return nil
+}
+
+// ListResources 列出服务器提供的全部资源（自动处理分页）
+func (c *MCPClient) ListResources(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		var params map[string]interface{}
+		if cursor != "" {
+			params = map[string]interface{}{"cursor": cursor}
+		}
+
+		var result struct {
+			Resources  []Resource `json:"resources"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "resources/list", params, &result); err != nil {
+			return nil, err
+		}
+		resources = append(resources, result.Resources...)
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	return resources, nil
+}
+
+// ReadResource 读取资源内容
+func (c *MCPClient) ReadResource(ctx context.Context, uri string) ([]ResourceContent, error) {
+	var result struct {
+		Contents []ResourceContent `json:"contents"`
+	}
+	if err := c.request(ctx, "resources/read", map[string]interface{}{"uri": uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+// ListPrompts 列出服务器提供的全部提示模板（自动处理分页）
+func (c *MCPClient) ListPrompts(ctx context.Context) ([]Prompt, error) {
+	var prompts []Prompt
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		var params map[string]interface{}
+		if cursor != "" {
+			params = map[string]interface{}{"cursor": cursor}
+		}
+
+		var result struct {
+			Prompts    []Prompt `json:"prompts"`
+			NextCursor string   `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "prompts/list", params, &result); err != nil {
+			return nil, err
+		}
+		prompts = append(prompts, result.Prompts...)
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	return prompts, nil
+}
+
+// GetPrompt 按参数展开提示模板
+func (c *MCPClient) GetPrompt(ctx context.Context, name string, arguments map[string]string) (*PromptResult, error) {
+	params := map[string]interface{}{"name": name}
+	if len(arguments) > 0 {
+		params["arguments"] = arguments
+	}
+
+	var result PromptResult
+	if err := c.request(ctx, "prompts/get", params, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// activeClient 获取已启动服务器的客户端
+func (m *MCPManager) activeClient(serverName string) (*MCPClient, error) {
+	m.mu.RLock()
+	client, exists := m.clients[serverName]
+	m.mu.RUnlock()
+
+	if !exists {
+		return nil, fmt.Errorf("MCP服务器 %s 未启动", serverName)
+	}
+	return client, nil
+}
+
+// HasCapability 已启动的服务器是否声明了指定能力
+func (m *MCPManager) HasCapability(serverName, capability string) bool {
+	client, err := m.activeClient(serverName)
+	return err == nil && client.HasCapability(capability)
+}
+
+// ListResources 列出指定服务器的资源
+func (m *MCPManager) ListResources(ctx context.Context, serverName string) ([]Resource, error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListResources(ctx)
+}
+
+// ReadResource 读取指定服务器的资源
+func (m *MCPManager) ReadResource(ctx context.Context, serverName, uri string) ([]ResourceContent, error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return client.ReadResource(ctx, uri)
+}
+
+// ListPrompts 列出指定服务器的提示模板
+func (m *MCPManager) ListPrompts(ctx context.Context, serverName string) ([]Prompt, error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListPrompts(ctx)
+}
+
+// GetPrompt 展开指定服务器的提示模板
+func (m *MCPManager) GetPrompt(ctx context.Context, serverName, name string, arguments map[string]string) (*PromptResult, error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return client.GetPrompt(ctx, name, arguments)
+}
@@ -0,0 +1,119 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"zoteroflow2-server/core"
+)
+
+func TestMCPClientListResourcesPaginates(t *testing.T) {
+	client, server := newFakeServerPair(t)
+
+	done := make(chan []Resource, 1)
+	go func() {
+		resources, err := client.ListResources(context.Background())
+		if err != nil {
+			t.Errorf("ListResources() error = %v", err)
+		}
+		done <- resources
+	}()
+
+	first := server.next(t)
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "id": first.ID, "result": map[string]interface{}{
+		"resources":  []Resource{{URI: "paper://1/full.md", Name: "Paper 1"}},
+		"nextCursor": "page2",
+	}})
+
+	second := server.next(t)
+	var params struct {
+		Cursor string `json:"cursor"`
+	}
+	json.Unmarshal(second.Params, &params)
+	if second.Method != "resources/list" || params.Cursor != "page2" {
+		t.Fatalf("第二页请求 = %s %s", second.Method, second.Params)
+	}
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "id": second.ID, "result": map[string]interface{}{
+		"resources": []Resource{{URI: "paper://2/full.md", Name: "Paper 2"}},
+	}})
+
+	if resources := <-done; len(resources) != 2 || resources[1].URI != "paper://2/full.md" {
+		t.Errorf("ListResources() = %+v", resources)
+	}
+}
+
+func TestMCPClientGetPrompt(t *testing.T) {
+	client, server := newFakeServerPair(t)
+
+	done := make(chan *PromptResult, 1)
+	go func() {
+		result, err := client.GetPrompt(context.Background(), "summarize", map[string]string{"doi": "10.1/x"})
+		if err != nil {
+			t.Errorf("GetPrompt() error = %v", err)
+		}
+		done <- result
+	}()
+
+	req := server.next(t)
+	var params struct {
+		Name      string            `json:"name"`
+		Arguments map[string]string `json:"arguments"`
+	}
+	json.Unmarshal(req.Params, &params)
+	if req.Method != "prompts/get" || params.Name != "summarize" || params.Arguments["doi"] != "10.1/x" {
+		t.Fatalf("请求 = %s %s", req.Method, req.Params)
+	}
+	server.write(t, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": map[string]interface{}{"type": "text", "text": "请总结 10.1/x"}},
+		},
+	}})
+
+	result := <-done
+	if result == nil || len(result.Messages) != 1 || result.Messages[0].Content.Text != "请总结 10.1/x" {
+		t.Errorf("GetPrompt() = %+v", result)
+	}
+}
+
+func TestMCPClientCapabilities(t *testing.T) {
+	server := NewServer("test", "1.0")
+	server.SetResourceProvider(staticResources{"zoteroflow://results/a/full.md": "# A"})
+	client := newServerPair(t, server)
+
+	if !client.HasCapability("resources") || client.HasCapability("prompts") {
+		t.Errorf("capabilities = %s", client.capabilities)
+	}
+
+	contents, err := client.ReadResource(context.Background(), "zoteroflow://results/a/full.md")
+	if err != nil || len(contents) != 1 || contents[0].Text != "# A" {
+		t.Errorf("ReadResource() = %+v, %v", contents, err)
+	}
+}
+
+func TestGetPromptTool(t *testing.T) {
+	desc := describePrompts([]Prompt{
+		{Name: "summarize", Description: "总结论文", Arguments: []PromptArgument{{Name: "doi", Required: true}, {Name: "style"}}},
+		{Name: "critique"},
+	})
+	if !strings.Contains(desc, "summarize - 总结论文，参数: doi(必填), style") || !strings.Contains(desc, "\n    critique") {
+		t.Errorf("describePrompts() = %s", desc)
+	}
+
+	text := formatPromptMessages([]core.ChatMessage{{Role: "user", Content: "请总结 10.1/x"}, {Role: "assistant", Content: "好的"}})
+	if text != "[user]\n请总结 10.1/x\n\n[assistant]\n好的" {
+		t.Errorf("formatPromptMessages() = %q", text)
+	}
+
+	bridge := &AIMCPBridge{}
+	if err := bridge.ValidateToolCall(&ToolCall{Server: "s", Tool: getPromptTool, Arguments: map[string]interface{}{"name": "summarize", "arguments": map[string]interface{}{"doi": "10.1/x"}}}); err != nil {
+		t.Errorf("有效的get_prompt调用: %v", err)
+	}
+	if err := bridge.ValidateToolCall(&ToolCall{Server: "s", Tool: getPromptTool, Arguments: map[string]interface{}{"arguments": "doi"}}); err == nil {
+		t.Error("缺少name或arguments不是对象时应校验失败")
+	}
+	if !isBridgeTool(getPromptTool) || isBridgeTool("search_europe_pmc") {
+		t.Error("isBridgeTool() 判断错误")
+	}
+}
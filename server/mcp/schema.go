@@ -0,0 +1,311 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ArgumentError 工具参数未通过inputSchema校验
+type ArgumentError struct {
+	Server   string
+	Tool     string
+	Problems []string
+}
+
+func (e *ArgumentError) Error() string {
+	return fmt.Sprintf("工具 %s.%s 参数校验失败: %s", e.Server, e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// ValidateArguments 按JSON Schema校验工具参数，返回全部问题描述
+//
+// 支持MCP工具常用的子集：type、enum、const、required、properties、
+// additionalProperties、items、min/max系列约束、pattern以及anyOf/oneOf/allOf
+func ValidateArguments(schema map[string]interface{}, args map[string]interface{}) []string {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	// 统一为JSON解码后的类型（数字为float64），与服务器看到的一致
+	var value interface{} = map[string]interface{}{}
+	if args != nil {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return []string{fmt.Sprintf("参数无法序列化为JSON: %v", err)}
+		}
+		json.Unmarshal(data, &value)
+	}
+
+	// Go代码中构造的schema同样规范化，保证数值约束为float64
+	var normalized map[string]interface{}
+	if data, err := json.Marshal(schema); err != nil || json.Unmarshal(data, &normalized) != nil {
+		return []string{"inputSchema无效"}
+	}
+
+	var problems []string
+	validateValue(normalized, value, "", &problems)
+	return problems
+}
+
+// validateValue 递归校验单个值
+func validateValue(schema map[string]interface{}, value interface{}, path string, problems *[]string) {
+	name := path
+	if name == "" {
+		name = "参数"
+	}
+	report := func(format string, args ...interface{}) {
+		*problems = append(*problems, name+": "+fmt.Sprintf(format, args...))
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !matchesAnyType(value, types) {
+		report("应为 %s，实际为 %s", strings.Join(types, "|"), jsonTypeName(value))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && !containsValue(enum, value) {
+		report("取值必须是 %s 之一", formatEnum(enum))
+	}
+	if constValue, ok := schema["const"]; ok && !jsonEqual(constValue, value) {
+		report("取值必须是 %v", constValue)
+	}
+
+	for _, key := range []string{"anyOf", "oneOf"} {
+		branches, ok := schema[key].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := false
+		for _, branch := range branches {
+			if branchSchema, ok := branch.(map[string]interface{}); ok {
+				var branchProblems []string
+				validateValue(branchSchema, value, path, &branchProblems)
+				if len(branchProblems) == 0 {
+					matched = true
+					break
+				}
+			}
+		}
+		if !matched {
+			report("不符合任何可选格式")
+		}
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		for _, branch := range all {
+			if branchSchema, ok := branch.(map[string]interface{}); ok {
+				validateValue(branchSchema, value, path, problems)
+			}
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, problems)
+	case []interface{}:
+		if min, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < min {
+			report("至少需要 %v 项", min)
+		}
+		if max, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > max {
+			report("最多 %v 项", max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), problems)
+			}
+		}
+	case string:
+		length := float64(len([]rune(v)))
+		if min, ok := schemaNumber(schema, "minLength"); ok && length < min {
+			report("长度至少为 %v", min)
+		}
+		if max, ok := schemaNumber(schema, "maxLength"); ok && length > max {
+			report("长度不能超过 %v", max)
+		}
+		if pattern, ok := schema["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(v) {
+				report("不匹配格式 %s", pattern)
+			}
+		}
+	case float64:
+		if min, ok := schemaNumber(schema, "minimum"); ok && v < min {
+			report("不能小于 %v", min)
+		}
+		if max, ok := schemaNumber(schema, "maximum"); ok && v > max {
+			report("不能大于 %v", max)
+		}
+		if min, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= min {
+			report("必须大于 %v", min)
+		}
+		if max, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= max {
+			report("必须小于 %v", max)
+		}
+	}
+}
+
+// validateObject 校验对象的必填字段、属性和额外属性
+func validateObject(schema map[string]interface{}, obj map[string]interface{}, path string, problems *[]string) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			key, _ := r.(string)
+			if _, exists := obj[key]; key != "" && !exists {
+				*problems = append(*problems, fmt.Sprintf("缺少必需参数 %s", joinPath(path, key)))
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if propSchema, ok := properties[key].(map[string]interface{}); ok {
+			validateValue(propSchema, obj[key], joinPath(path, key), problems)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				*problems = append(*problems, fmt.Sprintf("不支持的参数 %s（可用参数: %s）", joinPath(path, key), propertyNames(properties)))
+			}
+		case map[string]interface{}:
+			validateValue(extra, obj[key], joinPath(path, key), problems)
+		}
+	}
+}
+
+// legacyArgumentsSchema 将预定义工具的参数描述（required写在属性内）转换为标准JSON Schema
+func legacyArgumentsSchema(arguments map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{}, len(arguments))
+	var required []interface{}
+	for name, arg := range arguments {
+		argMap, ok := arg.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		prop := make(map[string]interface{}, len(argMap))
+		for k, v := range argMap {
+			if k == "required" {
+				if r, ok := v.(bool); ok && r {
+					required = append(required, name)
+				}
+				continue
+			}
+			prop[k] = v
+		}
+		properties[name] = prop
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func propertyNames(properties map[string]interface{}) string {
+	if len(properties) == 0 {
+		return "无"
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func schemaTypes(t interface{}) []string {
+	switch v := t.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var types []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func matchesAnyType(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if f, ok := value.(float64); ok && f == math.Trunc(f) {
+				return true
+			}
+		case "number":
+			if _, ok := value.(float64); ok {
+				return true
+			}
+		default:
+			if jsonTypeName(value) == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	v, ok := schema[key].(float64)
+	return v, ok
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if jsonEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonEqual(a, b interface{}) bool {
+	da, _ := json.Marshal(a)
+	db, _ := json.Marshal(b)
+	return string(da) == string(db)
+}
+
+func formatEnum(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		data, _ := json.Marshal(v)
+		parts[i] = string(data)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,279 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ServerTool MCP服务器对外暴露的工具
+type ServerTool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+
+	// Handler 执行工具并返回文本结果；返回错误时以isError结果告知调用方
+	Handler func(ctx context.Context, args json.RawMessage) (string, error) `json:"-"`
+}
+
+// ResourceProvider 资源来源
+type ResourceProvider interface {
+	ListResources() ([]Resource, error)
+	ReadResource(uri string) (text string, mimeType string, err error)
+}
+
+// Server 基于stdio的MCP服务器
+type Server struct {
+	name      string
+	version   string
+	tools     map[string]ServerTool
+	resources ResourceProvider
+
+	writeMu sync.Mutex
+	out     io.Writer
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// NewServer 创建MCP服务器
+func NewServer(name, version string) *Server {
+	return &Server{
+		name:     name,
+		version:  version,
+		tools:    make(map[string]ServerTool),
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+// AddTool 注册工具
+func (s *Server) AddTool(tool ServerTool) {
+	s.tools[tool.Name] = tool
+}
+
+// SetResourceProvider 设置资源来源
+func (s *Server) SetResourceProvider(provider ResourceProvider) {
+	s.resources = provider
+}
+
+// Serve 从in逐行读取JSON-RPC请求并向out写入响应，直到输入结束
+func (s *Server) Serve(ctx context.Context, in io.Reader, out io.Writer) error {
+	s.out = out
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	reader := bufio.NewReaderSize(in, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			s.handleLine(ctx, line)
+		}
+		if err != nil {
+			s.wg.Wait()
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("读取请求失败: %w", err)
+		}
+	}
+}
+
+// handleLine 处理一行输入
+func (s *Server) handleLine(ctx context.Context, line []byte) {
+	var msg rpcMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		if len(bytes.TrimSpace(line)) > 0 {
+			s.writeError(json.RawMessage("null"), -32700, "Parse error")
+		}
+		return
+	}
+
+	// 通知
+	if len(msg.ID) == 0 {
+		s.handleNotification(msg)
+		return
+	}
+
+	// 工具调用可能耗时较长，并发执行，其余请求按顺序直接处理
+	if msg.Method == "tools/call" {
+		callCtx, cancel := context.WithCancel(ctx)
+		key := string(msg.ID)
+		s.mu.Lock()
+		s.inflight[key] = cancel
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.inflight, key)
+				s.mu.Unlock()
+				cancel()
+			}()
+			s.respond(msg.ID, s.callTool(callCtx, msg.Params))
+		}()
+		return
+	}
+
+	s.respond(msg.ID, s.handleRequest(msg))
+}
+
+// handleNotification 处理客户端通知
+func (s *Server) handleNotification(msg rpcMessage) {
+	if msg.Method != "notifications/cancelled" {
+		return
+	}
+	var params struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if json.Unmarshal(msg.Params, &params) != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.inflight[string(params.RequestID)]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+// handleRequest 处理非工具调用请求，返回结果或*MCPError
+func (s *Server) handleRequest(msg rpcMessage) interface{} {
+	switch msg.Method {
+	case "initialize":
+		capabilities := map[string]interface{}{
+			"tools": map[string]interface{}{},
+		}
+		if s.resources != nil {
+			capabilities["resources"] = map[string]interface{}{}
+		}
+		return map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities":    capabilities,
+			"serverInfo": map[string]interface{}{
+				"name":    s.name,
+				"version": s.version,
+			},
+		}
+	case "ping":
+		return map[string]interface{}{}
+	case "tools/list":
+		names := make([]string, 0, len(s.tools))
+		for name := range s.tools {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		tools := make([]ServerTool, 0, len(names))
+		for _, name := range names {
+			tools = append(tools, s.tools[name])
+		}
+		return map[string]interface{}{"tools": tools}
+	case "resources/list":
+		if s.resources == nil {
+			return map[string]interface{}{"resources": []Resource{}}
+		}
+		resources, err := s.resources.ListResources()
+		if err != nil {
+			return &MCPError{Code: -32603, Message: err.Error()}
+		}
+		if resources == nil {
+			resources = []Resource{}
+		}
+		return map[string]interface{}{"resources": resources}
+	case "resources/read":
+		var params struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(msg.Params, &params); err != nil || params.URI == "" || s.resources == nil {
+			return &MCPError{Code: -32602, Message: "缺少资源URI"}
+		}
+		text, mimeType, err := s.resources.ReadResource(params.URI)
+		if err != nil {
+			return &MCPError{Code: -32002, Message: err.Error()}
+		}
+		return map[string]interface{}{
+			"contents": []map[string]string{{
+				"uri":      params.URI,
+				"mimeType": mimeType,
+				"text":     text,
+			}},
+		}
+	default:
+		return &MCPError{Code: -32601, Message: "Method not found: " + msg.Method}
+	}
+}
+
+// callTool 执行工具调用
+func (s *Server) callTool(ctx context.Context, raw json.RawMessage) interface{} {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return &MCPError{Code: -32602, Message: "无效的工具调用参数"}
+	}
+
+	tool, ok := s.tools[params.Name]
+	if !ok {
+		return &MCPError{Code: -32602, Message: "未知工具: " + params.Name}
+	}
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	text, err := tool.Handler(ctx, params.Arguments)
+	if err != nil {
+		log.Printf("⚠️ [MCP服务器] 工具 %s 执行失败: %v", params.Name, err)
+		return toolResult(err.Error(), true)
+	}
+	return toolResult(text, false)
+}
+
+// toolResult 构建tools/call的文本结果
+func toolResult(text string, isError bool) map[string]interface{} {
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": isError,
+	}
+}
+
+// respond 写入响应或错误
+func (s *Server) respond(id json.RawMessage, result interface{}) {
+	if mcpErr, ok := result.(*MCPError); ok {
+		s.writeError(id, mcpErr.Code, mcpErr.Message)
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		s.writeError(id, -32603, err.Error())
+		return
+	}
+	s.write(rpcMessage{JSONRPC: "2.0", ID: id, Result: data})
+}
+
+// writeError 写入错误响应
+func (s *Server) writeError(id json.RawMessage, code int, message string) {
+	s.write(rpcMessage{JSONRPC: "2.0", ID: id, Error: &MCPError{Code: code, Message: message}})
+}
+
+// write 串行写入一行JSON
+func (s *Server) write(msg rpcMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("⚠️ [MCP服务器] 序列化响应失败: %v", err)
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if _, err := s.out.Write(append(data, '\n')); err != nil {
+		log.Printf("⚠️ [MCP服务器] 写入响应失败: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// staticResources 固定内容的资源来源
+type staticResources map[string]string
+
+func (r staticResources) ListResources() ([]Resource, error) {
+	var resources []Resource
+	for uri := range r {
+		resources = append(resources, Resource{URI: uri, Name: uri, MimeType: "text/markdown"})
+	}
+	return resources, nil
+}
+
+func (r staticResources) ReadResource(uri string) (string, string, error) {
+	text, ok := r[uri]
+	if !ok {
+		return "", "", fmt.Errorf("未知资源: %s", uri)
+	}
+	return text, "text/markdown", nil
+}
+
+// newServerPair 通过管道连接服务器与客户端
+func newServerPair(t *testing.T, server *Server) *MCPClient {
+	t.Helper()
+	clientToServerR, clientToServerW := io.Pipe()
+	serverToClientR, serverToClientW := io.Pipe()
+
+	go func() {
+		server.Serve(context.Background(), clientToServerR, serverToClientW)
+		serverToClientW.Close()
+	}()
+
+	client, err := newMCPClient(MCPServerConfig{Timeout: 5}, newStdioTransport(clientToServerW, serverToClientR))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.initialize(context.Background()); err != nil {
+		t.Fatalf("initialize() error = %v", err)
+	}
+	return client
+}
+
+func TestServerToolsAndResources(t *testing.T) {
+	server := NewServer("test", "1.0")
+	server.AddTool(ServerTool{
+		Name:        "echo",
+		Description: "回显",
+		InputSchema: objectSchema(map[string]interface{}{"text": map[string]interface{}{"type": "string"}}, "text"),
+		Handler: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var a struct {
+				Text string `json:"text"`
+			}
+			json.Unmarshal(args, &a)
+			if a.Text == "" {
+				return "", fmt.Errorf("text不能为空")
+			}
+			return "echo:" + a.Text, nil
+		},
+	})
+	server.SetResourceProvider(staticResources{"zoteroflow://results/a/full.md": "# A"})
+	client := newServerPair(t, server)
+
+	resp, err := client.Call(context.Background(), "tools/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list struct {
+		Tools []struct {
+			Name        string                 `json:"name"`
+			InputSchema map[string]interface{} `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	json.Unmarshal(resp.Result, &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != "echo" || list.Tools[0].InputSchema["type"] != "object" {
+		t.Errorf("tools/list = %s", resp.Result)
+	}
+
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+		IsError bool `json:"isError"`
+	}
+	resp, err = client.CallTool("echo", map[string]interface{}{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(resp.Result, &result)
+	if result.IsError || result.Content[0].Text != "echo:hi" {
+		t.Errorf("tools/call = %s", resp.Result)
+	}
+
+	// 工具错误以isError结果返回，而不是协议错误
+	resp, err = client.CallTool("echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal(resp.Result, &result)
+	if !result.IsError {
+		t.Errorf("期望isError结果: %s", resp.Result)
+	}
+
+	if _, err := client.CallTool("missing", nil); err == nil {
+		t.Error("未知工具应返回错误")
+	}
+
+	resp, err = client.Call(context.Background(), "resources/read", map[string]interface{}{"uri": "zoteroflow://results/a/full.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var read struct {
+		Contents []struct {
+			Text string `json:"text"`
+		} `json:"contents"`
+	}
+	json.Unmarshal(resp.Result, &read)
+	if len(read.Contents) != 1 || read.Contents[0].Text != "# A" {
+		t.Errorf("resources/read = %s", resp.Result)
+	}
+}
@@ -0,0 +1,410 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// ServerState MCP服务器运行状态
+type ServerState string
+
+const (
+	StateStopped    ServerState = "stopped"
+	StateStarting   ServerState = "starting"
+	StateRunning    ServerState = "running"
+	StateUnhealthy  ServerState = "unhealthy"
+	StateRestarting ServerState = "restarting"
+	StateFailed     ServerState = "failed"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	defaultStderrBufferLines   = 200
+	maxRestartBackoff          = 30 * time.Second
+	// 重启次数在该时间窗口内累计，窗口内超过RetryAttempts次即判定为失败，不再重启
+	restartWindow = 10 * time.Minute
+	// 连续多少次ping失败后判定为不健康并重启
+	unhealthyPingThreshold = 2
+)
+
+// ServerStatus MCP服务器状态报告
+type ServerStatus struct {
+	Name         string      `json:"name"`
+	Description  string      `json:"description,omitempty"`
+	Transport    string      `json:"transport"`
+	URL          string      `json:"url,omitempty"`
+	Enabled      bool        `json:"enabled"`
+	State        ServerState `json:"state"`
+	PID          int         `json:"pid,omitempty"`
+	Restarts     int         `json:"restarts"`
+	LastError    string      `json:"last_error,omitempty"`
+	StartedAt    *time.Time  `json:"started_at,omitempty"`
+	LastPing     *time.Time  `json:"last_ping,omitempty"`
+	PendingCalls int         `json:"pending_calls"`
+	StderrTail   []string    `json:"stderr_tail,omitempty"`
+}
+
+// ringBuffer 固定容量的行缓冲区，保留最近的stderr输出
+type ringBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+// newRingBuffer 创建行缓冲区
+func newRingBuffer(capacity int) *ringBuffer {
+	if capacity <= 0 {
+		capacity = defaultStderrBufferLines
+	}
+	return &ringBuffer{lines: make([]string, capacity)}
+}
+
+// Add 追加一行，超出容量时覆盖最旧的行
+func (r *ringBuffer) Add(line string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Tail 返回最近的n行（n<=0时返回全部）
+func (r *ringBuffer) Tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ordered []string
+	if r.full {
+		ordered = append(ordered, r.lines[r.next:]...)
+	}
+	ordered = append(ordered, r.lines[:r.next]...)
+
+	if n > 0 && len(ordered) > n {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// capture 持续读取stderr直到流结束
+func (r *ringBuffer) capture(name string, stderr io.Reader, echo bool) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 16*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		r.Add(line)
+		if echo {
+			log.Printf("🪵 [MCP stderr] %s: %s", name, line)
+		}
+	}
+}
+
+// serverSupervisor 监督单个MCP服务器：健康检查、崩溃重启和状态记录
+type serverSupervisor struct {
+	manager *MCPManager
+	name    string
+	config  MCPServerConfig
+	stderr  *ringBuffer
+
+	startMu sync.Mutex // 串行化启动过程
+
+	mu           sync.Mutex // 保护以下字段
+	client       *MCPClient
+	state        ServerState
+	restarts     int
+	restartTimes []time.Time // restartWindow内的重启尝试时间
+	pingFailures int
+	lastError    string
+	startedAt    time.Time
+	lastPing     time.Time
+	monitoring   bool
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// newServerSupervisor 创建服务器监督器
+func newServerSupervisor(manager *MCPManager, name string, config MCPServerConfig) *serverSupervisor {
+	return &serverSupervisor{
+		manager: manager,
+		name:    name,
+		config:  config,
+		stderr:  newRingBuffer(manager.config.GlobalSettings.StderrBufferLines),
+		state:   StateStopped,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// ensureRunning 确保服务器已启动，首次启动后开始后台监督
+func (s *serverSupervisor) ensureRunning() error {
+	s.startMu.Lock()
+	defer s.startMu.Unlock()
+
+	s.mu.Lock()
+	if s.client != nil && s.client.IsActive() {
+		s.mu.Unlock()
+		log.Printf("MCP服务器 %s 已经在运行，复用现有连接", s.name)
+		return nil
+	}
+	s.state = StateStarting
+	s.mu.Unlock()
+
+	client, err := s.manager.createClient(s.name, s.config, s.stderr)
+	if err != nil {
+		s.mu.Lock()
+		s.state = StateFailed
+		s.lastError = err.Error()
+		s.mu.Unlock()
+		return err
+	}
+
+	s.attach(client)
+
+	s.mu.Lock()
+	startMonitor := !s.monitoring
+	s.monitoring = true
+	s.mu.Unlock()
+
+	if startMonitor {
+		go s.monitor()
+	}
+	return nil
+}
+
+// attach 记录新建立的客户端连接
+func (s *serverSupervisor) attach(client *MCPClient) {
+	s.mu.Lock()
+	s.client = client
+	s.state = StateRunning
+	s.pingFailures = 0
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+
+	s.manager.setClient(s.name, client)
+}
+
+// monitor 后台监督循环
+func (s *serverSupervisor) monitor() {
+	ticker := time.NewTicker(s.manager.healthCheckInterval())
+	defer ticker.Stop()
+
+	for {
+		s.mu.Lock()
+		client := s.client
+		s.mu.Unlock()
+
+		var done <-chan struct{}
+		if client != nil {
+			done = client.Done()
+		}
+
+		select {
+		case <-s.stopCh:
+			return
+		case <-done:
+			s.restart(client, fmt.Errorf("MCP服务器连接中断: %s", client.ExitStatus()))
+		case <-ticker.C:
+			if client != nil {
+				s.healthCheck(client)
+			}
+		}
+	}
+}
+
+// healthCheck 发送ping检查服务器是否响应
+func (s *serverSupervisor) healthCheck(client *MCPClient) {
+	timeout := client.timeout
+	if timeout > 10*time.Second {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	response, err := client.Call(ctx, "ping", nil)
+	if err == nil && response.Error != nil {
+		err = fmt.Errorf("ping失败: %s", response.Error.Message)
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.lastPing = time.Now()
+		s.pingFailures = 0
+		s.state = StateRunning
+		s.mu.Unlock()
+		return
+	}
+
+	s.pingFailures++
+	s.lastError = err.Error()
+	failures := s.pingFailures
+	s.state = StateUnhealthy
+	s.mu.Unlock()
+
+	log.Printf("⚠️ [MCP监督] %s 健康检查失败 (%d/%d): %v", s.name, failures, unhealthyPingThreshold, err)
+	if failures >= unhealthyPingThreshold {
+		s.restart(client, fmt.Errorf("连续 %d 次健康检查失败: %w", failures, err))
+	}
+}
+
+// restart 关闭旧连接并按指数退避重启
+//
+// 重启尝试在restartWindow内累计而不是每次崩溃后清零，反复崩溃的服务器在窗口内
+// 超过RetryAttempts次尝试后进入StateFailed
+func (s *serverSupervisor) restart(old *MCPClient, reason error) {
+	log.Printf("🔄 [MCP监督] 重启 %s: %v", s.name, reason)
+
+	old.Close()
+	s.manager.removeClient(s.name, old)
+
+	s.mu.Lock()
+	s.client = nil
+	s.lastError = reason.Error()
+	s.mu.Unlock()
+
+	maxAttempts := s.manager.retryAttempts(s.config)
+	for {
+		attempt, ok := s.nextRestartAttempt(maxAttempts)
+		if !ok {
+			break
+		}
+		s.setState(StateRestarting)
+
+		select {
+		case <-s.stopCh:
+			return
+		case <-time.After(restartBackoff(attempt)):
+		}
+
+		s.startMu.Lock()
+		s.mu.Lock()
+		recovered := s.client != nil && s.client.IsActive()
+		s.mu.Unlock()
+		if recovered {
+			// 期间已被StartServer重新启动
+			s.startMu.Unlock()
+			return
+		}
+
+		client, err := s.manager.createClient(s.name, s.config, s.stderr)
+		if err != nil {
+			s.startMu.Unlock()
+			log.Printf("❌ [MCP监督] %s 第 %d/%d 次重启失败: %v", s.name, attempt, maxAttempts, err)
+			s.mu.Lock()
+			s.lastError = err.Error()
+			s.mu.Unlock()
+			continue
+		}
+
+		select {
+		case <-s.stopCh:
+			// 重启过程中已被停止
+			s.startMu.Unlock()
+			client.Close()
+			return
+		default:
+		}
+
+		s.mu.Lock()
+		s.restarts++
+		s.mu.Unlock()
+		s.attach(client)
+		s.startMu.Unlock()
+
+		log.Printf("✅ [MCP监督] %s 重启成功 (第 %d 次尝试)", s.name, attempt)
+		return
+	}
+
+	s.setState(StateFailed)
+	log.Printf("❌ [MCP监督] %s 在 %v 内已重启 %d 次，停止重启", s.name, restartWindow, maxAttempts)
+}
+
+// nextRestartAttempt 记录一次重启尝试，返回它在restartWindow内的序号；超过maxAttempts时返回false
+func (s *serverSupervisor) nextRestartAttempt(maxAttempts int) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	recent := s.restartTimes[:0]
+	for _, t := range s.restartTimes {
+		if now.Sub(t) < restartWindow {
+			recent = append(recent, t)
+		}
+	}
+	s.restartTimes = recent
+
+	if len(recent) >= maxAttempts {
+		return len(recent), false
+	}
+	s.restartTimes = append(s.restartTimes, now)
+	return len(s.restartTimes), true
+}
+
+// stop 停止监督并关闭连接
+func (s *serverSupervisor) stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+
+	s.mu.Lock()
+	client := s.client
+	s.client = nil
+	s.state = StateStopped
+	s.mu.Unlock()
+
+	if client != nil {
+		client.Close()
+	}
+}
+
+// setState 更新状态
+func (s *serverSupervisor) setState(state ServerState) {
+	s.mu.Lock()
+	s.state = state
+	s.mu.Unlock()
+}
+
+// status 生成状态报告
+func (s *serverSupervisor) status(stderrLines int) ServerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := ServerStatus{
+		Name:        s.name,
+		Description: s.config.Description,
+		Transport:   transportKind(s.config),
+		URL:         s.config.URL,
+		Enabled:     s.config.Enabled,
+		State:       s.state,
+		Restarts:    s.restarts,
+		LastError:   s.lastError,
+		StderrTail:  s.stderr.Tail(stderrLines),
+	}
+	if s.client != nil {
+		status.PID = s.client.PID()
+		status.PendingCalls = s.client.PendingCalls()
+	}
+	if !s.startedAt.IsZero() {
+		startedAt := s.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !s.lastPing.IsZero() {
+		lastPing := s.lastPing
+		status.LastPing = &lastPing
+	}
+	return status
+}
+
+// restartBackoff 计算第n次重启前的等待时间
+func restartBackoff(attempt int) time.Duration {
+	delay := time.Second << uint(attempt-1)
+	if delay <= 0 || delay > maxRestartBackoff {
+		return maxRestartBackoff
+	}
+	return delay
+}
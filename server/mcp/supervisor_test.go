@@ -0,0 +1,134 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestHelperMCPServer 作为子进程运行的最小stdio MCP服务器
+func TestHelperMCPServer(t *testing.T) {
+	if os.Getenv("ZF_FAKE_MCP") != "1" {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, "fake server ready:", os.Getenv("ZF_FAKE_GREETING"))
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			os.Exit(0)
+		}
+
+		var msg rpcMessage
+		if json.Unmarshal(line, &msg) != nil || len(msg.ID) == 0 {
+			continue
+		}
+
+		result := map[string]interface{}{}
+		if msg.Method == "tools/call" {
+			var params struct {
+				Name string `json:"name"`
+			}
+			json.Unmarshal(msg.Params, &params)
+			if params.Name == "crash" {
+				fmt.Fprintln(os.Stderr, "crashing on request")
+				os.Exit(3)
+			}
+			result["content"] = []map[string]string{{"type": "text", "text": "ok"}}
+		}
+
+		data, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+		os.Stdout.Write(append(data, '\n'))
+	}
+}
+
+// newFakeServerManager 创建只配置了假MCP服务器的管理器
+func newFakeServerManager(t *testing.T, retryAttempts int) *MCPManager {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "mcp_config.json")
+	config := map[string]interface{}{
+		"mcpServers": map[string]interface{}{
+			"fake": map[string]interface{}{
+				"enabled":       true,
+				"command":       os.Args[0],
+				"args":          []string{"-test.run=TestHelperMCPServer"},
+				"env":           map[string]string{"ZF_FAKE_MCP": "1", "ZF_FAKE_GREETING": "hello"},
+				"timeout":       5,
+				"retryAttempts": retryAttempts,
+			},
+		},
+	}
+	data, _ := json.Marshal(config)
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := NewMCPManager(configFile)
+	if err != nil {
+		t.Fatalf("NewMCPManager() error = %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+// waitForState 等待服务器进入指定状态
+func waitForState(t *testing.T, manager *MCPManager, state ServerState, restarts int) ServerStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status := manager.Status()[0]
+		if status.State == state && status.Restarts == restarts {
+			return status
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("服务器未进入 %s (重启 %d 次): %+v", state, restarts, manager.Status())
+	return ServerStatus{}
+}
+
+func TestMCPManagerSupervisesServer(t *testing.T) {
+	manager := newFakeServerManager(t, 2)
+
+	if err := manager.StartServer("fake"); err != nil {
+		t.Fatalf("StartServer() error = %v", err)
+	}
+
+	if _, err := manager.CallTool("fake", "echo", nil); err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+
+	// 触发崩溃，等待监督器重启
+	manager.CallTool("fake", "crash", nil)
+	waitForState(t, manager, StateRunning, 1)
+
+	tail, _ := manager.StderrTail("fake", 0)
+	if len(tail) == 0 || tail[0] != "fake server ready: hello" {
+		t.Errorf("stderr未按配置的环境变量捕获: %v", tail)
+	}
+	if _, err := manager.CallTool("fake", "echo", nil); err != nil {
+		t.Errorf("重启后调用失败: %v", err)
+	}
+}
+
+func TestMCPManagerStopsRestartingCrashLoop(t *testing.T) {
+	manager := newFakeServerManager(t, 1)
+	if err := manager.StartServer("fake"); err != nil {
+		t.Fatalf("StartServer() error = %v", err)
+	}
+
+	// 第一次崩溃在重启预算内
+	manager.CallTool("fake", "crash", nil)
+	waitForState(t, manager, StateRunning, 1)
+
+	// 窗口内再次崩溃：重启次数不因上次成功而清零，超过retryAttempts后停止重启
+	manager.CallTool("fake", "crash", nil)
+	status := waitForState(t, manager, StateFailed, 1)
+	if status.PID != 0 {
+		t.Errorf("失败后不应有运行中的进程: %+v", status)
+	}
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+)
+
+// ToolInfo 服务器通过tools/list声明的工具
+type ToolInfo struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+}
+
+// ListTools 列出服务器提供的全部工具（自动处理分页）
+func (c *MCPClient) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var tools []ToolInfo
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		var params map[string]interface{}
+		if cursor != "" {
+			params = map[string]interface{}{"cursor": cursor}
+		}
+
+		var result struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.request(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+
+		if result.NextCursor == "" {
+			break
+		}
+		cursor = result.NextCursor
+	}
+	return tools, nil
+}
+
+// toolInfo 查找工具声明，首次使用时拉取并缓存tools/list
+func (c *MCPClient) toolInfo(ctx context.Context, name string) (ToolInfo, bool, error) {
+	c.mu.Lock()
+	cached := c.tools
+	c.mu.Unlock()
+
+	if cached == nil {
+		tools, err := c.ListTools(ctx)
+		if err != nil {
+			return ToolInfo{}, false, err
+		}
+		cached = make(map[string]ToolInfo, len(tools))
+		for _, tool := range tools {
+			cached[tool.Name] = tool
+		}
+		c.mu.Lock()
+		c.tools = cached
+		c.mu.Unlock()
+	}
+
+	tool, ok := cached[name]
+	return tool, ok, nil
+}
+
+// ListTools 列出指定服务器的工具
+func (m *MCPManager) ListTools(ctx context.Context, serverName string) ([]ToolInfo, error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, err
+	}
+	return client.ListTools(ctx)
+}
+
+// ToolSchema 获取工具的inputSchema，found为false表示服务器未声明该工具
+func (m *MCPManager) ToolSchema(ctx context.Context, serverName, toolName string) (schema map[string]interface{}, found bool, err error) {
+	client, err := m.activeClient(serverName)
+	if err != nil {
+		return nil, false, err
+	}
+	tool, found, err := client.toolInfo(ctx, toolName)
+	if err != nil {
+		return nil, false, err
+	}
+	return tool.InputSchema, found, nil
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// 支持的传输方式
+const (
+	TransportStdio = "stdio" // 本地子进程，按行收发JSON
+	TransportHTTP  = "http"  // Streamable HTTP
+	TransportSSE   = "sse"   // 旧版HTTP+SSE
+)
+
+// transport MCP消息传输层
+type transport interface {
+	// start 开始接收消息：deliver处理每条收到的JSON-RPC消息，closed在连接中断时调用
+	start(deliver func([]byte), closed func()) error
+	// send 发送一条JSON-RPC消息
+	send(ctx context.Context, data []byte) error
+	// close 关闭传输
+	close() error
+}
+
+// initializedHook 会话初始化完成后需要额外动作的传输层
+type initializedHook interface {
+	initialized()
+}
+
+// transportKind 返回服务器配置的传输方式
+func transportKind(config MCPServerConfig) string {
+	switch strings.ToLower(config.Transport) {
+	case "", TransportStdio:
+		return TransportStdio
+	case TransportHTTP, "streamable-http", "streamablehttp":
+		return TransportHTTP
+	case TransportSSE:
+		return TransportSSE
+	default:
+		return strings.ToLower(config.Transport)
+	}
+}
+
+// stdioTransport 基于子进程stdin/stdout的传输层
+type stdioTransport struct {
+	stdin   io.WriteCloser
+	stdout  io.Reader
+	writeMu sync.Mutex // 串行化对stdin的写入
+}
+
+// newStdioTransport 创建stdio传输层
+func newStdioTransport(stdin io.WriteCloser, stdout io.Reader) *stdioTransport {
+	return &stdioTransport{stdin: stdin, stdout: stdout}
+}
+
+// start 启动唯一读取stdout的协程
+func (t *stdioTransport) start(deliver func([]byte), closed func()) error {
+	go func() {
+		defer closed()
+
+		reader := bufio.NewReaderSize(t.stdout, 64*1024)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 {
+				deliver(line)
+			}
+			if err != nil {
+				if err != io.EOF && !isClosedPipe(err) {
+					log.Printf("⚠️ [MCP读取] 读取服务器输出失败: %v", err)
+				}
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// send 写入一行JSON
+func (t *stdioTransport) send(ctx context.Context, data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// close 关闭stdin
+func (t *stdioTransport) close() error {
+	return t.stdin.Close()
+}
+
+// isClosedPipe 判断是否为进程退出导致的管道关闭
+func isClosedPipe(err error) bool {
+	return err == os.ErrClosed || strings.Contains(err.Error(), "file already closed")
+}
+
+// expandHeaders 展开配置中的请求头，并附加Bearer认证
+func expandHeaders(config MCPServerConfig) map[string]string {
+	headers := make(map[string]string, len(config.Headers)+1)
+	for key, value := range config.Headers {
+		headers[key] = os.ExpandEnv(value)
+	}
+	if token := os.ExpandEnv(config.BearerToken); token != "" {
+		headers["Authorization"] = "Bearer " + token
+	}
+	return headers
+}
@@ -0,0 +1,371 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionHeader Streamable HTTP会话ID请求头
+const sessionHeader = "Mcp-Session-Id"
+
+// streamableHTTPTransport Streamable HTTP传输层：每条消息单独POST，
+// 响应可以是JSON或SSE流；会话建立后可通过GET接收服务器推送
+type streamableHTTPTransport struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+
+	deliver func([]byte)
+	closed  func()
+
+	mu        sync.Mutex
+	sessionID string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newStreamableHTTPTransport 创建Streamable HTTP传输层
+func newStreamableHTTPTransport(config MCPServerConfig) *streamableHTTPTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &streamableHTTPTransport{
+		url:        config.URL,
+		headers:    expandHeaders(config),
+		httpClient: &http.Client{}, // 流式响应不设整体超时，由调用方context控制
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// start 记录回调，连接在首次发送时建立
+func (t *streamableHTTPTransport) start(deliver func([]byte), closed func()) error {
+	t.deliver = deliver
+	t.closed = closed
+	return nil
+}
+
+// send POST一条消息并处理JSON或SSE响应
+func (t *streamableHTTPTransport) send(ctx context.Context, data []byte) error {
+	reqCtx, reqCancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(t.ctx, reqCancel)
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		stop()
+		reqCancel()
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	t.applyHeaders(req)
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		stop()
+		reqCancel()
+		return fmt.Errorf("请求失败: %w", err)
+	}
+
+	if sessionID := resp.Header.Get(sessionHeader); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+
+	finish := func() {
+		resp.Body.Close()
+		stop()
+		reqCancel()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusAccepted:
+		finish()
+		return nil
+	case resp.StatusCode == http.StatusNotFound && t.currentSession() != "":
+		// 会话已失效，交给监督器重新建立连接
+		finish()
+		t.closed()
+		return fmt.Errorf("MCP会话已失效")
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		finish()
+		return fmt.Errorf("HTTP错误 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if isEventStream(resp.Header.Get("Content-Type")) {
+		go func() {
+			defer finish()
+			if err := readSSE(resp.Body, func(event, data string) {
+				if event == "" || event == "message" {
+					t.deliver([]byte(data))
+				}
+			}); err != nil && reqCtx.Err() == nil {
+				log.Printf("⚠️ [MCP HTTP] 读取SSE响应失败: %v", err)
+			}
+		}()
+		return nil
+	}
+
+	defer finish()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取响应失败: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		t.deliver(body)
+	}
+	return nil
+}
+
+// initialized 会话建立后打开GET流接收服务器主动推送的消息（服务器可不支持）
+func (t *streamableHTTPTransport) initialized() {
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	t.applyHeaders(req)
+
+	go func() {
+		resp, err := t.httpClient.Do(req)
+		if err != nil {
+			if t.ctx.Err() == nil {
+				log.Printf("⚠️ [MCP HTTP] 打开通知流失败: %v", err)
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK || !isEventStream(resp.Header.Get("Content-Type")) {
+			// 405表示服务器不提供独立通知流，属于正常情况
+			return
+		}
+
+		readSSE(resp.Body, func(event, data string) {
+			if event == "" || event == "message" {
+				t.deliver([]byte(data))
+			}
+		})
+	}()
+}
+
+// close 结束会话并中断所有流
+func (t *streamableHTTPTransport) close() error {
+	if sessionID := t.currentSession(); sessionID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		if req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil); err == nil {
+			t.applyHeaders(req)
+			if resp, err := t.httpClient.Do(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+	}
+	t.cancel()
+	return nil
+}
+
+// applyHeaders 设置配置的请求头和会话ID
+func (t *streamableHTTPTransport) applyHeaders(req *http.Request) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	if sessionID := t.currentSession(); sessionID != "" {
+		req.Header.Set(sessionHeader, sessionID)
+	}
+}
+
+// currentSession 当前会话ID
+func (t *streamableHTTPTransport) currentSession() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+// sseTransport 旧版HTTP+SSE传输层：GET建立事件流，服务器通过endpoint事件
+// 告知POST地址，所有响应和通知都从事件流返回
+type sseTransport struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+	endpoint   string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// newSSETransport 创建HTTP+SSE传输层
+func newSSETransport(config MCPServerConfig) *sseTransport {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sseTransport{
+		url:        config.URL,
+		headers:    expandHeaders(config),
+		httpClient: &http.Client{},
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// start 建立事件流并等待服务器下发消息端点
+func (t *sseTransport) start(deliver func([]byte), closed func()) error {
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return fmt.Errorf("创建SSE请求失败: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接SSE端点失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return fmt.Errorf("SSE端点返回错误 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	endpointCh := make(chan string, 1)
+	go func() {
+		defer resp.Body.Close()
+		defer closed()
+
+		err := readSSE(resp.Body, func(event, data string) {
+			switch event {
+			case "endpoint":
+				select {
+				case endpointCh <- data:
+				default:
+				}
+			case "", "message":
+				deliver([]byte(data))
+			}
+		})
+		if err != nil && t.ctx.Err() == nil {
+			log.Printf("⚠️ [MCP SSE] 事件流中断: %v", err)
+		}
+	}()
+
+	select {
+	case endpoint := <-endpointCh:
+		resolved, err := resolveEndpoint(t.url, endpoint)
+		if err != nil {
+			t.cancel()
+			return err
+		}
+		t.endpoint = resolved
+		return nil
+	case <-time.After(15 * time.Second):
+		t.cancel()
+		return fmt.Errorf("等待SSE endpoint事件超时")
+	}
+}
+
+// send POST消息到服务器指定的端点
+func (t *sseTransport) send(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("HTTP错误 %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// close 中断事件流
+func (t *sseTransport) close() error {
+	t.cancel()
+	return nil
+}
+
+// resolveEndpoint 将endpoint事件中的相对地址解析为绝对URL
+func resolveEndpoint(base, endpoint string) (string, error) {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("无效的SSE地址: %w", err)
+	}
+	ref, err := url.Parse(strings.TrimSpace(endpoint))
+	if err != nil {
+		return "", fmt.Errorf("无效的endpoint: %w", err)
+	}
+	return baseURL.ResolveReference(ref).String(), nil
+}
+
+// isEventStream 判断Content-Type是否为SSE
+func isEventStream(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "text/event-stream"
+}
+
+// readSSE 解析Server-Sent Events流，对每个事件调用handle
+func readSSE(r io.Reader, handle func(event, data string)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	var event string
+	var data []string
+
+	flush := func() {
+		if len(data) > 0 {
+			handle(event, strings.Join(data, "\n"))
+		}
+		event = ""
+		data = data[:0]
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case line == "":
+			if err == nil {
+				flush()
+			}
+		case strings.HasPrefix(line, ":"):
+			// 注释/心跳
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+
+		if err != nil {
+			flush()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// handleRPC 远程服务器桩的最小MCP实现
+func handleRPC(msg rpcMessage) map[string]interface{} {
+	result := map[string]interface{}{}
+	switch msg.Method {
+	case "initialize":
+		result["protocolVersion"] = protocolVersion
+		result["capabilities"] = map[string]interface{}{"tools": map[string]interface{}{}}
+	case "tools/call":
+		var params struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		result["content"] = []map[string]string{{"type": "text", "text": "remote:" + params.Name}}
+	}
+	return map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result}
+}
+
+// newRemoteManager 写入单个远程服务器配置并创建管理器
+func newRemoteManager(t *testing.T, server map[string]interface{}) *MCPManager {
+	t.Helper()
+	configFile := filepath.Join(t.TempDir(), "mcp_config.json")
+	data, _ := json.Marshal(map[string]interface{}{
+		"mcpServers": map[string]interface{}{"remote": server},
+	})
+	if err := os.WriteFile(configFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	manager, err := NewMCPManager(configFile)
+	if err != nil {
+		t.Fatalf("NewMCPManager() error = %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+	return manager
+}
+
+func expectRemoteTool(t *testing.T, manager *MCPManager) {
+	t.Helper()
+	if err := manager.StartServer("remote"); err != nil {
+		t.Fatalf("StartServer() error = %v", err)
+	}
+	resp, err := manager.CallTool("remote", "search", nil)
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+	var result struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	json.Unmarshal(resp.Result, &result)
+	if len(result.Content) == 0 || result.Content[0].Text != "remote:search" {
+		t.Errorf("工具结果 = %s", resp.Result)
+	}
+}
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	t.Setenv("ZF_TEST_MCP_TOKEN", "secret")
+
+	var sessionChecked atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("X-Lab") != "zotero" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			http.Error(w, "no stream", http.StatusMethodNotAllowed)
+			return
+		case http.MethodDelete:
+			return
+		}
+
+		var msg rpcMessage
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &msg)
+		if msg.Method != "initialize" {
+			if r.Header.Get(sessionHeader) != "sess-1" {
+				http.Error(w, "missing session", http.StatusBadRequest)
+				return
+			}
+			sessionChecked.Store(true)
+		}
+		if len(msg.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		data, _ := json.Marshal(handleRPC(msg))
+		if msg.Method == "initialize" {
+			w.Header().Set(sessionHeader, "sess-1")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(data)
+			return
+		}
+
+		// 工具调用以SSE流返回，先推送一条进度通知
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keepalive\n\n")
+		fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\",\"params\":{\"level\":\"info\",\"data\":\"working\"}}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+	}))
+	t.Cleanup(server.Close) // 先于管理器注册，确保管理器先关闭连接
+
+	manager := newRemoteManager(t, map[string]interface{}{
+		"enabled":     true,
+		"transport":   "http",
+		"url":         server.URL + "/mcp",
+		"headers":     map[string]string{"X-Lab": "zotero"},
+		"bearerToken": "${ZF_TEST_MCP_TOKEN}",
+		"timeout":     5,
+	})
+	expectRemoteTool(t, manager)
+
+	if !sessionChecked.Load() {
+		t.Error("后续请求未携带Mcp-Session-Id")
+	}
+	if status := manager.Status()[0]; status.Transport != TransportHTTP {
+		t.Errorf("Status().Transport = %q", status.Transport)
+	}
+}
+
+func TestSSETransport(t *testing.T) {
+	messages := make(chan []byte, 8)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		flusher := w.(http.Flusher)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /messages?sessionId=abc\n\n")
+		flusher.Flush()
+		for {
+			select {
+			case data := <-messages:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sessionId") != "abc" {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		var msg rpcMessage
+		json.NewDecoder(r.Body).Decode(&msg)
+		w.WriteHeader(http.StatusAccepted)
+		if len(msg.ID) > 0 {
+			data, _ := json.Marshal(handleRPC(msg))
+			messages <- data
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close) // 先于管理器注册，确保管理器先关闭连接
+
+	manager := newRemoteManager(t, map[string]interface{}{
+		"enabled":     true,
+		"transport":   "sse",
+		"url":         server.URL + "/sse",
+		"bearerToken": "token",
+		"timeout":     5,
+	})
+	expectRemoteTool(t, manager)
+}
+
+func TestReadSSE(t *testing.T) {
+	input := "event: endpoint\ndata: /a\n\n: comment\ndata: line1\ndata: line2\n\ndata: tail"
+	var got []string
+	err := readSSE(strings.NewReader(input), func(event, data string) {
+		got = append(got, event+"|"+data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"endpoint|/a", "|line1\nline2", "|tail"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("readSSE() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+package mcp
+
+import (
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+)
+
+// UsageBudget 配置中的AI用量预算
+func UsageBudget(cfg *config.Config) core.UsageBudget {
+	return core.UsageBudget{
+		DailyTokens:     cfg.AIDailyTokenBudget,
+		MonthlyTokens:   cfg.AIMonthlyTokenBudget,
+		DailyRequests:   cfg.AIDailyRequestBudget,
+		MonthlyRequests: cfg.AIMonthlyRequestBudget,
+		Action:          cfg.AIBudgetAction,
+		FallbackModel:   cfg.AIFallbackModel,
+	}
+}
+
+// MeteredClientConfig 由配置构造带用量记录的AI客户端参数（config包不依赖core，映射放在调用方）
+func MeteredClientConfig(cfg *config.Config) core.MeteredClientConfig {
+	return core.MeteredClientConfig{
+		APIKey:   cfg.AIAPIKey,
+		BaseURL:  cfg.AIBaseURL,
+		Model:    cfg.AIModel,
+		UsageDir: cfg.UsageDir,
+		Budget:   UsageBudget(cfg),
+	}
+}
+
+// NewAIClient 创建记录用量并执行预算限制的AI客户端，同一用量目录共用一个账本；
+// MCP服务、Web和CLI都通过它创建客户端
+func NewAIClient(cfg *config.Config) core.AIClient {
+	return core.NewMeteredClientFromConfig(MeteredClientConfig(cfg))
+}
@@ -0,0 +1,28 @@
+package mcp
+
+import (
+	"testing"
+
+	"zoteroflow2-server/config"
+)
+
+func TestMeteredClientConfig(t *testing.T) {
+	cfg := &config.Config{
+		AIAPIKey:           "test_api_key",
+		AIBaseURL:          "https://example.com/v1",
+		AIModel:            "glm-4.6",
+		UsageDir:           "/test/usage",
+		AIDailyTokenBudget: 1000,
+		AIBudgetAction:     "downgrade",
+		AIFallbackModel:    "glm-4-flash",
+	}
+
+	client := MeteredClientConfig(cfg)
+	if client.APIKey != cfg.AIAPIKey || client.BaseURL != cfg.AIBaseURL || client.Model != cfg.AIModel || client.UsageDir != cfg.UsageDir {
+		t.Errorf("MeteredClientConfig() = %+v", client)
+	}
+	if client.Budget != UsageBudget(cfg) || client.Budget.DailyTokens != 1000 ||
+		client.Budget.Action != "downgrade" || client.Budget.FallbackModel != "glm-4-flash" {
+		t.Errorf("Budget = %+v", client.Budget)
+	}
+}
@@ -0,0 +1,476 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+)
+
+const (
+	resourceScheme      = "zoteroflow://results/"
+	defaultFulltextSize = 20000 // get_fulltext默认返回的最大字符数
+	askDocumentMaxChars = 30000 // ask_document送入模型的全文上限
+	parseJobTimeout     = 30 * time.Minute
+)
+
+// ZoteroFlowServer 将ZoteroFlow的文献库能力以MCP工具形式暴露
+type ZoteroFlowServer struct {
+	*Server
+	config   *config.Config
+	zoteroDB *core.ZoteroDB
+	prompts  *core.PromptLibrary
+
+	mu   sync.Mutex
+	jobs map[string]*parseJob
+}
+
+// parseJob 后台PDF解析任务
+type parseJob struct {
+	ItemKey    string    `json:"item_key"`
+	Title      string    `json:"title"`
+	Status     string    `json:"status"` // running/completed/failed
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+}
+
+// NewZoteroFlowServer 创建ZoteroFlow MCP服务器
+func NewZoteroFlowServer(cfg *config.Config, version string) (*ZoteroFlowServer, error) {
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+
+	s := &ZoteroFlowServer{
+		Server:   NewServer("zoteroflow2", version),
+		config:   cfg,
+		zoteroDB: zoteroDB,
+		prompts:  core.LoadPromptLibrary(cfg.PromptsDir, cfg.PromptLanguage),
+		jobs:     make(map[string]*parseJob),
+	}
+	s.registerTools()
+	s.SetResourceProvider(s)
+	return s, nil
+}
+
+// Close 释放数据库连接
+func (s *ZoteroFlowServer) Close() error {
+	return s.zoteroDB.Close()
+}
+
+// itemArgs 定位文献的通用参数
+type itemArgs struct {
+	ItemKey string `json:"item_key"`
+	ItemID  int    `json:"item_id"`
+}
+
+// itemSchema 定位文献参数的JSON Schema属性
+func itemSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"item_key": map[string]interface{}{"type": "string", "description": "Zotero条目Key，如 ABCD1234"},
+		"item_id":  map[string]interface{}{"type": "integer", "description": "Zotero条目ID（item_key二选一）"},
+	}
+}
+
+// objectSchema 构建object类型的inputSchema
+func objectSchema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// registerTools 注册全部工具
+func (s *ZoteroFlowServer) registerTools() {
+	s.AddTool(ServerTool{
+		Name:        "search_library",
+		Description: "按标题关键词搜索本地Zotero文献库",
+		InputSchema: objectSchema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string", "description": "标题关键词"},
+			"limit": map[string]interface{}{"type": "integer", "description": "最多返回条数，默认10"},
+		}, "query"),
+		Handler: s.searchLibrary,
+	})
+	s.AddTool(ServerTool{
+		Name:        "get_item",
+		Description: "获取文献的元数据（作者、年份、DOI、摘要、标签）及解析状态",
+		InputSchema: objectSchema(itemSchema()),
+		Handler:     s.getItem,
+	})
+	s.AddTool(ServerTool{
+		Name:        "get_fulltext",
+		Description: "获取已解析文献的Markdown全文",
+		InputSchema: objectSchema(mergeProps(itemSchema(), map[string]interface{}{
+			"offset":    map[string]interface{}{"type": "integer", "description": "起始字符位置，用于分段读取"},
+			"max_chars": map[string]interface{}{"type": "integer", "description": fmt.Sprintf("最多返回字符数，默认%d", defaultFulltextSize)},
+		})),
+		Handler: s.getFulltext,
+	})
+	s.AddTool(ServerTool{
+		Name:        "list_collections",
+		Description: "列出Zotero文献库中的分类及文献数量",
+		InputSchema: objectSchema(map[string]interface{}{}),
+		Handler:     s.listCollections,
+	})
+	s.AddTool(ServerTool{
+		Name:        "parse_pdf",
+		Description: "使用MinerU在后台解析文献PDF，用get_parse_status查询进度",
+		InputSchema: objectSchema(itemSchema()),
+		Handler:     s.parsePDF,
+	})
+	s.AddTool(ServerTool{
+		Name:        "get_parse_status",
+		Description: "查询文献的PDF解析状态",
+		InputSchema: objectSchema(itemSchema()),
+		Handler:     s.getParseStatus,
+	})
+	s.AddTool(ServerTool{
+		Name:        "ask_document",
+		Description: "基于已解析文献的全文回答问题",
+		InputSchema: objectSchema(mergeProps(itemSchema(), map[string]interface{}{
+			"question": map[string]interface{}{"type": "string", "description": "关于该文献的问题"},
+		}), "question"),
+		Handler: s.askDocument,
+	})
+}
+
+// mergeProps 合并schema属性
+func mergeProps(a, b map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(a)+len(b))
+	for k, v := range a {
+		merged[k] = v
+	}
+	for k, v := range b {
+		merged[k] = v
+	}
+	return merged
+}
+
+// toJSON 工具结果统一以缩进JSON返回
+func toJSON(v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("序列化结果失败: %w", err)
+	}
+	return string(data), nil
+}
+
+// resolveItem 根据参数定位文献
+func (s *ZoteroFlowServer) resolveItem(args json.RawMessage) (*core.ZoteroItem, error) {
+	var a itemArgs
+	if err := json.Unmarshal(args, &a); err != nil {
+		return nil, fmt.Errorf("参数格式错误: %w", err)
+	}
+	switch {
+	case a.ItemKey != "":
+		return s.zoteroDB.GetItemByKey(a.ItemKey)
+	case a.ItemID > 0:
+		return s.zoteroDB.GetItemByID(a.ItemID)
+	default:
+		return nil, fmt.Errorf("需要提供 item_key 或 item_id")
+	}
+}
+
+// resolveParsed 定位文献及其解析结果
+func (s *ZoteroFlowServer) resolveParsed(args json.RawMessage) (*core.ZoteroItem, *core.ParsedResult, error) {
+	item, err := s.resolveItem(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	parsed, err := core.FindParsedResult(s.config.ResultsDir, item)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parsed == nil {
+		return item, nil, fmt.Errorf("文献《%s》尚未解析，请先调用 parse_pdf", item.Title)
+	}
+	return item, parsed, nil
+}
+
+// searchLibrary search_library工具
+func (s *ZoteroFlowServer) searchLibrary(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil {
+		return "", fmt.Errorf("参数格式错误: %w", err)
+	}
+	if a.Limit <= 0 {
+		a.Limit = 10
+	}
+
+	results, err := s.zoteroDB.SearchByTitle(a.Query, a.Limit)
+	if err != nil {
+		return "", err
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("未找到与 \"%s\" 相关的文献", a.Query), nil
+	}
+	return toJSON(results)
+}
+
+// getItem get_item工具
+func (s *ZoteroFlowServer) getItem(ctx context.Context, args json.RawMessage) (string, error) {
+	item, err := s.resolveItem(args)
+	if err != nil {
+		return "", err
+	}
+
+	result := map[string]interface{}{"item": item, "parsed": false}
+	if parsed, err := core.FindParsedResult(s.config.ResultsDir, item); err == nil && parsed != nil {
+		result["parsed"] = true
+		result["resource_uri"] = resourceURI(parsed.Name)
+	}
+	return toJSON(result)
+}
+
+// getFulltext get_fulltext工具
+func (s *ZoteroFlowServer) getFulltext(ctx context.Context, args json.RawMessage) (string, error) {
+	_, parsed, err := s.resolveParsed(args)
+	if err != nil {
+		return "", err
+	}
+
+	var a struct {
+		Offset   int `json:"offset"`
+		MaxChars int `json:"max_chars"`
+	}
+	json.Unmarshal(args, &a)
+	if a.MaxChars <= 0 {
+		a.MaxChars = defaultFulltextSize
+	}
+
+	content, err := parsed.ReadFullText()
+	if err != nil {
+		return "", err
+	}
+
+	runes := []rune(content)
+	if a.Offset < 0 || a.Offset >= len(runes) {
+		a.Offset = 0
+	}
+	end := a.Offset + a.MaxChars
+	if end > len(runes) {
+		end = len(runes)
+	}
+
+	text := string(runes[a.Offset:end])
+	if end < len(runes) {
+		text += fmt.Sprintf("\n\n[已截断：共 %d 字符，下一段请使用 offset=%d]", len(runes), end)
+	}
+	return text, nil
+}
+
+// listCollections list_collections工具
+func (s *ZoteroFlowServer) listCollections(ctx context.Context, args json.RawMessage) (string, error) {
+	collections, err := s.zoteroDB.ListCollections()
+	if err != nil {
+		return "", err
+	}
+	if len(collections) == 0 {
+		return "文献库中没有分类", nil
+	}
+	return toJSON(collections)
+}
+
+// parsePDF parse_pdf工具：在后台启动解析任务
+func (s *ZoteroFlowServer) parsePDF(ctx context.Context, args json.RawMessage) (string, error) {
+	if s.config.MineruToken == "" {
+		return "", fmt.Errorf("未配置MINERU_TOKEN，无法解析PDF")
+	}
+	item, err := s.resolveItem(args)
+	if err != nil {
+		return "", err
+	}
+	if item.PDFPath == "" {
+		return "", fmt.Errorf("文献《%s》没有可用的PDF附件", item.Title)
+	}
+
+	s.mu.Lock()
+	if job, ok := s.jobs[item.ItemKey]; ok && job.Status == "running" {
+		s.mu.Unlock()
+		return fmt.Sprintf("文献《%s》正在解析中", item.Title), nil
+	}
+	job := &parseJob{ItemKey: item.ItemKey, Title: item.Title, Status: "running", StartedAt: time.Now()}
+	s.jobs[item.ItemKey] = job
+	s.mu.Unlock()
+
+	go func() {
+		parseCtx, cancel := context.WithTimeout(context.Background(), parseJobTimeout)
+		defer cancel()
+
+		client := core.NewMinerUClientWithResultsDir(s.config.MineruAPIURL, s.config.MineruToken, s.config.ResultsDir)
+		client.IndexDir = s.config.IndexDir
+		if s.config.AIAPIKey != "" {
+			client.Summarizer = core.NewSummarizer(NewAIClient(s.config), s.config.SummaryCacheDir)
+		}
+		_, err := client.ParseItem(parseCtx, item)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		job.FinishedAt = time.Now()
+		if err != nil {
+			job.Status = "failed"
+			job.Error = err.Error()
+			log.Printf("⚠️ [MCP服务器] 解析 %s 失败: %v", item.Title, err)
+			return
+		}
+		job.Status = "completed"
+	}()
+
+	return fmt.Sprintf("已开始解析《%s》，请稍后使用 get_parse_status 查询进度", item.Title), nil
+}
+
+// getParseStatus get_parse_status工具
+func (s *ZoteroFlowServer) getParseStatus(ctx context.Context, args json.RawMessage) (string, error) {
+	item, err := s.resolveItem(args)
+	if err != nil {
+		return "", err
+	}
+
+	status := map[string]interface{}{
+		"item_key": item.ItemKey,
+		"title":    item.Title,
+		"status":   "not_parsed",
+	}
+
+	s.mu.Lock()
+	if job, ok := s.jobs[item.ItemKey]; ok {
+		copied := *job
+		status["status"] = copied.Status
+		status["job"] = copied
+	}
+	s.mu.Unlock()
+
+	if parsed, err := core.FindParsedResult(s.config.ResultsDir, item); err == nil && parsed != nil {
+		if status["status"] != "running" {
+			status["status"] = "completed"
+		}
+		status["result"] = parsed.Name
+		status["resource_uri"] = resourceURI(parsed.Name)
+	} else if status["status"] == "not_parsed" && item.PDFPath != "" {
+		// 没有结果目录时，参考解析记录中最近一次失败的原因
+		if records, err := core.GetParseRecords(""); err == nil {
+			for i := len(records) - 1; i >= 0; i-- {
+				if records[i].PDFPath == item.PDFPath && records[i].Status == "failed" {
+					status["status"] = "failed"
+					status["error"] = records[i].ErrorMessage
+					break
+				}
+			}
+		}
+	}
+
+	return toJSON(status)
+}
+
+// askDocument ask_document工具
+func (s *ZoteroFlowServer) askDocument(ctx context.Context, args json.RawMessage) (string, error) {
+	var a struct {
+		Question string `json:"question"`
+	}
+	if err := json.Unmarshal(args, &a); err != nil || strings.TrimSpace(a.Question) == "" {
+		return "", fmt.Errorf("需要提供 question")
+	}
+	if s.config.AIAPIKey == "" {
+		return "", fmt.Errorf("未配置AI_API_KEY，无法进行文献问答")
+	}
+
+	item, parsed, err := s.resolveParsed(args)
+	if err != nil {
+		return "", err
+	}
+	content, err := parsed.ReadFullText()
+	if err != nil {
+		return "", err
+	}
+	if runes := []rune(content); len(runes) > askDocumentMaxChars {
+		content = string(runes[:askDocumentMaxChars])
+	}
+
+	aiClient := NewAIClient(s.config)
+	askCtx, cancel := context.WithTimeout(core.WithUsageFeature(ctx, core.FeatureAsk), time.Duration(s.config.AITimeout)*time.Second*3)
+	defer cancel()
+
+	resp, err := aiClient.Chat(askCtx, &core.AIRequest{
+		Model: s.config.AIModel,
+		Messages: []core.ChatMessage{
+			{
+				Role:    "system",
+				Content: s.prompts.System(core.PromptDocumentQA, core.PromptData{Title: item.Title, Context: content}),
+			},
+			{Role: "user", Content: a.Question},
+		},
+		MaxTokens:   2000,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("AI请求失败: %w", err)
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		return "", fmt.Errorf("AI响应为空")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// resourceURI 解析结果对应的资源URI
+func resourceURI(name string) string {
+	return resourceScheme + url.PathEscape(name) + "/full.md"
+}
+
+// ListResources 列出所有已解析文献的full.md
+func (s *ZoteroFlowServer) ListResources() ([]Resource, error) {
+	results, err := core.ListParsedResults(s.config.ResultsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := make([]Resource, 0, len(results))
+	for _, result := range results {
+		description := "MinerU解析的Markdown全文"
+		if result.Info != nil && result.Info.ItemKey != "" {
+			description += "，Zotero条目 " + result.Info.ItemKey
+		}
+		resources = append(resources, Resource{
+			URI:         resourceURI(result.Name),
+			Name:        result.Title(),
+			Description: description,
+			MimeType:    "text/markdown",
+		})
+	}
+	return resources, nil
+}
+
+// ReadResource 读取资源内容
+func (s *ZoteroFlowServer) ReadResource(uri string) (string, string, error) {
+	if !strings.HasPrefix(uri, resourceScheme) || !strings.HasSuffix(uri, "/full.md") {
+		return "", "", fmt.Errorf("未知资源: %s", uri)
+	}
+	name, err := url.PathUnescape(strings.TrimSuffix(strings.TrimPrefix(uri, resourceScheme), "/full.md"))
+	if err != nil {
+		return "", "", fmt.Errorf("无效的资源URI: %s", uri)
+	}
+
+	parsed, err := core.GetParsedResult(s.config.ResultsDir, name)
+	if err != nil {
+		return "", "", err
+	}
+	content, err := parsed.ReadFullText()
+	if err != nil {
+		return "", "", err
+	}
+	return content, "text/markdown", nil
+}
@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/core"
+)
+
+// HandleItemAnnotations 返回条目在Zotero中的批注：GET /api/items/:key/annotations?format=json|md&by=color|page
+//
+// :key 可以是条目Key或解析结果目录名
+func HandleItemAnnotations(c *gin.Context) {
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "连接Zotero数据库失败: " + err.Error()})
+		return
+	}
+	defer zoteroDB.Close()
+
+	item, err := zoteroDB.ResolveItem(c.Param("key"), cfg.ResultsDir)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	annotations, err := zoteroDB.GetItemAnnotations(item.ItemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "md" {
+		markdown, err := core.RenderAnnotationsMarkdown(item, annotations, c.Query("by"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", "attachment; filename=\"annotations-"+item.ItemKey+".md\"")
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(markdown))
+		return
+	}
+	if annotations == nil {
+		annotations = []core.ZoteroAnnotation{}
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item, "annotations": annotations})
+}
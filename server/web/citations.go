@@ -0,0 +1,89 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+)
+
+// groundedCoverage 问题词项至少有这一比例出现在检索片段中，才认为本地文献可以回答
+const groundedCoverage = 0.6
+
+// groundedAnswer 基于已解析文献的片段回答，返回带引用的答案；本地文献不相关时返回false
+func groundedAnswer(query string, cfg *config.Config, aiClient core.AIClient) (AskResponse, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	pipeline := core.NewRAGPipeline(cfg.ResultsDir)
+	chunks, err := pipeline.Retrieve(ctx, query, core.RAGOptions{})
+	if err != nil {
+		log.Printf("检索本地文献失败: %v", err)
+		return AskResponse{}, false
+	}
+	if len(chunks) == 0 || core.QueryCoverage(query, chunks) < groundedCoverage {
+		return AskResponse{}, false
+	}
+
+	answer, err := core.AnswerWithChunks(ctx, aiClient, getPromptLibrary(cfg), query, chunks)
+	if err != nil {
+		log.Printf("基于文献片段回答失败: %v", err)
+		return AskResponse{}, false
+	}
+	if len(answer.Unsupported) > 0 {
+		log.Printf("⚠️ 答案中有 %d 句未找到依据", len(answer.Unsupported))
+	}
+
+	response := AskResponse{
+		Answer:      answer.Answer,
+		Citations:   withCitationURLs(cfg.ResultsDir, answer.Citations),
+		Unsupported: answer.Unsupported,
+	}
+	if len(response.Citations) > 0 {
+		response.PDFURL = response.Citations[0].URL
+	}
+	return response, true
+}
+
+// withCitationURLs 为引用填写可跳转到对应页的PDF链接（结果目录中有source.pdf时）
+func withCitationURLs(resultsDir string, citations []core.Citation) []core.Citation {
+	for i := range citations {
+		if _, err := os.Stat(filepath.Join(resultsDir, citations[i].Document, "source.pdf")); err != nil {
+			continue
+		}
+		citations[i].URL = "/api/results/" + url.PathEscape(citations[i].Document) + "/pdf"
+		if citations[i].Page > 0 {
+			citations[i].URL += fmt.Sprintf("#page=%d", citations[i].Page)
+		}
+	}
+	return citations
+}
+
+// HandleResultPDF 返回解析结果目录中的原始PDF：GET /api/results/:name/pdf
+func HandleResultPDF(c *gin.Context) {
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	result, err := core.GetParsedResult(cfg.ResultsDir, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	pdfPath := filepath.Join(result.Dir, "source.pdf")
+	if _, err := os.Stat(pdfPath); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该文献没有保存原始PDF"})
+		return
+	}
+	c.File(pdfPath)
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/core"
+)
+
+// CompareRequest 文献比较请求：documents为解析结果目录名或Zotero条目Key
+type CompareRequest struct {
+	Documents []string             `json:"documents"`
+	Aspects   []core.CompareAspect `json:"aspects,omitempty"` // 为空时使用默认维度
+}
+
+// CompareResponse 比较结果，附带Markdown渲染
+type CompareResponse struct {
+	*core.Comparison
+	Markdown string `json:"markdown"`
+}
+
+// HandleCompare 对照比较多篇文献：POST /api/compare
+func HandleCompare(c *gin.Context) {
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Documents) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请至少提供两篇文献"})
+		return
+	}
+	for _, aspect := range req.Aspects {
+		if aspect.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "比较维度缺少名称"})
+			return
+		}
+	}
+	manager, cfg, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	for _, ref := range req.Documents {
+		if _, err := manager.ResolveDocument(ref); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conversationTimeout)
+	defer cancel()
+	comparison, err := manager.Compare(ctx, req.Documents, req.Aspects)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 复制后再补充链接，避免修改已保存对话中的引用
+	comparison.Citations = withCitationURLs(cfg.ResultsDir, append([]core.Citation(nil), comparison.Citations...))
+	c.JSON(http.StatusOK, CompareResponse{Comparison: comparison, Markdown: comparison.Markdown()})
+}
@@ -0,0 +1,265 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// conversationTimeout 单轮对话的超时时间
+const conversationTimeout = 90 * time.Second
+
+// 进程内共享的对话管理器，对话写入磁盘，跨请求和重启保留
+var (
+	sharedConversations     *core.AIConversationManager
+	sharedConversationsErr  error
+	sharedConversationsOnce sync.Once
+)
+
+// getConversationManager 获取共享的对话管理器
+func getConversationManager() (*core.AIConversationManager, *config.Config, error) {
+	cfg := loadConfig()
+	if cfg == nil {
+		return nil, nil, fmt.Errorf("配置加载失败")
+	}
+	sharedConversationsOnce.Do(func() {
+		if cfg.AIAPIKey == "" {
+			sharedConversationsErr = fmt.Errorf("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置")
+			return
+		}
+		zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+		if err != nil {
+			log.Printf("连接Zotero数据库失败，对话仅使用解析结果作为上下文: %v", err)
+			zoteroDB = nil
+		}
+		manager := core.NewAIConversationManager(mcp.NewAIClient(cfg), zoteroDB)
+		manager.SetRAGPipeline(core.NewRAGPipeline(cfg.ResultsDir))
+		manager.SetConversationStore(core.NewConversationStore(cfg.ConversationsDir))
+		manager.SetContextBudget(core.ResolveContextBudget(cfg.AIModel, cfg.AIContextWindow))
+		manager.SetPromptLibrary(getPromptLibrary(cfg))
+		sharedConversations = manager
+	})
+	return sharedConversations, cfg, sharedConversationsErr
+}
+
+// ConversationRequest 新建对话或继续对话的请求
+type ConversationRequest struct {
+	Message       string   `json:"message"`
+	DocumentNames []string `json:"document_names,omitempty"` // 限定文献（解析结果目录名）
+}
+
+// conversationReply 将对话最后一条回复转换为问答响应
+func conversationReply(cfg *config.Config, conv *core.Conversation) AskResponse {
+	msg := conv.Messages[len(conv.Messages)-1]
+	usage := conv.Usage
+	response := AskResponse{Answer: msg.Content, ConversationID: conv.ID, Usage: &usage}
+	if msg.Metadata != nil {
+		citations := append([]core.Citation(nil), msg.Metadata.Citations...)
+		response.Citations = withCitationURLs(cfg.ResultsDir, citations)
+		response.Unsupported = msg.Metadata.Unsupported
+	}
+	if len(response.Citations) > 0 {
+		response.PDFURL = response.Citations[0].URL
+	}
+	return response
+}
+
+// continueConversation 在已有对话中回答问题，供/api/ask携带conversation_id时使用
+func continueConversation(ctx context.Context, convID, query string) (AskResponse, error) {
+	manager, cfg, err := getConversationManager()
+	if err != nil {
+		return AskResponse{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, conversationTimeout)
+	defer cancel()
+
+	conv, err := manager.ContinueConversation(ctx, convID, query)
+	if err != nil {
+		return AskResponse{}, err
+	}
+	return conversationReply(cfg, conv), nil
+}
+
+// conversationManagerOrAbort 获取对话管理器，失败时写入错误响应
+func conversationManagerOrAbort(c *gin.Context) (*core.AIConversationManager, *config.Config, bool) {
+	manager, cfg, err := getConversationManager()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return nil, nil, false
+	}
+	return manager, cfg, true
+}
+
+// conversationErrorStatus 对话不存在时返回404
+func conversationErrorStatus(err error) int {
+	if errors.Is(err, core.ErrConversationNotFound) || errors.Is(err, core.ErrInvalidConversationID) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// HandleListConversations 列出历史对话：GET /api/conversations
+func HandleListConversations(c *gin.Context) {
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	summaries, err := manager.ListConversations()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if summaries == nil {
+		summaries = []core.ConversationSummary{}
+	}
+	c.JSON(http.StatusOK, gin.H{"conversations": summaries})
+}
+
+// HandleCreateConversation 开始新对话：POST /api/conversations
+func HandleCreateConversation(c *gin.Context) {
+	var req ConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Message) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入有效问题"})
+		return
+	}
+	manager, cfg, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), conversationTimeout)
+	defer cancel()
+
+	var conv *core.Conversation
+	var err error
+	if len(req.DocumentNames) > 0 {
+		conv, err = manager.StartConversationWithDocument(ctx, req.Message, &core.DocumentContext{DocumentNames: req.DocumentNames})
+	} else {
+		conv, err = manager.StartConversation(ctx, req.Message, nil)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conversationReply(cfg, conv))
+}
+
+// HandleGetConversation 获取对话全部消息：GET /api/conversations/:id
+func HandleGetConversation(c *gin.Context) {
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	conv, err := manager.GetConversation(c.Param("id"))
+	if err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conv)
+}
+
+// HandleContinueConversation 在对话中继续提问：POST /api/conversations/:id/messages
+func HandleContinueConversation(c *gin.Context) {
+	var req ConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Message) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入有效问题"})
+		return
+	}
+	response, err := continueConversation(c.Request.Context(), c.Param("id"), req.Message)
+	if err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// HandleRenameConversation 重命名对话：PATCH /api/conversations/:id {"title": "..."}
+func HandleRenameConversation(c *gin.Context) {
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Title) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "标题不能为空"})
+		return
+	}
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	conv, err := manager.RenameConversation(c.Param("id"), req.Title)
+	if err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conv.Summary())
+}
+
+// HandleDeleteConversation 删除对话：DELETE /api/conversations/:id
+func HandleDeleteConversation(c *gin.Context) {
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	if err := manager.DeleteConversation(c.Param("id")); err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": c.Param("id")})
+}
+
+// HandleForkConversation 从对话创建分支：POST /api/conversations/:id/fork {"at": 消息数}
+func HandleForkConversation(c *gin.Context) {
+	var req struct {
+		At int `json:"at"`
+	}
+	// 请求体可省略，默认复制全部消息
+	_ = c.ShouldBindJSON(&req)
+
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	conv, err := manager.ForkConversation(c.Param("id"), req.At)
+	if err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conv.Summary())
+}
+
+// HandleExportConversation 导出对话：GET /api/conversations/:id/export?format=md|json
+func HandleExportConversation(c *gin.Context) {
+	manager, _, ok := conversationManagerOrAbort(c)
+	if !ok {
+		return
+	}
+	conv, err := manager.GetConversation(c.Param("id"))
+	if err != nil {
+		c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "md"))
+	data, err := core.ExportConversation(conv, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	contentType, ext := "text/markdown; charset=utf-8", "md"
+	if format == "json" {
+		contentType, ext = "application/json; charset=utf-8", "json"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, conv.ID, ext))
+	c.Data(http.StatusOK, contentType, data)
+}
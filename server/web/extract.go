@@ -0,0 +1,136 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// extractionTimeout 一次抽取请求的超时时间
+const extractionTimeout = 10 * time.Minute
+
+// ExtractRequest 信息抽取请求：schema和fields二选一，documents和collection二选一
+type ExtractRequest struct {
+	Schema     *core.ExtractionSchema `json:"schema,omitempty"`
+	Fields     string                 `json:"fields,omitempty"` // "名称[:类型[:说明]], ..."
+	Name       string                 `json:"name,omitempty"`   // 使用fields时的模式名
+	Documents  []string               `json:"documents,omitempty"`
+	Collection string                 `json:"collection,omitempty"`
+	Format     string                 `json:"format,omitempty"` // json（默认）、csv、excel、md
+	Force      bool                   `json:"force,omitempty"`
+}
+
+// HandleExtract 按抽取模式生成文献信息矩阵，json格式直接返回矩阵，其他格式作为附件下载
+func HandleExtract(c *gin.Context) {
+	var req ExtractRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求格式错误"})
+		return
+	}
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+	if cfg.AIAPIKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置"})
+		return
+	}
+
+	schema, err := extractionSchema(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	results, err := extractionTargets(cfg, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), extractionTimeout)
+	defer cancel()
+	extractor := core.NewExtractor(
+		mcp.NewAIClient(cfg),
+		core.NewRAGPipeline(cfg.ResultsDir),
+		cfg.AIResponseFormat,
+	)
+	matrix := extractor.ExtractAll(ctx, schema, results, req.Force, nil)
+
+	if req.Format == "" || req.Format == "json" {
+		c.JSON(http.StatusOK, matrix)
+		return
+	}
+	data, contentType, ext, err := matrix.Export(req.Format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, schema.Name, ext))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// extractionSchema 从请求中取得抽取模式
+func extractionSchema(req *ExtractRequest) (*core.ExtractionSchema, error) {
+	switch {
+	case req.Schema != nil && req.Fields != "":
+		return nil, fmt.Errorf("schema和fields只能指定一个")
+	case req.Schema != nil:
+		if err := req.Schema.Validate(); err != nil {
+			return nil, err
+		}
+		return req.Schema, nil
+	case req.Fields != "":
+		name := req.Name
+		if name == "" {
+			name = "matrix"
+		}
+		return core.ParseExtractionFields(name, req.Fields)
+	default:
+		return nil, fmt.Errorf("请提供schema或fields")
+	}
+}
+
+// extractionTargets 解析请求指定的文献，分类中未解析的条目被跳过
+func extractionTargets(cfg *config.Config, req *ExtractRequest) ([]core.ParsedResult, error) {
+	if req.Collection == "" {
+		if len(req.Documents) == 0 {
+			return nil, fmt.Errorf("请提供documents或collection")
+		}
+		results := make([]core.ParsedResult, 0, len(req.Documents))
+		for _, name := range req.Documents {
+			result, err := core.GetParsedResult(cfg.ResultsDir, name)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, *result)
+		}
+		return results, nil
+	}
+
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("连接Zotero数据库失败: %w", err)
+	}
+	defer zoteroDB.Close()
+	items, err := zoteroDB.CollectionItems(req.Collection, true)
+	if err != nil {
+		return nil, err
+	}
+	var results []core.ParsedResult
+	for i := range items {
+		if result, err := core.FindParsedResult(cfg.ResultsDir, &items[i]); err == nil && result != nil {
+			results = append(results, *result)
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("分类 %s 中没有已解析的文献", req.Collection)
+	}
+	return results, nil
+}
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+)
+
+// fullTextRefreshInterval 两次检查来源文件变化的最小间隔
+const fullTextRefreshInterval = time.Minute
+
+// 进程内共享的全文索引，避免每次请求重新加载
+var (
+	sharedFullTextMu        sync.Mutex
+	sharedFullTextIndex     *core.FullTextIndex
+	sharedFullTextRefreshed time.Time
+)
+
+// searchFullText 按需增量刷新共享全文索引后搜索
+func searchFullText(cfg *config.Config, query string, limit int) ([]core.FullTextHit, error) {
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		log.Printf("连接Zotero数据库失败，仅搜索解析结果: %v", err)
+		zoteroDB = nil
+	} else {
+		defer zoteroDB.Close()
+	}
+
+	sharedFullTextMu.Lock()
+	if sharedFullTextIndex == nil {
+		index, err := core.OpenFullTextIndex(cfg.IndexDir)
+		if err != nil {
+			sharedFullTextMu.Unlock()
+			return nil, err
+		}
+		sharedFullTextIndex = index
+	}
+	index := sharedFullTextIndex
+	if time.Since(sharedFullTextRefreshed) > fullTextRefreshInterval {
+		sources, err := core.CollectFullTextSources(zoteroDB, cfg.ResultsDir)
+		if err == nil {
+			_, err = index.Update(sources)
+		}
+		if err != nil {
+			log.Printf("刷新全文索引失败: %v", err)
+		} else {
+			sharedFullTextRefreshed = time.Now()
+		}
+	}
+	sharedFullTextMu.Unlock()
+
+	if zoteroDB != nil {
+		return zoteroDB.SearchFullText(index, query, limit)
+	}
+	return index.Search(query, limit)
+}
+
+// HandleFullTextSearch 全文搜索接口：GET /api/search/fulltext?q=查询&limit=20
+func HandleFullTextSearch(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请输入查询内容"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	hits, err := searchFullText(cfg, query, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"query": query,
+		"hits":  hits,
+	})
+}
+
+// handleFullTextQuery 问答入口中的全文搜索
+func handleFullTextQuery(query string, cfg *config.Config) (string, string) {
+	for _, prefix := range []string{"全文搜索", "全文检索", "全文", "fulltext"} {
+		query = strings.ReplaceAll(query, prefix, "")
+	}
+	query = strings.Trim(query, " :：,，")
+	if query == "" {
+		return "请输入要在全文中搜索的内容，例如：全文搜索 \"self attention\"", ""
+	}
+
+	hits, err := searchFullText(cfg, query, 10)
+	if err != nil {
+		return "全文搜索失败: " + err.Error(), ""
+	}
+	if len(hits) == 0 {
+		return fmt.Sprintf("全文中未找到与 \"%s\" 相关的内容", query), ""
+	}
+
+	var formatted strings.Builder
+	formatted.WriteString(fmt.Sprintf("全文中找到 %d 篇相关文献：\n\n", len(hits)))
+	for i, hit := range hits {
+		formatted.WriteString(fmt.Sprintf("%d. **%s**\n", i+1, hit.Title))
+		if hit.Snippet != "" {
+			formatted.WriteString(fmt.Sprintf("   %s\n", hit.Snippet))
+		}
+		formatted.WriteString("\n")
+	}
+	return formatted.String(), ""
+}
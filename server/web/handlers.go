@@ -2,6 +2,7 @@ package web
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -16,13 +17,26 @@ import (
 
 // AskRequest 请求结构
 type AskRequest struct {
-	Query string `json:"query"`
+	Query          string `json:"query"`
+	ConversationID string `json:"conversation_id,omitempty"` // 指定时在该对话中继续提问
+
+	// 以下字段用于/summarize等命令的模板变量
+	Document  string `json:"document,omitempty"`  // 解析结果目录名，指定时基于该文献回答
+	Selection string `json:"selection,omitempty"` // 用户选中的文本
+	Language  string `json:"language,omitempty"`  // 回答语言，默认使用PROMPT_LANGUAGE
 }
 
 // AskResponse 响应结构
 type AskResponse struct {
-	Answer string `json:"answer"`
-	PDFURL string `json:"pdfUrl,omitempty"`
+	Answer      string              `json:"answer"`
+	PDFURL      string              `json:"pdfUrl,omitempty"`
+	Citations   []core.Citation     `json:"citations,omitempty"`   // 与答案中[编号]对应的文献片段
+	Unsupported []core.CitationFlag `json:"unsupported,omitempty"` // 未找到依据的句子
+
+	ConversationID string          `json:"conversationId,omitempty"`
+	Usage          *core.UsageInfo `json:"usage,omitempty"` // 对话累计的token用量
+
+	Intent *core.IntentResult `json:"intent,omitempty"` // 智能路由的意图分类结果
 }
 
 // HandleAsk 处理AI问答请求
@@ -42,13 +56,25 @@ func HandleAsk(c *gin.Context) {
 		return
 	}
 
-	// 智能路由：根据问题内容自动选择处理方式
-	response, pdfURL := intelligentRouterWithAI(req.Query, cfg)
+	// 以/开头的命令按提示模板展开
+	if _, _, ok := core.ParseCommand(req.Query); ok {
+		handleAskCommand(c, cfg, req)
+		return
+	}
+
+	// 携带对话ID时在已保存的对话中继续，保留多轮上下文
+	if req.ConversationID != "" {
+		response, err := continueConversation(c.Request.Context(), req.ConversationID, req.Query)
+		if err != nil {
+			c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
 
-	c.JSON(http.StatusOK, AskResponse{
-		Answer: response,
-		PDFURL: pdfURL,
-	})
+	// 智能路由：根据问题内容自动选择处理方式
+	c.JSON(http.StatusOK, intelligentRouterWithAI(req.Query, cfg))
 }
 
 // loadConfig 加载配置
@@ -61,38 +87,45 @@ func loadConfig() *config.Config {
 	return cfg
 }
 
-// intelligentRouterWithAI 集成AI功能的智能路由器
-func intelligentRouterWithAI(query string, cfg *config.Config) (string, string) {
-	query = strings.ToLower(query)
-
-	// PDF查看类
-	if containsAny(query, []string{"查看", "预览", "打开", "view", "open", "preview"}) {
-		return handlePDFView(query)
-	}
-
-	// 相关文献分析类
-	if containsAny(query, []string{"相关", "related", "相似", "similar", "推荐"}) {
-		return handleRelatedLiterature(query, cfg)
-	}
-
-	// 文献搜索类
-	if containsAny(query, []string{"搜索", "找", "查找", "search", "find"}) {
-		return handleRealSearch(query, cfg)
+// intelligentRouterWithAI 按意图分类结果选择处理方式，配置了AI时由模型分类，否则使用规则
+func intelligentRouterWithAI(query string, cfg *config.Config) AskResponse {
+	var client core.AIClient
+	if cfg.AIAPIKey != "" {
+		client = mcp.NewAIClient(cfg)
 	}
-
-	// 文献分析类
-	if containsAny(query, []string{"分析", "总结", "概括", "analyze", "summary"}) {
-		return handleRealAnalysis(query, cfg)
+	intent := core.NewIntentClassifier(client).Classify(context.Background(), query)
+	log.Printf("🧭 意图: %s（%s, %.2f）%+v", intent.Intent, intent.Source, intent.Confidence, intent.Slots)
+
+	var response AskResponse
+	switch intent.Intent {
+	case core.IntentViewPDF:
+		response = textAnswer(handlePDFView(intent.Slots))
+	case core.IntentRelated:
+		response = handleRelatedLiterature(query, cfg)
+	case core.IntentFullText:
+		response = textAnswer(handleFullTextQuery(intent.Slots.Question, cfg))
+	case core.IntentSearch:
+		response = textAnswer(handleRealSearch(intent.Slots, cfg))
+	case core.IntentAnalyze:
+		response = handleRealAnalysis(query, cfg)
+	default:
+		response = handleRealAIChat(query, cfg)
 	}
+	response.Intent = &intent
+	return response
+}
 
-	// AI对话类
-	return handleRealAIChat(query, cfg)
+// textAnswer 包装不含引用的文本答案
+func textAnswer(answer, pdfURL string) AskResponse {
+	return AskResponse{Answer: answer, PDFURL: pdfURL}
 }
 
 // handlePDFView PDF查看处理
-func handlePDFView(query string) (string, string) {
-	// 从查询中提取文献名称或DOI
-	docName := extractDocumentName(query)
+func handlePDFView(slots core.IntentSlots) (string, string) {
+	docName := slots.Document
+	if docName == "" {
+		docName = slots.DOI
+	}
 	if docName == "" {
 		return "请指定要查看的文献名称或DOI，例如：查看Attention Is All You Need", ""
 	}
@@ -119,10 +152,10 @@ func findPDFPath(docName string) (string, error) {
 }
 
 // handleRelatedLiterature 相关文献分析处理
-func handleRelatedLiterature(query string, cfg *config.Config) (string, string) {
+func handleRelatedLiterature(query string, cfg *config.Config) AskResponse {
 	// 检查MCP配置
 	if !mcp.IsMCPConfigured() {
-		return "MCP功能未配置，无法进行相关文献分析。请检查MCP服务器配置。", ""
+		return textAnswer("MCP功能未配置，无法进行相关文献分析。请检查MCP服务器配置。", "")
 	}
 
 	// 直接使用AI处理文献搜索和分析，让AI自己理解查询意图
@@ -132,29 +165,8 @@ func handleRelatedLiterature(query string, cfg *config.Config) (string, string)
 	return handleRealAIChat(enhancedQuery, cfg)
 }
 
-// extractDocumentName 从查询中提取文献名称
-func extractDocumentName(query string) string {
-	// 简单的文献名称提取逻辑
-	query = strings.ToLower(query)
-
-	// 移除关键词
-	prefixes := []string{"查看", "预览", "打开", "view", "open", "preview", "pdf", "论文", "文献"}
-	for _, prefix := range prefixes {
-		query = strings.ReplaceAll(query, prefix, "")
-		query = strings.TrimSpace(query)
-	}
-
-	// 移除标点符号
-	query = strings.Trim(query, " ,.!?，。！？")
-
-	if len(query) > 0 {
-		return query
-	}
-	return ""
-}
-
-// handleRealSearch 真实文献搜索处理
-func handleRealSearch(query string, cfg *config.Config) (string, string) {
+// handleRealSearch 真实文献搜索处理：指定分类时列出分类中的文献，否则按DOI或标题关键词搜索
+func handleRealSearch(slots core.IntentSlots, cfg *config.Config) (string, string) {
 	// 连接Zotero数据库
 	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
 	if err != nil {
@@ -163,8 +175,35 @@ func handleRealSearch(query string, cfg *config.Config) (string, string) {
 	}
 	defer zoteroDB.Close()
 
-	// 搜索文献
-	items, err := zoteroDB.SearchByTitle(query, 10)
+	query := slots.Question
+	var items []core.ZoteroItem
+	switch {
+	case slots.Collection != "":
+		query = "分类 " + slots.Collection
+		items, err = zoteroDB.CollectionItems(slots.Collection, true)
+		if len(items) > 10 {
+			items = items[:10]
+		}
+	case slots.DOI != "":
+		query = slots.DOI
+		var all []core.ZoteroItem
+		all, err = zoteroDB.ListItems()
+		for _, item := range all {
+			if strings.EqualFold(item.DOI, slots.DOI) {
+				items = append(items, item)
+			}
+		}
+	default:
+		var results []core.SearchResult
+		results, err = zoteroDB.SearchByTitle(query, 10)
+		for _, result := range results {
+			item := result.ZoteroItem
+			if result.DOI != "" {
+				item.DOI = result.DOI
+			}
+			items = append(items, item)
+		}
+	}
 	if err != nil {
 		log.Printf("搜索文献失败: %v", err)
 		return "搜索失败: " + err.Error(), ""
@@ -196,30 +235,31 @@ func handleRealSearch(query string, cfg *config.Config) (string, string) {
 }
 
 // handleRealAnalysis 真实文献分析处理
-func handleRealAnalysis(query string, cfg *config.Config) (string, string) {
+func handleRealAnalysis(query string, cfg *config.Config) AskResponse {
 	// 首先尝试AI分析
 	if cfg.AIAPIKey != "" {
 		return handleRealAIChat(query, cfg)
 	}
 
 	// 如果没有AI配置，则使用简单的文本分析
-	return "AI分析功能未配置。请在 .env 文件中设置 AI_API_KEY 来启用智能分析功能。", ""
+	return textAnswer("AI分析功能未配置。请在 .env 文件中设置 AI_API_KEY 来启用智能分析功能。", "")
 }
 
 // handleRealAIChat 真实AI对话处理
-func handleRealAIChat(query string, cfg *config.Config) (string, string) {
+func handleRealAIChat(query string, cfg *config.Config) AskResponse {
 	if cfg.AIAPIKey == "" {
-		return "AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置", ""
+		return textAnswer("AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置", "")
 	}
 
 	// 创建AI客户端
-	aiClient := core.NewGLMClient(cfg.AIAPIKey, cfg.AIBaseURL, cfg.AIModel)
-	if aiClient == nil {
-		return "AI客户端创建失败，请检查配置", ""
+	aiClient := mcp.NewAIClient(cfg)
+
+	// 本地已解析文献能覆盖问题时，基于文献片段回答并附带引用
+	if response, ok := groundedAnswer(query, cfg, aiClient); ok {
+		return response
 	}
 
-	// 创建AI-MCP桥接器（与CLI模式相同）
-	aiMCPBridge := mcp.NewAIMCPBridge(aiClient, cfg)
+	aiMCPBridge := newAIMCPBridge(aiClient, cfg)
 	defer aiMCPBridge.Close()
 
 	// 让AI选择工具
@@ -234,7 +274,7 @@ func handleRealAIChat(query string, cfg *config.Config) (string, string) {
 			Messages: []core.ChatMessage{
 				{
 					Role:    "system",
-					Content: "你是一个专业的学术文献助手，能够帮助用户分析、搜索和回答关于学术文献的问题。请用中文回答，保持专业和准确。",
+					Content: getPromptLibrary(cfg).System(core.PromptAssistant, core.PromptData{}),
 				},
 				{
 					Role:    "user",
@@ -246,103 +286,114 @@ func handleRealAIChat(query string, cfg *config.Config) (string, string) {
 		}
 
 		// 发送AI请求（带超时）
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+		ctx, cancel := context.WithTimeout(core.WithUsageFeature(context.Background(), core.FeatureChat), 60*time.Second)
 		defer cancel()
 
 		response, err := aiClient.Chat(ctx, aiRequest)
 		if err != nil {
 			log.Printf("AI请求失败: %v", err)
-			return "AI请求失败: " + err.Error(), ""
+			return textAnswer("AI请求失败: "+err.Error(), "")
 		}
 
 		if response == nil || len(response.Choices) == 0 {
-			return "AI响应为空，请稍后重试", ""
+			return textAnswer("AI响应为空，请稍后重试", "")
 		}
 
-		return response.Choices[0].Message.Content, ""
+		return textAnswer(response.Choices[0].Message.Content, "")
 	}
 
 	// 如果AI选择了工具，执行工具调用并获取结果
 	if toolCall != nil {
-		log.Printf("🔧 执行工具调用: %s.%s", toolCall.Server, toolCall.Tool)
+		return textAnswer(answerWithToolCall(query, cfg, aiClient, aiMCPBridge, toolCall, aiResponse), "")
+	}
 
-		// 调用工具
-		toolResponse, err := aiMCPBridge.CallTool(toolCall)
-		if err != nil {
-			log.Printf("工具调用失败: %v", err)
-			return "工具调用失败: " + err.Error(), ""
-		}
+	// 如果AI没有选择工具，但有直接回复
+	if aiResponse != nil && *aiResponse != "" {
+		return textAnswer(*aiResponse, "")
+	}
 
-		// 解析工具结果
-		toolResult := aiMCPBridge.ParseToolResult(toolResponse)
-		log.Printf("📄 工具调用完成，结果长度: %d", len(toolResult))
-
-		// 使用AI分析和总结工具结果
-		if len(toolResult) > 0 {
-			log.Printf("🧠 开始用AI分析工具结果...")
-
-			// 构建AI分析请求
-			analysisPrompt := fmt.Sprintf(`请分析以下搜索结果，并生成一份简洁、用户友好的摘要报告。搜索关键词："%s"
-
-搜索结果（原始数据）：
-%s
-
-请提供：
-1. 对搜索结果的分析和总结
-2. 最重要的发现或亮点
-3. 相关性和质量评估
-4. 用中文回答，保持专业和准确`, query, toolResult)
-
-			// 使用AI分析工具结果
-			analysisRequest := &core.AIRequest{
-				Model: cfg.AIModel,
-				Messages: []core.ChatMessage{
-					{
-						Role:    "system",
-						Content: "你是一个专业的学术文献分析师，能够分析搜索结果并生成简洁、有用的摘要。请用中文回答。",
-					},
-					{
-						Role:    "user",
-						Content: analysisPrompt,
-					},
-				},
-				MaxTokens:   2000,
-				Temperature: 0.3,
-			}
+	// 如果没有工具响应，返回默认消息
+	return textAnswer("AI已处理您的请求，但没有生成具体响应。", "")
+}
 
-			// 发送AI分析请求（带超时）
-			ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
-			defer cancel()
-
-			analysisResponse, err := aiClient.Chat(ctx, analysisRequest)
-			if err != nil {
-				log.Printf("AI分析失败: %v", err)
-				// 降级到GenerateFinalAnswer
-				log.Printf("降级到GenerateFinalAnswer方法...")
-				finalAnswer := aiMCPBridge.GenerateFinalAnswer(&query, &toolResult, aiResponse)
-				return finalAnswer, ""
-			}
+// newAIMCPBridge 创建AI-MCP桥接器，复用进程内共享的MCP连接
+func newAIMCPBridge(aiClient core.AIClient, cfg *config.Config) *mcp.AIMCPBridge {
+	manager, err := getMCPManager()
+	if err != nil {
+		log.Printf("共享MCP管理器不可用，使用独立连接: %v", err)
+		return mcp.NewAIMCPBridge(aiClient, cfg)
+	}
+	return mcp.NewAIMCPBridgeWithManager(aiClient, cfg, manager)
+}
 
-			if analysisResponse != nil && len(analysisResponse.Choices) > 0 {
-				log.Printf("✅ AI分析成功，生成用户友好的答案")
-				return analysisResponse.Choices[0].Message.Content, ""
-			}
+// answerWithToolCall 执行工具调用并用AI分析结果生成答案
+func answerWithToolCall(query string, cfg *config.Config, aiClient core.AIClient, aiMCPBridge *mcp.AIMCPBridge, toolCall *mcp.ToolCall, aiResponse *string) string {
+	log.Printf("🔧 执行工具调用: %s.%s", toolCall.Server, toolCall.Tool)
+
+	// 调用工具
+	toolResponse, err := aiMCPBridge.CallTool(toolCall)
+	var confirmErr *mcp.ConfirmationRequiredError
+	if errors.As(err, &confirmErr) {
+		return confirmationPrompt(query, confirmErr.Call, aiResponse)
+	}
+	if err != nil {
+		log.Printf("工具调用失败: %v", err)
+		return "工具调用失败: " + err.Error()
+	}
+
+	// 解析工具结果
+	toolResult := aiMCPBridge.ParseToolResult(toolResponse)
+	log.Printf("📄 工具调用完成，结果长度: %d", len(toolResult))
+
+	// 使用AI分析和总结工具结果
+	if len(toolResult) > 0 {
+		log.Printf("🧠 开始用AI分析工具结果...")
+
+		// 构建AI分析请求
+		prompts := getPromptLibrary(cfg)
+		analysisPrompt := prompts.System(core.PromptToolAnalysisRequest, core.PromptData{Question: query, Context: toolResult})
+
+		// 使用AI分析工具结果
+		analysisRequest := &core.AIRequest{
+			Model: cfg.AIModel,
+			Messages: []core.ChatMessage{
+				{
+					Role:    "system",
+					Content: prompts.System(core.PromptToolAnalysis, core.PromptData{}),
+				},
+				{
+					Role:    "user",
+					Content: analysisPrompt,
+				},
+			},
+			MaxTokens:   2000,
+			Temperature: 0.3,
+		}
+
+		// 发送AI分析请求（带超时）
+		ctx, cancel := context.WithTimeout(core.WithUsageFeature(context.Background(), core.FeatureToolAnalysis), 90*time.Second)
+		defer cancel()
 
-			log.Printf("⚠️ AI分析响应为空，使用降级方案")
+		analysisResponse, err := aiClient.Chat(ctx, analysisRequest)
+		if err != nil {
+			log.Printf("AI分析失败: %v", err)
+			// 降级到GenerateFinalAnswer
+			log.Printf("降级到GenerateFinalAnswer方法...")
+			finalAnswer := aiMCPBridge.GenerateFinalAnswer(&query, &toolResult, aiResponse)
+			return finalAnswer
 		}
 
-		// 降级到原始方法
-		finalAnswer := aiMCPBridge.GenerateFinalAnswer(&query, &toolResult, aiResponse)
-		return finalAnswer, ""
-	}
+		if analysisResponse != nil && len(analysisResponse.Choices) > 0 {
+			log.Printf("✅ AI分析成功，生成用户友好的答案")
+			return analysisResponse.Choices[0].Message.Content
+		}
 
-	// 如果AI没有选择工具，但有直接回复
-	if aiResponse != nil && *aiResponse != "" {
-		return *aiResponse, ""
+		log.Printf("⚠️ AI分析响应为空，使用降级方案")
 	}
 
-	// 如果没有工具响应，返回默认消息
-	return "AI已处理您的请求，但没有生成具体响应。", ""
+	// 降级到原始方法
+	finalAnswer := aiMCPBridge.GenerateFinalAnswer(&query, &toolResult, aiResponse)
+	return finalAnswer
 }
 
 // formatSearchResults 搜索结果格式化
@@ -361,16 +412,6 @@ func formatSearchResults(results []string) string {
 	return formatted.String()
 }
 
-// containsAny 简单的关键词匹配
-func containsAny(text string, keywords []string) bool {
-	for _, keyword := range keywords {
-		if strings.Contains(text, keyword) {
-			return true
-		}
-	}
-	return false
-}
-
 // HandleStatus 系统状态检查
 func HandleStatus(c *gin.Context) {
 	status := gin.H{
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/mcp"
+)
+
+// confirmationTTL 待确认工具调用的有效期
+const confirmationTTL = 10 * time.Minute
+
+// pendingToolCall 等待用户确认的工具调用
+type pendingToolCall struct {
+	Query      string
+	Call       *mcp.ToolCall
+	AIResponse *string
+	Created    time.Time
+}
+
+var (
+	pendingCallsMu sync.Mutex
+	pendingCalls   = make(map[string]*pendingToolCall)
+)
+
+// ConfirmRequest 工具调用确认请求
+type ConfirmRequest struct {
+	ID      string `json:"id"`
+	Approve bool   `json:"approve"`
+}
+
+// storePendingCall 保存待确认的调用并返回确认ID，同时清理过期记录
+func storePendingCall(query string, call *mcp.ToolCall, aiResponse *string) string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+
+	pendingCallsMu.Lock()
+	defer pendingCallsMu.Unlock()
+	for key, pending := range pendingCalls {
+		if time.Since(pending.Created) > confirmationTTL {
+			delete(pendingCalls, key)
+		}
+	}
+	pendingCalls[id] = &pendingToolCall{Query: query, Call: call, AIResponse: aiResponse, Created: time.Now()}
+	return id
+}
+
+// takePendingCall 取出待确认的调用（每个确认ID只能使用一次）
+func takePendingCall(id string) (*pendingToolCall, bool) {
+	pendingCallsMu.Lock()
+	defer pendingCallsMu.Unlock()
+	pending, ok := pendingCalls[id]
+	if !ok {
+		return nil, false
+	}
+	delete(pendingCalls, id)
+	if time.Since(pending.Created) > confirmationTTL {
+		return nil, false
+	}
+	return pending, true
+}
+
+// confirmationPrompt 生成提示用户确认工具调用的回复
+func confirmationPrompt(query string, call *mcp.ToolCall, aiResponse *string) string {
+	id := storePendingCall(query, call, aiResponse)
+	args, _ := json.MarshalIndent(call.Arguments, "", "  ")
+	log.Printf("🛑 工具调用等待确认: %s.%s (ID: %s)", call.Server, call.Tool, id)
+
+	return fmt.Sprintf(`⚠️ 调用工具 %s.%s 需要您的确认。
+
+参数：
+%s
+
+确认ID：%s（%d分钟内有效）
+请通过 POST /api/mcp/confirm 提交 {"id": "%s", "approve": true} 执行，或提交 approve=false 取消。`,
+		call.Server, call.Tool, string(args), id, int(confirmationTTL.Minutes()), id)
+}
+
+// HandleMCPConfirm 确认或拒绝等待中的工具调用
+func HandleMCPConfirm(c *gin.Context) {
+	var req ConfirmRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少确认ID"})
+		return
+	}
+
+	pending, ok := takePendingCall(req.ID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "确认ID不存在或已过期"})
+		return
+	}
+
+	if !req.Approve {
+		log.Printf("🚫 用户拒绝工具调用: %s.%s", pending.Call.Server, pending.Call.Tool)
+		c.JSON(http.StatusOK, AskResponse{Answer: fmt.Sprintf("已取消调用工具 %s.%s", pending.Call.Server, pending.Call.Tool)})
+		return
+	}
+
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	aiClient := mcp.NewAIClient(cfg)
+	aiMCPBridge := newAIMCPBridge(aiClient, cfg)
+	defer aiMCPBridge.Close()
+
+	pending.Call.Confirmed = true
+	answer := answerWithToolCall(pending.Query, cfg, aiClient, aiMCPBridge, pending.Call, pending.AIResponse)
+	c.JSON(http.StatusOK, AskResponse{Answer: answer})
+}
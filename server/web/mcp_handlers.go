@@ -0,0 +1,102 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/mcp"
+)
+
+// 进程内共享的MCP管理器，使服务器连接和监督状态跨请求保留
+var (
+	sharedMCPManager     *mcp.MCPManager
+	sharedMCPManagerErr  error
+	sharedMCPManagerOnce sync.Once
+)
+
+// getMCPManager 获取共享的MCP管理器
+func getMCPManager() (*mcp.MCPManager, error) {
+	sharedMCPManagerOnce.Do(func() {
+		sharedMCPManager, sharedMCPManagerErr = mcp.NewMCPManager(mcp.DefaultConfigFile)
+	})
+	return sharedMCPManager, sharedMCPManagerErr
+}
+
+// HandleMCPStatus 返回各MCP服务器的运行状态
+func HandleMCPStatus(c *gin.Context) {
+	manager, err := getMCPManager()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "MCP管理器不可用: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"servers": manager.Status(),
+		"config":  mcp.GetMCPStatus(),
+	})
+}
+
+// HandleMCPStderr 返回指定MCP服务器最近的stderr输出
+func HandleMCPStderr(c *gin.Context) {
+	manager, err := getMCPManager()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "MCP管理器不可用: " + err.Error()})
+		return
+	}
+
+	lines, _ := strconv.Atoi(c.DefaultQuery("lines", "100"))
+	tail, err := manager.StderrTail(c.Param("name"), lines)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"server": c.Param("name"),
+		"lines":  tail,
+	})
+}
+
+// HandleMCPCacheStats 返回工具结果缓存的占用和命中统计
+func HandleMCPCacheStats(c *gin.Context) {
+	manager, err := getMCPManager()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "MCP管理器不可用: " + err.Error()})
+		return
+	}
+	cache := manager.Cache()
+	if cache == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工具结果缓存未启用"})
+		return
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// HandleMCPCacheClear 清除工具结果缓存，可用server和tool参数限定范围
+func HandleMCPCacheClear(c *gin.Context) {
+	manager, err := getMCPManager()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "MCP管理器不可用: " + err.Error()})
+		return
+	}
+	cache := manager.Cache()
+	if cache == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "工具结果缓存未启用"})
+		return
+	}
+
+	removed, err := cache.Clear(c.Query("server"), c.Query("tool"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
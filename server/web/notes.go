@@ -0,0 +1,44 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/core"
+)
+
+// HandleItemNotes 返回条目在Zotero中的子笔记（已转换为Markdown）：GET /api/items/:key/notes?format=json|md
+//
+// :key 可以是条目Key或解析结果目录名
+func HandleItemNotes(c *gin.Context) {
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "连接Zotero数据库失败: " + err.Error()})
+		return
+	}
+	defer zoteroDB.Close()
+
+	item, err := zoteroDB.ResolveItem(c.Param("key"), cfg.ResultsDir)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	notes, err := zoteroDB.GetItemNotes(item.ItemID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Query("format") == "md" {
+		c.Header("Content-Disposition", "attachment; filename=\"notes-"+item.ItemKey+".md\"")
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(core.RenderNotesMarkdown(item, notes)))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"item": item, "notes": notes})
+}
@@ -0,0 +1,80 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+)
+
+// 进程内共享的提示模板库，修改模板后重启服务生效
+var (
+	sharedPrompts     *core.PromptLibrary
+	sharedPromptsOnce sync.Once
+)
+
+// getPromptLibrary 获取共享的提示模板库
+func getPromptLibrary(cfg *config.Config) *core.PromptLibrary {
+	sharedPromptsOnce.Do(func() {
+		sharedPrompts = core.LoadPromptLibrary(cfg.PromptsDir, cfg.PromptLanguage)
+	})
+	return sharedPrompts
+}
+
+// HandleListCommands 列出可在/api/ask中使用的命令：GET /api/commands
+func HandleListCommands(c *gin.Context) {
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"commands": getPromptLibrary(cfg).Commands()})
+}
+
+// handleAskCommand 展开/summarize等命令后回答：指定文献时基于该文献开始对话，
+// 携带对话ID时在对话中继续，否则按普通问题处理
+func handleAskCommand(c *gin.Context, cfg *config.Config, req AskRequest) {
+	data := core.PromptData{Selection: req.Selection, Language: req.Language}
+	if req.Document != "" {
+		result, err := core.GetParsedResult(cfg.ResultsDir, req.Document)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		data = core.DocumentPromptData(result)
+		data.Selection, data.Language = req.Selection, req.Language
+	}
+	prompt, _, err := getPromptLibrary(cfg).Expand(req.Query, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	switch {
+	case req.ConversationID != "":
+		response, err := continueConversation(c.Request.Context(), req.ConversationID, prompt)
+		if err != nil {
+			c.JSON(conversationErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	case req.Document != "":
+		manager, _, ok := conversationManagerOrAbort(c)
+		if !ok {
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), conversationTimeout)
+		defer cancel()
+		conv, err := manager.StartConversationWithDocument(ctx, prompt, &core.DocumentContext{DocumentNames: []string{req.Document}})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, conversationReply(cfg, conv))
+	default:
+		c.JSON(http.StatusOK, handleRealAIChat(prompt, cfg))
+	}
+}
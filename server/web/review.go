@@ -0,0 +1,175 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/config"
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+const (
+	// reviewTimeout 单个综述任务的超时时间
+	reviewTimeout = 30 * time.Minute
+	// reviewJobTTL 已结束的综述任务保留时间
+	reviewJobTTL = time.Hour
+)
+
+// 综述任务状态
+const (
+	reviewJobRunning = "running"
+	reviewJobDone    = "done"
+	reviewJobFailed  = "failed"
+)
+
+// ReviewRequest 综述请求：collection、tag和query三选一
+type ReviewRequest struct {
+	core.ReviewScope
+	core.ReviewOptions
+	MaxPapers int `json:"max_papers,omitempty"`
+}
+
+// reviewJob 后台执行的综述任务，前端轮询进度
+type reviewJob struct {
+	ID        string                 `json:"id"`
+	Status    string                 `json:"status"`
+	Scope     string                 `json:"scope"`
+	Progress  core.ReviewProgress    `json:"progress"`
+	Skipped   []string               `json:"skipped,omitempty"` // 范围内未解析的条目
+	Review    *core.LiteratureReview `json:"review,omitempty"`
+	Markdown  string                 `json:"markdown,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+var (
+	reviewJobsMu sync.Mutex
+	reviewJobs   = make(map[string]*reviewJob)
+)
+
+// HandleCreateReview 创建综述任务，返回任务ID；通过 GET /api/reviews/:id 查询进度和结果
+func HandleCreateReview(c *gin.Context) {
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求格式错误"})
+		return
+	}
+	if err := req.ReviewScope.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+	if cfg.AIAPIKey == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "AI功能未配置，请设置 AI_API_KEY 环境变量或在 .env 文件中配置"})
+		return
+	}
+
+	job := newReviewJob(req.ReviewScope.String())
+	go runReviewJob(cfg, job.ID, req)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// HandleGetReview 查询综述任务的进度和结果
+func HandleGetReview(c *gin.Context) {
+	reviewJobsMu.Lock()
+	defer reviewJobsMu.Unlock()
+	job, ok := reviewJobs[c.Param("id")]
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "综述任务不存在或已过期"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// newReviewJob 登记新任务，同时清理过期的已结束任务
+func newReviewJob(scope string) reviewJob {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	now := time.Now()
+	job := &reviewJob{ID: hex.EncodeToString(buf), Status: reviewJobRunning, Scope: scope, CreatedAt: now, UpdatedAt: now}
+
+	reviewJobsMu.Lock()
+	defer reviewJobsMu.Unlock()
+	for id, existing := range reviewJobs {
+		if existing.Status != reviewJobRunning && time.Since(existing.UpdatedAt) > reviewJobTTL {
+			delete(reviewJobs, id)
+		}
+	}
+	reviewJobs[job.ID] = job
+	return *job
+}
+
+// updateReviewJob 在锁内修改任务
+func updateReviewJob(id string, update func(job *reviewJob)) {
+	reviewJobsMu.Lock()
+	defer reviewJobsMu.Unlock()
+	if job, ok := reviewJobs[id]; ok {
+		update(job)
+		job.UpdatedAt = time.Now()
+	}
+}
+
+// runReviewJob 在后台生成综述并记录进度
+func runReviewJob(cfg *config.Config, id string, req ReviewRequest) {
+	fail := func(err error) {
+		log.Printf("❌ 综述任务 %s 失败: %v", id, err)
+		updateReviewJob(id, func(job *reviewJob) {
+			job.Status = reviewJobFailed
+			job.Error = err.Error()
+		})
+	}
+
+	zoteroDB, err := core.NewZoteroDB(cfg.ZoteroDBPath, cfg.ZoteroDataDir)
+	if err != nil {
+		log.Printf("⚠️ 连接Zotero数据库失败，参考文献仅包含标题: %v", err)
+		zoteroDB = nil
+	} else {
+		defer zoteroDB.Close()
+	}
+
+	results, skipped, err := core.ResolveReviewScope(req.ReviewScope, zoteroDB, cfg.ResultsDir, cfg.IndexDir, req.MaxPapers)
+	if err != nil {
+		fail(err)
+		return
+	}
+	updateReviewJob(id, func(job *reviewJob) { job.Skipped = skipped })
+
+	client := mcp.NewAIClient(cfg)
+	embedder, err := core.NewEmbeddingProvider(cfg.EmbeddingProvider, cfg.EmbeddingBaseURL, cfg.EmbeddingAPIKey, cfg.EmbeddingModel)
+	if err != nil {
+		log.Printf("⚠️ 嵌入服务不可用，使用本地嵌入聚类: %v", err)
+		embedder = nil
+	}
+	generator := core.NewReviewGenerator(client, core.NewSummarizer(client, cfg.SummaryCacheDir), embedder)
+
+	ctx, cancel := context.WithTimeout(context.Background(), reviewTimeout)
+	defer cancel()
+	review, err := generator.Generate(ctx, req.ReviewScope.String(), core.NewReviewPapers(results, zoteroDB), req.ReviewOptions,
+		func(progress core.ReviewProgress) {
+			updateReviewJob(id, func(job *reviewJob) { job.Progress = progress })
+		})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	markdown := review.Markdown()
+	updateReviewJob(id, func(job *reviewJob) {
+		job.Status = reviewJobDone
+		job.Review = review
+		job.Markdown = markdown
+	})
+	log.Printf("✅ 综述任务 %s 完成: %s", id, review.Title)
+}
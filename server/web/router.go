@@ -36,8 +36,32 @@ func SetupRouter() *gin.Engine {
 	api := r.Group("/api")
 	{
 		api.POST("/ask", HandleAsk)
+		api.GET("/commands", HandleListCommands)
 		api.GET("/status", HandleStatus)
 		api.GET("/config", HandleStaticConfig)
+		api.GET("/search/fulltext", HandleFullTextSearch)
+		api.GET("/results/:name/pdf", HandleResultPDF)
+		api.GET("/items/:key/annotations", HandleItemAnnotations)
+		api.GET("/items/:key/notes", HandleItemNotes)
+		api.POST("/extract", HandleExtract)
+		api.POST("/writeback", HandleWriteback)
+		api.POST("/compare", HandleCompare)
+		api.POST("/reviews", HandleCreateReview)
+		api.GET("/reviews/:id", HandleGetReview)
+		api.GET("/conversations", HandleListConversations)
+		api.POST("/conversations", HandleCreateConversation)
+		api.GET("/conversations/:id", HandleGetConversation)
+		api.PATCH("/conversations/:id", HandleRenameConversation)
+		api.DELETE("/conversations/:id", HandleDeleteConversation)
+		api.POST("/conversations/:id/messages", HandleContinueConversation)
+		api.POST("/conversations/:id/fork", HandleForkConversation)
+		api.GET("/conversations/:id/export", HandleExportConversation)
+		api.GET("/mcp/status", HandleMCPStatus)
+		api.GET("/mcp/servers/:name/stderr", HandleMCPStderr)
+		api.POST("/mcp/confirm", HandleMCPConfirm)
+		api.GET("/mcp/cache", HandleMCPCacheStats)
+		api.GET("/usage", HandleUsage)
+		api.DELETE("/mcp/cache", HandleMCPCacheClear)
 	}
 
 	// 健康检查
@@ -55,7 +79,7 @@ func SetupRouter() *gin.Engine {
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
 
 		if c.Request.Method == "OPTIONS" {
@@ -9,6 +9,8 @@ let pdfDoc = null;
 
 // 应用状态
 let isLoading = false;
+let currentCitations = [];
+let pendingPage = 1;
 
 // 主要功能函数
 async function askQuestion() {
@@ -49,7 +51,9 @@ async function askQuestion() {
         const data = await response.json();
 
         // 显示结果
-        resultContent.innerHTML = formatAnswer(data.answer);
+        currentCitations = data.citations || [];
+        resultContent.innerHTML = linkCitations(formatAnswer(data.answer)) +
+            renderCitations(currentCitations, data.unsupported || []);
         resultSection.style.display = 'block';
 
         // 检查是否有PDF文件可以查看
@@ -94,6 +98,61 @@ function formatAnswer(answer) {
         .replace(/$/, '</p>');
 }
 
+// 将答案中的[编号]转换为可点击的引用
+function linkCitations(html) {
+    if (currentCitations.length === 0) return html;
+    return html.replace(/\[(\d+)\]/g, (match, n) => {
+        const citation = currentCitations.find(c => c.number === Number(n));
+        if (!citation) return match;
+        return `<a href="#" class="citation-ref" title="${escapeHTML(citation.title)}" onclick="jumpToCitation(${n}); return false;">[${n}]</a>`;
+    });
+}
+
+// 渲染引用来源列表和未找到依据的句子
+function renderCitations(citations, unsupported) {
+    let html = '';
+    if (citations.length > 0) {
+        html += '<div class="citations"><h4>引用来源</h4><ol>';
+        for (const c of citations) {
+            let location = c.section ? ` §${escapeHTML(c.section)}` : '';
+            if (c.page) location += c.page_end && c.page_end !== c.page ? ` pp.${c.page}-${c.page_end}` : ` p.${c.page}`;
+            const jump = c.url ? ` <a href="#" onclick="jumpToCitation(${c.number}); return false;">跳转到PDF</a>` : '';
+            html += `<li value="${c.number}"><strong>${escapeHTML(c.title)}</strong>${location}${jump}` +
+                `<div class="citation-quote">${escapeHTML(c.quote)}</div></li>`;
+        }
+        html += '</ol></div>';
+    }
+    if (unsupported.length > 0) {
+        html += '<div class="unsupported"><h4>⚠️ 以下内容未在引用文献中找到依据</h4><ul>';
+        for (const flag of unsupported) {
+            html += `<li>${escapeHTML(flag.sentence)} <span class="flag-reason">（${escapeHTML(flag.reason)}）</span></li>`;
+        }
+        html += '</ul></div>';
+    }
+    return html;
+}
+
+// 打开引用对应的PDF页面
+function jumpToCitation(number) {
+    const citation = currentCitations.find(c => c.number === number);
+    if (!citation || !citation.url) {
+        showMessage('该引用没有可查看的PDF', 'error');
+        return;
+    }
+    const match = citation.url.match(/#page=(\d+)/);
+    pendingPage = match ? Number(match[1]) : 1;
+    currentPDF = citation.url;
+    document.getElementById('pdfViewBtn').style.display = 'inline-block';
+    openPDFViewer();
+    document.getElementById('pdfViewerSection').scrollIntoView({ behavior: 'smooth' });
+}
+
+function escapeHTML(text) {
+    const div = document.createElement('div');
+    div.textContent = text || '';
+    return div.innerHTML;
+}
+
 // 复制结果功能
 function copyResult() {
     const resultContent = document.getElementById('resultContent');
@@ -233,7 +292,8 @@ function loadPDFWithJS(pdfUrl) {
     pdfjsLib.getDocument(pdfUrl).promise.then(function(pdf) {
         pdfDoc = pdf;
         totalPages = pdf.numPages;
-        currentPage = 1;
+        currentPage = Math.min(Math.max(pendingPage, 1), totalPages);
+        pendingPage = 1;
         currentZoom = 1.0;
 
         updatePageInfo();
@@ -0,0 +1,35 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/core"
+	"zoteroflow2-server/mcp"
+)
+
+// HandleUsage AI用量报告和预算状态：GET /api/usage?days=30&by=feature
+func HandleUsage(c *gin.Context) {
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days 必须是正整数"})
+		return
+	}
+	ledger := core.SharedUsageLedger(cfg.UsageDir)
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	report, err := ledger.Report(today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1), c.DefaultQuery("by", "feature"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"report": report, "status": ledger.Status(mcp.UsageBudget(cfg))})
+}
@@ -0,0 +1,63 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"zoteroflow2-server/core"
+)
+
+// WritebackRequest 写回请求：documents和collection二选一，tags为空时使用配置的标签
+type WritebackRequest struct {
+	Documents  []string `json:"documents,omitempty"`
+	Collection string   `json:"collection,omitempty"`
+	DryRun     bool     `json:"dry_run,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Schemas    []string `json:"schemas,omitempty"`
+}
+
+// HandleWriteback 将摘要、关键要点和抽取结果作为子笔记写回Zotero：POST /api/writeback
+func HandleWriteback(c *gin.Context) {
+	var req WritebackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求格式错误"})
+		return
+	}
+	cfg := loadConfig()
+	if cfg == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "配置加载失败"})
+		return
+	}
+	if !req.DryRun && cfg.ZoteroLibrary == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "写回未配置，请设置 ZOTERO_LIBRARY 和 ZOTERO_API_KEY"})
+		return
+	}
+
+	results, err := extractionTargets(cfg, &ExtractRequest{Documents: req.Documents, Collection: req.Collection})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Tags == nil {
+		req.Tags = core.SplitCommaList(cfg.ZoteroWritebackTags)
+	}
+
+	var api *core.ZoteroWebAPI
+	if !req.DryRun {
+		api = core.NewZoteroWebAPI(cfg.ZoteroAPIURL, cfg.ZoteroLibrary, cfg.ZoteroAPIKey)
+	}
+	writer := core.NewZoteroWriter(api)
+	opts := core.WritebackOptions{DryRun: req.DryRun, Tags: req.Tags, Schemas: req.Schemas}
+
+	reports := []*core.WritebackResult{}
+	var errors []gin.H
+	for i := range results {
+		report, err := writer.WriteBack(c.Request.Context(), &results[i], opts)
+		if err != nil {
+			errors = append(errors, gin.H{"document": results[i].Name, "error": err.Error()})
+			continue
+		}
+		reports = append(reports, report)
+	}
+	c.JSON(http.StatusOK, gin.H{"results": reports, "errors": errors})
+}